@@ -3,8 +3,8 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"nsfw-go/internal/moviecode"
 	"nsfw-go/internal/service"
-	"regexp"
 	"strings"
 	"time"
 
@@ -102,7 +102,7 @@ func (h *JAVDbSearchHandler) SearchJAVDb(c *gin.Context) {
 	// 设置默认搜索类型
 	if req.SearchType == "" {
 		// 根据查询词判断搜索类型
-		if h.isLikelyMovieCode(query) {
+		if moviecode.IsCode(query) {
 			req.SearchType = "movie"
 		} else {
 			req.SearchType = "actress"
@@ -212,24 +212,3 @@ func (h *JAVDbSearchHandler) searchActress(c *gin.Context, ctx context.Context,
 		Data:    response,
 	})
 }
-
-// isLikelyMovieCode 判断是否像影片番号
-func (h *JAVDbSearchHandler) isLikelyMovieCode(query string) bool {
-	query = strings.ToUpper(strings.TrimSpace(query))
-
-	// 常见番号模式
-	patterns := []string{
-		`^[A-Z]{2,10}-\d{3,5}$`, // ABC-123, ABCD-1234
-		`^[A-Z]{2,10}\d{3,5}$`,  // ABC123, ABCD1234
-		`^\d{6}_\d{3}$`,         // 123456_789
-		`^[A-Z]+\s*\d+$`,        // ABC 123, ABC123
-	}
-
-	for _, pattern := range patterns {
-		if matched, _ := regexp.MatchString(pattern, query); matched {
-			return true
-		}
-	}
-
-	return false
-}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"nsfw-go/internal/model"
 	"nsfw-go/internal/repo"
 	"nsfw-go/internal/service"
 	"os"
@@ -16,45 +18,202 @@ import (
 
 // LocalMovie 本地影片结构（用于API响应）
 type LocalMovie struct {
-	Title      string `json:"title"`
-	Code       string `json:"code"`
-	Actress    string `json:"actress"`
-	Path       string `json:"path"`
-	Size       int64  `json:"size"`
-	Modified   string `json:"modified"`
-	Format     string `json:"format"`
-	FanartPath string `json:"fanart_path"`
-	FanartURL  string `json:"fanart_url"`
-	HasFanart  bool   `json:"has_fanart"`
+	Title      string           `json:"title"`
+	Code       string           `json:"code"`
+	Actress    string           `json:"actress"`
+	Path       string           `json:"path"`
+	Root       string           `json:"root"`
+	Version    string           `json:"version"`
+	Size       int64            `json:"size"` // 所有分段的总大小
+	Modified   string           `json:"modified"`
+	Format     string           `json:"format"`
+	FanartPath string           `json:"fanart_path"`
+	FanartURL  string           `json:"fanart_url"`
+	HasFanart  bool             `json:"has_fanart"`
+	PartCount  int              `json:"part_count"`
+	Parts      []LocalMoviePart `json:"parts,omitempty"`
+	Versions   []LocalMovie     `json:"versions,omitempty"` // 同一番号的其他版本（仅列表接口）
+
+	HasSubtitle        bool                 `json:"has_subtitle"`
+	HasChineseSubtitle bool                 `json:"has_chinese_subtitle"`
+	HardSubtitle       bool                 `json:"hard_subtitle"` // 文件名带中文字幕标记
+	Uncensored         bool                 `json:"uncensored"`    // 文件名带无码标记
+	SubtitleLanguages  []string             `json:"subtitle_languages"`
+	Subtitles          []LocalMovieSubtitle `json:"subtitles,omitempty"`
+
+	Duration   int    `json:"duration"` // 时长（秒）
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	VideoCodec string `json:"video_codec"`
+	AudioCodec string `json:"audio_codec"`
+	Bitrate    int64  `json:"bitrate"` // 平均码率（bit/s）
+	Quality    string `json:"quality"`
+
+	ContentHash string `json:"content_hash"`
+
+	NFOPath string `json:"nfo_path"`
+	MovieID *uint  `json:"movie_id"` // 由NFO导入的影片元数据ID
+}
+
+// LocalMoviePart 多分段影片的分段文件（用于API响应）
+type LocalMoviePart struct {
+	Part int    `json:"part"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// LocalMovieSubtitle 外挂字幕文件（用于API响应）
+type LocalMovieSubtitle struct {
+	Path     string `json:"path"`
+	Format   string `json:"format"`
+	Language string `json:"language"`
+}
+
+// splitLanguages 拆分逗号分隔的字幕语言
+func splitLanguages(languages string) []string {
+	if languages == "" {
+		return []string{}
+	}
+	return strings.Split(languages, ",")
+}
+
+// newLocalMovie 将数据库模型转换为API响应格式
+func newLocalMovie(movie *model.LocalMovie) LocalMovie {
+	item := LocalMovie{
+		Title:      movie.Title,
+		Code:       movie.Code,
+		Actress:    movie.Actress,
+		Path:       movie.Path,
+		Root:       movie.Root,
+		Version:    movie.Version,
+		Size:       movie.Size,
+		Modified:   movie.Modified.Format("2006-01-02 15:04:05"),
+		Format:     movie.Format,
+		FanartPath: movie.FanartPath,
+		FanartURL:  movie.FanartURL,
+		HasFanart:  movie.HasFanart,
+		PartCount:  movie.PartCount,
+
+		HasSubtitle:        movie.HasSubtitle,
+		HasChineseSubtitle: movie.HasChineseSubtitle,
+		HardSubtitle:       movie.HardSubtitle,
+		Uncensored:         movie.Uncensored,
+		SubtitleLanguages:  splitLanguages(movie.SubtitleLanguages),
+
+		Duration:   movie.Duration,
+		Width:      movie.Width,
+		Height:     movie.Height,
+		VideoCodec: movie.VideoCodec,
+		AudioCodec: movie.AudioCodec,
+		Bitrate:    movie.Bitrate,
+		Quality:    movie.Quality,
+
+		ContentHash: movie.ContentHash,
+
+		NFOPath: movie.NFOPath,
+		MovieID: movie.MovieID,
+	}
+	for _, part := range movie.Parts {
+		item.Parts = append(item.Parts, LocalMoviePart{Part: part.Part, Path: part.Path, Size: part.Size})
+	}
+	for _, subtitle := range movie.Subtitles {
+		item.Subtitles = append(item.Subtitles, LocalMovieSubtitle{Path: subtitle.Path, Format: subtitle.Format, Language: subtitle.Language})
+	}
+	return item
+}
+
+// parseLocalMovieFilter 从查询参数解析本地影片筛选与排序条件
+func parseLocalMovieFilter(c *gin.Context) repo.LocalMovieFilter {
+	filter := repo.LocalMovieFilter{
+		Actress:    c.Query("actress"),
+		Root:       c.Query("root"),
+		Quality:    c.Query("quality"),
+		VideoCodec: c.Query("video_codec"),
+		SortBy:     c.Query("sort_by"),
+		SortOrder:  c.Query("sort_order"),
+	}
+	filter.MinHeight, _ = strconv.Atoi(c.Query("min_height"))
+	filter.MinDuration, _ = strconv.Atoi(c.Query("min_duration"))
+	filter.MaxDuration, _ = strconv.Atoi(c.Query("max_duration"))
+	if hasSubtitle := c.Query("has_subtitle"); hasSubtitle != "" {
+		if val, err := strconv.ParseBool(hasSubtitle); err == nil {
+			filter.HasSubtitle = &val
+		}
+	}
+	if hasChineseSubtitle := c.Query("has_chinese_subtitle"); hasChineseSubtitle != "" {
+		if val, err := strconv.ParseBool(hasChineseSubtitle); err == nil {
+			filter.HasChineseSubtitle = &val
+		}
+	}
+	return filter
 }
 
 // LocalHandler 本地影片处理器
 type LocalHandler struct {
 	localMovieRepo   repo.LocalMovieRepository
 	scannerService   *service.ScannerService
-	mediaLibraryPath string
+	duplicateService *service.DuplicateService
+	nfoWriterService *service.NFOWriterService
 }
 
 // NewLocalHandler 创建本地影片处理器
-func NewLocalHandler(localMovieRepo repo.LocalMovieRepository, scannerService *service.ScannerService, mediaLibraryPath string) *LocalHandler {
+func NewLocalHandler(localMovieRepo repo.LocalMovieRepository, scannerService *service.ScannerService, duplicateService *service.DuplicateService, nfoWriterService *service.NFOWriterService) *LocalHandler {
 	return &LocalHandler{
 		localMovieRepo:   localMovieRepo,
 		scannerService:   scannerService,
-		mediaLibraryPath: mediaLibraryPath,
+		duplicateService: duplicateService,
+		nfoWriterService: nfoWriterService,
 	}
 }
 
+// GetLibraryRoots 获取已启用的媒体库根目录
+// @Summary 获取媒体库根目录
+// @Description 获取已启用的媒体库根目录及其布局、扫描间隔配置
+// @Tags local
+// @Accept json
+// @Produce json
+// @Success 200 {object} Response{data=[]model.MediaRoot} "根目录列表"
+// @Router /local/roots [get]
+func (h *LocalHandler) GetLibraryRoots(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data:    h.scannerService.Roots(),
+	})
+}
+
 // ScanLocalMovies 触发手动扫描本地影片库
 // @Summary 手动扫描本地影片库
-// @Description 触发一次完整的本地影片库扫描，更新数据库中的影片信息
+// @Description 创建一个扫描任务，增量更新数据库中的影片信息；默认在后台执行并立即返回任务，wait=true 时等待扫描结束。已有扫描在执行时返回 409
 // @Tags local
 // @Accept json
 // @Produce json
-// @Success 200 {object} Response "扫描成功"
+// @Param root query string false "只扫描指定的媒体库根目录"
+// @Param wait query bool false "等待扫描结束后返回"
+// @Success 200 {object} Response{data=service.ScanJobStatus} "扫描已结束（wait=true）"
+// @Success 202 {object} Response{data=service.ScanJobStatus} "扫描任务已创建"
+// @Failure 409 {object} Response{data=service.ScanJobStatus} "已有扫描任务正在执行"
 // @Failure 500 {object} ErrorResponse "扫描失败"
 // @Router /local/scan [post]
 func (h *LocalHandler) ScanLocalMovies(c *gin.Context) {
-	err := h.scannerService.ForceRescan()
+	root := c.Query("root")
+	wait, _ := strconv.ParseBool(c.Query("wait"))
+
+	var job *service.ScanJobStatus
+	var err error
+	if wait {
+		job, err = h.scannerService.RunScan(root, model.ScanTriggerManual)
+	} else {
+		job, err = h.scannerService.StartScan(root, model.ScanTriggerManual)
+	}
+	if errors.Is(err, service.ErrScanInProgress) {
+		c.JSON(http.StatusConflict, Response{
+			Code:    "SCAN_IN_PROGRESS",
+			Message: err.Error(),
+			Data:    h.scannerService.CurrentScan(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    "ERROR",
@@ -63,21 +222,181 @@ func (h *LocalHandler) ScanLocalMovies(c *gin.Context) {
 		return
 	}
 
+	if !wait {
+		c.JSON(http.StatusAccepted, Response{
+			Code:    "SUCCESS",
+			Message: "扫描任务已创建",
+			Data:    job,
+		})
+		return
+	}
+	if job.Status == model.ScanStatusFailed {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    "ERROR",
+			Message: fmt.Sprintf("扫描失败: %s", job.Error),
+			Data:    job,
+		})
+		return
+	}
 	c.JSON(http.StatusOK, Response{
 		Code:    "SUCCESS",
 		Message: "手动扫描已完成",
+		Data:    job,
+	})
+}
+
+// GetCurrentScan 获取正在执行的扫描任务
+// @Summary 获取扫描进度
+// @Description 获取正在执行的扫描任务的阶段、已遍历目录数、已找到文件数、估计进度与剩余时间，没有任务时 data 为空
+// @Tags local
+// @Accept json
+// @Produce json
+// @Success 200 {object} Response{data=service.ScanJobStatus} "扫描任务"
+// @Router /local/scan/current [get]
+func (h *LocalHandler) GetCurrentScan(c *gin.Context) {
+	job := h.scannerService.CurrentScan()
+	message := "没有正在执行的扫描"
+	if job != nil {
+		message = "获取成功"
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: message,
+		Data:    job,
+	})
+}
+
+// CancelScan 取消正在执行的扫描任务
+// @Summary 取消扫描
+// @Description 取消正在执行的扫描任务，已同步的影片保留，本次扫描不会移除任何记录
+// @Tags local
+// @Accept json
+// @Produce json
+// @Param id query int false "扫描任务ID，不传时取消当前任务"
+// @Success 200 {object} Response{data=service.ScanJobStatus} "已取消"
+// @Failure 404 {object} ErrorResponse "没有正在执行的扫描任务"
+// @Router /local/scan/cancel [post]
+func (h *LocalHandler) CancelScan(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Query("id"), 10, 32)
+	job, err := h.scannerService.CancelScan(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Code:    "NOT_FOUND",
+			Message: "没有正在执行的扫描任务",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "扫描已取消",
+		Data:    job,
+	})
+}
+
+// GetScanHistory 获取扫描记录
+// @Summary 获取扫描记录
+// @Description 分页获取历次扫描的触发方式、状态、耗时与新增、移除、变更数量
+// @Tags local
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Param root query string false "媒体库根目录筛选"
+// @Success 200 {object} Response{data=ListResponse{items=[]model.ScanHistory}} "扫描记录"
+// @Failure 500 {object} ErrorResponse "获取失败"
+// @Router /local/scan/history [get]
+func (h *LocalHandler) GetScanHistory(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	histories, total, err := h.scannerService.ScanHistory((page-1)*limit, limit, c.Query("root"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    "ERROR",
+			Message: fmt.Sprintf("获取扫描记录失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data: ListResponse{
+			Items:      histories,
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetScanHistoryDetail 获取扫描记录详情
+// @Summary 获取扫描记录详情
+// @Description 获取单次扫描的详细信息，包括新增、移除、变更的文件列表
+// @Tags local
+// @Accept json
+// @Produce json
+// @Param id path int true "扫描记录ID"
+// @Success 200 {object} Response{data=model.ScanHistory} "扫描记录"
+// @Failure 400 {object} ErrorResponse "无效的ID"
+// @Failure 404 {object} ErrorResponse "扫描记录不存在"
+// @Router /local/scan/history/{id} [get]
+func (h *LocalHandler) GetScanHistoryDetail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_ID",
+			Message: "无效的扫描记录ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	history, err := h.scannerService.GetScanHistory(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Code:    "NOT_FOUND",
+			Message: "扫描记录不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data:    history,
 	})
 }
 
 // GetLocalMovies 获取本地影片列表（从数据库读取）
 // @Summary 获取本地影片列表
-// @Description 分页获取本地影片库中的影片列表，支持按女优筛选
+// @Description 按逻辑影片分页获取本地影片库中的影片列表，同一番号的多个版本聚合为一项，支持按女优、根目录、画质、编码、时长、字幕筛选和排序
 // @Tags local
 // @Accept json
 // @Produce json
 // @Param page query int false "页码" default(1)
 // @Param limit query int false "每页数量" default(100)
 // @Param actress query string false "女优筛选"
+// @Param root query string false "媒体库根目录筛选"
+// @Param quality query string false "画质筛选（4K、1080p、720p、480p、SD）"
+// @Param video_codec query string false "视频编码筛选（h264、hevc、av1 等）"
+// @Param min_height query int false "最小分辨率高度"
+// @Param min_duration query int false "最短时长（秒）"
+// @Param max_duration query int false "最长时长（秒）"
+// @Param has_subtitle query bool false "是否有字幕"
+// @Param has_chinese_subtitle query bool false "是否有中文字幕"
+// @Param sort_by query string false "排序字段（created_at、size、duration、height、bitrate、modified）"
+// @Param sort_order query string false "排序方向（asc、desc）"
 // @Success 200 {object} ListResponse{items=[]LocalMovie} "影片列表"
 // @Failure 500 {object} ErrorResponse "获取失败"
 // @Router /local/movies [get]
@@ -85,7 +404,7 @@ func (h *LocalHandler) GetLocalMovies(c *gin.Context) {
 	// 获取分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
-	actress := c.Query("actress")
+	filter := parseLocalMovieFilter(c)
 
 	if page < 1 {
 		page = 1
@@ -97,7 +416,7 @@ func (h *LocalHandler) GetLocalMovies(c *gin.Context) {
 	offset := (page - 1) * limit
 
 	// 从数据库获取数据
-	dbMovies, total, err := h.localMovieRepo.List(offset, limit, actress)
+	titles, total, err := h.localMovieRepo.ListTitles(offset, limit, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    "ERROR",
@@ -106,21 +425,17 @@ func (h *LocalHandler) GetLocalMovies(c *gin.Context) {
 		return
 	}
 
-	// 转换为API响应格式
-	movies := make([]LocalMovie, len(dbMovies))
-	for i, movie := range dbMovies {
-		movies[i] = LocalMovie{
-			Title:      movie.Title,
-			Code:       movie.Code,
-			Actress:    movie.Actress,
-			Path:       movie.Path,
-			Size:       movie.Size,
-			Modified:   movie.Modified.Format("2006-01-02 15:04:05"),
-			Format:     movie.Format,
-			FanartPath: movie.FanartPath,
-			FanartURL:  movie.FanartURL,
-			HasFanart:  movie.HasFanart,
+	// 转换为API响应格式，主版本在前，其余版本放入 versions
+	movies := make([]LocalMovie, 0, len(titles))
+	for _, title := range titles {
+		if len(title.Versions) == 0 {
+			continue
 		}
+		item := newLocalMovie(title.Versions[0])
+		for _, version := range title.Versions[1:] {
+			item.Versions = append(item.Versions, newLocalMovie(version))
+		}
+		movies = append(movies, item)
 	}
 
 	c.JSON(http.StatusOK, Response{
@@ -138,14 +453,17 @@ func (h *LocalHandler) GetLocalMovies(c *gin.Context) {
 
 // GetLocalMovieStats 获取本地影片统计信息
 // @Summary 获取本地影片统计
-// @Description 获取本地影片库的统计信息，包括总数、女优数量等
+// @Description 获取本地影片库的统计信息，包括总数、女优数量、各根目录影片数等
 // @Tags local
 // @Accept json
 // @Produce json
+// @Param root query string false "媒体库根目录筛选"
 // @Success 200 {object} Response "统计信息"
 // @Failure 500 {object} ErrorResponse "获取失败"
 // @Router /local/stats [get]
 func (h *LocalHandler) GetLocalMovieStats(c *gin.Context) {
+	root := c.Query("root")
+
 	// 获取总数
 	total, err := h.localMovieRepo.Count()
 	if err != nil {
@@ -156,8 +474,21 @@ func (h *LocalHandler) GetLocalMovieStats(c *gin.Context) {
 		return
 	}
 
+	// 获取按根目录统计
+	rootCounts, err := h.localMovieRepo.CountByRoot()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    "ERROR",
+			Message: fmt.Sprintf("获取根目录统计失败: %v", err),
+		})
+		return
+	}
+	if root != "" {
+		total = rootCounts[root]
+	}
+
 	// 获取按女优统计
-	actressCounts, err := h.localMovieRepo.CountByActress()
+	actressCounts, err := h.localMovieRepo.CountByActress(root)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    "ERROR",
@@ -183,6 +514,7 @@ func (h *LocalHandler) GetLocalMovieStats(c *gin.Context) {
 			"total_movies":   total,
 			"actress_counts": actressCounts,
 			"actress_total":  len(actressCounts),
+			"root_counts":    rootCounts,
 			"last_scan_time": lastScanTime.Format("2006-01-02 15:04:05"),
 		},
 	})
@@ -195,6 +527,16 @@ func (h *LocalHandler) GetLocalMovieStats(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param keyword query string true "搜索关键词"
+// @Param root query string false "媒体库根目录筛选"
+// @Param quality query string false "画质筛选（4K、1080p、720p、480p、SD）"
+// @Param video_codec query string false "视频编码筛选（h264、hevc、av1 等）"
+// @Param min_height query int false "最小分辨率高度"
+// @Param min_duration query int false "最短时长（秒）"
+// @Param max_duration query int false "最长时长（秒）"
+// @Param has_subtitle query bool false "是否有字幕"
+// @Param has_chinese_subtitle query bool false "是否有中文字幕"
+// @Param sort_by query string false "排序字段（created_at、size、duration、height、bitrate、modified）"
+// @Param sort_order query string false "排序方向（asc、desc）"
 // @Success 200 {object} Response{data=[]LocalMovie} "搜索结果"
 // @Failure 400 {object} ErrorResponse "参数错误"
 // @Failure 500 {object} ErrorResponse "搜索失败"
@@ -209,8 +551,12 @@ func (h *LocalHandler) SearchLocalMovies(c *gin.Context) {
 		return
 	}
 
+	// 筛选条件（搜索时不按女优精确筛选）
+	filter := parseLocalMovieFilter(c)
+	filter.Actress = ""
+
 	// 从数据库搜索影片
-	dbMovies, _, err := h.localMovieRepo.Search(keyword, 0, 100) // 默认返回前100个结果
+	dbMovies, _, err := h.localMovieRepo.SearchWithFilter(keyword, filter, 0, 100) // 默认返回前100个结果
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    "ERROR",
@@ -222,18 +568,7 @@ func (h *LocalHandler) SearchLocalMovies(c *gin.Context) {
 	// 转换为API响应格式
 	movies := make([]LocalMovie, len(dbMovies))
 	for i, movie := range dbMovies {
-		movies[i] = LocalMovie{
-			Title:      movie.Title,
-			Code:       movie.Code,
-			Actress:    movie.Actress,
-			Path:       movie.Path,
-			Size:       movie.Size,
-			Modified:   movie.Modified.Format("2006-01-02 15:04:05"),
-			Format:     movie.Format,
-			FanartPath: movie.FanartPath,
-			FanartURL:  movie.FanartURL,
-			HasFanart:  movie.HasFanart,
-		}
+		movies[i] = newLocalMovie(movie)
 	}
 
 	c.JSON(http.StatusOK, Response{
@@ -243,6 +578,73 @@ func (h *LocalHandler) SearchLocalMovies(c *gin.Context) {
 	})
 }
 
+// DuplicateGroup 重复影片分组（用于API响应）
+type DuplicateGroup struct {
+	Key         string       `json:"key"` // 内容摘要或番号
+	Movies      []LocalMovie `json:"movies"`
+	TotalSize   int64        `json:"total_size"`
+	Reclaimable int64        `json:"reclaimable"` // 只保留第一项时可释放的空间
+}
+
+// DuplicateReport 重复影片报告（用于API响应）
+type DuplicateReport struct {
+	Identical            []DuplicateGroup `json:"identical"` // 内容完全相同的文件
+	SameCode             []DuplicateGroup `json:"same_code"` // 番号相同但文件不同
+	IdenticalReclaimable int64            `json:"identical_reclaimable"`
+	SameCodeReclaimable  int64            `json:"same_code_reclaimable"`
+	TotalReclaimable     int64            `json:"total_reclaimable"`
+}
+
+// newDuplicateGroups 将重复分组转换为API响应格式
+func newDuplicateGroups(groups []service.DuplicateGroup) []DuplicateGroup {
+	items := make([]DuplicateGroup, 0, len(groups))
+	for _, group := range groups {
+		item := DuplicateGroup{
+			Key:         group.Key,
+			Movies:      make([]LocalMovie, 0, len(group.Movies)),
+			TotalSize:   group.TotalSize,
+			Reclaimable: group.Reclaimable,
+		}
+		for _, movie := range group.Movies {
+			item.Movies = append(item.Movies, newLocalMovie(movie))
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// GetDuplicates 获取重复影片报告
+// @Summary 获取重复影片报告
+// @Description 列出内容完全相同的文件分组与番号相同但文件不同的分组，并统计可释放的空间
+// @Tags local
+// @Accept json
+// @Produce json
+// @Success 200 {object} Response{data=DuplicateReport} "重复影片报告"
+// @Failure 500 {object} ErrorResponse "获取失败"
+// @Router /local/duplicates [get]
+func (h *LocalHandler) GetDuplicates(c *gin.Context) {
+	report, err := h.duplicateService.Report()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    "ERROR",
+			Message: fmt.Sprintf("获取重复影片失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data: DuplicateReport{
+			Identical:            newDuplicateGroups(report.Identical),
+			SameCode:             newDuplicateGroups(report.SameCode),
+			IdenticalReclaimable: report.IdenticalReclaimable,
+			SameCodeReclaimable:  report.SameCodeReclaimable,
+			TotalReclaimable:     report.TotalReclaimable,
+		},
+	})
+}
+
 // ServeImage 提供图片服务
 func (h *LocalHandler) ServeImage(c *gin.Context) {
 	imagePath := c.Param("filepath")
@@ -260,46 +662,8 @@ func (h *LocalHandler) ServeImage(c *gin.Context) {
 	// 清理路径中的双斜杠
 	decodedPath = strings.ReplaceAll(decodedPath, "//", "/")
 
-	var fullPath string
-
-	// 调试日志
-	log.Printf("ServeImage - 原始路径: %s", decodedPath)
-	log.Printf("ServeImage - 媒体库路径: %s", h.mediaLibraryPath)
-
-	// 处理容器路径映射
-	if strings.HasPrefix(decodedPath, "/app/media/") {
-		// 容器内路径，去除 /app 前缀并直接使用
-		fullPath = strings.TrimPrefix(decodedPath, "/app")
-		log.Printf("ServeImage - 容器路径处理后: %s", fullPath)
-	} else if strings.HasPrefix(decodedPath, "/media/") {
-		// 已经是宿主机路径，直接使用
-		fullPath = decodedPath
-		log.Printf("ServeImage - 宿主机路径直接使用: %s", fullPath)
-	} else {
-		// 相对路径，拼接到媒体库路径
-		// 在容器环境中，直接使用配置的媒体库路径，不进行映射
-		basePath := h.mediaLibraryPath
-		log.Printf("ServeImage - 使用媒体库路径: %s", basePath)
-		fullPath = filepath.Join(basePath, decodedPath)
-		log.Printf("ServeImage - 相对路径拼接结果: %s", fullPath)
-	}
-
-	// 安全检查：确保路径在允许的媒体目录内
-	allowedPaths := []string{
-		h.mediaLibraryPath,     // 配置的媒体库路径
-		"/media/Links/PornDB",  // 实际的媒体目录
-		"/MediaCenter",         // 可能的媒体中心路径
-	}
-
-	isAllowed := false
-	for _, allowedPath := range allowedPaths {
-		if strings.HasPrefix(fullPath, allowedPath) {
-			isAllowed = true
-			break
-		}
-	}
-
-	if !isAllowed {
+	fullPath, ok := h.resolveImagePath(decodedPath)
+	if !ok {
 		c.JSON(http.StatusForbidden, Response{
 			Code:    "ERROR",
 			Message: "访问被拒绝",
@@ -336,3 +700,179 @@ func (h *LocalHandler) ServeImage(c *gin.Context) {
 	c.Header("Cache-Control", "public, max-age=3600") // 缓存1小时
 	c.File(fullPath)
 }
+
+// resolveImagePath 将图片URL路径解析为文件路径，只允许访问已启用的媒体库根目录
+func (h *LocalHandler) resolveImagePath(decodedPath string) (string, bool) {
+	roots := h.scannerService.Roots()
+	if len(roots) == 0 {
+		return "", false
+	}
+
+	var fullPath string
+	name, rest, _ := strings.Cut(strings.TrimPrefix(decodedPath, "/"), "/")
+	for _, root := range roots {
+		if root.Name == name {
+			// 根目录名称开头的相对路径
+			fullPath = filepath.Join(root.Path, rest)
+			break
+		}
+	}
+
+	if fullPath == "" {
+		if strings.HasPrefix(decodedPath, "/app/media/") {
+			// 容器内路径，去除 /app 前缀并直接使用
+			fullPath = strings.TrimPrefix(decodedPath, "/app")
+		} else if withinRoots(filepath.Clean(decodedPath), roots) {
+			// 已经是宿主机路径，直接使用
+			fullPath = decodedPath
+		} else {
+			// 旧版本生成的相对路径，拼接到第一个媒体库路径
+			fullPath = filepath.Join(roots[0].Path, decodedPath)
+		}
+	}
+
+	// 安全检查：确保路径在允许的媒体目录内
+	fullPath = filepath.Clean(fullPath)
+	if !withinRoots(fullPath, roots) {
+		log.Printf("ServeImage - 拒绝访问媒体库以外的路径: %s", fullPath)
+		return "", false
+	}
+	return fullPath, true
+}
+
+// withinRoots 判断路径是否位于任一媒体库根目录内
+func withinRoots(fullPath string, roots []model.MediaRoot) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(filepath.Clean(root.Path), fullPath)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NFOWriteRequest NFO写入参数
+type NFOWriteRequest struct {
+	DryRun      bool `form:"dry_run"`
+	Overwrite   bool `form:"overwrite"`
+	SkipArtwork bool `form:"skip_artwork"`
+	Scrape      bool `form:"scrape"`
+	Limit       int  `form:"limit"`
+}
+
+// options 转换为服务层的写入选项
+func (r NFOWriteRequest) options() service.NFOWriteOptions {
+	return service.NFOWriteOptions{
+		DryRun:      r.DryRun,
+		Overwrite:   r.Overwrite,
+		SkipArtwork: r.SkipArtwork,
+		Scrape:      r.Scrape,
+	}
+}
+
+// WriteNFO 为单部本地影片写入NFO与图片
+// @Summary 写入影片NFO与图片
+// @Description 使用影片库中的元数据（或在线抓取）在影片目录中写入 Kodi/Jellyfin 兼容的 movie.nfo、poster.jpg、fanart.jpg 与 extrafanart
+// @Tags local
+// @Accept json
+// @Produce json
+// @Param id path int true "本地影片ID"
+// @Param dry_run query bool false "只返回计划写入的文件，不写入磁盘"
+// @Param overwrite query bool false "覆盖已存在的文件"
+// @Param skip_artwork query bool false "只写入NFO，不下载图片"
+// @Param scrape query bool false "影片库中没有元数据时在线抓取"
+// @Success 200 {object} Response{data=service.NFOWriteResult} "写入结果"
+// @Failure 400 {object} ErrorResponse "请求参数错误"
+// @Failure 404 {object} ErrorResponse "影片不存在"
+// @Failure 422 {object} ErrorResponse "找不到影片元数据"
+// @Failure 500 {object} ErrorResponse "写入失败"
+// @Router /local/movies/{id}/nfo [post]
+func (h *LocalHandler) WriteNFO(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_ID",
+			Message: "无效的影片ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+	var req NFOWriteRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result, err := h.nfoWriterService.WriteLocalMovie(c.Request.Context(), uint(id), req.options())
+	if err != nil {
+		status, code := http.StatusInternalServerError, "ERROR"
+		switch {
+		case errors.Is(err, service.ErrLocalMovieNotFound):
+			status, code = http.StatusNotFound, "NOT_FOUND"
+		case errors.Is(err, service.ErrNoMetadata):
+			status, code = http.StatusUnprocessableEntity, "NO_METADATA"
+		}
+		c.JSON(status, ErrorResponse{
+			Code:    code,
+			Message: "写入NFO失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	message := "写入完成"
+	if req.DryRun {
+		message = "预览完成，未写入文件"
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: message,
+		Data:    result,
+	})
+}
+
+// WriteMissingNFO 为所有没有NFO的本地影片批量写入NFO与图片
+// @Summary 批量写入缺失的NFO
+// @Description 为所有没有NFO文件的本地影片写入NFO与图片，建议先使用 dry_run 预览
+// @Tags local
+// @Accept json
+// @Produce json
+// @Param dry_run query bool false "只返回计划写入的文件，不写入磁盘"
+// @Param overwrite query bool false "覆盖已存在的图片文件"
+// @Param skip_artwork query bool false "只写入NFO，不下载图片"
+// @Param scrape query bool false "影片库中没有元数据时在线抓取"
+// @Param limit query int false "最多处理的影片数量，0 表示不限制"
+// @Success 200 {object} Response{data=service.NFOBatchResult} "批量写入结果"
+// @Failure 400 {object} ErrorResponse "请求参数错误"
+// @Failure 500 {object} ErrorResponse "写入失败"
+// @Router /local/nfo [post]
+func (h *LocalHandler) WriteMissingNFO(c *gin.Context) {
+	var req NFOWriteRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result, err := h.nfoWriterService.WriteMissing(c.Request.Context(), req.Limit, req.options())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    "ERROR",
+			Message: fmt.Sprintf("批量写入NFO失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("共 %d 部影片，写入 %d 部，跳过 %d 部，失败 %d 部", result.Total, result.Written, result.Skipped, result.Failed),
+		Data:    result,
+	})
+}
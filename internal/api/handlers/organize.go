@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"nsfw-go/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizeHandler 媒体库整理处理器
+type OrganizeHandler struct {
+	organizerService *service.OrganizerService
+}
+
+// NewOrganizeHandler 创建媒体库整理处理器
+func NewOrganizeHandler(organizerService *service.OrganizerService) *OrganizeHandler {
+	return &OrganizeHandler{
+		organizerService: organizerService,
+	}
+}
+
+// bindOrganizeRequest 解析整理请求，允许空请求体
+func bindOrganizeRequest(c *gin.Context) (service.OrganizeRequest, bool) {
+	var req service.OrganizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: "请求参数无效",
+			Error:   err.Error(),
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// organizeErrorStatus 整理错误对应的HTTP状态码与错误码
+func organizeErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidOrganizeTemplate), errors.Is(err, service.ErrOrganizeRootNotFound):
+		return http.StatusBadRequest, "INVALID_REQUEST"
+	case errors.Is(err, service.ErrOrganizeRunNotFound):
+		return http.StatusNotFound, "NOT_FOUND"
+	case errors.Is(err, service.ErrOrganizeInProgress), errors.Is(err, service.ErrScanInProgress):
+		return http.StatusConflict, "IN_PROGRESS"
+	case errors.Is(err, service.ErrOrganizeAlreadyUndone):
+		return http.StatusConflict, "ALREADY_UNDONE"
+	}
+	return http.StatusInternalServerError, "ERROR"
+}
+
+// PlanOrganize 预览整理计划
+// @Summary 预览媒体库整理
+// @Description 按路径模板计算每部影片的目标路径与需要执行的移动操作，不修改任何文件。模板占位符：{actress} {code} {title} {year} {studio} {ext}
+// @Tags organize
+// @Accept json
+// @Produce json
+// @Param request body service.OrganizeRequest false "整理范围与模板"
+// @Success 200 {object} Response{data=service.OrganizePlan} "整理计划"
+// @Failure 400 {object} ErrorResponse "模板或根目录无效"
+// @Failure 500 {object} ErrorResponse "生成计划失败"
+// @Router /local/organize/plan [post]
+func (h *OrganizeHandler) PlanOrganize(c *gin.Context) {
+	req, ok := bindOrganizeRequest(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.organizerService.Plan(req)
+	if err != nil {
+		status, code := organizeErrorStatus(err)
+		c.JSON(status, ErrorResponse{
+			Code:    code,
+			Message: "生成整理计划失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "预览完成，未修改文件",
+		Data:    plan,
+	})
+}
+
+// ExecuteOrganize 执行整理
+// @Summary 执行媒体库整理
+// @Description 按路径模板移动并重命名影片及其NFO、图片与字幕，每一步操作都记录在整理日志中，可通过撤销接口恢复
+// @Tags organize
+// @Accept json
+// @Produce json
+// @Param request body service.OrganizeRequest false "整理范围与模板"
+// @Success 200 {object} Response{data=model.OrganizeRun} "整理记录"
+// @Failure 400 {object} ErrorResponse "模板或根目录无效"
+// @Failure 409 {object} ErrorResponse "已有整理或扫描任务正在执行"
+// @Failure 500 {object} ErrorResponse "整理失败"
+// @Router /local/organize/execute [post]
+func (h *OrganizeHandler) ExecuteOrganize(c *gin.Context) {
+	req, ok := bindOrganizeRequest(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.organizerService.Execute(req)
+	if err != nil {
+		status, code := organizeErrorStatus(err)
+		c.JSON(status, ErrorResponse{
+			Code:    code,
+			Message: "整理失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "整理完成",
+		Data:    run,
+	})
+}
+
+// GetOrganizeRuns 获取整理记录
+// @Summary 获取整理记录
+// @Description 分页获取历次整理的状态与数量统计
+// @Tags organize
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Success 200 {object} Response{data=ListResponse{items=[]model.OrganizeRun}} "整理记录"
+// @Failure 500 {object} ErrorResponse "获取失败"
+// @Router /local/organize/runs [get]
+func (h *OrganizeHandler) GetOrganizeRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	runs, total, err := h.organizerService.ListRuns((page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Code:    "ERROR",
+			Message: "获取整理记录失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data: ListResponse{
+			Items:      runs,
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetOrganizeRun 获取整理记录详情
+// @Summary 获取整理记录详情
+// @Description 获取单次整理的详细信息，包括按顺序排列的文件操作日志
+// @Tags organize
+// @Accept json
+// @Produce json
+// @Param id path int true "整理记录ID"
+// @Success 200 {object} Response{data=model.OrganizeRun} "整理记录"
+// @Failure 400 {object} ErrorResponse "无效的ID"
+// @Failure 404 {object} ErrorResponse "记录不存在"
+// @Router /local/organize/runs/{id} [get]
+func (h *OrganizeHandler) GetOrganizeRun(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_ID",
+			Message: "无效的整理记录ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	run, err := h.organizerService.GetRun(uint(id))
+	if err != nil {
+		status, code := organizeErrorStatus(err)
+		c.JSON(status, ErrorResponse{
+			Code:    code,
+			Message: "获取整理记录失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data:    run,
+	})
+}
+
+// UndoOrganize 撤销整理
+// @Summary 撤销整理
+// @Description 按整理日志倒序将文件移回原位置并恢复数据库中的路径，部分失败时可再次调用
+// @Tags organize
+// @Accept json
+// @Produce json
+// @Param id path int true "整理记录ID"
+// @Success 200 {object} Response{data=model.OrganizeRun} "撤销后的整理记录"
+// @Failure 400 {object} ErrorResponse "无效的ID"
+// @Failure 404 {object} ErrorResponse "记录不存在"
+// @Failure 409 {object} ErrorResponse "已撤销或有任务正在执行"
+// @Failure 500 {object} ErrorResponse "撤销失败"
+// @Router /local/organize/runs/{id}/undo [post]
+func (h *OrganizeHandler) UndoOrganize(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_ID",
+			Message: "无效的整理记录ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	run, err := h.organizerService.Undo(uint(id))
+	if err != nil {
+		status, code := organizeErrorStatus(err)
+		c.JSON(status, ErrorResponse{
+			Code:    code,
+			Message: "撤销整理失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	message := "撤销完成"
+	if run.Error != "" {
+		message = "部分文件未能恢复，可再次撤销"
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: message,
+		Data:    run,
+	})
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"nsfw-go/internal/model"
 	"nsfw-go/internal/service"
@@ -22,24 +24,56 @@ func NewRankingHandler(rankingService *service.RankingService) *RankingHandler {
 	}
 }
 
+// boardParam 读取榜单标识，兼容旧的 type 和 period 参数
+func boardParam(c *gin.Context) string {
+	for _, key := range []string{"board", "type", "period"} {
+		if value := c.Query(key); value != "" {
+			return value
+		}
+	}
+	return model.RankTypeDaily
+}
+
+// invalidMovieBoard 校验影片榜单标识，无效时返回错误响应
+func (h *RankingHandler) invalidMovieBoard(c *gin.Context, board string) bool {
+	if h.rankingService.IsMovieBoard(board) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": "无效的榜单，支持: " + strings.Join(h.rankingService.MovieBoardKeys(), ", "),
+	})
+	return true
+}
+
+// GetBoards 获取已配置的榜单
+// @Summary 获取榜单列表
+// @Description 获取已配置的榜单：标识、名称、类型（影片/女优）、分类（有码/无码/欧美/FC2）、周期与爬取页数
+// @Tags rankings
+// @Accept json
+// @Produce json
+// @Success 200 {object} Response{data=[]crawler.RankingBoard} "榜单列表"
+// @Router /rankings/boards [get]
+func (h *RankingHandler) GetBoards(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.rankingService.Boards(),
+	})
+}
+
 // GetRankings 获取排行榜
 // @Summary 获取排行榜列表
-// @Description 获取JAVDb排行榜数据，支持日榜、周榜、月榜
+// @Description 获取JAVDb影片榜单数据，榜单标识见 /rankings/boards，如 daily、uncensored-weekly、fc2-monthly
 // @Tags rankings
 // @Accept json
 // @Produce json
-// @Param type query string false "排行榜类型" default(daily) Enums(daily, weekly, monthly)
+// @Param board query string false "榜单标识，兼容 type、period 参数" default(daily)
 // @Param limit query int false "数量限制" default(50)
 // @Success 200 {object} Response{data=[]model.Ranking} "排行榜列表"
 // @Failure 400 {object} ErrorResponse "参数错误"
 // @Failure 500 {object} ErrorResponse "获取失败"
 // @Router /rankings [get]
 func (h *RankingHandler) GetRankings(c *gin.Context) {
-	// 兼容 type 和 period 参数
-	rankType := c.DefaultQuery("type", "")
-	if rankType == "" {
-		rankType = c.DefaultQuery("period", model.RankTypeDaily)
-	}
+	board := boardParam(c)
 	limitStr := c.DefaultQuery("limit", "50")
 
 	limit, err := strconv.Atoi(limitStr)
@@ -50,15 +84,12 @@ func (h *RankingHandler) GetRankings(c *gin.Context) {
 		return
 	}
 
-	// 验证排行榜类型
-	if rankType != model.RankTypeDaily && rankType != model.RankTypeWeekly && rankType != model.RankTypeMonthly {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "无效的排行榜类型，支持: daily, weekly, monthly",
-		})
+	// 验证榜单
+	if h.invalidMovieBoard(c, board) {
 		return
 	}
 
-	rankings, err := h.rankingService.GetRankings(rankType, limit)
+	rankings, err := h.rankingService.GetRankings(board, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "获取排行榜失败: " + err.Error(),
@@ -69,11 +100,57 @@ func (h *RankingHandler) GetRankings(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
 		"data":      rankings,
-		"rank_type": rankType,
+		"board":     board,
+		"rank_type": board,
 		"count":     len(rankings),
 	})
 }
 
+// GetActressRankings 获取女优排行榜
+// @Summary 获取女优排行榜
+// @Description 获取女优榜单的最新排名，已关联本地女优的条目带有 actress_id
+// @Tags rankings
+// @Accept json
+// @Produce json
+// @Param board query string false "女优榜单标识" default(actress-censored)
+// @Param limit query int false "数量限制" default(50)
+// @Success 200 {object} Response{data=[]model.ActressRanking} "女优排行榜"
+// @Failure 400 {object} ErrorResponse "参数错误"
+// @Failure 500 {object} ErrorResponse "获取失败"
+// @Router /rankings/actresses [get]
+func (h *RankingHandler) GetActressRankings(c *gin.Context) {
+	board := c.DefaultQuery("board", "actress-censored")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的limit参数",
+		})
+		return
+	}
+
+	if !h.rankingService.IsActressBoard(board) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的女优榜单: " + board,
+		})
+		return
+	}
+
+	rankings, err := h.rankingService.GetActressRankings(board, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取女优排行榜失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rankings,
+		"board":   board,
+		"count":   len(rankings),
+	})
+}
+
 // GetRankingStats 获取排行榜统计信息
 func (h *RankingHandler) GetRankingStats(c *gin.Context) {
 	stats, err := h.rankingService.GetRankingStats()
@@ -124,7 +201,7 @@ func (h *RankingHandler) TriggerCheck(c *gin.Context) {
 
 // GetLocalExists 获取本地已存在的排行榜影片
 func (h *RankingHandler) GetLocalExists(c *gin.Context) {
-	rankType := c.DefaultQuery("type", "all")
+	board := c.DefaultQuery("board", c.DefaultQuery("type", "all"))
 	limitStr := c.DefaultQuery("limit", "100")
 
 	limit, err := strconv.Atoi(limitStr)
@@ -137,18 +214,21 @@ func (h *RankingHandler) GetLocalExists(c *gin.Context) {
 
 	var allRankings []*model.Ranking
 
-	if rankType == "all" {
-		// 获取所有类型的排行榜
-		rankTypes := []string{model.RankTypeDaily, model.RankTypeWeekly, model.RankTypeMonthly}
-		for _, rt := range rankTypes {
-			rankings, err := h.rankingService.GetRankings(rt, limit/3)
+	if board == "all" {
+		// 获取所有影片榜单
+		boards := h.rankingService.MovieBoardKeys()
+		for _, key := range boards {
+			rankings, err := h.rankingService.GetRankings(key, limit/len(boards))
 			if err != nil {
 				continue
 			}
 			allRankings = append(allRankings, rankings...)
 		}
 	} else {
-		rankings, err := h.rankingService.GetRankings(rankType, limit)
+		if h.invalidMovieBoard(c, board) {
+			return
+		}
+		rankings, err := h.rankingService.GetRankings(board, limit)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "获取排行榜失败: " + err.Error(),
@@ -169,8 +249,88 @@ func (h *RankingHandler) GetLocalExists(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success":     true,
 		"data":        localExists,
-		"rank_type":   rankType,
+		"board":       board,
+		"rank_type":   board,
 		"total_count": len(allRankings),
 		"local_count": len(localExists),
 	})
 }
+
+// GetRankingTrends 获取排行榜趋势
+// @Summary 获取排行榜趋势
+// @Description 对比最新一次与上一次爬取的整榜快照，返回排名变化、新上榜、跌出榜单、历史最高排名与上榜天数
+// @Tags rankings
+// @Accept json
+// @Produce json
+// @Param board query string false "榜单标识，兼容 type 参数" default(daily)
+// @Param sort query string false "排序方式：position 按当前排名，change 按上升幅度" default(position) Enums(position, change)
+// @Success 200 {object} service.RankingTrend "排行榜趋势"
+// @Failure 400 {object} ErrorResponse "参数错误"
+// @Failure 404 {object} ErrorResponse "暂无历史快照"
+// @Failure 500 {object} ErrorResponse "获取失败"
+// @Router /rankings/trends [get]
+func (h *RankingHandler) GetRankingTrends(c *gin.Context) {
+	board := boardParam(c)
+	if h.invalidMovieBoard(c, board) {
+		return
+	}
+	sortBy := c.DefaultQuery("sort", service.RankingTrendSortPosition)
+	if sortBy != service.RankingTrendSortPosition && sortBy != service.RankingTrendSortChange {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的排序方式，支持: position, change",
+		})
+		return
+	}
+
+	trend, err := h.rankingService.GetRankingTrends(board, sortBy)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrNoRankingSnapshot) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": "获取排行榜趋势失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    trend,
+	})
+}
+
+// GetRankingHistory 获取番号的排行榜历史
+// @Summary 获取番号的排行榜历史
+// @Description 获取番号在各影片榜单上每次爬取的排名，以及历史最高排名与上榜天数
+// @Tags rankings
+// @Accept json
+// @Produce json
+// @Param code path string true "番号"
+// @Param days query int false "只返回最近几天的排名，0 表示全部" default(0)
+// @Success 200 {object} service.RankingCodeHistory "排行榜历史"
+// @Failure 400 {object} ErrorResponse "参数错误"
+// @Failure 500 {object} ErrorResponse "获取失败"
+// @Router /rankings/history/{code} [get]
+func (h *RankingHandler) GetRankingHistory(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "0"))
+	if err != nil || days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的days参数",
+		})
+		return
+	}
+
+	history, err := h.rankingService.GetCodeHistory(c.Param("code"), days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取排行榜历史失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    history,
+	})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"nsfw-go/internal/model"
 	"nsfw-go/internal/service"
@@ -13,15 +14,29 @@ import (
 // RankingDownloadHandler 排行榜下载处理器
 type RankingDownloadHandler struct {
 	downloadService *service.RankingDownloadService
+	rankingService  *service.RankingService
 }
 
 // NewRankingDownloadHandler 创建排行榜下载处理器
-func NewRankingDownloadHandler(downloadService *service.RankingDownloadService) *RankingDownloadHandler {
+func NewRankingDownloadHandler(downloadService *service.RankingDownloadService, rankingService *service.RankingService) *RankingDownloadHandler {
 	return &RankingDownloadHandler{
 		downloadService: downloadService,
+		rankingService:  rankingService,
 	}
 }
 
+// invalidBoard 校验订阅的榜单标识，只能订阅已配置的影片榜单
+func (h *RankingDownloadHandler) invalidBoard(c *gin.Context, board string) bool {
+	if h.rankingService.IsMovieBoard(board) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"error":   "无效的榜单，支持: " + strings.Join(h.rankingService.MovieBoardKeys(), ", "),
+	})
+	return true
+}
+
 // StartDownloadRequest 开始下载请求
 type StartDownloadRequest struct {
 	Code     string `json:"code" binding:"required"`
@@ -193,6 +208,33 @@ func (h *RankingDownloadHandler) RetryTask(c *gin.Context) {
 	})
 }
 
+// ImportTask 将已完成的任务导入媒体库
+func (h *RankingDownloadHandler) ImportTask(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的任务ID",
+		})
+		return
+	}
+
+	result, err := h.downloadService.ImportTask(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已导入媒体库",
+		"data":    result,
+	})
+}
+
 // GetTaskStats 获取任务统计
 func (h *RankingDownloadHandler) GetTaskStats(c *gin.Context) {
 	stats, err := h.downloadService.GetTaskStats()
@@ -210,6 +252,70 @@ func (h *RankingDownloadHandler) GetTaskStats(c *gin.Context) {
 	})
 }
 
+// GetBlocklist 获取种子黑名单
+func (h *RankingDownloadHandler) GetBlocklist(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的limit参数",
+		})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的offset参数",
+		})
+		return
+	}
+
+	entries, total, err := h.downloadService.GetBlocklist(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取种子黑名单失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"entries": entries,
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+		},
+	})
+}
+
+// RemoveFromBlocklist 从种子黑名单中移除
+func (h *RankingDownloadHandler) RemoveFromBlocklist(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的黑名单ID",
+		})
+		return
+	}
+
+	if err := h.downloadService.RemoveFromBlocklist(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已从黑名单中移除",
+	})
+}
+
 // GetSubscriptions 获取所有订阅配置
 func (h *RankingDownloadHandler) GetSubscriptions(c *gin.Context) {
 	subscriptions, err := h.downloadService.GetSubscriptions()
@@ -270,6 +376,9 @@ func (h *RankingDownloadHandler) UpdateSubscription(c *gin.Context) {
 		})
 		return
 	}
+	if h.invalidBoard(c, rankType) {
+		return
+	}
 
 	var req UpdateSubscriptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -306,12 +415,8 @@ func (h *RankingDownloadHandler) RunSubscriptionDownload(c *gin.Context) {
 		return
 	}
 
-	// 验证排行榜类型
-	if rankType != model.RankTypeDaily && rankType != model.RankTypeWeekly && rankType != model.RankTypeMonthly {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "无效的排行榜类型，支持: daily, weekly, monthly",
-		})
+	// 验证榜单
+	if h.invalidBoard(c, rankType) {
 		return
 	}
 
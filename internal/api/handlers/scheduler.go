@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"nsfw-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SchedulerHandler 定时任务处理器
+type SchedulerHandler struct {
+	schedulerService *service.SchedulerService
+}
+
+// NewSchedulerHandler 创建定时任务处理器
+func NewSchedulerHandler(schedulerService *service.SchedulerService) *SchedulerHandler {
+	return &SchedulerHandler{
+		schedulerService: schedulerService,
+	}
+}
+
+// schedulerErrorStatus 定时任务错误对应的HTTP状态码与错误码
+func schedulerErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		return http.StatusNotFound, "NOT_FOUND"
+	case errors.Is(err, service.ErrJobRunning):
+		return http.StatusConflict, "IN_PROGRESS"
+	}
+	return http.StatusInternalServerError, "ERROR"
+}
+
+// GetJobs 获取定时任务列表
+// @Summary 获取定时任务列表
+// @Description 获取所有后台定时任务的调度规则、时区、下一次与上一次执行时间以及上一次执行结果
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Success 200 {object} Response{data=[]service.ScheduledJobStatus} "定时任务列表"
+// @Router /scheduler/jobs [get]
+func (h *SchedulerHandler) GetJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data:    h.schedulerService.List(),
+	})
+}
+
+// GetJob 获取定时任务详情
+// @Summary 获取定时任务详情
+// @Description 获取单个定时任务的调度规则与执行状态
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Param name path string true "任务名称"
+// @Success 200 {object} Response{data=service.ScheduledJobStatus} "定时任务"
+// @Failure 404 {object} ErrorResponse "任务不存在"
+// @Router /scheduler/jobs/{name} [get]
+func (h *SchedulerHandler) GetJob(c *gin.Context) {
+	h.respond(c, "获取定时任务失败", "获取成功", h.schedulerService.Get)
+}
+
+// PauseJob 暂停定时任务
+// @Summary 暂停定时任务
+// @Description 暂停后不再按计划执行，暂停状态在服务重启后保持
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Param name path string true "任务名称"
+// @Success 200 {object} Response{data=service.ScheduledJobStatus} "定时任务"
+// @Failure 404 {object} ErrorResponse "任务不存在"
+// @Router /scheduler/jobs/{name}/pause [post]
+func (h *SchedulerHandler) PauseJob(c *gin.Context) {
+	h.respond(c, "暂停定时任务失败", "已暂停", h.schedulerService.Pause)
+}
+
+// ResumeJob 恢复定时任务
+// @Summary 恢复定时任务
+// @Description 恢复按计划执行，从当前时间开始计算下一次执行时间
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Param name path string true "任务名称"
+// @Success 200 {object} Response{data=service.ScheduledJobStatus} "定时任务"
+// @Failure 404 {object} ErrorResponse "任务不存在"
+// @Router /scheduler/jobs/{name}/resume [post]
+func (h *SchedulerHandler) ResumeJob(c *gin.Context) {
+	h.respond(c, "恢复定时任务失败", "已恢复", h.schedulerService.Resume)
+}
+
+// TriggerJob 立即执行定时任务
+// @Summary 立即执行定时任务
+// @Description 在后台立即执行一次，不影响下一次计划执行时间，暂停的任务也可以手动执行
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Param name path string true "任务名称"
+// @Success 200 {object} Response{data=service.ScheduledJobStatus} "定时任务"
+// @Failure 404 {object} ErrorResponse "任务不存在"
+// @Failure 409 {object} ErrorResponse "任务正在执行"
+// @Router /scheduler/jobs/{name}/run [post]
+func (h *SchedulerHandler) TriggerJob(c *gin.Context) {
+	h.respond(c, "执行定时任务失败", "任务已开始执行", h.schedulerService.Trigger)
+}
+
+// respond 按任务名称调用 fn 并返回任务状态
+func (h *SchedulerHandler) respond(c *gin.Context, failMessage, okMessage string, fn func(name string) (*service.ScheduledJobStatus, error)) {
+	job, err := fn(c.Param("name"))
+	if err != nil {
+		status, code := schedulerErrorStatus(err)
+		c.JSON(status, ErrorResponse{
+			Code:    code,
+			Message: failMessage,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: okMessage,
+		Data:    job,
+	})
+}
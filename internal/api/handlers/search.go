@@ -33,6 +33,8 @@ type SearchRequest struct {
 	Limit   int    `form:"limit" json:"limit"`
 	Actress string `form:"actress" json:"actress"`
 	Code    string `form:"code" json:"code"`
+
+	HasChineseSubtitle *bool `form:"has_chinese_subtitle" json:"has_chinese_subtitle"` // 只搜索有（或没有）中文字幕的本地影片
 }
 
 // SearchResponse 搜索响应
@@ -56,6 +58,12 @@ type LocalMovieResult struct {
 	Format    string `json:"format"`
 	HasFanart bool   `json:"has_fanart"`
 	FanartURL string `json:"fanart_url"`
+
+	HasSubtitle        bool     `json:"has_subtitle"`
+	HasChineseSubtitle bool     `json:"has_chinese_subtitle"`
+	SubtitleLanguages  []string `json:"subtitle_languages"`
+	Duration           int      `json:"duration"` // 时长（秒）
+	Quality            string   `json:"quality"`
 }
 
 // RankingResult 排行榜搜索结果
@@ -143,6 +151,7 @@ func (h *SearchHandler) searchLocalMovies(req SearchRequest) ([]LocalMovieResult
 	offset := (req.Page - 1) * req.Limit
 	var movies []*model.LocalMovie
 	var err error
+	filter := repo.LocalMovieFilter{HasChineseSubtitle: req.HasChineseSubtitle}
 
 	// 根据搜索条件选择不同的查询方法
 	if req.Code != "" {
@@ -151,13 +160,16 @@ func (h *SearchHandler) searchLocalMovies(req SearchRequest) ([]LocalMovieResult
 		if err != nil {
 			return []LocalMovieResult{}, nil // 没找到不算错误
 		}
+		if req.HasChineseSubtitle != nil && movie.HasChineseSubtitle != *req.HasChineseSubtitle {
+			return []LocalMovieResult{}, nil
+		}
 		movies = []*model.LocalMovie{movie}
 	} else if req.Actress != "" {
 		// 按女优搜索
-		movies, _, err = h.localMovieRepo.SearchByActress(req.Actress, offset, req.Limit)
+		movies, _, err = h.localMovieRepo.SearchByActress(req.Actress, filter, offset, req.Limit)
 	} else if req.Query != "" {
 		// 综合搜索
-		movies, _, err = h.localMovieRepo.Search(req.Query, offset, req.Limit)
+		movies, _, err = h.localMovieRepo.SearchWithFilter(req.Query, filter, offset, req.Limit)
 	} else {
 		return []LocalMovieResult{}, nil
 	}
@@ -179,6 +191,12 @@ func (h *SearchHandler) searchLocalMovies(req SearchRequest) ([]LocalMovieResult
 			Format:    movie.Format,
 			HasFanart: movie.HasFanart,
 			FanartURL: movie.FanartURL,
+
+			HasSubtitle:        movie.HasSubtitle,
+			HasChineseSubtitle: movie.HasChineseSubtitle,
+			SubtitleLanguages:  splitLanguages(movie.SubtitleLanguages),
+			Duration:           movie.Duration,
+			Quality:            movie.Quality,
 		}
 		results = append(results, result)
 	}
@@ -35,7 +35,7 @@ func (h *StatsHandler) GetSystemStats(c *gin.Context) {
 	log.Printf("本地影片数量: %d", localMovieCount)
 
 	// 获取排行榜统计信息
-	rankingStats, err := h.rankingRepo.GetStatsByType()
+	rankingStats, err := h.rankingRepo.GetStatsByBoard()
 	if err != nil {
 		log.Printf("获取排行榜统计失败: %v", err)
 		c.Header("X-Debug-Ranking-Error", err.Error())
@@ -82,6 +82,7 @@ func (h *StatsHandler) GetSystemStats(c *gin.Context) {
 		"health_status":  "online",
 		"api_status":     "healthy",
 		"last_scan_time": lastScanTimeStr,
+		"boards":         rankingStats, // 所有榜单的统计，键为榜单标识
 	}
 
 	log.Printf("响应数据: %+v", responseData)
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"nsfw-go/internal/downloader"
 	"nsfw-go/internal/service"
 	"strings"
 
@@ -226,17 +227,15 @@ func (h *TorrentHandler) GetDownloadStatus(c *gin.Context) {
 	}
 
 	for _, torrent := range torrents {
-		if state, ok := torrent["state"].(string); ok {
-			switch state {
-			case "downloading", "metaDL", "allocating":
-				stats["downloading"]++
-			case "uploading", "stalledUP", "queuedUP":
-				stats["completed"]++
-			case "pausedDL", "pausedUP":
-				stats["paused"]++
-			case "error", "missingFiles":
-				stats["error"]++
-			}
+		switch torrent.State {
+		case downloader.StateDownloading:
+			stats["downloading"]++
+		case downloader.StateSeeding, downloader.StateCompleted:
+			stats["completed"]++
+		case downloader.StatePaused:
+			stats["paused"]++
+		case downloader.StateError:
+			stats["error"]++
 		}
 	}
 
@@ -247,9 +246,63 @@ func (h *TorrentHandler) GetDownloadStatus(c *gin.Context) {
 	})
 }
 
+// GetIndexers 获取索引器列表
+// @Summary 获取索引器列表
+// @Description 获取已启用的种子索引器，并通过 Torznab caps 接口查询每个索引器支持的搜索参数与分类
+// @Tags torrents
+// @Accept json
+// @Produce json
+// @Success 200 {object} Response{data=[]service.IndexerStatus} "索引器列表"
+// @Router /torrents/indexers [get]
+func (h *TorrentHandler) GetIndexers(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data:    h.torrentService.GetIndexers(c.Request.Context()),
+	})
+}
+
+// ExplainTorrentsForCode 获取番号种子的评分明细
+// @Summary 获取番号种子的评分明细
+// @Description 搜索指定番号的全部种子，按选择规则评分并返回每条规则的加减分与排除原因，不检查本地是否已存在
+// @Tags torrents
+// @Accept json
+// @Produce json
+// @Param code query string true "番号"
+// @Success 200 {object} Response{data=service.TorrentSelection} "评分明细"
+// @Failure 400 {object} ErrorResponse "参数错误"
+// @Failure 500 {object} ErrorResponse "搜索失败"
+// @Router /torrents/explain [get]
+func (h *TorrentHandler) ExplainTorrentsForCode(c *gin.Context) {
+	code := strings.TrimSpace(c.Query("code"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: "番号不能为空",
+		})
+		return
+	}
+
+	selection, err := h.torrentService.ExplainTorrentsForCode(code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Code:    "ERROR",
+			Message: "搜索种子失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    "SUCCESS",
+		Message: "获取成功",
+		Data:    selection,
+	})
+}
+
 // GetBestTorrentForCode 获取番号最佳种子
 // @Summary 获取番号最佳种子
-// @Description 搜索指定番号的种子并返回最大文件大小的种子（保证清晰度）
+// @Description 搜索指定番号的种子并返回选择规则得分最高的种子，评分明细见 /torrents/explain
 // @Tags torrents
 // @Accept json
 // @Produce json
@@ -313,7 +366,7 @@ func (h *TorrentHandler) GetBestTorrentForCode(c *gin.Context) {
 
 // DownloadBestTorrentForCode 下载番号最佳种子
 // @Summary 下载番号最佳种子
-// @Description 自动为指定番号搜索并下载最大文件大小的种子（保证清晰度）
+// @Description 自动为指定番号搜索并下载选择规则得分最高的种子
 // @Tags torrents
 // @Accept json
 // @Produce json
@@ -1,12 +1,17 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"nsfw-go/internal/api/handlers"
 	"nsfw-go/internal/crawler"
+	"nsfw-go/internal/downloader"
+	"nsfw-go/internal/indexer"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
 	"nsfw-go/internal/repo"
 	"nsfw-go/internal/service"
 	"strings"
@@ -38,14 +43,185 @@ func (a *LocalMovieAdapter) SearchByCode(code string) (*service.LocalMovie, erro
 	}, nil
 }
 
+// downloadClientDefaults 各下载器的默认地址与账号
+var downloadClientDefaults = map[string]downloader.Config{
+	downloader.TypeQBittorrent:  {Host: "http://your-qbittorrent-server:8080", Username: "admin", Password: "adminadmin"},
+	downloader.TypeTransmission: {Host: "http://127.0.0.1:9091"},
+	downloader.TypeAria2:        {Host: "http://127.0.0.1:6800"},
+	downloader.TypeDeluge:       {Host: "http://127.0.0.1:8112", Password: "deluge"},
+}
+
+// loadDownloadClientConfig 从数据库读取 torrent.client 选择的下载器配置（torrent.<client>.host 等），
+// configStoreService 为 nil 时返回 qBittorrent 默认配置
+func loadDownloadClientConfig(configStoreService *service.ConfigStoreService) downloader.Config {
+	clientType := downloader.TypeQBittorrent
+	if configStoreService != nil {
+		if config, err := configStoreService.GetConfig("torrent.client"); err == nil {
+			if value := strings.ToLower(strings.Trim(config.String(), "\"")); value != "" {
+				clientType = value
+			}
+		}
+	}
+
+	cfg := downloadClientDefaults[clientType]
+	cfg.Type = clientType
+	if configStoreService == nil {
+		return cfg
+	}
+	prefix := "torrent." + clientType + "."
+	if config, err := configStoreService.GetConfig(prefix + "host"); err == nil {
+		if host := strings.Trim(config.String(), "\""); host != "" {
+			cfg.Host = host
+		}
+	}
+	if config, err := configStoreService.GetConfig(prefix + "username"); err == nil {
+		cfg.Username = strings.Trim(config.String(), "\"")
+	}
+	// Aria2 使用 RPC 密钥代替密码
+	passwordKey := prefix + "password"
+	if clientType == downloader.TypeAria2 {
+		passwordKey = prefix + "secret"
+	}
+	if config, err := configStoreService.GetConfig(passwordKey); err == nil {
+		cfg.Password = strings.Trim(config.String(), "\"")
+	}
+	if config, err := configStoreService.GetConfig(prefix + "timeout"); err == nil {
+		if timeout, err := time.ParseDuration(strings.Trim(config.String(), "\"")); err == nil {
+			cfg.Timeout = timeout
+		}
+	}
+	return cfg
+}
+
+// loadDownloadRetryPolicy 读取下载失败的重试策略，未配置或无效的项使用默认值
+func loadDownloadRetryPolicy(configStoreService *service.ConfigStoreService) service.DownloadRetryPolicy {
+	policy := service.DefaultDownloadRetryPolicy()
+	if config, err := configStoreService.GetConfig("download.retry_count"); err == nil {
+		if count := config.Int(); count >= 0 {
+			policy.MaxRetries = count
+		}
+	}
+	for key, value := range map[string]*time.Duration{
+		"download.retry_delay":   &policy.Delay,
+		"download.stall_timeout": &policy.StallTimeout,
+	} {
+		if config, err := configStoreService.GetConfig(key); err == nil {
+			if d, err := time.ParseDuration(strings.Trim(config.String(), "\"")); err == nil && d >= 0 {
+				*value = d
+			}
+		}
+	}
+	return policy
+}
+
+// loadImportSettings 读取下载完成后导入媒体库的配置
+func loadImportSettings(configStoreService *service.ConfigStoreService) service.ImportSettings {
+	settings := service.DefaultImportSettings()
+	if config, err := configStoreService.GetConfig("download.import.enabled"); err == nil {
+		settings.Enabled = config.Bool()
+	}
+	if config, err := configStoreService.GetConfig("download.import.mode"); err == nil {
+		if mode := strings.Trim(config.String(), "\""); mode != "" {
+			settings.Mode = mode
+		}
+	}
+	if config, err := configStoreService.GetConfig("download.import.root"); err == nil {
+		settings.Root = strings.Trim(config.String(), "\"")
+	}
+	if config, err := configStoreService.GetConfig("download.import.min_size"); err == nil {
+		settings.MinVideoSize = int64(config.Int()) << 20
+	}
+	if config, err := configStoreService.GetConfig("download.import.path_mappings"); err == nil {
+		config.JSON(&settings.PathMappings)
+	}
+	return settings
+}
+
+// loadUpgradePolicy 读取已有影片的画质升级策略，未配置或无效的项使用默认值
+func loadUpgradePolicy(configStoreService *service.ConfigStoreService) service.UpgradePolicy {
+	policy := service.DefaultUpgradePolicy()
+	for key, value := range map[string]*bool{
+		"download.upgrade.enabled":           &policy.Enabled,
+		"download.upgrade.prefer_subtitle":   &policy.PreferSubtitle,
+		"download.upgrade.prefer_uncensored": &policy.PreferUncensored,
+	} {
+		if config, err := configStoreService.GetConfig(key); err == nil {
+			*value = config.Bool()
+		}
+	}
+	if config, err := configStoreService.GetConfig("download.upgrade.min_resolution"); err == nil {
+		if height := config.Int(); height >= 0 {
+			policy.MinResolution = height
+		}
+	}
+	if config, err := configStoreService.GetConfig("download.upgrade.action"); err == nil {
+		if action := strings.Trim(config.String(), "\""); action != "" {
+			policy.Action = action
+		}
+	}
+	if config, err := configStoreService.GetConfig("download.upgrade.archive_dir"); err == nil {
+		policy.ArchiveDir = strings.Trim(config.String(), "\"")
+	}
+	if config, err := configStoreService.GetConfig("download.upgrade.batch_size"); err == nil {
+		policy.BatchSize = config.Int()
+	}
+	if config, err := configStoreService.GetConfig("download.upgrade.recheck_interval"); err == nil {
+		if d, err := time.ParseDuration(strings.Trim(config.String(), "\"")); err == nil {
+			policy.RecheckInterval = d
+		}
+	}
+	return policy
+}
+
+// loadTorrentSelectionRules 从数据库读取种子选择规则（torrent.selection.*），大小配置单位为 MB
+func loadTorrentSelectionRules(configStoreService *service.ConfigStoreService) service.TorrentSelectionRules {
+	rules := service.DefaultTorrentSelectionRules()
+	if config, err := configStoreService.GetConfig("torrent.selection.require_code_match"); err == nil {
+		rules.RequireCodeMatch = config.Bool()
+	}
+	if config, err := configStoreService.GetConfig("torrent.selection.preferred_tags"); err == nil {
+		var tags []service.TorrentTag
+		if config.JSON(&tags) == nil && len(tags) > 0 {
+			rules.PreferredTags = tags
+		}
+	}
+	if config, err := configStoreService.GetConfig("torrent.selection.exclude_keywords"); err == nil {
+		config.JSON(&rules.ExcludeKeywords)
+	}
+	if config, err := configStoreService.GetConfig("torrent.selection.tracker_weights"); err == nil {
+		config.JSON(&rules.TrackerWeights)
+	}
+	if config, err := configStoreService.GetConfig("torrent.selection.min_size"); err == nil {
+		rules.MinSize = int64(config.Int()) << 20
+	}
+	if config, err := configStoreService.GetConfig("torrent.selection.max_size"); err == nil {
+		rules.MaxSize = int64(config.Int()) << 20
+	}
+	for key, value := range map[string]*int{
+		"torrent.selection.min_seeders":    &rules.MinSeeders,
+		"torrent.selection.max_age":        &rules.MaxAgeDays,
+		"torrent.selection.seeders_weight": &rules.SeedersWeight,
+		"torrent.selection.size_weight":    &rules.SizeWeight,
+		"torrent.selection.age_weight":     &rules.AgeWeight,
+	} {
+		if config, err := configStoreService.GetConfig(key); err == nil {
+			*value = config.Int()
+		}
+	}
+	return rules
+}
+
 // SetupRoutes 设置所有路由
 func SetupRoutes(r *gin.Engine, db *gorm.DB) {
-	// 清空数据库中的模拟数据
-	clearDatabaseData(db)
-
 	// 创建仓库
 	localMovieRepo := repo.NewLocalMovieRepository(db)
+	movieRepo := repo.NewMovieRepository(db)
+	actressRepo := repo.NewActressRepository(db)
+	studioRepo := repo.NewStudioRepository(db)
+	seriesRepo := repo.NewSeriesRepository(db)
+	tagRepo := repo.NewTagRepository(db)
 	rankingRepo := repo.NewRankingRepository(db)
+	actressRankingRepo := repo.NewActressRankingRepository(db)
 	rankingDownloadTaskRepo := repo.NewRankingDownloadTaskRepository(db)
 	subscriptionRepo := repo.NewSubscriptionRepository(db)
 
@@ -123,30 +299,51 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		jackettAPIKey = strings.Trim(config.String(), "\"")
 	}
 	
-	// 获取 qBittorrent 配置
-	qbittorrentHost := "http://your-qbittorrent-server:8080"
-	qbittorrentUser := "admin"
-	qbittorrentPass := "adminadmin"
-	if config, err := configStoreService.GetConfig("torrent.qbittorrent.host"); err == nil {
-		qbittorrentHost = strings.Trim(config.String(), "\"")
+	// 获取下载器配置，torrent.client 选择 qbittorrent、transmission、aria2 或 deluge
+	downloadClientConfig := loadDownloadClientConfig(configStoreService)
+	downloadClient, err := downloader.New(downloadClientConfig)
+	if err != nil {
+		log.Printf("⚠️  下载器配置无效，使用 qBittorrent: %v", err)
+		downloadClientConfig = loadDownloadClientConfig(nil)
+		downloadClient, _ = downloader.New(downloadClientConfig)
 	}
-	if config, err := configStoreService.GetConfig("torrent.qbittorrent.username"); err == nil {
-		qbittorrentUser = strings.Trim(config.String(), "\"")
+	
+	// 索引器：torrent.indexers 配置了 Torznab/Newznab 索引器时并行查询，否则使用 Jackett 聚合搜索
+	var indexers []indexer.Indexer
+	var indexerConfigs []indexer.Config
+	if config, err := configStoreService.GetConfig("torrent.indexers"); err == nil {
+		config.JSON(&indexerConfigs)
 	}
-	if config, err := configStoreService.GetConfig("torrent.qbittorrent.password"); err == nil {
-		qbittorrentPass = strings.Trim(config.String(), "\"")
+	for _, indexerConfig := range indexerConfigs {
+		if !indexerConfig.IsEnabled() {
+			continue
+		}
+		idx, err := indexer.New(indexerConfig)
+		if err != nil {
+			log.Printf("⚠️  索引器配置无效，已跳过: %v", err)
+			continue
+		}
+		indexers = append(indexers, idx)
+	}
+	if len(indexers) == 0 {
+		jackettIndexer, err := indexer.New(indexer.Config{Name: "jackett", Type: indexer.TypeJackett, URL: jackettHost, APIKey: jackettAPIKey})
+		if err != nil {
+			log.Printf("⚠️  Jackett 配置无效，没有可用的索引器: %v", err)
+		} else {
+			indexers = append(indexers, jackettIndexer)
+		}
 	}
 	
 	torrentService := service.NewTorrentService(
-		jackettHost,
-		jackettAPIKey,
-		qbittorrentHost,
-		qbittorrentUser,
-		qbittorrentPass,
+		indexer.NewSearcher(indexers...),
+		downloadClient,
 		localMovieAdapter,
 	)
 	
-	log.Printf("🔧 种子服务已创建 - Jackett: %s, qBittorrent: %s", jackettHost, qbittorrentHost)
+	log.Printf("🔧 种子服务已创建 - 索引器: %d 个, %s: %s", len(indexers), downloadClient.Name(), downloadClientConfig.Host)
+	
+	// 种子选择规则
+	torrentService.SetSelectionRules(loadTorrentSelectionRules(configStoreService))
 	
 	// 注入Telegram服务到种子下载服务
 	if telegramService != nil {
@@ -173,37 +370,177 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 	)
 	log.Printf("📥 排行榜下载服务已创建")
 
+	rankingDownloadService.SetBlocklistRepo(repo.NewTorrentBlocklistRepository(db))
+	rankingDownloadService.SetRetryPolicy(loadDownloadRetryPolicy(configStoreService))
+
 	// 记录系统启动相关日志
 	logService.LogInfo("system", "routes", "开始初始化服务路由")
 	logService.LogInfo("system", "database", "数据库连接已建立")
 	logService.LogInfo("system", "config", "配置服务已初始化")
 
-	// 创建扫描服务（从配置中获取媒体库路径）
-	mediaLibraryPath := "/media/default"
-	if config, err := configStoreService.GetConfig("media.base_path"); err == nil {
-		mediaLibraryPath = strings.Trim(config.String(), "\"")
+	// 创建扫描服务（从配置中获取媒体库根目录，未配置时使用 media.base_path）
+	var mediaRoots []model.MediaRoot
+	if config, err := configStoreService.GetConfig("media.roots"); err == nil {
+		if err := config.JSON(&mediaRoots); err != nil {
+			logService.LogError("scanner", "media-scan", "媒体库根目录配置解析失败: "+err.Error())
+		}
+	}
+	if len(mediaRoots) == 0 {
+		mediaLibraryPath := "/media/default"
+		if config, err := configStoreService.GetConfig("media.base_path"); err == nil {
+			mediaLibraryPath = strings.Trim(config.String(), "\"")
+		}
+		mediaRoots = []model.MediaRoot{{Name: "default", Path: mediaLibraryPath, Enabled: true}}
+	}
+	scannerService := service.NewScannerService(localMovieRepo, mediaRoots, logService)
+	scannerService.SetMovieRepository(movieRepo)
+	scannerService.SetHistoryRepository(repo.NewScanHistoryRepository(db))
+	scannerService.SetCatalogService(service.NewCatalogService(movieRepo, actressRepo, studioRepo, seriesRepo, tagRepo, logService))
+	watchEnabled := true
+	if config, err := configStoreService.GetConfig("media.watch_enabled"); err == nil {
+		watchEnabled = config.Bool()
+	}
+	scannerService.SetWatchEnabled(watchEnabled)
+
+	// 番号识别规则，自定义规则优先于内置规则
+	var codeRules []moviecode.Rule
+	var codeIgnorePrefixes []string
+	if config, err := configStoreService.GetConfig("code.rules"); err == nil {
+		config.JSON(&codeRules)
+	}
+	if config, err := configStoreService.GetConfig("code.ignore_prefixes"); err == nil {
+		config.JSON(&codeIgnorePrefixes)
+	}
+	if err := moviecode.Configure(codeRules, codeIgnorePrefixes); err != nil {
+		logService.LogError("system", "movie-code", "番号规则配置无效，使用内置规则: "+err.Error())
+	}
+
+	// 目录布局与文件过滤条件
+	scanOptions := service.DefaultScanOptions()
+	if config, err := configStoreService.GetConfig("media.layout"); err == nil {
+		scanOptions.Layout = strings.Trim(config.String(), "\"")
+	}
+	if config, err := configStoreService.GetConfig("media.layouts"); err == nil {
+		config.JSON(&scanOptions.Layouts)
+	}
+	if config, err := configStoreService.GetConfig("media.exclude"); err == nil {
+		config.JSON(&scanOptions.Exclude)
+	}
+	if config, err := configStoreService.GetConfig("media.supported_exts"); err == nil {
+		config.JSON(&scanOptions.SupportedExts)
+	}
+	if config, err := configStoreService.GetConfig("media.min_file_size"); err == nil {
+		scanOptions.MinFileSize = int64(config.Int())
+	}
+	if config, err := configStoreService.GetConfig("media.max_file_size"); err == nil {
+		scanOptions.MaxFileSize = int64(config.Int())
+	}
+	if err := scannerService.SetScanOptions(scanOptions); err != nil {
+		logService.LogError("scanner", "media-scan", "部分媒体库根目录配置无效，已跳过: "+err.Error())
 	}
-	scannerService := service.NewScannerService(localMovieRepo, mediaLibraryPath, logService)
 
 	// 创建排行榜服务（现在 logService 已经创建）
-	rankingService := service.NewRankingService(crawlerConfig, rankingRepo, localMovieRepo, logService)
+	rankingService := service.NewRankingService(crawlerConfig, rankingRepo, actressRankingRepo, localMovieRepo, logService)
+	var rankingBoards []crawler.RankingBoard
+	if config, err := configStoreService.GetConfig("ranking.boards"); err == nil {
+		config.JSON(&rankingBoards)
+	}
+	if err := rankingService.SetBoards(rankingBoards); err != nil {
+		logService.LogError("crawler", "ranking", "榜单配置无效，使用默认榜单: "+err.Error())
+	}
+	// 旧的排行榜记录没有榜单标识，补充后才能按榜单查询
+	if err := rankingRepo.BackfillBoards(); err != nil {
+		logService.LogError("crawler", "ranking", "补充排行榜记录的榜单标识失败: "+err.Error())
+	}
+	// 本地影片的番号等价键用于排行榜匹配，旧记录需要先补充；媒体库有变化后立即更新本地存在状态
+	if count, err := scannerService.BackfillCodeKeys(); err != nil {
+		logService.LogError("scanner", "media-scan", "补充番号等价键失败: "+err.Error())
+	} else if count > 0 {
+		logService.LogInfo("scanner", "media-scan", fmt.Sprintf("已为 %d 部本地影片补充番号等价键", count))
+	}
+	scannerService.OnLibraryChanged(func() {
+		rankingService.CheckLocalExists(context.Background())
+	})
 
 	// 创建JAVDb搜索服务（现在 logService 已经创建）
 	javdbSearchService := service.NewJAVDbSearchService(crawlerConfig, logService)
 
 	// 启动服务
-	logService.LogInfo("scanner", "media-scan", "启动媒体库扫描服务，路径: "+mediaLibraryPath)
+	logService.LogInfo("scanner", "media-scan", fmt.Sprintf("启动媒体库扫描服务，共 %d 个根目录", len(scannerService.Roots())))
 	scannerService.Start()
 
-	logService.LogInfo("crawler", "ranking", "启动排行榜爬虫服务")
-	rankingService.Start()
+	// 定时任务调度：排行榜爬取、本地检查与各媒体库根目录的定时扫描，调度规则可通过 scheduler.jobs 覆盖
+	schedulerService := service.NewSchedulerService(repo.NewScheduledJobRepository(db), logService)
+	if config, err := configStoreService.GetConfig("scheduler.timezone"); err == nil {
+		if err := schedulerService.SetTimezone(strings.Trim(config.String(), "\"")); err != nil {
+			logService.LogError("system", "scheduler", "默认时区配置无效，使用服务器时区: "+err.Error())
+		}
+	}
+	var scheduledJobConfigs map[string]model.ScheduledJobConfig
+	if config, err := configStoreService.GetConfig("scheduler.jobs"); err == nil {
+		config.JSON(&scheduledJobConfigs)
+	}
+	schedulerService.SetJobConfigs(scheduledJobConfigs)
+	scheduledJobs := []service.ScheduledJobSpec{
+		{Name: "ranking-crawl", Description: "爬取已配置的JAVDb影片榜单与女优榜单", Cron: "0 12 * * *", CatchUp: true, Run: rankingService.ScheduledCrawl},
+		{Name: "ranking-check", Description: "检查排行榜影片是否已在本地", Cron: "0 * * * *", CatchUp: true, Run: rankingService.ScheduledCheck},
+		{Name: "download-sync", Description: "从下载器同步下载任务的进度与状态", Cron: "@every 30s", Run: rankingDownloadService.SyncDownloadProgress},
+		{Name: "download-upgrade", Description: "为画质低于升级策略的本地影片搜索更好的版本并排队下载", Cron: "0 4 * * *", Run: rankingDownloadService.CheckUpgrades},
+	}
+	for _, root := range scannerService.Roots() {
+		scheduledJobs = append(scheduledJobs, service.ScheduledJobSpec{
+			Name:        "media-scan-" + root.Name,
+			Description: "扫描媒体库 " + root.Name,
+			Cron:        fmt.Sprintf("@every %s", scannerService.ScanInterval(root.Name)),
+			Run:         scannerService.ScheduledScan(root.Name),
+		})
+	}
+	for _, job := range scheduledJobs {
+		if err := schedulerService.Register(job); err != nil {
+			logService.LogError("system", "scheduler", "注册定时任务失败: "+err.Error())
+		}
+	}
+	schedulerService.Start()
 
 	// 创建处理器
 	logService.LogInfo("system", "handlers", "初始化API处理器")
-	localHandler := handlers.NewLocalHandler(localMovieRepo, scannerService, mediaLibraryPath)
+	duplicateService := service.NewDuplicateService(localMovieRepo)
+	nfoWriterService := service.NewNFOWriterService(localMovieRepo, movieRepo, logService)
+	nfoWriterService.SetLibraryRoots(scannerService.Roots())
+	nfoWriterService.SetCrawler(crawler.NewJAVDbCrawler(crawlerConfig))
+	localHandler := handlers.NewLocalHandler(localMovieRepo, scannerService, duplicateService, nfoWriterService)
+	organizerService := service.NewOrganizerService(localMovieRepo, movieRepo, repo.NewOrganizeRepository(db), scannerService, logService)
+	if config, err := configStoreService.GetConfig("media.organize_template"); err == nil {
+		if err := organizerService.SetTemplate(strings.Trim(config.String(), "\"")); err != nil {
+			logService.LogError("scanner", "media-organize", "整理模板配置无效，使用默认模板: "+err.Error())
+		}
+	}
+	organizeHandler := handlers.NewOrganizeHandler(organizerService)
+
+	// 下载完成后按整理模板导入媒体库
+	importService := service.NewImportService(localMovieRepo, rankingRepo, scannerService, organizerService, nfoWriterService, logService)
+	if err := importService.SetSettings(loadImportSettings(configStoreService)); err != nil {
+		logService.LogError("torrent", "download-import", "导入配置无效，使用默认配置: "+err.Error())
+	}
+	rankingDownloadService.SetImportService(importService)
+	// 归档目录不能位于媒体库中，需要先设置媒体库根目录
+	rankingDownloadService.SetLibraryRoots(scannerService.Roots())
+	if err := rankingDownloadService.SetUpgradePolicy(loadUpgradePolicy(configStoreService)); err != nil {
+		logService.LogError("torrent", "download-upgrade", "画质升级配置无效，不启用画质升级: "+err.Error())
+	}
+	rankingDownloadService.ResumeImports(context.Background())
+
+	// 启动下载队列，同时执行的任务数由 download.max_concurrent 控制
+	// 番号规则、导入服务与升级策略都设置后再启动，恢复的任务才会使用自定义配置
+	if config, err := configStoreService.GetConfig("download.max_concurrent"); err == nil {
+		rankingDownloadService.SetMaxConcurrent(config.Int())
+	}
+	rankingDownloadService.StartQueue(context.Background())
+
+	schedulerHandler := handlers.NewSchedulerHandler(schedulerService)
 	statsHandler := handlers.NewStatsHandler(localMovieRepo, rankingRepo)
 	rankingHandler := handlers.NewRankingHandler(rankingService)
-	rankingDownloadHandler := handlers.NewRankingDownloadHandler(rankingDownloadService)
+	rankingDownloadHandler := handlers.NewRankingDownloadHandler(rankingDownloadService, rankingService)
 	searchHandler := handlers.NewSearchHandler(localMovieRepo, rankingRepo)
 	javdbSearchHandler := handlers.NewJAVDbSearchHandler(javdbSearchService)
 	configHandler := handlers.NewConfigHandler(configService, telegramService)
@@ -218,8 +555,10 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 	} else {
 		logService.LogWarn("system", "telegram", "Telegram通知服务未配置或已禁用")
 	}
-	logService.LogInfo("torrent", "jackett", "Jackett搜索服务配置: "+jackettHost)
-	logService.LogInfo("torrent", "qbittorrent", "qBittorrent下载服务配置: "+qbittorrentHost)
+	for _, idx := range indexers {
+		logService.LogInfo("torrent", "indexer", "种子索引器: "+idx.Name())
+	}
+	logService.LogInfo("torrent", downloadClient.Name(), "下载器配置: "+downloadClient.Name()+" "+downloadClientConfig.Host)
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
@@ -243,16 +582,37 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				local.GET("/search", localHandler.SearchLocalMovies)   // 搜索本地影片
 				local.GET("/stats", localHandler.GetLocalMovieStats)   // 获取本地影片统计
 				local.GET("/image/*filepath", localHandler.ServeImage) // 提供图片服务
+				local.GET("/roots", localHandler.GetLibraryRoots)      // 获取媒体库根目录
+				local.GET("/duplicates", localHandler.GetDuplicates)   // 获取重复影片报告
+				local.POST("/movies/:id/nfo", localHandler.WriteNFO)   // 写入单部影片的NFO与图片
+				local.POST("/nfo", localHandler.WriteMissingNFO)       // 批量写入缺失的NFO
+
+				// 扫描任务
+				local.GET("/scan/current", localHandler.GetCurrentScan)           // 获取扫描进度
+				local.POST("/scan/cancel", localHandler.CancelScan)               // 取消扫描
+				local.GET("/scan/history", localHandler.GetScanHistory)           // 获取扫描记录
+				local.GET("/scan/history/:id", localHandler.GetScanHistoryDetail) // 获取扫描记录详情
+
+				// 媒体库整理
+				local.POST("/organize/plan", organizeHandler.PlanOrganize)          // 预览整理计划
+				local.POST("/organize/execute", organizeHandler.ExecuteOrganize)    // 执行整理
+				local.GET("/organize/runs", organizeHandler.GetOrganizeRuns)        // 获取整理记录
+				local.GET("/organize/runs/:id", organizeHandler.GetOrganizeRun)     // 获取整理记录详情
+				local.POST("/organize/runs/:id/undo", organizeHandler.UndoOrganize) // 撤销整理
 			}
 
 			// 排行榜相关路由
 			rankings := v1.Group("/rankings")
 			{
-				rankings.GET("", rankingHandler.GetRankings)           // 获取排行榜
-				rankings.GET("/stats", rankingHandler.GetRankingStats) // 获取排行榜统计
-				rankings.GET("/local", rankingHandler.GetLocalExists)  // 获取本地已存在的排行榜影片
-				rankings.POST("/crawl", rankingHandler.TriggerCrawl)   // 手动触发爬取
-				rankings.POST("/check", rankingHandler.TriggerCheck)   // 手动触发本地检查
+				rankings.GET("", rankingHandler.GetRankings)                     // 获取排行榜
+				rankings.GET("/boards", rankingHandler.GetBoards)                // 获取已配置的榜单
+				rankings.GET("/actresses", rankingHandler.GetActressRankings)    // 获取女优排行榜
+				rankings.GET("/stats", rankingHandler.GetRankingStats)           // 获取排行榜统计
+				rankings.GET("/local", rankingHandler.GetLocalExists)            // 获取本地已存在的排行榜影片
+				rankings.GET("/trends", rankingHandler.GetRankingTrends)         // 获取排行榜趋势
+				rankings.GET("/history/:code", rankingHandler.GetRankingHistory) // 获取番号的排行榜历史
+				rankings.POST("/crawl", rankingHandler.TriggerCrawl)             // 手动触发爬取
+				rankings.POST("/check", rankingHandler.TriggerCheck)             // 手动触发本地检查
 
 				// 下载任务相关
 				rankings.POST("/download", rankingDownloadHandler.StartDownload)                        // 开始下载任务
@@ -260,8 +620,11 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				rankings.GET("/download-tasks", rankingDownloadHandler.GetDownloadTasks)                // 获取任务列表
 				rankings.DELETE("/download-tasks/:id", rankingDownloadHandler.CancelTask)               // 取消任务
 				rankings.POST("/download-tasks/:id/retry", rankingDownloadHandler.RetryTask)            // 重试任务
+				rankings.POST("/download-tasks/:id/import", rankingDownloadHandler.ImportTask)          // 导入媒体库
 				rankings.GET("/download-stats", rankingDownloadHandler.GetTaskStats)                    // 获取任务统计
 				rankings.PUT("/download-tasks/:code/progress", rankingDownloadHandler.UpdateTaskProgress) // 更新任务进度
+				rankings.GET("/blocklist", rankingDownloadHandler.GetBlocklist)                           // 种子黑名单
+				rankings.DELETE("/blocklist/:id", rankingDownloadHandler.RemoveFromBlocklist)             // 移出黑名单
 
 				// 订阅下载相关
 				rankings.GET("/subscriptions", rankingDownloadHandler.GetSubscriptions)                       // 获取所有订阅配置
@@ -270,6 +633,16 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				rankings.POST("/subscription/:rank_type/run", rankingDownloadHandler.RunSubscriptionDownload) // 执行订阅下载
 			}
 
+			// 定时任务
+			scheduler := v1.Group("/scheduler")
+			{
+				scheduler.GET("/jobs", schedulerHandler.GetJobs)                 // 获取定时任务列表
+				scheduler.GET("/jobs/:name", schedulerHandler.GetJob)            // 获取定时任务详情
+				scheduler.POST("/jobs/:name/pause", schedulerHandler.PauseJob)   // 暂停定时任务
+				scheduler.POST("/jobs/:name/resume", schedulerHandler.ResumeJob) // 恢复定时任务
+				scheduler.POST("/jobs/:name/run", schedulerHandler.TriggerJob)   // 立即执行定时任务
+			}
+
 			// 统计信息路由
 			v1.GET("/stats", statsHandler.GetSystemStats)
 
@@ -324,7 +697,9 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 			{
 				torrents.GET("/search", torrentHandler.SearchTorrents)             // 基础搜索（支持任意关键词）
 				torrents.GET("/search/code", torrentHandler.SearchTorrentsForCode) // 按番号搜索（检查本地是否存在）
-				torrents.GET("/best", torrentHandler.GetBestTorrentForCode)        // 获取番号最佳种子（最大文件）
+				torrents.GET("/best", torrentHandler.GetBestTorrentForCode)        // 获取番号最佳种子（选择规则得分最高）
+				torrents.GET("/explain", torrentHandler.ExplainTorrentsForCode)    // 番号种子的评分明细
+				torrents.GET("/indexers", torrentHandler.GetIndexers)              // 索引器及其能力
 				torrents.POST("/download", torrentHandler.DownloadTorrent)         // 下载种子
 				torrents.POST("/download/best", torrentHandler.DownloadBestTorrentForCode) // 下载番号最佳种子
 				torrents.GET("/list", torrentHandler.GetTorrentList)               // 获取下载列表
@@ -387,29 +762,3 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		c.File("./web/dist/index.html")
 	})
 }
-
-// clearDatabaseData 清空数据库中的模拟数据
-func clearDatabaseData(db *gorm.DB) {
-	// 清空所有模拟数据表
-	db.Exec("DELETE FROM movies")
-	db.Exec("DELETE FROM actresses")
-	db.Exec("DELETE FROM studios")
-	db.Exec("DELETE FROM series")
-	db.Exec("DELETE FROM tags")
-	db.Exec("DELETE FROM users")
-	db.Exec("DELETE FROM watch_history")
-	db.Exec("DELETE FROM favorites")
-	db.Exec("DELETE FROM movie_actresses")
-	db.Exec("DELETE FROM movie_tags")
-
-	// 重置自增ID
-	db.Exec("ALTER SEQUENCE movies_id_seq RESTART WITH 1")
-	db.Exec("ALTER SEQUENCE actresses_id_seq RESTART WITH 1")
-	db.Exec("ALTER SEQUENCE studios_id_seq RESTART WITH 1")
-	db.Exec("ALTER SEQUENCE series_id_seq RESTART WITH 1")
-	db.Exec("ALTER SEQUENCE tags_id_seq RESTART WITH 1")
-	db.Exec("ALTER SEQUENCE users_id_seq RESTART WITH 1")
-
-	// 注意：不清空 local_movies 和 rankings 表，因为这是我们的缓存数据
-}
-
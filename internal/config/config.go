@@ -10,14 +10,19 @@ import (
 
 // Config 系统配置结构
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	Bot      BotConfig      `mapstructure:"bot"`
-	Crawler  CrawlerConfig  `mapstructure:"crawler"`
-	Media    MediaConfig    `mapstructure:"media"`
-	Security SecurityConfig `mapstructure:"security"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	Bot       BotConfig       `mapstructure:"bot"`
+	Crawler   CrawlerConfig   `mapstructure:"crawler"`
+	Media     MediaConfig     `mapstructure:"media"`
+	Code      CodeConfig      `mapstructure:"code"`
+	Ranking   RankingConfig   `mapstructure:"ranking"`
+	Torrent   TorrentConfig   `mapstructure:"torrent"`
+	Download  DownloadConfig  `mapstructure:"download"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Security  SecurityConfig  `mapstructure:"security"`
+	Log       LogConfig       `mapstructure:"log"`
 }
 
 // ServerConfig HTTP服务器配置
@@ -75,11 +80,148 @@ type CrawlerConfig struct {
 
 // MediaConfig 媒体库配置
 type MediaConfig struct {
-	BasePath      string   `mapstructure:"base_path"`
-	ScanInterval  int      `mapstructure:"scan_interval"` // 小时
-	SupportedExts []string `mapstructure:"supported_exts"`
-	MinFileSize   int64    `mapstructure:"min_file_size"` // MB
-	MaxFileSize   int64    `mapstructure:"max_file_size"` // MB
+	BasePath         string            `mapstructure:"base_path"`
+	ScanInterval     int               `mapstructure:"scan_interval"` // 小时
+	SupportedExts    []string          `mapstructure:"supported_exts"`
+	MinFileSize      int64             `mapstructure:"min_file_size"`     // MB
+	MaxFileSize      int64             `mapstructure:"max_file_size"`     // MB
+	WatchEnabled     bool              `mapstructure:"watch_enabled"`     // 实时监听目录变化
+	Layout           string            `mapstructure:"layout"`            // 布局名称或路径模板，如 {actress}/{code} {title}、flat、recursive
+	Layouts          map[string]string `mapstructure:"layouts"`           // 自定义布局：名称 -> 布局定义
+	Exclude          []string          `mapstructure:"exclude"`           // 排除规则（glob）
+	OrganizeTemplate string            `mapstructure:"organize_template"` // 整理路径模板，如 {actress}/[{code}] {title}/{code}.{ext}
+	Roots            []MediaRootConfig `mapstructure:"roots"`             // 多个媒体库根目录，为空时使用 BasePath
+}
+
+// MediaRootConfig 媒体库根目录配置
+type MediaRootConfig struct {
+	Name         string   `mapstructure:"name" json:"name"`
+	Path         string   `mapstructure:"path" json:"path"`
+	Layout       string   `mapstructure:"layout" json:"layout"`
+	Exclude      []string `mapstructure:"exclude" json:"exclude"`
+	ScanInterval int      `mapstructure:"scan_interval" json:"scan_interval"` // 分钟
+	Enabled      bool     `mapstructure:"enabled" json:"enabled"`
+}
+
+// CodeConfig 番号识别配置
+type CodeConfig struct {
+	Rules          []CodeRuleConfig `mapstructure:"rules"`           // 自定义规则，优先于内置规则
+	IgnorePrefixes []string         `mapstructure:"ignore_prefixes"` // 不是番号的前缀
+}
+
+// CodeRuleConfig 番号识别规则
+type CodeRuleConfig struct {
+	Name      string  `mapstructure:"name" json:"name"`
+	Pattern   string  `mapstructure:"pattern" json:"pattern"`               // 正则表达式，必须包含 number 命名分组，可包含 prefix 分组
+	Prefix    string  `mapstructure:"prefix" json:"prefix"`                 // 固定前缀
+	Separator *string `mapstructure:"separator" json:"separator,omitempty"` // 前缀与编号之间的分隔符，未设置时为 "-"，"" 表示不使用分隔符
+	Digits    int     `mapstructure:"digits" json:"digits"`                 // 编号位数
+}
+
+// RankingConfig 排行榜配置
+type RankingConfig struct {
+	Boards []RankingBoardConfig `mapstructure:"boards"` // 要爬取的榜单，为空时使用默认榜单
+}
+
+// RankingBoardConfig 排行榜来源，key 与内置榜单相同时未填写的字段使用内置值
+type RankingBoardConfig struct {
+	Key      string `mapstructure:"key"`      // 榜单标识，如 daily、uncensored-weekly、actress-censored
+	Name     string `mapstructure:"name"`     // 显示名称
+	Kind     string `mapstructure:"kind"`     // movies 或 actors
+	Category string `mapstructure:"category"` // censored、uncensored、western、fc2
+	Period   string `mapstructure:"period"`   // daily、weekly、monthly
+	Pages    int    `mapstructure:"pages"`    // 爬取页数
+	Path     string `mapstructure:"path"`     // 自定义页面路径
+}
+
+// TorrentConfig 种子配置
+type TorrentConfig struct {
+	Indexers  []TorrentIndexerConfig `mapstructure:"indexers"` // 为空时使用 Web 配置中 Jackett 的聚合搜索
+	Selection TorrentSelectionConfig `mapstructure:"selection"`
+}
+
+// TorrentIndexerConfig 种子索引器
+type TorrentIndexerConfig struct {
+	Name       string        `mapstructure:"name" json:"name"`
+	Type       string        `mapstructure:"type" json:"type"`             // torznab（默认）、newznab、jackett
+	URL        string        `mapstructure:"url" json:"url"`               // Torznab 接口地址，如 http://prowlarr:9696/1/api
+	APIKey     string        `mapstructure:"api_key" json:"api_key"`       // API 密钥
+	Categories []int         `mapstructure:"categories" json:"categories"` // 搜索分类，为空时使用 6000（XXX）及其子分类
+	Timeout    time.Duration `mapstructure:"timeout" json:"timeout"`       // 请求超时，默认 30 秒
+	Enabled    *bool         `mapstructure:"enabled" json:"enabled"`       // 为空时启用
+}
+
+// TorrentSelectionConfig 种子选择规则，自动下载时选择未被排除且得分最高的种子
+type TorrentSelectionConfig struct {
+	RequireCodeMatch bool               `mapstructure:"require_code_match"` // 标题中必须包含同一番号
+	PreferredTags    []TorrentTagConfig `mapstructure:"preferred_tags"`     // 偏好标签，为空时使用内置标签
+	ExcludeKeywords  []string           `mapstructure:"exclude_keywords"`   // 标题包含任一关键词时排除
+	MinSize          int64              `mapstructure:"min_size"`           // MB，0 表示不限制
+	MaxSize          int64              `mapstructure:"max_size"`           // MB，0 表示不限制
+	MinSeeders       int                `mapstructure:"min_seeders"`
+	MaxAge           int                `mapstructure:"max_age"`         // 发布天数上限，0 表示不限制
+	TrackerWeights   map[string]int     `mapstructure:"tracker_weights"` // 按索引器名称加减分
+	SeedersWeight    int                `mapstructure:"seeders_weight"`  // 做种数得分上限
+	SizeWeight       int                `mapstructure:"size_weight"`     // 文件大小得分上限
+	AgeWeight        int                `mapstructure:"age_weight"`      // 发布时间得分上限，越新得分越高
+}
+
+// TorrentTagConfig 种子偏好标签，标题包含任一关键词或番号带有任一变体后缀时加分
+type TorrentTagConfig struct {
+	Name     string   `mapstructure:"name" json:"name"`
+	Keywords []string `mapstructure:"keywords" json:"keywords"`
+	Variants []string `mapstructure:"variants" json:"variants"` // 番号变体后缀，如 C、U、UC、4K
+	Weight   int      `mapstructure:"weight" json:"weight"`     // 负数表示减分
+}
+
+// DownloadConfig 下载队列与重试配置
+type DownloadConfig struct {
+	MaxConcurrent int                   `mapstructure:"max_concurrent"` // 同时搜索并添加到下载器的任务数
+	RetryCount    int                   `mapstructure:"retry_count"`    // 失败后自动换种重试的次数
+	RetryDelay    string                `mapstructure:"retry_delay"`    // 第一次重试的等待时间，之后每次翻倍，最长24小时
+	StallTimeout  string                `mapstructure:"stall_timeout"`  // 下载进度超过该时间没有增长时换种，0 表示不检测
+	Import        DownloadImportConfig  `mapstructure:"import"`
+	Upgrade       DownloadUpgradeConfig `mapstructure:"upgrade"`
+}
+
+// DownloadImportConfig 下载完成后导入媒体库的配置
+type DownloadImportConfig struct {
+	Enabled      bool                `mapstructure:"enabled"`
+	Mode         string              `mapstructure:"mode"`          // move、copy 或 hardlink（默认，跨文件系统时改为复制）
+	Root         string              `mapstructure:"root"`          // 导入的媒体库根目录名称，为空时使用第一个根目录
+	MinSize      int64               `mapstructure:"min_size"`      // MB，更小的视频视为预览
+	PathMappings []ImportPathMapping `mapstructure:"path_mappings"` // 下载器与本服务看到的路径不同时的映射
+}
+
+// DownloadUpgradeConfig 已有影片的画质升级配置
+type DownloadUpgradeConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	MinResolution    int    `mapstructure:"min_resolution"`    // 分辨率下限（高度），低于该值时寻找更高分辨率的版本，0 表示不按分辨率升级
+	PreferSubtitle   bool   `mapstructure:"prefer_subtitle"`   // 没有中文字幕时寻找中文字幕版本
+	PreferUncensored bool   `mapstructure:"prefer_uncensored"` // 不是无码版本时寻找无码流出版本
+	Action           string `mapstructure:"action"`            // 旧文件处理方式：archive（默认，移动到归档目录）或 replace（删除）
+	ArchiveDir       string `mapstructure:"archive_dir"`       // 归档目录，不能位于媒体库中
+	BatchSize        int    `mapstructure:"batch_size"`        // 每次检查的影片数
+	RecheckInterval  string `mapstructure:"recheck_interval"`  // 同一部影片再次检查的间隔
+}
+
+// ImportPathMapping 下载路径映射
+type ImportPathMapping struct {
+	From string `mapstructure:"from" json:"from"` // 下载器中的路径前缀
+	To   string `mapstructure:"to" json:"to"`     // 本服务中的路径前缀
+}
+
+// SchedulerConfig 定时任务配置
+type SchedulerConfig struct {
+	Timezone string                        `mapstructure:"timezone"` // 默认时区，为空时使用服务器时区
+	Jobs     map[string]SchedulerJobConfig `mapstructure:"jobs"`     // 按任务名称覆盖调度规则
+}
+
+// SchedulerJobConfig 单个定时任务的调度配置
+type SchedulerJobConfig struct {
+	Cron     string `mapstructure:"cron" json:"cron,omitempty"`
+	Timezone string `mapstructure:"timezone" json:"timezone,omitempty"`
+	CatchUp  *bool  `mapstructure:"catch_up" json:"catch_up,omitempty"`
 }
 
 // SecurityConfig 安全配置
@@ -300,6 +442,33 @@ func setDefaults() {
 	viper.SetDefault("media.supported_exts", []string{".mp4", ".mkv", ".avi", ".mov", ".wmv"})
 	viper.SetDefault("media.min_file_size", 100)   // 100MB
 	viper.SetDefault("media.max_file_size", 10240) // 10GB
+	viper.SetDefault("media.watch_enabled", true)
+	viper.SetDefault("media.layout", "{actress}/{dir}")
+	viper.SetDefault("media.organize_template", "{actress}/[{code}] {title}/{code}.{ext}")
+
+	// Torrent selection defaults
+	viper.SetDefault("torrent.selection.require_code_match", true)
+	viper.SetDefault("torrent.selection.min_size", 200)   // 200MB
+	viper.SetDefault("torrent.selection.max_size", 12288) // 12GB
+	viper.SetDefault("torrent.selection.min_seeders", 1)
+	viper.SetDefault("torrent.selection.seeders_weight", 30)
+	viper.SetDefault("torrent.selection.size_weight", 10)
+
+	// Download queue defaults
+	viper.SetDefault("download.max_concurrent", 3)
+	viper.SetDefault("download.retry_count", 3)
+	viper.SetDefault("download.retry_delay", "5m")
+	viper.SetDefault("download.stall_timeout", "2h")
+	viper.SetDefault("download.import.enabled", false)
+	viper.SetDefault("download.import.mode", "hardlink")
+	viper.SetDefault("download.import.min_size", 100) // 100MB
+	viper.SetDefault("download.upgrade.enabled", false)
+	viper.SetDefault("download.upgrade.min_resolution", 1080)
+	viper.SetDefault("download.upgrade.prefer_subtitle", true)
+	viper.SetDefault("download.upgrade.prefer_uncensored", false)
+	viper.SetDefault("download.upgrade.action", "archive")
+	viper.SetDefault("download.upgrade.batch_size", 20)
+	viper.SetDefault("download.upgrade.recheck_interval", "168h")
 
 	// Security defaults
 	viper.SetDefault("security.jwt_secret", "your-secret-key-change-it")
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net/http"
 	"net/url"
+	"nsfw-go/internal/moviecode"
 	"regexp"
 	"strings"
 	"time"
@@ -143,25 +144,11 @@ func (bc *BaseCrawler) IsHealthy(ctx context.Context) bool {
 	return true
 }
 
-// ExtractMovieCode 从字符串中提取影片番号
+// ExtractMovieCode 从字符串中提取影片番号（标准写法），无法识别时返回空字符串
 func (bc *BaseCrawler) ExtractMovieCode(text string) string {
-	// 常见的番号格式匹配
-	patterns := []string{
-		`([A-Z]{2,5}-?\d{3,4})`, // SSIS-001, ABP999, MIDE-123等
-		`([A-Z]{1,3}\d{2,4})`,   // T28-123, S2M-123等
-		`(\d{6}[-_]\d{3})`,      // 数字型番号
-		`([A-Z]+\d+[A-Z]*)`,     // 其他变体
-	}
-
-	text = strings.ToUpper(strings.TrimSpace(text))
-
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		if matches := re.FindStringSubmatch(text); len(matches) > 1 {
-			return matches[1]
-		}
+	if code, ok := moviecode.Parse(text); ok {
+		return code.String()
 	}
-
 	return ""
 }
 
@@ -170,29 +157,7 @@ func (bc *BaseCrawler) NormalizeMovieCode(code string) string {
 	if code == "" {
 		return ""
 	}
-
-	// 转换为大写并去除空格
-	code = strings.ToUpper(strings.TrimSpace(code))
-
-	// 移除多余的分隔符
-	code = strings.ReplaceAll(code, "--", "-")
-	code = strings.ReplaceAll(code, "__", "_")
-
-	// 标准化常见格式
-	re := regexp.MustCompile(`([A-Z]+)[-_]?(\d+)`)
-	if matches := re.FindStringSubmatch(code); len(matches) == 3 {
-		prefix := matches[1]
-		number := matches[2]
-
-		// 补零到3位数
-		for len(number) < 3 {
-			number = "0" + number
-		}
-
-		return prefix + "-" + number
-	}
-
-	return code
+	return moviecode.Normalize(code)
 }
 
 // ParseRating 解析评分
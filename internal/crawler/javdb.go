@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"nsfw-go/internal/moviecode"
 	"strings"
 	"time"
 
@@ -113,10 +114,8 @@ func (jc *JAVDbCrawler) GetMovieByCode(ctx context.Context, code string) (*Movie
 	}
 
 	// 找到匹配的影片
-	normalizedCode := jc.NormalizeMovieCode(code)
 	for _, result := range searchResults {
-		resultCode := jc.NormalizeMovieCode(result.Code)
-		if resultCode == normalizedCode {
+		if moviecode.Equal(result.Code, code) {
 			return jc.GetMovieByURL(ctx, result.DetailURL)
 		}
 	}
@@ -259,6 +258,15 @@ func (jc *JAVDbCrawler) GetMovieByURL(ctx context.Context, movieURL string) (*Mo
 			}
 		})
 
+		// 获取剧照
+		e.DOM.Find(".preview-images a.tile-item").Each(func(i int, s *goquery.Selection) {
+			if href, exists := s.Attr("href"); exists && !strings.HasPrefix(href, "#") {
+				if fullURL, err := jc.BuildURL(jc.baseURL, href); err == nil {
+					movie.SampleImages = append(movie.SampleImages, fullURL)
+				}
+			}
+		})
+
 		// 获取简介
 		descEl := e.DOM.Find(".video-detail .content p")
 		if descEl.Length() > 0 {
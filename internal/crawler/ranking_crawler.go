@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -35,13 +36,259 @@ func NewRankingCrawler(config *CrawlerConfig) *RankingCrawler {
 	}
 }
 
-// CrawlRanking 爬取排行榜
-func (rc *RankingCrawler) CrawlRanking(ctx context.Context, rankType string) ([]RankingItem, error) {
+// 榜单类型
+const (
+	BoardKindMovies = "movies" // 影片排行榜
+	BoardKindActors = "actors" // 女优排行榜
+)
+
+// 榜单分类
+const (
+	BoardCategoryCensored   = "censored"   // 有码
+	BoardCategoryUncensored = "uncensored" // 无码
+	BoardCategoryWestern    = "western"    // 欧美
+	BoardCategoryFC2        = "fc2"        // FC2
+)
+
+// maxBoardKeyLength 榜单标识的最大长度，与订阅、下载任务的 rank_type 字段长度一致
+const maxBoardKeyLength = 20
+
+// RankingBoard 排行榜来源
+type RankingBoard struct {
+	Key      string `json:"key"`      // 榜单标识，保存在排行榜记录中，订阅与接口使用该标识
+	Name     string `json:"name"`     // 显示名称
+	Kind     string `json:"kind"`     // movies 影片榜、actors 女优榜
+	Category string `json:"category"` // censored、uncensored、western、fc2
+	Period   string `json:"period"`   // daily、weekly、monthly，女优榜可以为空
+	Pages    int    `json:"pages"`    // 爬取页数，默认 1
+	Path     string `json:"path"`     // 自定义页面路径（如片商、系列的影片列表），为空时按类型、分类与周期生成
+}
+
+// BuiltinRankingBoards 内置的榜单：各分类的日榜、周榜、月榜与女优榜
+// 有码影片榜沿用 daily、weekly、monthly 作为标识，兼容已有的排行榜记录与订阅
+func BuiltinRankingBoards() []RankingBoard {
+	periods := []struct{ key, name string }{{"daily", "日榜"}, {"weekly", "周榜"}, {"monthly", "月榜"}}
+	categories := []struct{ key, name string }{
+		{BoardCategoryCensored, "有码"},
+		{BoardCategoryUncensored, "无码"},
+		{BoardCategoryWestern, "欧美"},
+		{BoardCategoryFC2, "FC2"},
+	}
+
+	var boards []RankingBoard
+	for _, category := range categories {
+		for _, period := range periods {
+			key := category.key + "-" + period.key
+			if category.key == BoardCategoryCensored {
+				key = period.key
+			}
+			boards = append(boards, RankingBoard{
+				Key:      key,
+				Name:     category.name + period.name,
+				Kind:     BoardKindMovies,
+				Category: category.key,
+				Period:   period.key,
+				Pages:    1,
+			})
+		}
+	}
+	// FC2 没有女优榜
+	for _, category := range categories[:3] {
+		boards = append(boards, RankingBoard{
+			Key:      "actress-" + category.key,
+			Name:     category.name + "女优榜",
+			Kind:     BoardKindActors,
+			Category: category.key,
+			Pages:    1,
+		})
+	}
+	return boards
+}
+
+// DefaultRankingBoards 未配置 ranking.boards 时爬取的榜单
+func DefaultRankingBoards() []RankingBoard {
+	enabled := map[string]bool{
+		"daily": true, "weekly": true, "monthly": true,
+		"uncensored-daily": true, "uncensored-weekly": true, "uncensored-monthly": true,
+		"actress-censored": true,
+	}
+	var boards []RankingBoard
+	for _, board := range BuiltinRankingBoards() {
+		if enabled[board.Key] {
+			boards = append(boards, board)
+		}
+	}
+	return boards
+}
+
+// ResolveRankingBoards 补全配置的榜单：与内置榜单同名时，未填写的字段使用内置值
+func ResolveRankingBoards(configured []RankingBoard) ([]RankingBoard, error) {
+	builtin := make(map[string]RankingBoard)
+	for _, board := range BuiltinRankingBoards() {
+		builtin[board.Key] = board
+	}
+
+	seen := make(map[string]bool)
+	boards := make([]RankingBoard, 0, len(configured))
+	for _, board := range configured {
+		if base, ok := builtin[board.Key]; ok {
+			if board.Name == "" {
+				board.Name = base.Name
+			}
+			if board.Kind == "" {
+				board.Kind = base.Kind
+			}
+			if board.Category == "" {
+				board.Category = base.Category
+			}
+			if board.Period == "" {
+				board.Period = base.Period
+			}
+		}
+		if board.Kind == "" {
+			board.Kind = BoardKindMovies
+		}
+		if board.Category == "" {
+			board.Category = BoardCategoryCensored
+		}
+		if board.Pages <= 0 {
+			board.Pages = 1
+		}
+		if board.Name == "" {
+			board.Name = board.Key
+		}
+		if err := board.validate(); err != nil {
+			return nil, err
+		}
+		if seen[board.Key] {
+			return nil, fmt.Errorf("榜单 %s 重复", board.Key)
+		}
+		seen[board.Key] = true
+		boards = append(boards, board)
+	}
+	return boards, nil
+}
+
+// validate 检查榜单配置
+func (b RankingBoard) validate() error {
+	if b.Key == "" || len(b.Key) > maxBoardKeyLength {
+		return fmt.Errorf("榜单标识 %q 无效：不能为空且不超过 %d 个字符", b.Key, maxBoardKeyLength)
+	}
+	if b.Kind != BoardKindMovies && b.Kind != BoardKindActors {
+		return fmt.Errorf("榜单 %s 的类型 %q 无效，支持: movies, actors", b.Key, b.Kind)
+	}
+	switch b.Category {
+	case BoardCategoryCensored, BoardCategoryUncensored, BoardCategoryWestern, BoardCategoryFC2:
+	default:
+		return fmt.Errorf("榜单 %s 的分类 %q 无效，支持: censored, uncensored, western, fc2", b.Key, b.Category)
+	}
+	switch b.Period {
+	case "", "daily", "weekly", "monthly":
+	default:
+		return fmt.Errorf("榜单 %s 的周期 %q 无效，支持: daily, weekly, monthly", b.Key, b.Period)
+	}
+	if b.Kind == BoardKindMovies && b.Period == "" && b.Path == "" {
+		return fmt.Errorf("榜单 %s 缺少周期或自定义路径", b.Key)
+	}
+	return nil
+}
+
+// pageURL 榜单第 page 页的地址
+func (b RankingBoard) pageURL(baseURL string, page int) string {
+	var u string
+	if b.Path != "" {
+		u = strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(b.Path, "/")
+	} else {
+		query := url.Values{}
+		if b.Period != "" {
+			query.Set("p", b.Period)
+		}
+		query.Set("t", b.Category)
+		u = fmt.Sprintf("%s/rankings/%s?%s", baseURL, b.Kind, query.Encode())
+	}
+	if page > 1 {
+		sep := "?"
+		if strings.Contains(u, "?") {
+			sep = "&"
+		}
+		u += fmt.Sprintf("%spage=%d", sep, page)
+	}
+	return u
+}
+
+// ActressRankingItem 女优排行榜项目
+type ActressRankingItem struct {
+	JAVDbID   string `json:"javdb_id"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+	Position  int    `json:"position"`
+}
+
+// CrawlBoard 爬取影片榜单，按配置的页数依次爬取，排名跨页连续
+func (rc *RankingCrawler) CrawlBoard(ctx context.Context, board RankingBoard) ([]RankingItem, error) {
 	var items []RankingItem
-	var crawlErr error
+	for page := 1; page <= board.Pages; page++ {
+		if page > 1 {
+			// 添加延时，避免请求过于频繁
+			select {
+			case <-ctx.Done():
+				return items, ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+		}
+
+		pageItems, err := rc.crawlMoviePage(board.pageURL(rc.baseURL, page), len(items))
+		if err != nil {
+			if page > 1 && len(items) > 0 {
+				log.Printf("[排行榜爬虫] %s 第 %d 页爬取失败，保留前 %d 页: %v", board.Key, page, page-1, err)
+				break
+			}
+			return nil, err
+		}
+		if len(pageItems) == 0 {
+			break
+		}
+		items = append(items, pageItems...)
+	}
+
+	log.Printf("[排行榜爬虫] %s 榜单爬取完成，共 %d 个项目", board.Key, len(items))
+	return items, nil
+}
+
+// CrawlActressBoard 爬取女优榜单
+func (rc *RankingCrawler) CrawlActressBoard(ctx context.Context, board RankingBoard) ([]ActressRankingItem, error) {
+	var items []ActressRankingItem
+	for page := 1; page <= board.Pages; page++ {
+		if page > 1 {
+			select {
+			case <-ctx.Done():
+				return items, ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+		}
 
-	// 构建排行榜URL
-	rankingURL := fmt.Sprintf("%s/rankings/movies?p=%s&t=censored", rc.baseURL, rankType)
+		pageItems, err := rc.crawlActressPage(board.pageURL(rc.baseURL, page), len(items))
+		if err != nil {
+			if page > 1 && len(items) > 0 {
+				log.Printf("[排行榜爬虫] %s 第 %d 页爬取失败，保留前 %d 页: %v", board.Key, page, page-1, err)
+				break
+			}
+			return nil, err
+		}
+		if len(pageItems) == 0 {
+			break
+		}
+		items = append(items, pageItems...)
+	}
+
+	log.Printf("[排行榜爬虫] %s 女优榜爬取完成，共 %d 位", board.Key, len(items))
+	return items, nil
+}
+
+// crawlMoviePage 爬取一页影片列表，offset 为之前各页的条目数
+func (rc *RankingCrawler) crawlMoviePage(pageURL string, offset int) ([]RankingItem, error) {
+	var items []RankingItem
+	var crawlErr error
 
 	// 为每次爬取创建新的 Collector，避免 URL 缓存问题
 	c := colly.NewCollector(
@@ -104,7 +351,7 @@ func (rc *RankingCrawler) CrawlRanking(ctx context.Context, rankType string) ([]
 
 		// 如果没有明确的排名元素，使用当前索引+1
 		if item.Position == 0 {
-			item.Position = len(items) + 1
+			item.Position = offset + len(items) + 1
 		}
 
 		// 验证必要字段
@@ -121,9 +368,8 @@ func (rc *RankingCrawler) CrawlRanking(ctx context.Context, rankType string) ([]
 	})
 
 	// 访问排行榜页面
-	log.Printf("[排行榜爬虫] 开始爬取 %s 排行榜: %s", rankType, rankingURL)
-	err := c.Visit(rankingURL)
-	if err != nil {
+	log.Printf("[排行榜爬虫] 开始爬取排行榜: %s", pageURL)
+	if err := c.Visit(pageURL); err != nil {
 		return nil, fmt.Errorf("访问排行榜页面失败: %v", err)
 	}
 
@@ -132,30 +378,64 @@ func (rc *RankingCrawler) CrawlRanking(ctx context.Context, rankType string) ([]
 	if crawlErr != nil {
 		return nil, crawlErr
 	}
-
-	log.Printf("[排行榜爬虫] %s 排行榜爬取完成，共 %d 个项目", rankType, len(items))
 	return items, nil
 }
 
-// CrawlAllRankings 爬取所有类型的排行榜
-func (rc *RankingCrawler) CrawlAllRankings(ctx context.Context) (map[string][]RankingItem, error) {
-	rankTypes := []string{"daily", "weekly", "monthly"}
-	results := make(map[string][]RankingItem)
+// crawlActressPage 爬取一页女优排行榜，offset 为之前各页的条目数
+func (rc *RankingCrawler) crawlActressPage(pageURL string, offset int) ([]ActressRankingItem, error) {
+	var items []ActressRankingItem
+	var crawlErr error
 
-	for _, rankType := range rankTypes {
-		items, err := rc.CrawlRanking(ctx, rankType)
-		if err != nil {
-			log.Printf("[排行榜爬虫] 爬取 %s 排行榜失败: %v", rankType, err)
-			continue
+	c := colly.NewCollector(
+		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"),
+	)
+
+	c.OnHTML("#actors .actor-box, .actors .box", func(e *colly.HTMLElement) {
+		item := ActressRankingItem{}
+
+		linkEl := e.DOM.Find("a").First()
+		if href, exists := linkEl.Attr("href"); exists {
+			// 从URL路径中提取女优ID，例如: /actors/abc12 -> abc12
+			parts := strings.Split(strings.Split(href, "?")[0], "/")
+			item.JAVDbID = parts[len(parts)-1]
+		}
+
+		item.Name = rc.CleanText(e.DOM.Find("strong").First().Text())
+		if item.Name == "" {
+			item.Name = rc.CleanText(linkEl.AttrOr("title", ""))
+		}
+
+		imgEl := e.DOM.Find("img").First()
+		for _, attr := range []string{"src", "data-src"} {
+			if src, exists := imgEl.Attr(attr); exists && src != "" {
+				if fullURL, err := rc.BuildURL(rc.baseURL, src); err == nil {
+					item.AvatarURL = fullURL
+					break
+				}
+			}
 		}
 
-		results[rankType] = items
+		item.Position = offset + len(items) + 1
+		if item.Name != "" {
+			items = append(items, item)
+		}
+	})
+
+	c.OnError(func(r *colly.Response, err error) {
+		crawlErr = fmt.Errorf("爬取女优排行榜失败: %v", err)
+	})
 
-		// 添加延时，避免请求过于频繁
-		time.Sleep(2 * time.Second)
+	log.Printf("[排行榜爬虫] 开始爬取女优排行榜: %s", pageURL)
+	if err := c.Visit(pageURL); err != nil {
+		return nil, fmt.Errorf("访问女优排行榜页面失败: %v", err)
 	}
 
-	return results, nil
+	c.Wait()
+
+	if crawlErr != nil {
+		return nil, crawlErr
+	}
+	return items, nil
 }
 
 // IsHealthy 检查爬虫健康状态
@@ -30,6 +30,7 @@ type MovieData struct {
 	Series            *SeriesData   `json:"series,omitempty"`
 	Actresses         []ActressData `json:"actresses,omitempty"`
 	Tags              []TagData     `json:"tags,omitempty"`
+	SampleImages      []string      `json:"sample_images,omitempty"`
 }
 
 // ActressData 女优数据结构
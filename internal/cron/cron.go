@@ -0,0 +1,234 @@
+// Package cron 解析 cron 表达式并计算下一次执行时间
+//
+// 支持标准的五段式表达式（分 时 日 月 周），每段可以是 *、数值、范围（1-5）、列表（1,3,5）
+// 与步长（*/15、0-30/10），月份与星期可以使用英文缩写（JAN、MON）。另外支持 @yearly、@monthly、
+// @weekly、@daily、@hourly 以及固定间隔 @every 15m。
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 解析后的调度规则
+type Schedule struct {
+	expr string
+	loc  *time.Location
+
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+
+	every time.Duration // @every 固定间隔，不为 0 时忽略其他字段
+}
+
+// field 表达式字段的取值范围
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "分钟", min: 0, max: 59}
+	hourField   = field{name: "小时", min: 0, max: 23}
+	domField    = field{name: "日", min: 1, max: 31}
+	monthField  = field{name: "月", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	dowField = field{name: "星期", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+// descriptors 预定义的表达式
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse 解析 cron 表达式，loc 为空时使用本地时区
+func Parse(expr string, loc *time.Location) (*Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	expr = strings.TrimSpace(expr)
+	s := &Schedule{expr: expr, loc: loc}
+
+	spec := expr
+	if strings.HasPrefix(spec, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("无效的间隔 %q: %v", expr, err)
+		}
+		if every < time.Second {
+			return nil, fmt.Errorf("间隔不能小于 1 秒: %q", expr)
+		}
+		s.every = every
+		return s, nil
+	}
+	if strings.HasPrefix(spec, "@") {
+		d, ok := descriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("未知的表达式 %q", expr)
+		}
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("表达式 %q 应包含 5 段（分 时 日 月 周），实际为 %d 段", expr, len(fields))
+	}
+
+	var err error
+	if s.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	// 星期中的 7 与 0 都表示周日
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+// String 返回原始表达式
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Location 返回调度使用的时区
+func (s *Schedule) Location() *time.Location {
+	return s.loc
+}
+
+// Next 返回 t 之后的下一次执行时间，找不到（如 2 月 30 日）时返回零值
+// 夏令时开始时跳过的本地时间不会执行，例如 2:30 的任务在当天不执行
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every).Truncate(time.Second)
+	}
+
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc))
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc))
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc))
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// forward 前进到 next；夏令时开始时本地时间不存在，time.Date 可能返回不晚于 t 的时间（如 2:00 变为 1:00），
+// 此时改为按实际时间前进到下一个整点，保证每次都会向后推进
+func forward(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+}
+
+// dayMatches 日与星期的匹配规则：两者都有限制时满足其一即可，否则按有限制的一方匹配
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// parse 解析单个字段，返回按位表示的取值集合
+func (f field) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		lo, hi, step := f.min, f.max, 1
+
+		rangeExpr := part
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段的步长无效: %q", f.name, part)
+			}
+			step = n
+			rangeExpr = part[:i]
+		}
+
+		switch {
+		case rangeExpr == "*" || rangeExpr == "?":
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s字段的范围无效: %q", f.name, part)
+			}
+		default:
+			v, err := f.value(rangeExpr)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// 5/10 表示从 5 开始每 10 个单位
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value 解析单个数值或英文缩写
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s字段的取值无效: %q（范围 %d-%d）", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
@@ -0,0 +1,177 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("时区 %s 不可用: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+		"@weekdays",
+		"@every 10",
+		"@every 500ms",
+	}
+	for _, expr := range tests {
+		if _, err := Parse(expr, time.UTC); err == nil {
+			t.Errorf("Parse(%q) 应返回错误", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2026, 1, 14, 10, 17, 42, 0, time.UTC) // 周三
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 14, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 14, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2026, 1, 14, 10, 25, 0, 0, time.UTC)},
+		{"0-30/10 11 * * *", time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+		{"0 9,18 * * *", time.Date(2026, 1, 14, 18, 0, 0, 0, time.UTC)},
+		{"0 3 * * MON-FRI", time.Date(2026, 1, 15, 3, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 mar *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 日与星期都有限制时满足其一即可：15 日（周四）早于下一个周日
+		{"0 0 15 * SUN", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2026, 1, 14, 11, 47, 42, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr, time.UTC)
+		if err != nil {
+			t.Errorf("Parse(%q) 失败: %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%v) = %v，期望 %v", tt.expr, from, got, tt.want)
+		}
+	}
+}
+
+func TestNextTimeZone(t *testing.T) {
+	shanghai := mustLoad(t, "Asia/Shanghai")
+	kolkata := mustLoad(t, "Asia/Kolkata")
+	from := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		loc  *time.Location
+		want time.Time
+	}{
+		// 上海 08:00 之后的 03:00 是次日
+		{"0 3 * * *", shanghai, time.Date(2026, 5, 1, 19, 0, 0, 0, time.UTC)},
+		{"0 12 * * *", shanghai, time.Date(2026, 5, 1, 4, 0, 0, 0, time.UTC)},
+		// 半小时偏移的时区
+		{"0 * * * *", kolkata, time.Date(2026, 5, 1, 0, 30, 0, 0, time.UTC)},
+		{"0 6 * * *", kolkata, time.Date(2026, 5, 1, 0, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr, tt.loc)
+		if err != nil {
+			t.Fatalf("Parse(%q) 失败: %v", tt.expr, err)
+		}
+		got := s.Next(from)
+		if !got.Equal(tt.want) {
+			t.Errorf("%q@%s.Next(%v) = %v，期望 %v", tt.expr, tt.loc, from, got, tt.want)
+		}
+		if got.Location() != tt.loc {
+			t.Errorf("%q@%s.Next 返回的时区为 %s", tt.expr, tt.loc, got.Location())
+		}
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	santiago := mustLoad(t, "America/Santiago")
+	tests := []struct {
+		name string
+		expr string
+		loc  *time.Location
+		from time.Time
+		want time.Time
+	}{
+		{
+			// 2026-03-08 02:00 EST 跳到 03:00 EDT，当天的 02:30 不存在
+			name: "夏令时开始跳过的时刻",
+			expr: "30 2 * * *", loc: ny,
+			from: time.Date(2026, 3, 8, 0, 0, 0, 0, ny),
+			want: time.Date(2026, 3, 9, 2, 30, 0, 0, ny),
+		},
+		{
+			name: "夏令时开始后的时刻",
+			expr: "0 3 * * *", loc: ny,
+			from: time.Date(2026, 3, 8, 0, 0, 0, 0, ny),
+			want: time.Date(2026, 3, 8, 3, 0, 0, 0, ny),
+		},
+		{
+			name: "夏令时开始的每小时任务",
+			expr: "15 * * * *", loc: ny,
+			from: time.Date(2026, 3, 8, 1, 30, 0, 0, ny),
+			want: time.Date(2026, 3, 8, 3, 15, 0, 0, ny),
+		},
+		{
+			// 2026-11-01 02:00 EDT 回到 01:00 EST，当天比平时多一小时
+			name: "夏令时结束",
+			expr: "0 3 * * *", loc: ny,
+			from: time.Date(2026, 10, 31, 3, 0, 0, 0, ny),
+			want: time.Date(2026, 11, 1, 3, 0, 0, 0, ny),
+		},
+		{
+			// 圣地亚哥在午夜切换夏令时，2026-09-06 00:00 不存在
+			name: "午夜切换夏令时",
+			expr: "0 0 * * *", loc: santiago,
+			from: time.Date(2026, 9, 5, 12, 0, 0, 0, santiago),
+			want: time.Date(2026, 9, 7, 0, 0, 0, 0, santiago),
+		},
+		{
+			name: "午夜切换夏令时当天的其他时刻",
+			expr: "0 9 * * *", loc: santiago,
+			from: time.Date(2026, 9, 5, 12, 0, 0, 0, santiago),
+			want: time.Date(2026, 9, 6, 9, 0, 0, 0, santiago),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr, tt.loc)
+			if err != nil {
+				t.Fatalf("Parse(%q) 失败: %v", tt.expr, err)
+			}
+			done := make(chan time.Time, 1)
+			go func() { done <- s.Next(tt.from) }()
+			select {
+			case got := <-done:
+				if !got.Equal(tt.want) {
+					t.Errorf("%q.Next(%v) = %v，期望 %v", tt.expr, tt.from, got, tt.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%q.Next(%v) 未能返回", tt.expr, tt.from)
+			}
+		})
+	}
+}
@@ -65,6 +65,9 @@ func Initialize(cfg *config.Config) error {
 
 // autoMigrate 自动迁移数据库模式
 func autoMigrate(db *gorm.DB) error {
+	if err := DropRetiredIndexes(db); err != nil {
+		return err
+	}
 	return db.AutoMigrate(
 		&model.Movie{},
 		&model.Actress{},
@@ -77,6 +80,17 @@ func autoMigrate(db *gorm.DB) error {
 		&model.WatchHistory{},
 		&model.Favorite{},
 		&model.LocalMovie{},
+		&model.LocalMoviePart{},
+		&model.LocalMovieSubtitle{},
+		&model.ScanHistory{},
+		&model.Ranking{},
+		&model.RankingSnapshot{},
+		&model.ActressRanking{},
+		&model.RankingDownloadTask{},
+		&model.TorrentBlocklist{},
+		&model.ScheduledJob{},
+		&model.OrganizeRun{},
+		&model.OrganizeJournalEntry{},
 		&model.ConfigStore{},
 		&model.ConfigCategory{},
 		&model.ConfigTemplate{},
@@ -84,6 +98,30 @@ func autoMigrate(db *gorm.DB) error {
 	)
 }
 
+// DropRetiredIndexes 删除已改为普通索引的旧唯一索引
+// AutoMigrate 只按索引名判断是否存在，不会把同名的唯一索引改成普通索引，需要在迁移前先删除
+func DropRetiredIndexes(db *gorm.DB) error {
+	task := &model.RankingDownloadTask{}
+	if !db.Migrator().HasTable(task) {
+		return nil
+	}
+	indexes, err := db.Migrator().GetIndexes(task)
+	if err != nil {
+		return fmt.Errorf("读取下载任务索引失败: %w", err)
+	}
+	for _, index := range indexes {
+		if index.Name() != "idx_ranking_download_tasks_code" {
+			continue
+		}
+		if unique, ok := index.Unique(); ok && unique {
+			if err := db.Migrator().DropIndex(task, index.Name()); err != nil {
+				return fmt.Errorf("删除下载任务番号唯一索引失败: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
 // Close 关闭数据库连接
 func Close() error {
 	if DB != nil {
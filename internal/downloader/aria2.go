@@ -0,0 +1,297 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// aria2Keys tellActive/tellWaiting/tellStopped 请求的字段
+var aria2Keys = []string{
+	"gid", "status", "totalLength", "completedLength", "downloadSpeed", "infoHash",
+	"dir", "files", "bittorrent", "errorCode", "errorMessage", "followedBy",
+}
+
+// aria2 单次查询等待与已停止任务的最大数量
+const aria2ListLimit = 1000
+
+// aria2 Aria2 JSON-RPC 客户端，只管理 BT 下载，种子按 info hash 对应到 GID
+type aria2 struct {
+	cfg      Config
+	endpoint string
+	client   *http.Client
+	id       atomic.Int64
+}
+
+func newAria2(cfg Config) *aria2 {
+	endpoint := cfg.Host
+	if !strings.HasSuffix(endpoint, "/jsonrpc") {
+		endpoint += "/jsonrpc"
+	}
+	return &aria2{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// aria2Status aria2.tellStatus 返回的下载任务，数值均为字符串
+type aria2Status struct {
+	GID             string   `json:"gid"`
+	Status          string   `json:"status"`
+	TotalLength     string   `json:"totalLength"`
+	CompletedLength string   `json:"completedLength"`
+	DownloadSpeed   string   `json:"downloadSpeed"`
+	InfoHash        string   `json:"infoHash"`
+	Dir             string   `json:"dir"`
+	ErrorCode       string   `json:"errorCode"`
+	ErrorMessage    string   `json:"errorMessage"`
+	FollowedBy      []string `json:"followedBy"`
+	Files           []struct {
+		Path string `json:"path"`
+	} `json:"files"`
+	Bittorrent struct {
+		Info struct {
+			Name string `json:"name"`
+		} `json:"info"`
+	} `json:"bittorrent"`
+}
+
+func (a *aria2) Name() string {
+	return TypeAria2
+}
+
+// Add 添加磁力链接或 HTTP 种子链接，Aria2 不支持分类与标签
+func (a *aria2) Add(ctx context.Context, uri string, opts AddOptions) error {
+	options := map[string]string{}
+	if opts.SavePath != "" {
+		options["dir"] = opts.SavePath
+	}
+	if opts.Paused {
+		options["pause"] = "true"
+	}
+	if err := a.call(ctx, "aria2.addUri", nil, []string{uri}, options); err != nil {
+		return fmt.Errorf("添加种子到Aria2失败: %v", err)
+	}
+	return nil
+}
+
+func (a *aria2) List(ctx context.Context) ([]Torrent, error) {
+	raw, err := a.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	torrents := make([]Torrent, 0, len(raw))
+	for _, r := range raw {
+		torrents = append(torrents, r.torrent())
+	}
+	return torrents, nil
+}
+
+func (a *aria2) Get(ctx context.Context, hash string) (*Torrent, error) {
+	raw, err := a.find(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	torrent := raw.torrent()
+	return &torrent, nil
+}
+
+func (a *aria2) Pause(ctx context.Context, hash string) error {
+	return a.gidCall(ctx, hash, "aria2.pause")
+}
+
+func (a *aria2) Resume(ctx context.Context, hash string) error {
+	return a.gidCall(ctx, hash, "aria2.unpause")
+}
+
+// Remove 删除下载任务，Aria2 不会删除已下载的文件
+func (a *aria2) Remove(ctx context.Context, hash string, deleteFiles bool) error {
+	if deleteFiles {
+		return fmt.Errorf("Aria2 无法删除已下载的文件: %w", ErrNotSupported)
+	}
+	raw, err := a.find(ctx, hash)
+	if err != nil {
+		return err
+	}
+	switch raw.Status {
+	case "complete", "error", "removed":
+		return a.call(ctx, "aria2.removeDownloadResult", nil, raw.GID)
+	}
+	return a.call(ctx, "aria2.remove", nil, raw.GID)
+}
+
+func (a *aria2) SetCategory(ctx context.Context, hash, category string) error {
+	return fmt.Errorf("Aria2 不支持分类: %w", ErrNotSupported)
+}
+
+// SetSavePath 修改保存目录，只对尚未开始或已暂停的任务有效，Aria2 不会移动已下载的文件
+func (a *aria2) SetSavePath(ctx context.Context, hash, dir string) error {
+	raw, err := a.find(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if raw.Status != "waiting" && raw.Status != "paused" {
+		return fmt.Errorf("Aria2 只能修改未开始或已暂停任务的保存目录: %w", ErrNotSupported)
+	}
+	return a.call(ctx, "aria2.changeOption", nil, raw.GID, map[string]string{"dir": dir})
+}
+
+// list 获取全部 BT 下载任务，跳过 HTTP 种子文件与磁力链接元数据这类已被后续任务接替的任务
+func (a *aria2) list(ctx context.Context) ([]aria2Status, error) {
+	var active, waiting, stopped []aria2Status
+	if err := a.call(ctx, "aria2.tellActive", &active, aria2Keys); err != nil {
+		return nil, fmt.Errorf("获取种子列表失败: %v", err)
+	}
+	if err := a.call(ctx, "aria2.tellWaiting", &waiting, 0, aria2ListLimit, aria2Keys); err != nil {
+		return nil, fmt.Errorf("获取种子列表失败: %v", err)
+	}
+	if err := a.call(ctx, "aria2.tellStopped", &stopped, 0, aria2ListLimit, aria2Keys); err != nil {
+		return nil, fmt.Errorf("获取种子列表失败: %v", err)
+	}
+
+	var result []aria2Status
+	for _, group := range [][]aria2Status{active, waiting, stopped} {
+		for _, r := range group {
+			if r.InfoHash == "" || len(r.FollowedBy) > 0 || r.Status == "removed" {
+				continue
+			}
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+func (a *aria2) find(ctx context.Context, hash string) (*aria2Status, error) {
+	raw, err := a.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash = strings.ToLower(hash)
+	for i := range raw {
+		if strings.ToLower(raw[i].InfoHash) == hash {
+			return &raw[i], nil
+		}
+	}
+	return nil, ErrTorrentNotFound
+}
+
+// gidCall 按哈希找到 GID 后调用只接收 GID 的方法
+func (a *aria2) gidCall(ctx context.Context, hash, method string) error {
+	raw, err := a.find(ctx, hash)
+	if err != nil {
+		return err
+	}
+	return a.call(ctx, method, nil, raw.GID)
+}
+
+// torrent 转换为统一的种子结构
+func (r aria2Status) torrent() Torrent {
+	total, _ := strconv.ParseInt(r.TotalLength, 10, 64)
+	completed, _ := strconv.ParseInt(r.CompletedLength, 10, 64)
+	speed, _ := strconv.ParseInt(r.DownloadSpeed, 10, 64)
+
+	name := r.Bittorrent.Info.Name
+	contentPath := joinPath(r.Dir, name)
+	if name == "" && len(r.Files) > 0 {
+		contentPath = r.Files[0].Path
+		name = path.Base(contentPath)
+	}
+
+	torrent := Torrent{
+		Hash:          strings.ToLower(r.InfoHash),
+		Name:          name,
+		RawState:      r.Status,
+		Size:          total,
+		Downloaded:    completed,
+		DownloadSpeed: speed,
+		SavePath:      r.Dir,
+		ContentPath:   contentPath,
+		MagnetURI:     "magnet:?xt=urn:btih:" + strings.ToLower(r.InfoHash),
+		ErrorMessage:  r.ErrorMessage,
+	}
+	finished := total > 0 && completed >= total
+	if total > 0 {
+		torrent.Progress = float64(completed) / float64(total)
+	}
+	if speed > 0 && total > completed {
+		torrent.ETA = (total - completed) / speed
+	}
+
+	switch r.Status {
+	case "active":
+		if finished {
+			torrent.State = StateSeeding
+		} else {
+			torrent.State = StateDownloading
+		}
+	case "waiting":
+		torrent.State = StateQueued
+	case "paused":
+		if finished {
+			torrent.State = StateCompleted
+		} else {
+			torrent.State = StatePaused
+		}
+	case "complete":
+		torrent.State = StateCompleted
+	default:
+		torrent.State = StateError
+	}
+	return torrent
+}
+
+// call 调用 JSON-RPC 方法，配置了密钥时作为第一个参数传入 token:密钥
+func (a *aria2) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if a.cfg.Password != "" {
+		params = append([]interface{}{"token:" + a.cfg.Password}, params...)
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      strconv.FormatInt(a.id.Add(1), 10),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Aria2 出错时同样返回 JSON，状态码可能是 400 或 500，因此不按状态码判断
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("解析响应失败，状态码: %d: %v", resp.StatusCode, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s (code %d)", response.Error.Message, response.Error.Code)
+	}
+	if result != nil && len(response.Result) > 0 {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package downloader 下载器客户端，统一 qBittorrent、Transmission、Aria2 与 Deluge 的种子管理接口
+//
+// 各下载器的种子状态被转换为统一的 Torrent 结构，哈希统一为小写的 info hash，
+// 上层服务只依赖 DownloadClient 接口，通过配置 torrent.client 选择具体实现。
+package downloader
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 支持的下载器类型
+const (
+	TypeQBittorrent  = "qbittorrent"
+	TypeTransmission = "transmission"
+	TypeAria2        = "aria2"
+	TypeDeluge       = "deluge"
+)
+
+// 统一的种子状态
+const (
+	StateQueued      = "queued"      // 排队等待下载
+	StateDownloading = "downloading" // 下载中（包括获取元数据、等待连接）
+	StatePaused      = "paused"      // 未完成时暂停
+	StateChecking    = "checking"    // 校验中
+	StateSeeding     = "seeding"     // 下载完成并做种
+	StateCompleted   = "completed"   // 下载完成且已停止
+	StateError       = "error"       // 下载器报告错误，需要人工处理
+)
+
+var (
+	// ErrTorrentNotFound 下载器中不存在指定的种子
+	ErrTorrentNotFound = errors.New("下载器中找不到种子")
+	// ErrNotSupported 下载器不支持该操作（如 Aria2 的分类）
+	ErrNotSupported = errors.New("下载器不支持该操作")
+	// ErrTorrentExists 下载器拒绝添加已存在的种子
+	ErrTorrentExists = errors.New("已存在于下载列表中，无法重复添加")
+)
+
+// Torrent 下载器中的种子
+type Torrent struct {
+	Hash          string  `json:"hash"`          // info hash（小写）
+	Name          string  `json:"name"`          // 种子名称
+	State         string  `json:"state"`         // 统一状态
+	RawState      string  `json:"raw_state"`     // 下载器原始状态
+	Progress      float64 `json:"progress"`      // 0-1
+	Size          int64   `json:"size"`          // 选中文件的大小（字节）
+	Downloaded    int64   `json:"downloaded"`    // 已下载字节数
+	DownloadSpeed int64   `json:"dlspeed"`       // 字节/秒
+	ETA           int64   `json:"eta"`           // 剩余秒数，0 表示未知或已完成
+	SavePath      string  `json:"save_path"`     // 保存目录
+	ContentPath   string  `json:"content_path"`  // 单文件种子为文件路径，多文件种子为根目录
+	Category      string  `json:"category"`      // 分类（Transmission 为第一个标签，Aria2 不支持）
+	MagnetURI     string  `json:"magnet_uri"`    // 磁力链接，下载器不提供时为空
+	ErrorMessage  string  `json:"error_message"` // 下载器报告的错误信息
+}
+
+// Done 种子已下载完成（做种或已停止）
+func (t Torrent) Done() bool {
+	return t.State == StateSeeding || t.State == StateCompleted
+}
+
+// Failed 种子出错，需要人工处理
+func (t Torrent) Failed() bool {
+	return t.State == StateError
+}
+
+// AddOptions 添加种子的选项
+type AddOptions struct {
+	SavePath string   // 保存目录，为空时使用下载器默认目录
+	Category string   // 分类
+	Tags     []string // 标签，不支持标签的下载器忽略
+	Paused   bool     // 添加后暂停
+}
+
+// DownloadClient 下载器客户端
+type DownloadClient interface {
+	// Name 下载器类型
+	Name() string
+	// Add 添加磁力链接或 HTTP 种子链接
+	Add(ctx context.Context, uri string, opts AddOptions) error
+	// List 获取全部种子
+	List(ctx context.Context) ([]Torrent, error)
+	// Get 按哈希获取种子，不存在时返回 ErrTorrentNotFound
+	Get(ctx context.Context, hash string) (*Torrent, error)
+	// Pause 暂停种子
+	Pause(ctx context.Context, hash string) error
+	// Resume 恢复种子
+	Resume(ctx context.Context, hash string) error
+	// Remove 删除种子，deleteFiles 为 true 时同时删除已下载的文件
+	Remove(ctx context.Context, hash string, deleteFiles bool) error
+	// SetCategory 设置种子分类
+	SetCategory(ctx context.Context, hash, category string) error
+	// SetSavePath 设置种子保存目录，下载器会移动已下载的文件
+	SetSavePath(ctx context.Context, hash, path string) error
+}
+
+// Config 下载器连接配置
+type Config struct {
+	Type     string        // 下载器类型，为空时使用 qbittorrent
+	Host     string        // 地址，如 http://127.0.0.1:8080；Aria2 与 Transmission 可以直接填写 RPC 地址
+	Username string        // 用户名（qBittorrent、Transmission）
+	Password string        // 密码（qBittorrent、Transmission、Deluge Web）或 Aria2 的 RPC 密钥
+	Timeout  time.Duration // 请求超时，默认 30 秒
+}
+
+// New 按配置创建下载器客户端
+func New(cfg Config) (DownloadClient, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("下载器地址不能为空")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	cfg.Host = strings.TrimRight(cfg.Host, "/")
+
+	switch strings.ToLower(cfg.Type) {
+	case "", TypeQBittorrent:
+		return newQBittorrent(cfg), nil
+	case TypeTransmission:
+		return newTransmission(cfg), nil
+	case TypeAria2:
+		return newAria2(cfg), nil
+	case TypeDeluge:
+		return newDeluge(cfg), nil
+	}
+	return nil, fmt.Errorf("不支持的下载器类型: %s", cfg.Type)
+}
+
+// Types 支持的下载器类型
+func Types() []string {
+	return []string{TypeQBittorrent, TypeTransmission, TypeAria2, TypeDeluge}
+}
+
+// MagnetInfoHash 从磁力链接中提取 info hash（40位小写十六进制），32位 base32 格式的哈希会转换为十六进制
+// 下载器都以十六进制报告哈希；不是磁力链接或哈希格式无效时返回空字符串
+func MagnetInfoHash(uri string) string {
+	if !strings.HasPrefix(strings.ToLower(uri), "magnet:") {
+		return ""
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	for _, xt := range u.Query()["xt"] {
+		if hash, ok := strings.CutPrefix(strings.ToLower(xt), "urn:btih:"); ok {
+			return normalizeInfoHash(hash)
+		}
+	}
+	return ""
+}
+
+// normalizeInfoHash 将 btih 哈希转换为40位小写十六进制，格式无效时返回空字符串
+func normalizeInfoHash(hash string) string {
+	switch len(hash) {
+	case 40:
+		if _, err := hex.DecodeString(hash); err == nil {
+			return hash
+		}
+	case 32:
+		if raw, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash)); err == nil {
+			return hex.EncodeToString(raw)
+		}
+	}
+	return ""
+}
+
+// find 在种子列表中按哈希查找
+func find(torrents []Torrent, hash string) (*Torrent, error) {
+	hash = strings.ToLower(hash)
+	for i := range torrents {
+		if torrents[i].Hash == hash {
+			return &torrents[i], nil
+		}
+	}
+	return nil, ErrTorrentNotFound
+}
+
+// joinPath 拼接下载器返回的目录与名称，保留下载器所在系统的路径分隔符
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	if name == "" {
+		return dir
+	}
+	sep := "/"
+	if strings.Contains(dir, `\`) && !strings.Contains(dir, "/") {
+		sep = `\`
+	}
+	return strings.TrimRight(dir, `/\`) + sep + name
+}
@@ -0,0 +1,306 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Deluge Web 未登录时返回的错误码
+const delugeErrNotAuthenticated = 1
+
+// delugeKeys core.get_torrents_status 请求的字段，save_path 为 1.x 写法，download_location 为 2.x 写法
+var delugeKeys = []string{
+	"hash", "name", "state", "progress", "total_wanted", "total_done", "download_payload_rate",
+	"eta", "save_path", "download_location", "label", "message",
+}
+
+// deluge Deluge Web JSON-RPC 客户端，分类通过 Label 插件实现
+type deluge struct {
+	cfg      Config
+	endpoint string
+	client   *http.Client
+	id       atomic.Int64
+
+	mu     sync.Mutex
+	authed bool
+}
+
+func newDeluge(cfg Config) *deluge {
+	jar, _ := cookiejar.New(nil)
+	endpoint := cfg.Host
+	if !strings.HasSuffix(endpoint, "/json") {
+		endpoint += "/json"
+	}
+	return &deluge{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: cfg.Timeout, Jar: jar},
+	}
+}
+
+// delugeTorrent core.get_torrents_status 返回的种子
+type delugeTorrent struct {
+	Hash             string  `json:"hash"`
+	Name             string  `json:"name"`
+	State            string  `json:"state"`
+	Progress         float64 `json:"progress"` // 0-100
+	TotalWanted      int64   `json:"total_wanted"`
+	TotalDone        int64   `json:"total_done"`
+	DownloadRate     float64 `json:"download_payload_rate"`
+	Eta              float64 `json:"eta"`
+	SavePath         string  `json:"save_path"`
+	DownloadLocation string  `json:"download_location"`
+	Label            string  `json:"label"`
+	Message          string  `json:"message"`
+}
+
+// delugeError JSON-RPC 返回的错误
+type delugeError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *delugeError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+func (d *deluge) Name() string {
+	return TypeDeluge
+}
+
+// Add 添加磁力链接或 HTTP 种子链接，设置了分类时通过 Label 插件打标签
+func (d *deluge) Add(ctx context.Context, uri string, opts AddOptions) error {
+	options := map[string]interface{}{"add_paused": opts.Paused}
+	if opts.SavePath != "" {
+		options["download_location"] = opts.SavePath
+	}
+	method := "core.add_torrent_url"
+	if strings.HasPrefix(strings.ToLower(uri), "magnet:") {
+		method = "core.add_torrent_magnet"
+	}
+
+	var hash *string
+	if err := d.call(ctx, method, &hash, uri, options); err != nil {
+		return fmt.Errorf("添加种子到Deluge失败: %v", err)
+	}
+	if hash == nil {
+		return fmt.Errorf("Deluge 拒绝添加种子，可能是种子%w", ErrTorrentExists)
+	}
+	if opts.Category != "" {
+		// 未启用 Label 插件时不影响下载
+		_ = d.SetCategory(ctx, *hash, opts.Category)
+	}
+	return nil
+}
+
+func (d *deluge) List(ctx context.Context) ([]Torrent, error) {
+	return d.status(ctx, map[string]interface{}{})
+}
+
+func (d *deluge) Get(ctx context.Context, hash string) (*Torrent, error) {
+	torrents, err := d.status(ctx, map[string]interface{}{"id": []string{strings.ToLower(hash)}})
+	if err != nil {
+		return nil, err
+	}
+	return find(torrents, hash)
+}
+
+func (d *deluge) Pause(ctx context.Context, hash string) error {
+	return d.call(ctx, "core.pause_torrent", nil, []string{hash})
+}
+
+func (d *deluge) Resume(ctx context.Context, hash string) error {
+	return d.call(ctx, "core.resume_torrent", nil, []string{hash})
+}
+
+func (d *deluge) Remove(ctx context.Context, hash string, deleteFiles bool) error {
+	return d.call(ctx, "core.remove_torrent", nil, hash, deleteFiles)
+}
+
+// SetCategory 设置标签，标签不存在时先创建；Label 插件只接受小写标签
+func (d *deluge) SetCategory(ctx context.Context, hash, category string) error {
+	label := strings.ToLower(category)
+	if label != "" {
+		var labels []string
+		if err := d.call(ctx, "label.get_labels", &labels); err != nil {
+			return fmt.Errorf("Deluge 未启用 Label 插件: %w", ErrNotSupported)
+		}
+		exists := false
+		for _, l := range labels {
+			exists = exists || l == label
+		}
+		if !exists {
+			if err := d.call(ctx, "label.add", nil, label); err != nil {
+				return fmt.Errorf("创建标签失败: %v", err)
+			}
+		}
+	}
+	return d.call(ctx, "label.set_torrent", nil, hash, label)
+}
+
+func (d *deluge) SetSavePath(ctx context.Context, hash, path string) error {
+	return d.call(ctx, "core.move_storage", nil, []string{hash}, path)
+}
+
+// status 按过滤条件获取种子
+func (d *deluge) status(ctx context.Context, filter map[string]interface{}) ([]Torrent, error) {
+	var raw map[string]delugeTorrent
+	if err := d.call(ctx, "core.get_torrents_status", &raw, filter, delugeKeys); err != nil {
+		return nil, fmt.Errorf("获取种子列表失败: %v", err)
+	}
+	torrents := make([]Torrent, 0, len(raw))
+	for hash, r := range raw {
+		if r.Hash == "" {
+			r.Hash = hash
+		}
+		torrents = append(torrents, r.torrent())
+	}
+	return torrents, nil
+}
+
+// torrent 转换为统一的种子结构
+func (r delugeTorrent) torrent() Torrent {
+	savePath := r.DownloadLocation
+	if savePath == "" {
+		savePath = r.SavePath
+	}
+	torrent := Torrent{
+		Hash:          strings.ToLower(r.Hash),
+		Name:          r.Name,
+		RawState:      r.State,
+		Progress:      r.Progress / 100,
+		Size:          r.TotalWanted,
+		Downloaded:    r.TotalDone,
+		DownloadSpeed: int64(r.DownloadRate),
+		SavePath:      savePath,
+		ContentPath:   joinPath(savePath, r.Name),
+		Category:      r.Label,
+	}
+	if r.Eta > 0 {
+		torrent.ETA = int64(r.Eta)
+	}
+
+	switch r.State {
+	case "Error":
+		torrent.State = StateError
+		torrent.ErrorMessage = r.Message
+	case "Seeding":
+		torrent.State = StateSeeding
+	case "Paused":
+		if r.TotalWanted > 0 && r.TotalDone >= r.TotalWanted {
+			torrent.State = StateCompleted
+		} else {
+			torrent.State = StatePaused
+		}
+	case "Queued":
+		if r.TotalWanted > 0 && r.TotalDone >= r.TotalWanted {
+			torrent.State = StateSeeding
+		} else {
+			torrent.State = StateQueued
+		}
+	case "Checking", "Moving":
+		torrent.State = StateChecking
+	default:
+		torrent.State = StateDownloading
+	}
+	return torrent
+}
+
+// call 调用 JSON-RPC 方法，未登录或会话过期时登录并连接守护进程后重试一次
+func (d *deluge) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if err := d.login(ctx, false); err != nil {
+		return err
+	}
+	err := d.rpc(ctx, method, result, params...)
+	if rpcErr, ok := err.(*delugeError); ok && rpcErr.Code == delugeErrNotAuthenticated {
+		if err := d.login(ctx, true); err != nil {
+			return err
+		}
+		err = d.rpc(ctx, method, result, params...)
+	}
+	return err
+}
+
+// login 登录 Deluge Web，Web 界面未连接守护进程时连接第一个守护进程
+func (d *deluge) login(ctx context.Context, refresh bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.authed && !refresh {
+		return nil
+	}
+
+	var ok bool
+	if err := d.rpc(ctx, "auth.login", &ok, d.cfg.Password); err != nil {
+		return fmt.Errorf("登录Deluge失败: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("登录Deluge失败，密码错误")
+	}
+
+	var connected bool
+	if err := d.rpc(ctx, "web.connected", &connected); err != nil {
+		return fmt.Errorf("获取Deluge连接状态失败: %v", err)
+	}
+	if !connected {
+		var hosts [][]interface{}
+		if err := d.rpc(ctx, "web.get_hosts", &hosts); err != nil {
+			return fmt.Errorf("获取Deluge守护进程列表失败: %v", err)
+		}
+		if len(hosts) == 0 || len(hosts[0]) == 0 {
+			return fmt.Errorf("Deluge Web 未配置守护进程")
+		}
+		if err := d.rpc(ctx, "web.connect", nil, hosts[0][0]); err != nil {
+			return fmt.Errorf("连接Deluge守护进程失败: %v", err)
+		}
+	}
+	d.authed = true
+	return nil
+}
+
+func (d *deluge) rpc(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":     d.id.Add(1),
+		"method": method,
+		"params": params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	body, err := readResponse(d.client.Do(req))
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *delugeError    `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result != nil && len(response.Result) > 0 {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// statusError 下载器返回了非 2xx 状态码
+type statusError struct {
+	code int
+	body string
+}
+
+func (e statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("状态码: %d", e.code)
+	}
+	return fmt.Sprintf("状态码: %d，响应: %s", e.code, e.body)
+}
+
+func asStatusError(err error, target *statusError) bool {
+	return err != nil && errors.As(err, target)
+}
+
+// readResponse 读取响应内容，非 2xx 状态码返回 statusError
+func readResponse(resp *http.Response, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		text := strings.TrimSpace(string(body))
+		if len(text) > 200 {
+			text = text[:200]
+		}
+		return nil, statusError{code: resp.StatusCode, body: text}
+	}
+	return body, nil
+}
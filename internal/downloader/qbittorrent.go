@@ -0,0 +1,285 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// qBittorrent 无法估计剩余时间时返回的 eta
+const qbittorrentEtaInfinity = 8640000
+
+// qbittorrent qBittorrent Web API v2 客户端
+type qbittorrent struct {
+	cfg    Config
+	client *http.Client
+
+	mu      sync.Mutex
+	cookies []*http.Cookie
+}
+
+func newQBittorrent(cfg Config) *qbittorrent {
+	return &qbittorrent{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// qbittorrentTorrent /api/v2/torrents/info 返回的种子
+type qbittorrentTorrent struct {
+	Hash        string  `json:"hash"`
+	Name        string  `json:"name"`
+	MagnetURI   string  `json:"magnet_uri"`
+	Progress    float64 `json:"progress"`
+	DlSpeed     int64   `json:"dlspeed"`
+	Downloaded  int64   `json:"downloaded"`
+	Size        int64   `json:"size"`
+	Eta         int64   `json:"eta"`
+	State       string  `json:"state"`
+	Category    string  `json:"category"`
+	ContentPath string  `json:"content_path"`
+	SavePath    string  `json:"save_path"`
+}
+
+func (q *qbittorrent) Name() string {
+	return TypeQBittorrent
+}
+
+// Add 添加种子，urls 参数同时支持磁力链接与 HTTP 种子链接
+func (q *qbittorrent) Add(ctx context.Context, uri string, opts AddOptions) error {
+	form := url.Values{
+		"urls":        {uri},
+		"root_folder": {"false"},
+	}
+	if opts.SavePath != "" {
+		form.Set("savepath", opts.SavePath)
+	}
+	if opts.Category != "" {
+		form.Set("category", opts.Category)
+	}
+	if len(opts.Tags) > 0 {
+		form.Set("tags", strings.Join(opts.Tags, ","))
+	}
+	// qBittorrent 5.0 起使用 stopped 代替 paused
+	paused := fmt.Sprint(opts.Paused)
+	form.Set("paused", paused)
+	form.Set("stopped", paused)
+
+	body, err := q.post(ctx, "/api/v2/torrents/add", form)
+	if err != nil {
+		return fmt.Errorf("添加种子到qBittorrent失败: %v", err)
+	}
+	if strings.TrimSpace(string(body)) == "Fails." {
+		return fmt.Errorf("qBittorrent 拒绝添加种子，可能是种子%w", ErrTorrentExists)
+	}
+	return nil
+}
+
+func (q *qbittorrent) List(ctx context.Context) ([]Torrent, error) {
+	return q.info(ctx, "")
+}
+
+func (q *qbittorrent) Get(ctx context.Context, hash string) (*Torrent, error) {
+	torrents, err := q.info(ctx, strings.ToLower(hash))
+	if err != nil {
+		return nil, err
+	}
+	return find(torrents, hash)
+}
+
+// Pause 暂停种子，qBittorrent 5.0 起接口改名为 stop
+func (q *qbittorrent) Pause(ctx context.Context, hash string) error {
+	return q.action(ctx, []string{"/api/v2/torrents/stop", "/api/v2/torrents/pause"}, url.Values{"hashes": {hash}})
+}
+
+// Resume 恢复种子，qBittorrent 5.0 起接口改名为 start
+func (q *qbittorrent) Resume(ctx context.Context, hash string) error {
+	return q.action(ctx, []string{"/api/v2/torrents/start", "/api/v2/torrents/resume"}, url.Values{"hashes": {hash}})
+}
+
+func (q *qbittorrent) Remove(ctx context.Context, hash string, deleteFiles bool) error {
+	_, err := q.post(ctx, "/api/v2/torrents/delete", url.Values{
+		"hashes":      {hash},
+		"deleteFiles": {fmt.Sprint(deleteFiles)},
+	})
+	return err
+}
+
+// SetCategory 设置分类，分类不存在时先创建
+func (q *qbittorrent) SetCategory(ctx context.Context, hash, category string) error {
+	form := url.Values{"hashes": {hash}, "category": {category}}
+	_, err := q.post(ctx, "/api/v2/torrents/setCategory", form)
+	var status statusError
+	if category != "" && asStatusError(err, &status) && status.code == http.StatusConflict {
+		if _, err := q.post(ctx, "/api/v2/torrents/createCategory", url.Values{"category": {category}}); err != nil {
+			return fmt.Errorf("创建分类失败: %v", err)
+		}
+		_, err = q.post(ctx, "/api/v2/torrents/setCategory", form)
+	}
+	return err
+}
+
+func (q *qbittorrent) SetSavePath(ctx context.Context, hash, path string) error {
+	_, err := q.post(ctx, "/api/v2/torrents/setLocation", url.Values{
+		"hashes":   {hash},
+		"location": {path},
+	})
+	return err
+}
+
+// info 获取种子列表，hash 为空时返回全部种子
+func (q *qbittorrent) info(ctx context.Context, hash string) ([]Torrent, error) {
+	path := "/api/v2/torrents/info"
+	if hash != "" {
+		path += "?hashes=" + url.QueryEscape(hash)
+	}
+	body, err := q.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取种子列表失败: %v", err)
+	}
+
+	var raw []qbittorrentTorrent
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("解析种子列表失败: %v", err)
+	}
+	torrents := make([]Torrent, 0, len(raw))
+	for _, t := range raw {
+		eta := t.Eta
+		if eta >= qbittorrentEtaInfinity || eta < 0 {
+			eta = 0
+		}
+		torrents = append(torrents, Torrent{
+			Hash:          strings.ToLower(t.Hash),
+			Name:          t.Name,
+			State:         qbittorrentState(t.State),
+			RawState:      t.State,
+			Progress:      t.Progress,
+			Size:          t.Size,
+			Downloaded:    t.Downloaded,
+			DownloadSpeed: t.DlSpeed,
+			ETA:           eta,
+			SavePath:      t.SavePath,
+			ContentPath:   t.ContentPath,
+			Category:      t.Category,
+			MagnetURI:     t.MagnetURI,
+		})
+	}
+	return torrents, nil
+}
+
+// qbittorrentState 转换 qBittorrent 种子状态
+func qbittorrentState(state string) string {
+	switch state {
+	case "error", "missingFiles":
+		return StateError
+	case "uploading", "stalledUP", "forcedUP", "queuedUP":
+		return StateSeeding
+	case "pausedUP", "stoppedUP":
+		return StateCompleted
+	case "pausedDL", "stoppedDL":
+		return StatePaused
+	case "queuedDL":
+		return StateQueued
+	case "checkingUP", "checkingDL", "checkingResumeData", "moving":
+		return StateChecking
+	}
+	return StateDownloading
+}
+
+// action 依次尝试新旧版本的接口，直到接口存在
+func (q *qbittorrent) action(ctx context.Context, paths []string, form url.Values) error {
+	var err error
+	for _, path := range paths {
+		_, err = q.post(ctx, path, form)
+		var status statusError
+		if !asStatusError(err, &status) || status.code != http.StatusNotFound {
+			return err
+		}
+	}
+	return err
+}
+
+func (q *qbittorrent) post(ctx context.Context, path string, form url.Values) ([]byte, error) {
+	return q.do(ctx, http.MethodPost, path, form)
+}
+
+// do 发送请求，未登录或会话过期（403）时重新登录后重试一次
+func (q *qbittorrent) do(ctx context.Context, method, path string, form url.Values) ([]byte, error) {
+	cookies, err := q.session(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	body, err := q.send(ctx, method, path, form, cookies)
+	var status statusError
+	if asStatusError(err, &status) && status.code == http.StatusForbidden {
+		if cookies, err = q.session(ctx, true); err != nil {
+			return nil, err
+		}
+		body, err = q.send(ctx, method, path, form, cookies)
+	}
+	return body, err
+}
+
+func (q *qbittorrent) send(ctx context.Context, method, path string, form url.Values, cookies []*http.Cookie) ([]byte, error) {
+	var reader io.Reader
+	if form != nil {
+		reader = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, q.cfg.Host+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return readResponse(q.client.Do(req))
+}
+
+// session 返回登录 Cookie，refresh 为 true 时重新登录
+func (q *qbittorrent) session(ctx context.Context, refresh bool) ([]*http.Cookie, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.cookies) > 0 && !refresh {
+		return q.cookies, nil
+	}
+
+	form := url.Values{
+		"username": {q.cfg.Username},
+		"password": {q.cfg.Password},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.cfg.Host+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// qBittorrent 校验 Referer/Origin 防止 CSRF
+	req.Header.Set("Referer", q.cfg.Host)
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("登录qBittorrent失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("登录qBittorrent失败，状态码: %d", resp.StatusCode)
+	}
+	if strings.TrimSpace(string(body)) == "Fails." {
+		return nil, fmt.Errorf("登录qBittorrent失败，用户名或密码错误")
+	}
+	cookies := resp.Cookies()
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("未获取到qBittorrent登录Cookie")
+	}
+	q.cookies = cookies
+	return cookies, nil
+}
@@ -0,0 +1,294 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// transmissionSessionHeader Transmission 防 CSRF 的会话头，过期时返回 409 并在响应头中给出新的值
+const transmissionSessionHeader = "X-Transmission-Session-Id"
+
+// transmissionFields torrent-get 请求的字段
+var transmissionFields = []string{
+	"hashString", "name", "status", "error", "errorString", "percentDone", "sizeWhenDone",
+	"leftUntilDone", "rateDownload", "eta", "downloadDir", "labels", "magnetLink",
+}
+
+// transmission Transmission RPC 客户端
+type transmission struct {
+	cfg      Config
+	endpoint string
+	client   *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+func newTransmission(cfg Config) *transmission {
+	endpoint := cfg.Host
+	if !strings.HasSuffix(endpoint, "/rpc") {
+		endpoint += "/transmission/rpc"
+	}
+	return &transmission{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// transmissionTorrent torrent-get 返回的种子
+type transmissionTorrent struct {
+	HashString    string   `json:"hashString"`
+	Name          string   `json:"name"`
+	Status        int      `json:"status"`
+	Error         int      `json:"error"`
+	ErrorString   string   `json:"errorString"`
+	PercentDone   float64  `json:"percentDone"`
+	SizeWhenDone  int64    `json:"sizeWhenDone"`
+	LeftUntilDone int64    `json:"leftUntilDone"`
+	RateDownload  int64    `json:"rateDownload"`
+	Eta           int64    `json:"eta"`
+	DownloadDir   string   `json:"downloadDir"`
+	Labels        []string `json:"labels"`
+	MagnetLink    string   `json:"magnetLink"`
+}
+
+func (t *transmission) Name() string {
+	return TypeTransmission
+}
+
+// Add 添加种子，filename 参数同时支持磁力链接与 HTTP 种子链接；分类与标签写入 labels
+func (t *transmission) Add(ctx context.Context, uri string, opts AddOptions) error {
+	args := map[string]interface{}{
+		"filename": uri,
+		"paused":   opts.Paused,
+	}
+	if opts.SavePath != "" {
+		args["download-dir"] = opts.SavePath
+	}
+	var labels []string
+	if opts.Category != "" {
+		labels = append(labels, opts.Category)
+	}
+	for _, tag := range opts.Tags {
+		if tag != opts.Category {
+			labels = append(labels, tag)
+		}
+	}
+	if len(labels) > 0 {
+		args["labels"] = labels
+	}
+
+	var result struct {
+		Duplicate *transmissionTorrent `json:"torrent-duplicate"`
+	}
+	if err := t.call(ctx, "torrent-add", args, &result); err != nil {
+		return fmt.Errorf("添加种子到Transmission失败: %v", err)
+	}
+	if result.Duplicate != nil {
+		return fmt.Errorf("种子 '%s' %w", result.Duplicate.Name, ErrTorrentExists)
+	}
+	return nil
+}
+
+func (t *transmission) List(ctx context.Context) ([]Torrent, error) {
+	return t.get(ctx, nil)
+}
+
+func (t *transmission) Get(ctx context.Context, hash string) (*Torrent, error) {
+	torrents, err := t.get(ctx, []string{strings.ToLower(hash)})
+	if err != nil {
+		return nil, err
+	}
+	return find(torrents, hash)
+}
+
+func (t *transmission) Pause(ctx context.Context, hash string) error {
+	return t.call(ctx, "torrent-stop", map[string]interface{}{"ids": []string{hash}}, nil)
+}
+
+func (t *transmission) Resume(ctx context.Context, hash string) error {
+	return t.call(ctx, "torrent-start", map[string]interface{}{"ids": []string{hash}}, nil)
+}
+
+func (t *transmission) Remove(ctx context.Context, hash string, deleteFiles bool) error {
+	return t.call(ctx, "torrent-remove", map[string]interface{}{
+		"ids":               []string{hash},
+		"delete-local-data": deleteFiles,
+	}, nil)
+}
+
+// SetCategory 将分类设置为第一个标签，保留其余标签
+func (t *transmission) SetCategory(ctx context.Context, hash, category string) error {
+	torrent, err := t.getRaw(ctx, hash)
+	if err != nil {
+		return err
+	}
+	labels := []string{}
+	if category != "" {
+		labels = append(labels, category)
+	}
+	for i, label := range torrent.Labels {
+		if i > 0 && label != category {
+			labels = append(labels, label)
+		}
+	}
+	return t.call(ctx, "torrent-set", map[string]interface{}{
+		"ids":    []string{hash},
+		"labels": labels,
+	}, nil)
+}
+
+func (t *transmission) SetSavePath(ctx context.Context, hash, path string) error {
+	return t.call(ctx, "torrent-set-location", map[string]interface{}{
+		"ids":      []string{hash},
+		"location": path,
+		"move":     true,
+	}, nil)
+}
+
+// get 获取种子列表，ids 为空时返回全部种子
+func (t *transmission) get(ctx context.Context, ids []string) ([]Torrent, error) {
+	raw, err := t.list(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	torrents := make([]Torrent, 0, len(raw))
+	for _, r := range raw {
+		torrents = append(torrents, r.torrent())
+	}
+	return torrents, nil
+}
+
+func (t *transmission) getRaw(ctx context.Context, hash string) (*transmissionTorrent, error) {
+	raw, err := t.list(ctx, []string{strings.ToLower(hash)})
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, ErrTorrentNotFound
+	}
+	return &raw[0], nil
+}
+
+func (t *transmission) list(ctx context.Context, ids []string) ([]transmissionTorrent, error) {
+	args := map[string]interface{}{"fields": transmissionFields}
+	if len(ids) > 0 {
+		args["ids"] = ids
+	}
+	var result struct {
+		Torrents []transmissionTorrent `json:"torrents"`
+	}
+	if err := t.call(ctx, "torrent-get", args, &result); err != nil {
+		return nil, fmt.Errorf("获取种子列表失败: %v", err)
+	}
+	return result.Torrents, nil
+}
+
+// torrent 转换为统一的种子结构
+func (r transmissionTorrent) torrent() Torrent {
+	torrent := Torrent{
+		Hash:          strings.ToLower(r.HashString),
+		Name:          r.Name,
+		RawState:      fmt.Sprint(r.Status),
+		Progress:      r.PercentDone,
+		Size:          r.SizeWhenDone,
+		Downloaded:    r.SizeWhenDone - r.LeftUntilDone,
+		DownloadSpeed: r.RateDownload,
+		SavePath:      r.DownloadDir,
+		ContentPath:   joinPath(r.DownloadDir, r.Name),
+		MagnetURI:     r.MagnetLink,
+		ErrorMessage:  r.ErrorString,
+	}
+	if r.Eta > 0 {
+		torrent.ETA = r.Eta
+	}
+	if len(r.Labels) > 0 {
+		torrent.Category = r.Labels[0]
+	}
+
+	// status: 0 停止, 1 等待校验, 2 校验中, 3 等待下载, 4 下载中, 5 等待做种, 6 做种中
+	// error: 1、2 为 Tracker 警告与错误，不影响下载；3 为本地错误（如磁盘写入失败）
+	switch {
+	case r.Error == 3:
+		torrent.State = StateError
+	case r.Status == 1 || r.Status == 2:
+		torrent.State = StateChecking
+	case r.Status == 3:
+		torrent.State = StateQueued
+	case r.Status == 5 || r.Status == 6:
+		torrent.State = StateSeeding
+	case r.Status == 0 && r.LeftUntilDone == 0 && r.SizeWhenDone > 0:
+		torrent.State = StateCompleted
+	case r.Status == 0:
+		torrent.State = StatePaused
+	default:
+		torrent.State = StateDownloading
+	}
+	return torrent
+}
+
+// call 调用 RPC 方法，会话过期（409）时使用响应头中新的会话 ID 重试一次
+func (t *transmission) call(ctx context.Context, method string, args map[string]interface{}, result interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"method":    method,
+		"arguments": args,
+	})
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if t.cfg.Username != "" || t.cfg.Password != "" {
+			req.SetBasicAuth(t.cfg.Username, t.cfg.Password)
+		}
+		t.mu.Lock()
+		req.Header.Set(transmissionSessionHeader, t.sessionID)
+		t.mu.Unlock()
+
+		resp, err := t.client.Do(req)
+		if err == nil && resp.StatusCode == http.StatusConflict {
+			t.mu.Lock()
+			t.sessionID = resp.Header.Get(transmissionSessionHeader)
+			t.mu.Unlock()
+			resp.Body.Close()
+			continue
+		}
+		body, err = readResponse(resp, err)
+		if err != nil {
+			return err
+		}
+		break
+	}
+	if body == nil {
+		return fmt.Errorf("获取Transmission会话ID失败")
+	}
+
+	var response struct {
+		Result    string          `json:"result"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if response.Result != "success" {
+		return fmt.Errorf("%s", response.Result)
+	}
+	if result != nil && len(response.Arguments) > 0 {
+		if err := json.Unmarshal(response.Arguments, result); err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+	return nil
+}
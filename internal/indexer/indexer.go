@@ -0,0 +1,220 @@
+// Package indexer 种子索引器，支持标准 Torznab/Newznab 接口（Prowlarr、Jackett 单索引器 Feed 等）
+// 以及 Jackett 聚合搜索接口
+//
+// 多个索引器并行查询，每个索引器有独立的超时、分类与启用开关，结果按 info hash 合并去重。
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nsfw-go/internal/downloader"
+)
+
+// 支持的索引器类型
+const (
+	TypeTorznab = "torznab"
+	TypeNewznab = "newznab"
+	TypeJackett = "jackett" // Jackett 聚合搜索接口 /api/v2.0/indexers/all/results
+)
+
+// DefaultCategories 默认搜索分类：Torznab 标准分类 6000（XXX）及其子分类
+var DefaultCategories = []int{6000, 6010, 6060, 6080}
+
+// Result 索引器返回的种子
+type Result struct {
+	Title       string    `json:"title"`
+	Link        string    `json:"link"`       // 种子文件下载链接
+	MagnetURI   string    `json:"magnet_uri"` // 磁力链接
+	InfoHash    string    `json:"info_hash"`  // info hash（小写）
+	Size        int64     `json:"size"`
+	Seeders     int       `json:"seeders"`
+	Leechers    int       `json:"leechers"`
+	PublishDate time.Time `json:"publish_date"`
+	Categories  []int     `json:"categories"`
+	Tracker     string    `json:"tracker"` // 来源站点，索引器不提供时为索引器名称
+	Indexer     string    `json:"indexer"` // 返回该结果的索引器
+}
+
+// Caps 索引器能力（t=caps）
+type Caps struct {
+	SearchAvailable bool       `json:"search_available"`
+	SearchParams    []string   `json:"search_params"`
+	Categories      []Category `json:"categories"`
+}
+
+// Category 索引器分类
+type Category struct {
+	ID      int        `json:"id"`
+	Name    string     `json:"name"`
+	Subcats []Category `json:"subcats,omitempty"`
+}
+
+// Indexer 种子索引器
+type Indexer interface {
+	// Name 索引器名称
+	Name() string
+	// Search 按关键词搜索
+	Search(ctx context.Context, query string) ([]Result, error)
+	// Caps 获取索引器能力
+	Caps(ctx context.Context) (*Caps, error)
+}
+
+// Config 索引器配置
+type Config struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`       // torznab（默认）、newznab 或 jackett
+	URL        string        `json:"url"`        // Torznab 接口地址，如 http://prowlarr:9696/1/api；jackett 类型为 Jackett 地址
+	APIKey     string        `json:"api_key"`    // API 密钥
+	Categories []int         `json:"categories"` // 搜索分类，为空时使用 DefaultCategories
+	Timeout    time.Duration `json:"timeout"`    // 单次请求超时，默认 30 秒
+	Enabled    *bool         `json:"enabled"`    // 为空时启用
+}
+
+// IsEnabled 是否启用
+func (c Config) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// New 按配置创建索引器
+func New(cfg Config) (Indexer, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("索引器 %s 的地址不能为空", cfg.Name)
+	}
+	if cfg.Name == "" {
+		if u, err := url.Parse(cfg.URL); err == nil {
+			cfg.Name = u.Host
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+
+	switch strings.ToLower(cfg.Type) {
+	case "", TypeTorznab, TypeNewznab:
+		return newTorznab(cfg), nil
+	case TypeJackett:
+		return newJackett(cfg), nil
+	}
+	return nil, fmt.Errorf("索引器 %s 的类型 %s 不支持", cfg.Name, cfg.Type)
+}
+
+// SearchError 单个索引器的搜索错误
+type SearchError struct {
+	Indexer string
+	Err     error
+}
+
+func (e SearchError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Indexer, e.Err)
+}
+
+func (e SearchError) Unwrap() error {
+	return e.Err
+}
+
+// Searcher 并行查询多个索引器
+type Searcher struct {
+	indexers []Indexer
+}
+
+// NewSearcher 创建多索引器搜索
+func NewSearcher(indexers ...Indexer) *Searcher {
+	return &Searcher{indexers: indexers}
+}
+
+// Indexers 已启用的索引器
+func (s *Searcher) Indexers() []Indexer {
+	return s.indexers
+}
+
+// Search 并行查询全部索引器，结果按 info hash 合并去重；
+// 部分索引器失败时返回其余结果与失败列表，全部失败时返回错误
+func (s *Searcher) Search(ctx context.Context, query string) ([]Result, []SearchError, error) {
+	if len(s.indexers) == 0 {
+		return nil, nil, fmt.Errorf("没有可用的索引器")
+	}
+
+	type response struct {
+		results []Result
+		err     error
+	}
+	responses := make([]response, len(s.indexers))
+	var wg sync.WaitGroup
+	for i, idx := range s.indexers {
+		wg.Add(1)
+		go func(i int, idx Indexer) {
+			defer wg.Done()
+			results, err := idx.Search(ctx, query)
+			responses[i] = response{results: results, err: err}
+		}(i, idx)
+	}
+	wg.Wait()
+
+	var all []Result
+	var failures []SearchError
+	for i, resp := range responses {
+		if resp.err != nil {
+			failures = append(failures, SearchError{Indexer: s.indexers[i].Name(), Err: resp.err})
+			continue
+		}
+		all = append(all, resp.results...)
+	}
+	if len(failures) == len(s.indexers) {
+		return nil, failures, fmt.Errorf("全部索引器搜索失败: %v", failures[0])
+	}
+	return Merge(all), failures, nil
+}
+
+// Merge 按 info hash 合并重复的种子，保留做种数最多的一条并补全缺失的链接；
+// 没有 info hash 的种子按下载链接去重
+func Merge(results []Result) []Result {
+	merged := make([]Result, 0, len(results))
+	index := make(map[string]int, len(results))
+	for _, r := range results {
+		r.InfoHash = strings.ToLower(r.InfoHash)
+		if r.InfoHash == "" {
+			r.InfoHash = downloader.MagnetInfoHash(r.MagnetURI)
+		}
+		key := r.InfoHash
+		if key == "" {
+			key = "link:" + r.Link
+		}
+		if key == "link:" {
+			merged = append(merged, r)
+			continue
+		}
+
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, r)
+			continue
+		}
+		existing := merged[i]
+		if r.Seeders > existing.Seeders {
+			r, existing = existing, r
+		}
+		if existing.MagnetURI == "" {
+			existing.MagnetURI = r.MagnetURI
+		}
+		if existing.Link == "" {
+			existing.Link = r.Link
+		}
+		if existing.Size == 0 {
+			existing.Size = r.Size
+		}
+		merged[i] = existing
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Seeders > merged[j].Seeders
+	})
+	return merged
+}
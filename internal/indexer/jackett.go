@@ -0,0 +1,117 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jackettDefaultCategories Jackett 聚合搜索的默认分类：标准 XXX 分类与常见成人站点的自定义分类
+var jackettDefaultCategories = []int{
+	6000, 6010, 6060, 6080,
+	100431, 100437, 100410, 100424,
+	100432, 100426, 100429, 100430,
+	100436, 100433, 100425,
+}
+
+// jackett Jackett 聚合搜索接口，一次请求查询 Jackett 中的全部索引器
+type jackett struct {
+	cfg    Config
+	client *http.Client
+}
+
+func newJackett(cfg Config) *jackett {
+	if len(cfg.Categories) == 0 {
+		cfg.Categories = jackettDefaultCategories
+	}
+	return &jackett{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// jackettResponse Jackett API响应结构
+type jackettResponse struct {
+	Results []struct {
+		Title       string `json:"Title"`
+		TrackerID   string `json:"TrackerId"`
+		Tracker     string `json:"Tracker"`
+		CategoryID  int    `json:"CategoryId"`
+		Category    []int  `json:"Category"` // Category 是数组而不是字符串
+		Size        int64  `json:"Size"`
+		Link        string `json:"Link"`
+		PublishDate string `json:"PublishDate"`
+		Seeders     int    `json:"Seeders"`
+		Peers       int    `json:"Peers"`
+		InfoHash    string `json:"InfoHash"`
+		MagnetURI   string `json:"MagnetUri"`
+	} `json:"Results"`
+}
+
+func (j *jackett) Name() string {
+	return j.cfg.Name
+}
+
+// Search 调用 /api/v2.0/indexers/all/results 搜索
+func (j *jackett) Search(ctx context.Context, query string) ([]Result, error) {
+	params := url.Values{"apikey": {j.cfg.APIKey}, "Query": {query}}
+	for _, cat := range j.cfg.Categories {
+		params.Add("Category[]", strconv.Itoa(cat))
+	}
+	apiURL := fmt.Sprintf("%s/api/v2.0/indexers/all/results?%s", j.cfg.URL, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("调用Jackett API失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Jackett API返回错误状态码: %d", resp.StatusCode)
+	}
+
+	var jackettResp jackettResponse
+	if err := json.NewDecoder(resp.Body).Decode(&jackettResp); err != nil {
+		return nil, fmt.Errorf("解析Jackett响应失败: %v", err)
+	}
+
+	results := make([]Result, 0, len(jackettResp.Results))
+	for _, item := range jackettResp.Results {
+		r := Result{
+			Title:      item.Title,
+			Link:       item.Link,
+			MagnetURI:  item.MagnetURI,
+			InfoHash:   strings.ToLower(item.InfoHash),
+			Size:       item.Size,
+			Seeders:    item.Seeders,
+			Leechers:   item.Peers,
+			Categories: item.Category,
+			Tracker:    item.Tracker,
+			Indexer:    j.cfg.Name,
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+			if pub, err := time.Parse(layout, item.PublishDate); err == nil {
+				r.PublishDate = pub
+				break
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Caps 通过 Jackett 聚合索引器的 Torznab 接口获取能力
+func (j *jackett) Caps(ctx context.Context) (*Caps, error) {
+	cfg := j.cfg
+	cfg.URL = j.cfg.URL + "/api/v2.0/indexers/all/results/torznab/api"
+	return newTorznab(cfg).Caps(ctx)
+}
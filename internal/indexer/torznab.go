@@ -0,0 +1,244 @@
+package indexer
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// torznab Torznab/Newznab 接口，Prowlarr 的 /{id}/api 与 Jackett 的 /api/v2.0/indexers/{id}/results/torznab/api
+type torznab struct {
+	cfg    Config
+	client *http.Client
+}
+
+func newTorznab(cfg Config) *torznab {
+	if len(cfg.Categories) == 0 {
+		cfg.Categories = DefaultCategories
+	}
+	return &torznab{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// torznabFeed t=search 返回的 RSS
+type torznabFeed struct {
+	Items []torznabItem `xml:"channel>item"`
+}
+
+type torznabItem struct {
+	Title     string `xml:"title"`
+	GUID      string `xml:"guid"`
+	Link      string `xml:"link"`
+	PubDate   string `xml:"pubDate"`
+	Size      int64  `xml:"size"`
+	Enclosure struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+	} `xml:"enclosure"`
+	Categories []string `xml:"category"`
+	// torznab:attr 与 newznab:attr 按本地名称匹配
+	Attrs []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"attr"`
+	JackettIndexer  string `xml:"jackettindexer"`
+	ProwlarrIndexer string `xml:"prowlarrindexer"`
+}
+
+// torznabError 接口返回的错误，如 <error code="100" description="Invalid API Key"/>
+type torznabError struct {
+	XMLName     xml.Name `xml:"error"`
+	Code        int      `xml:"code,attr"`
+	Description string   `xml:"description,attr"`
+}
+
+// torznabCaps t=caps 返回的能力描述
+type torznabCaps struct {
+	Searching struct {
+		Search struct {
+			Available       string `xml:"available,attr"`
+			SupportedParams string `xml:"supportedParams,attr"`
+		} `xml:"search"`
+	} `xml:"searching"`
+	Categories []struct {
+		ID      int    `xml:"id,attr"`
+		Name    string `xml:"name,attr"`
+		Subcats []struct {
+			ID   int    `xml:"id,attr"`
+			Name string `xml:"name,attr"`
+		} `xml:"subcat"`
+	} `xml:"categories>category"`
+}
+
+func (t *torznab) Name() string {
+	return t.cfg.Name
+}
+
+// Search t=search 按关键词搜索
+func (t *torznab) Search(ctx context.Context, query string) ([]Result, error) {
+	params := url.Values{"t": {"search"}, "q": {query}, "extended": {"1"}}
+	cats := make([]string, 0, len(t.cfg.Categories))
+	for _, cat := range t.cfg.Categories {
+		cats = append(cats, strconv.Itoa(cat))
+	}
+	if len(cats) > 0 {
+		params.Set("cat", strings.Join(cats, ","))
+	}
+
+	body, err := t.get(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	var feed torznabFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("解析搜索结果失败: %v", err)
+	}
+
+	results := make([]Result, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		results = append(results, t.result(item))
+	}
+	return results, nil
+}
+
+// Caps t=caps 获取索引器能力
+func (t *torznab) Caps(ctx context.Context) (*Caps, error) {
+	body, err := t.get(ctx, url.Values{"t": {"caps"}})
+	if err != nil {
+		return nil, err
+	}
+	var raw torznabCaps
+	if err := xml.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("解析索引器能力失败: %v", err)
+	}
+
+	caps := &Caps{SearchAvailable: raw.Searching.Search.Available == "yes"}
+	for _, param := range strings.Split(raw.Searching.Search.SupportedParams, ",") {
+		if param = strings.TrimSpace(param); param != "" {
+			caps.SearchParams = append(caps.SearchParams, param)
+		}
+	}
+	for _, c := range raw.Categories {
+		category := Category{ID: c.ID, Name: c.Name}
+		for _, sub := range c.Subcats {
+			category.Subcats = append(category.Subcats, Category{ID: sub.ID, Name: sub.Name})
+		}
+		caps.Categories = append(caps.Categories, category)
+	}
+	return caps, nil
+}
+
+// result 转换搜索结果，种子属性优先使用 torznab:attr
+func (t *torznab) result(item torznabItem) Result {
+	r := Result{
+		Title:   item.Title,
+		Size:    item.Size,
+		Tracker: t.cfg.Name,
+		Indexer: t.cfg.Name,
+	}
+	if r.Size == 0 {
+		r.Size = item.Enclosure.Length
+	}
+	for _, indexer := range []string{item.JackettIndexer, item.ProwlarrIndexer} {
+		if indexer = strings.TrimSpace(indexer); indexer != "" {
+			r.Tracker = indexer
+		}
+	}
+	if pub, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
+		r.PublishDate = pub
+	} else if pub, err := time.Parse(time.RFC1123, item.PubDate); err == nil {
+		r.PublishDate = pub
+	}
+
+	// 下载链接可能是种子文件，也可能直接是磁力链接
+	for _, link := range []string{item.Enclosure.URL, item.Link, item.GUID} {
+		switch {
+		case strings.HasPrefix(strings.ToLower(link), "magnet:"):
+			if r.MagnetURI == "" {
+				r.MagnetURI = link
+			}
+		case strings.HasPrefix(link, "http") && r.Link == "":
+			r.Link = link
+		}
+	}
+
+	peers := -1
+	for _, attr := range item.Attrs {
+		switch strings.ToLower(attr.Name) {
+		case "seeders":
+			r.Seeders, _ = strconv.Atoi(attr.Value)
+		case "peers":
+			peers, _ = strconv.Atoi(attr.Value)
+		case "leechers":
+			r.Leechers, _ = strconv.Atoi(attr.Value)
+		case "infohash":
+			r.InfoHash = strings.ToLower(attr.Value)
+		case "magneturl":
+			r.MagnetURI = attr.Value
+		case "size":
+			if size, err := strconv.ParseInt(attr.Value, 10, 64); err == nil && size > 0 {
+				r.Size = size
+			}
+		case "category":
+			if cat, err := strconv.Atoi(attr.Value); err == nil {
+				r.Categories = append(r.Categories, cat)
+			}
+		}
+	}
+	// Torznab 的 peers 包含做种者
+	if r.Leechers == 0 && peers > r.Seeders {
+		r.Leechers = peers - r.Seeders
+	}
+	if len(r.Categories) == 0 {
+		for _, c := range item.Categories {
+			if cat, err := strconv.Atoi(strings.TrimSpace(c)); err == nil {
+				r.Categories = append(r.Categories, cat)
+			}
+		}
+	}
+	return r
+}
+
+// get 请求接口，返回 XML 内容；接口返回 <error> 时转换为错误
+func (t *torznab) get(ctx context.Context, params url.Values) ([]byte, error) {
+	if t.cfg.APIKey != "" {
+		params.Set("apikey", t.cfg.APIKey)
+	}
+	apiURL := t.cfg.URL
+	if strings.Contains(apiURL, "?") {
+		apiURL += "&" + params.Encode()
+	} else {
+		apiURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求索引器失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取索引器响应失败: %v", err)
+	}
+	var apiErr torznabError
+	if xml.Unmarshal(body, &apiErr) == nil && apiErr.Description != "" {
+		return nil, fmt.Errorf("索引器返回错误 %d: %s", apiErr.Code, apiErr.Description)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("索引器返回错误状态码: %d", resp.StatusCode)
+	}
+	return body, nil
+}
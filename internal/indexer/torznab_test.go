@@ -0,0 +1,173 @@
+package indexer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const torznabSearchFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:torznab="http://torznab.com/schemas/2015/feed">
+<channel>
+  <item>
+    <title>SSIS-123 4K</title>
+    <guid>https://tracker.example/details/1</guid>
+    <link>https://prowlarr.example/1/download?id=1</link>
+    <pubDate>Wed, 14 Jan 2026 10:00:00 +0000</pubDate>
+    <size>1000</size>
+    <enclosure url="https://prowlarr.example/1/download?id=1" length="1000" type="application/x-bittorrent"/>
+    <prowlarrindexer>TrackerA</prowlarrindexer>
+    <torznab:attr name="seeders" value="12"/>
+    <torznab:attr name="peers" value="20"/>
+    <torznab:attr name="infohash" value="0123456789ABCDEF0123456789ABCDEF01234567"/>
+    <torznab:attr name="size" value="8589934592"/>
+    <torznab:attr name="category" value="6000"/>
+    <torznab:attr name="category" value="6010"/>
+  </item>
+  <item>
+    <title>SSIS-123-C</title>
+    <guid>magnet:?xt=urn:btih:abcdef</guid>
+    <pubDate>Wed, 14 Jan 2026 10:00:00 GMT</pubDate>
+    <enclosure url="https://jackett.example/dl/2" length="2048" type="application/x-bittorrent"/>
+    <category>6000</category>
+    <jackettindexer id="b"> TrackerB </jackettindexer>
+    <torznab:attr name="Seeders" value="3"/>
+    <torznab:attr name="leechers" value="4"/>
+    <torznab:attr name="peers" value="10"/>
+    <torznab:attr name="magneturl" value="magnet:?xt=urn:btih:fedcba"/>
+  </item>
+  <item>
+    <title>SSIS-123 no attrs</title>
+    <link>magnet:?xt=urn:btih:123456</link>
+    <newznab:attr xmlns:newznab="http://www.newznab.com/DTD/2010/feeds/attributes/" name="seeders" value="1"/>
+  </item>
+</channel>
+</rss>`
+
+func TestTorznabSearch(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(torznabSearchFeed))
+	}))
+	defer server.Close()
+
+	idx, err := New(Config{Name: "prowlarr", URL: server.URL + "/1/api", APIKey: "secret", Categories: []int{6000, 6010}})
+	if err != nil {
+		t.Fatalf("New 失败: %v", err)
+	}
+	results, err := idx.Search(context.Background(), "SSIS-123")
+	if err != nil {
+		t.Fatalf("Search 失败: %v", err)
+	}
+	for _, param := range []string{"t=search", "q=SSIS-123", "apikey=secret", "cat=6000%2C6010", "extended=1"} {
+		if !strings.Contains(query, param) {
+			t.Errorf("请求参数 %q 中缺少 %s", query, param)
+		}
+	}
+
+	want := []Result{
+		{
+			Title:       "SSIS-123 4K",
+			Link:        "https://prowlarr.example/1/download?id=1",
+			InfoHash:    "0123456789abcdef0123456789abcdef01234567",
+			Size:        8589934592,
+			Seeders:     12,
+			Leechers:    8,
+			PublishDate: time.Date(2026, 1, 14, 10, 0, 0, 0, time.UTC),
+			Categories:  []int{6000, 6010},
+			Tracker:     "TrackerA",
+			Indexer:     "prowlarr",
+		},
+		{
+			Title:       "SSIS-123-C",
+			Link:        "https://jackett.example/dl/2",
+			MagnetURI:   "magnet:?xt=urn:btih:fedcba",
+			Size:        2048,
+			Seeders:     3,
+			Leechers:    4,
+			PublishDate: time.Date(2026, 1, 14, 10, 0, 0, 0, time.UTC),
+			Categories:  []int{6000},
+			Tracker:     "TrackerB",
+			Indexer:     "prowlarr",
+		},
+		{
+			Title:     "SSIS-123 no attrs",
+			MagnetURI: "magnet:?xt=urn:btih:123456",
+			Seeders:   1,
+			Tracker:   "prowlarr",
+			Indexer:   "prowlarr",
+		},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("返回 %d 条结果，期望 %d 条", len(results), len(want))
+	}
+	for i := range want {
+		got := results[i]
+		if !got.PublishDate.Equal(want[i].PublishDate) {
+			t.Errorf("结果 %d 的发布时间 = %v，期望 %v", i, got.PublishDate, want[i].PublishDate)
+		}
+		got.PublishDate = want[i].PublishDate
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("结果 %d = %+v，期望 %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestTorznabCaps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<caps>
+  <searching><search available="yes" supportedParams="q, imdbid"/></searching>
+  <categories>
+    <category id="6000" name="XXX"><subcat id="6010" name="XXX/DVD"/></category>
+  </categories>
+</caps>`))
+	}))
+	defer server.Close()
+
+	idx, _ := New(Config{URL: server.URL})
+	caps, err := idx.Caps(context.Background())
+	if err != nil {
+		t.Fatalf("Caps 失败: %v", err)
+	}
+	want := &Caps{
+		SearchAvailable: true,
+		SearchParams:    []string{"q", "imdbid"},
+		Categories:      []Category{{ID: 6000, Name: "XXX", Subcats: []Category{{ID: 6010, Name: "XXX/DVD"}}}},
+	}
+	if !reflect.DeepEqual(caps, want) {
+		t.Errorf("Caps = %+v，期望 %+v", caps, want)
+	}
+}
+
+func TestTorznabErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"接口错误", http.StatusOK, `<error code="100" description="Invalid API Key"/>`, "Invalid API Key"},
+		{"状态码", http.StatusBadGateway, `bad gateway`, "502"},
+		{"无效的XML", http.StatusOK, `<rss><channel><item>`, "解析搜索结果失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			idx, _ := New(Config{URL: server.URL})
+			_, err := idx.Search(context.Background(), "SSIS-123")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Search 错误 = %v，期望包含 %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ type SystemConfig struct {
 	Download      DownloadConfig      `yaml:"download" json:"download"`
 	Notifications NotificationsConfig `yaml:"notifications" json:"notifications"`
 	Torrent       TorrentConfig       `yaml:"torrent" json:"torrent"`
+	Scheduler     SchedulerConfig     `yaml:"scheduler" json:"scheduler"`
 }
 
 // ServerConfig 服务器配置
@@ -72,11 +73,26 @@ type CrawlerConfig struct {
 
 // MediaConfig 媒体库配置
 type MediaConfig struct {
-	BasePath      string   `yaml:"base_path" json:"base_path"`
-	ScanInterval  int      `yaml:"scan_interval" json:"scan_interval"`
-	SupportedExts []string `yaml:"supported_exts" json:"supported_exts"`
-	MinFileSize   int      `yaml:"min_file_size" json:"min_file_size"`
-	MaxFileSize   int      `yaml:"max_file_size" json:"max_file_size"`
+	BasePath      string            `yaml:"base_path" json:"base_path"`
+	ScanInterval  int               `yaml:"scan_interval" json:"scan_interval"`
+	SupportedExts []string          `yaml:"supported_exts" json:"supported_exts"`
+	MinFileSize   int               `yaml:"min_file_size" json:"min_file_size"`
+	MaxFileSize   int               `yaml:"max_file_size" json:"max_file_size"`
+	WatchEnabled  bool              `yaml:"watch_enabled" json:"watch_enabled"`
+	Layout        string            `yaml:"layout" json:"layout"`
+	Layouts       map[string]string `yaml:"layouts" json:"layouts"`
+	Exclude       []string          `yaml:"exclude" json:"exclude"`
+	Roots         []MediaRoot       `yaml:"roots" json:"roots"`
+}
+
+// MediaRoot 媒体库根目录配置
+type MediaRoot struct {
+	Name         string   `yaml:"name" json:"name"`
+	Path         string   `yaml:"path" json:"path"`
+	Layout       string   `yaml:"layout" json:"layout"`               // 为空时使用全局布局
+	Exclude      []string `yaml:"exclude" json:"exclude"`             // 在全局排除规则之外追加
+	ScanInterval int      `yaml:"scan_interval" json:"scan_interval"` // 分钟，0 表示使用默认间隔
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
 }
 
 // SecurityConfig 安全配置
@@ -171,9 +187,13 @@ type ConfigTestRequest struct {
 
 // TorrentConfig 种子下载配置
 type TorrentConfig struct {
-	Jackett     JackettConfig     `yaml:"jackett" json:"jackett"`
-	QBittorrent QBittorrentConfig `yaml:"qbittorrent" json:"qbittorrent"`
-	Search      TorrentSearchConfig `yaml:"search" json:"search"`
+	Client       string             `yaml:"client" json:"client"` // 下载器：qbittorrent、transmission、aria2、deluge
+	Jackett      JackettConfig      `yaml:"jackett" json:"jackett"`
+	QBittorrent  QBittorrentConfig  `yaml:"qbittorrent" json:"qbittorrent"`
+	Transmission TransmissionConfig `yaml:"transmission" json:"transmission"`
+	Aria2        Aria2Config        `yaml:"aria2" json:"aria2"`
+	Deluge       DelugeConfig       `yaml:"deluge" json:"deluge"`
+	Search       TorrentSearchConfig `yaml:"search" json:"search"`
 }
 
 // JackettConfig Jackett配置
@@ -194,9 +214,44 @@ type QBittorrentConfig struct {
 	DownloadDir string `yaml:"download_dir" json:"download_dir"`
 }
 
+// TransmissionConfig Transmission配置
+type TransmissionConfig struct {
+	Host     string `yaml:"host" json:"host"` // 如 http://127.0.0.1:9091，也可以填写完整的 RPC 地址
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	Timeout  string `yaml:"timeout" json:"timeout"`
+}
+
+// Aria2Config Aria2配置
+type Aria2Config struct {
+	Host    string `yaml:"host" json:"host"`     // 如 http://127.0.0.1:6800，也可以填写完整的 /jsonrpc 地址
+	Secret  string `yaml:"secret" json:"secret"` // RPC 密钥（rpc-secret）
+	Timeout string `yaml:"timeout" json:"timeout"`
+}
+
+// DelugeConfig Deluge Web配置
+type DelugeConfig struct {
+	Host     string `yaml:"host" json:"host"`         // Deluge Web 地址，如 http://127.0.0.1:8112
+	Password string `yaml:"password" json:"password"` // Deluge Web 登录密码
+	Timeout  string `yaml:"timeout" json:"timeout"`
+}
+
 // TorrentSearchConfig 种子搜索配置
 type TorrentSearchConfig struct {
 	MaxResults int  `yaml:"max_results" json:"max_results"`
 	MinSeeders int  `yaml:"min_seeders" json:"min_seeders"`
 	SortBySize bool `yaml:"sort_by_size" json:"sort_by_size"`
 }
+
+// SchedulerConfig 定时任务配置
+type SchedulerConfig struct {
+	Timezone string                        `yaml:"timezone" json:"timezone"` // 默认时区，如 Asia/Shanghai，为空时使用服务器时区
+	Jobs     map[string]ScheduledJobConfig `yaml:"jobs" json:"jobs"`         // 按任务名称覆盖调度规则
+}
+
+// ScheduledJobConfig 单个定时任务的调度配置，未填写的字段使用任务默认值
+type ScheduledJobConfig struct {
+	Cron     string `yaml:"cron" json:"cron"`         // cron 表达式（分 时 日 月 周），或 @daily、@every 15m
+	Timezone string `yaml:"timezone" json:"timezone"` // 为空时使用默认时区
+	CatchUp  *bool  `yaml:"catch_up" json:"catch_up"` // 服务停止期间错过执行时，启动后是否补跑
+}
@@ -7,7 +7,7 @@ import (
 // RankingDownloadTask 排行榜下载任务模型
 type RankingDownloadTask struct {
 	BaseModel
-	Code         string    `gorm:"size:50;not null;uniqueIndex" json:"code"`        // 影片番号
+	Code         string    `gorm:"size:50;not null;index" json:"code"`              // 影片番号
 	Title        string    `gorm:"size:500" json:"title"`                           // 影片标题
 	CoverURL     string    `gorm:"size:2000" json:"cover_url"`                      // 封面图片URL
 	Status       string    `gorm:"size:20;not null;default:'pending'" json:"status"` // 下载状态
@@ -19,8 +19,26 @@ type RankingDownloadTask struct {
 	CompletedAt  *time.Time `json:"completed_at"`                                   // 完成时间
 	FileSize     int64     `gorm:"default:0" json:"file_size"`                      // 文件大小(字节)
 	DownloadedSize int64   `gorm:"default:0" json:"downloaded_size"`                // 已下载大小
-	Source       string    `gorm:"size:50;default:'manual'" json:"source"`          // 下载来源: manual, subscription
+	DownloadSpeed  int64   `gorm:"default:0" json:"download_speed"`                 // 下载速度(字节/秒)
+	ETA            int64   `gorm:"default:0" json:"eta"`                            // 预计剩余时间(秒)，未知时为0
+	ClientState    string  `gorm:"size:30" json:"client_state"`                     // 下载器中的种子状态
+	ContentPath    string  `gorm:"size:1000" json:"content_path"`                   // 下载内容在下载器中的路径
+	LastSyncedAt   *time.Time `json:"last_synced_at"`                                 // 最后一次同步下载器状态的时间
+	ProgressChangedAt *time.Time `json:"progress_changed_at"`                          // 下载进度最后一次增长的时间，用于判断下载停滞
+	RetryCount     int     `gorm:"default:0" json:"retry_count"`                    // 已自动重试的次数
+	NextRetryAt    *time.Time `gorm:"index" json:"next_retry_at"`                   // 下次自动重试的时间，之前不会被队列执行
+	Candidates     []DownloadCandidate `gorm:"serializer:json;type:text" json:"candidates"` // 搜索得到的候选种子（按得分排序，不含已放弃的种子）
+	Attempts       []DownloadAttempt   `gorm:"serializer:json;type:text" json:"attempts"`   // 下载尝试记录
+	ImportStatus   string  `gorm:"size:20" json:"import_status"`                    // 导入媒体库的状态，为空表示未导入
+	ImportedPath   string  `gorm:"size:1000" json:"imported_path"`                  // 导入后媒体库中的视频路径
+	ImportError    string  `gorm:"size:1000" json:"import_error"`                   // 导入失败的原因
+	ImportedAt     *time.Time `json:"imported_at"`                                    // 导入完成时间
+	UpgradeOf      *uint   `gorm:"index" json:"upgrade_of"`                         // 画质升级任务要替换的本地影片ID，普通任务为空
+	UpgradeReason  string  `gorm:"size:200" json:"upgrade_reason"`                  // 画质升级的原因，如 720p → 1080p、中文字幕
+	Source       string    `gorm:"size:50;default:'manual'" json:"source"`          // 下载来源: manual, subscription, upgrade
 	RankType     string    `gorm:"size:20" json:"rank_type"`                        // 排行榜类型(用于订阅下载)
+	Priority     int       `gorm:"default:0;index" json:"priority"`                 // 队列优先级，数值越大越先执行
+	QueuePosition int      `gorm:"-" json:"queue_position,omitempty"`               // 等待中的任务在队列中的位置（从1开始），不入库
 }
 
 // TableName 表名
@@ -28,6 +46,42 @@ func (RankingDownloadTask) TableName() string {
 	return "ranking_download_tasks"
 }
 
+// DownloadCandidate 下载任务的候选种子
+type DownloadCandidate struct {
+	Title    string `json:"title"`
+	Link     string `json:"link"`      // 种子文件链接或磁力链接
+	InfoHash string `json:"info_hash"` // info hash（小写），索引器未提供时为空
+	Size     int64  `json:"size"`
+	Seeders  int    `json:"seeders"`
+	Tracker  string `json:"tracker"`
+}
+
+// DownloadAttempt 下载任务的一次尝试
+type DownloadAttempt struct {
+	Number    int        `json:"number"`              // 第几次尝试，从1开始
+	Title     string     `json:"title,omitempty"`     // 使用的种子，搜索失败时为空
+	InfoHash  string     `json:"info_hash,omitempty"`
+	Tracker   string     `json:"tracker,omitempty"`
+	Added     bool       `json:"added,omitempty"` // 种子由本服务添加到下载器，只有这样的种子才会被删除或加入黑名单
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"` // 尝试失败的时间，进行中或成功时为空
+	Error     string     `json:"error,omitempty"`
+}
+
+// TorrentBlocklist 种子黑名单，下载失败或停滞的种子不会再被选择
+type TorrentBlocklist struct {
+	BaseModel
+	InfoHash string `gorm:"size:100;not null;uniqueIndex" json:"info_hash"` // info hash（小写）
+	Code     string `gorm:"size:50;index" json:"code"`                      // 加入黑名单时下载的番号
+	Title    string `gorm:"size:500" json:"title"`                          // 种子标题
+	Reason   string `gorm:"size:1000" json:"reason"`                        // 加入黑名单的原因
+}
+
+// TableName 表名
+func (TorrentBlocklist) TableName() string {
+	return "torrent_blocklist"
+}
+
 // 排行榜下载状态常量
 const (
 	RankingDownloadStatusPending    = "pending"     // 等待中
@@ -40,12 +94,38 @@ const (
 	RankingDownloadStatusCancelled  = "cancelled"   // 已取消
 )
 
+// 导入媒体库状态常量
+const (
+	ImportStatusImporting = "importing" // 导入中
+	ImportStatusImported  = "imported"  // 已导入
+	ImportStatusFailed    = "failed"    // 导入失败
+)
+
 // 下载来源常量
 const (
 	DownloadSourceManual       = "manual"       // 手动下载
 	DownloadSourceSubscription = "subscription" // 订阅下载
+	DownloadSourceUpgrade      = "upgrade"      // 画质升级下载
 )
 
+// 下载队列优先级常量，手动下载优先于订阅下载，画质升级在最后
+const (
+	DownloadPriorityUpgrade      = -10
+	DownloadPrioritySubscription = 0
+	DownloadPriorityManual       = 10
+)
+
+// DownloadPriorityForSource 下载来源对应的默认优先级
+func DownloadPriorityForSource(source string) int {
+	switch source {
+	case DownloadSourceManual:
+		return DownloadPriorityManual
+	case DownloadSourceUpgrade:
+		return DownloadPriorityUpgrade
+	}
+	return DownloadPrioritySubscription
+}
+
 // IsCompleted 是否已完成
 func (dt *RankingDownloadTask) IsCompleted() bool {
 	return dt.Status == RankingDownloadStatusCompleted
@@ -6,26 +6,91 @@ import (
 	"gorm.io/gorm"
 )
 
-// LocalMovie 本地影片数据库模型
+// LocalMovie 本地影片数据库模型（同一番号的一个版本，多分段影片的各分段记录在 Parts 中）
 type LocalMovie struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	Title       string         `gorm:"not null;index" json:"title"`
-	Code        string         `gorm:"index" json:"code"`
-	Actress     string         `gorm:"not null;index" json:"actress"`
-	Path        string         `gorm:"not null;unique" json:"path"`
-	Size        int64          `gorm:"not null" json:"size"`
-	Modified    time.Time      `gorm:"not null" json:"modified"`
-	Format      string         `gorm:"not null" json:"format"`
-	FanartPath  string         `json:"fanart_path"`
-	FanartURL   string         `json:"fanart_url"`
-	HasFanart   bool           `gorm:"default:false" json:"has_fanart"`
-	LastScanned time.Time      `gorm:"not null" json:"last_scanned"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint           `gorm:"primarykey" json:"id"`
+	Title              string         `gorm:"not null;index" json:"title"`
+	Code               string         `gorm:"index" json:"code"`
+	CodeKey            string         `gorm:"size:50;index" json:"code_key"` // 番号等价键（如 SSIS123），用于与排行榜、下载任务按番号匹配
+	Actress            string         `gorm:"not null;index" json:"actress"`
+	Path               string         `gorm:"not null;unique" json:"path"`
+	Root               string         `gorm:"size:100;index" json:"root"`      // 所属媒体库根目录名称
+	TitleKey           string         `gorm:"size:255;index" json:"title_key"` // 逻辑影片分组键，同一番号的不同版本相同
+	Version            string         `gorm:"size:100" json:"version"`         // 版本标识，如 4K、1080p
+	PartCount          int            `gorm:"default:1" json:"part_count"`     // 分段数量
+	Size               int64          `gorm:"not null" json:"size"`            // 所有分段的总大小
+	Modified           time.Time      `gorm:"not null" json:"modified"`
+	Format             string         `gorm:"not null" json:"format"`
+	FanartPath         string         `json:"fanart_path"`
+	FanartURL          string         `json:"fanart_url"`
+	HasFanart          bool           `gorm:"default:false" json:"has_fanart"`
+	HasSubtitle        bool           `gorm:"default:false;index" json:"has_subtitle"`
+	HasChineseSubtitle bool           `gorm:"default:false;index" json:"has_chinese_subtitle"`
+	SubtitleLanguages  string         `gorm:"size:100" json:"subtitle_languages"` // 字幕语言，逗号分隔，如 zh,ja
+	HardSubtitle       bool           `gorm:"default:false" json:"hard_subtitle"` // 文件名带 -C、ch 等中文字幕标记（内嵌字幕）
+	Uncensored         bool           `gorm:"default:false" json:"uncensored"`    // 文件名带 -U、无码、流出等无码标记
+	Duration           int            `gorm:"default:0;index" json:"duration"`    // 时长（秒），多分段影片为各分段之和
+	Width              int            `gorm:"default:0" json:"width"`
+	Height             int            `gorm:"default:0;index" json:"height"`
+	VideoCodec         string         `gorm:"size:20;index" json:"video_codec"`
+	AudioCodec         string         `gorm:"size:20" json:"audio_codec"`
+	Bitrate            int64          `gorm:"default:0" json:"bitrate"`          // 平均码率（bit/s）
+	Quality            string         `gorm:"size:20;index" json:"quality"`      // 画质：4K、1080p、720p、480p、SD
+	Probed             bool           `gorm:"default:false" json:"-"`            // 是否已探测过媒体信息
+	ContentHash        string         `gorm:"size:64;index" json:"content_hash"` // 文件内容摘要（大小+首尾数据块），多分段影片为各分段摘要的组合
+	NFOPath            string         `json:"nfo_path"`                          // 使用的NFO文件
+	NFOModified        time.Time      `json:"-"`                                 // NFO文件修改时间，用于判断是否需要重新导入
+	MovieID            *uint          `gorm:"index" json:"movie_id"`             // 由NFO导入的影片元数据
+	LastScanned        time.Time      `gorm:"not null" json:"last_scanned"`
+	UpgradeCheckedAt   *time.Time     `json:"upgrade_checked_at"` // 最后一次搜索更高画质版本的时间
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Parts     []LocalMoviePart     `gorm:"foreignKey:LocalMovieID" json:"parts,omitempty"`
+	Subtitles []LocalMovieSubtitle `gorm:"foreignKey:LocalMovieID" json:"subtitles,omitempty"`
+}
+
+// LocalMoviePart 多分段影片的分段文件（如 CD1/CD2、-A/-B）
+type LocalMoviePart struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	LocalMovieID uint      `gorm:"not null;index" json:"local_movie_id"`
+	Part         int       `gorm:"not null" json:"part"` // 分段序号，从1开始
+	Path         string    `gorm:"not null;index" json:"path"`
+	Size         int64     `gorm:"not null" json:"size"`
+	Modified     time.Time `gorm:"not null" json:"modified"`
+	ContentHash  string    `gorm:"size:64" json:"content_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// LocalMovieSubtitle 影片的外挂字幕文件
+type LocalMovieSubtitle struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	LocalMovieID uint      `gorm:"not null;index" json:"local_movie_id"`
+	Path         string    `gorm:"not null;index" json:"path"`
+	Format       string    `gorm:"size:10" json:"format"`          // srt、ass、ssa、vtt
+	Language     string    `gorm:"size:10;index" json:"language"`  // zh、ja、en、ko，无法识别时为空
+	Source       string    `gorm:"size:20" json:"language_source"` // 语言识别来源：filename、content
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// LocalTitle 逻辑影片，聚合同一番号的所有版本
+type LocalTitle struct {
+	TitleKey string        `json:"title_key"`
+	Versions []*LocalMovie `json:"versions"`
 }
 
 // TableName 指定表名
 func (LocalMovie) TableName() string {
 	return "local_movies"
 }
+
+func (LocalMoviePart) TableName() string {
+	return "local_movie_parts"
+}
+
+func (LocalMovieSubtitle) TableName() string {
+	return "local_movie_subtitles"
+}
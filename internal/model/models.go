@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -16,8 +17,12 @@ func (sa StringArray) Value() (driver.Value, error) {
 	if len(sa) == 0 {
 		return "{}", nil
 	}
-	data, err := json.Marshal(sa)
-	return string(data), err
+	// 按PostgreSQL数组字面量格式输出，元素统一加引号并转义
+	quoted := make([]string, len(sa))
+	for i, v := range sa {
+		quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}", nil
 }
 
 func (sa *StringArray) Scan(value interface{}) error {
@@ -26,16 +31,65 @@ func (sa *StringArray) Scan(value interface{}) error {
 		return nil
 	}
 
+	var text string
 	switch v := value.(type) {
 	case []byte:
-		return json.Unmarshal(v, sa)
+		text = string(v)
 	case string:
-		return json.Unmarshal([]byte(v), sa)
+		text = v
 	default:
 		return fmt.Errorf("无法扫描 %T 到 StringArray", value)
 	}
+
+	// 兼容早期以JSON格式写入的数据
+	if strings.HasPrefix(text, "[") {
+		return json.Unmarshal([]byte(text), sa)
+	}
+	*sa = parsePGArray(text)
+	return nil
 }
 
+// parsePGArray 解析一维PostgreSQL数组字面量，如 {a,"b c"}
+func parsePGArray(text string) StringArray {
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "{"), "}")
+	result := StringArray{}
+	if text == "" {
+		return result
+	}
+
+	var current strings.Builder
+	inQuotes, quoted := false, false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text):
+			i++
+			current.WriteByte(text[i])
+		case c == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case c == ',' && !inQuotes:
+			result = append(result, pgArrayElement(current.String(), quoted))
+			current.Reset()
+			quoted = false
+		default:
+			current.WriteByte(c)
+		}
+	}
+	return append(result, pgArrayElement(current.String(), quoted))
+}
+
+// pgArrayElement 处理数组元素，未加引号的 NULL 视为空字符串
+func pgArrayElement(v string, quoted bool) string {
+	if !quoted && v == "NULL" {
+		return ""
+	}
+	return v
+}
+
+// ExternalIDs 外部站点ID，键为站点类型
+type ExternalIDs map[string]string
+
 // Int64Array 整数数组类型
 type Int64Array []int64
 
@@ -120,6 +174,7 @@ type Movie struct {
 	BaseModel
 	Code              string      `gorm:"size:50;not null;uniqueIndex" json:"code"`
 	Title             string      `gorm:"size:500;not null" json:"title"`
+	OriginalTitle     string      `gorm:"size:500" json:"original_title"`
 	ReleaseDate       *time.Time  `json:"release_date"`
 	Duration          int         `json:"duration"` // 分钟
 	StudioID          *uint       `gorm:"index" json:"studio_id"`
@@ -140,6 +195,8 @@ type Movie struct {
 	DownloadProgress  int         `gorm:"default:0" json:"download_progress"`
 	LastWatched       *time.Time  `json:"last_watched"`
 	WatchCount        int         `gorm:"default:0" json:"watch_count"`
+	UniqueIDs         ExternalIDs `gorm:"serializer:json;type:text" json:"unique_ids"` // 外部站点ID，如 javdb、tmdb
+	SampleImages      StringArray `gorm:"type:text[]" json:"sample_images"`            // 剧照地址，写入 extrafanart
 
 	// 关联关系
 	Studio       *Studio        `json:"studio,omitempty"`
@@ -278,8 +335,18 @@ func GetAllModels() []interface{} {
 		&CrawlTask{},
 		&Favorite{},
 		&WatchHistory{},
+		&LocalMovie{},
+		&LocalMoviePart{},
+		&LocalMovieSubtitle{},
+		&ScanHistory{},
 		&Ranking{},
+		&RankingSnapshot{},
+		&ActressRanking{},
 		&RankingDownloadTask{},
+		&TorrentBlocklist{},
+		&ScheduledJob{},
+		&OrganizeRun{},
+		&OrganizeJournalEntry{},
 		&Subscription{},
 		&SubscriptionLimit{},
 	}
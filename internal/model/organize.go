@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+)
+
+// OrganizeRun 媒体库整理记录，每次执行整理生成一条，文件操作按顺序记录在 Entries 中，可据此撤销
+type OrganizeRun struct {
+	ID         uint                   `gorm:"primarykey" json:"id"`
+	Root       string                 `gorm:"size:100;index" json:"root"` // 整理的根目录，空表示所有根目录
+	Template   string                 `gorm:"size:500" json:"template"`   // 使用的路径模板
+	Status     string                 `gorm:"size:20;not null;index" json:"status"`
+	Movies     int                    `json:"movies"`     // 整理的影片数
+	Operations int                    `json:"operations"` // 完成的文件操作数
+	Failed     int                    `json:"failed"`     // 整理失败的影片数
+	StartedAt  time.Time              `gorm:"not null;index" json:"started_at"`
+	FinishedAt *time.Time             `json:"finished_at"`
+	UndoneAt   *time.Time             `json:"undone_at"`
+	Error      string                 `gorm:"type:text" json:"error,omitempty"`
+	Entries    []OrganizeJournalEntry `gorm:"foreignKey:RunID" json:"entries,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+}
+
+// OrganizeJournalEntry 整理日志条目，执行前写入 pending，完成后更新状态，服务中断后仍可据此撤销
+type OrganizeJournalEntry struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	RunID        uint      `gorm:"not null;index" json:"run_id"`
+	Seq          int       `gorm:"not null" json:"seq"` // 执行顺序，撤销时倒序处理
+	LocalMovieID uint      `gorm:"index" json:"local_movie_id"`
+	Op           string    `gorm:"size:10;not null" json:"op"`
+	From         string    `gorm:"type:text" json:"from,omitempty"`
+	To           string    `gorm:"type:text" json:"to,omitempty"`
+	Status       string    `gorm:"size:20;not null" json:"status"`
+	Error        string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// 整理状态常量
+const (
+	OrganizeStatusRunning   = "running"   // 执行中
+	OrganizeStatusCompleted = "completed" // 已完成
+	OrganizeStatusFailed    = "failed"    // 部分影片整理失败
+	OrganizeStatusUndone    = "undone"    // 已撤销
+)
+
+// 整理操作常量
+const (
+	OrganizeOpMkdir = "mkdir" // 创建目录
+	OrganizeOpMove  = "move"  // 移动或重命名文件、目录
+	OrganizeOpRmdir = "rmdir" // 删除整理后变空的目录
+)
+
+// 整理日志条目状态常量
+const (
+	OrganizeEntryPending = "pending" // 待执行
+	OrganizeEntryDone    = "done"    // 已执行
+	OrganizeEntryFailed  = "failed"  // 执行失败
+	OrganizeEntryUndone  = "undone"  // 已撤销
+)
@@ -10,7 +10,8 @@ type Ranking struct {
 	Code        string     `gorm:"size:50;not null;index" json:"code"`
 	Title       string     `gorm:"size:500;not null" json:"title"`
 	CoverURL    string     `gorm:"size:1000" json:"cover_url"`
-	RankType    string     `gorm:"size:20;not null;index" json:"rank_type"` // daily, weekly, monthly
+	Board       string     `gorm:"size:20;index" json:"board"`              // 榜单标识，如 daily、uncensored-weekly
+	RankType    string     `gorm:"size:20;not null;index" json:"rank_type"` // 榜单周期：daily, weekly, monthly
 	Position    int        `gorm:"not null;index" json:"position"`          // 排名位置
 	CrawledAt   time.Time  `gorm:"not null;index" json:"crawled_at"`        // 爬取时间
 	LocalExists bool       `gorm:"default:false;index" json:"local_exists"` // 是否在本地存在
@@ -28,3 +29,39 @@ const (
 	RankTypeWeekly  = "weekly"
 	RankTypeMonthly = "monthly"
 )
+
+// RankingSnapshot 排行榜历史快照，每次爬取按排行榜类型整榜保存一份，用于追踪排名变化
+type RankingSnapshot struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Code      string    `gorm:"size:50;not null;index" json:"code"`
+	Title     string    `gorm:"size:500" json:"title"`
+	CoverURL  string    `gorm:"size:1000" json:"cover_url"`
+	Board     string    `gorm:"size:20;index:idx_ranking_snapshots_board_crawled" json:"board"`
+	RankType  string    `gorm:"size:20" json:"rank_type"`
+	Position  int       `gorm:"not null" json:"position"`
+	CrawledAt time.Time `gorm:"not null;index:idx_ranking_snapshots_board_crawled" json:"crawled_at"` // 同一次爬取的记录时间相同
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 表名
+func (RankingSnapshot) TableName() string {
+	return "ranking_snapshots"
+}
+
+// ActressRanking 女优排行榜，每个榜单只保留最新一次爬取的结果
+type ActressRanking struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Board     string    `gorm:"size:20;not null;index" json:"board"` // 榜单标识，如 actress-censored
+	JAVDbID   string    `gorm:"size:50;index" json:"javdb_id"`
+	Name      string    `gorm:"size:200;not null" json:"name"`
+	AvatarURL string    `gorm:"size:1000" json:"avatar_url"`
+	Position  int       `gorm:"not null" json:"position"`
+	ActressID *uint     `gorm:"index" json:"actress_id"` // 同名的本地女优
+	CrawledAt time.Time `gorm:"not null" json:"crawled_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 表名
+func (ActressRanking) TableName() string {
+	return "actress_rankings"
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+)
+
+// ScanHistory 媒体库扫描记录
+type ScanHistory struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	Root         string     `gorm:"size:100;index" json:"root"`           // 扫描的根目录，空表示所有根目录
+	Trigger      string     `gorm:"size:20" json:"trigger"`               // 触发方式
+	Status       string     `gorm:"size:20;not null;index" json:"status"` // 扫描状态
+	StartedAt    time.Time  `gorm:"not null;index" json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	DurationMs   int64      `json:"duration_ms"` // 扫描耗时（毫秒）
+	DirsVisited  int        `json:"dirs_visited"`
+	FilesFound   int        `json:"files_found"` // 找到的视频文件数
+	Total        int        `json:"total"`       // 找到的影片数
+	Added        int        `json:"added"`
+	Removed      int        `json:"removed"`
+	Changed      int        `json:"changed"`
+	Unchanged    int        `json:"unchanged"`
+	AddedPaths   []string   `gorm:"serializer:json;type:text" json:"added_paths,omitempty"`
+	RemovedPaths []string   `gorm:"serializer:json;type:text" json:"removed_paths,omitempty"`
+	ChangedPaths []string   `gorm:"serializer:json;type:text" json:"changed_paths,omitempty"`
+	Error        string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName 表名
+func (ScanHistory) TableName() string {
+	return "scan_histories"
+}
+
+// 扫描状态常量
+const (
+	ScanStatusRunning   = "running"   // 扫描中
+	ScanStatusCompleted = "completed" // 已完成
+	ScanStatusFailed    = "failed"    // 失败
+	ScanStatusCancelled = "cancelled" // 已取消
+)
+
+// 扫描触发方式常量
+const (
+	ScanTriggerManual   = "manual"   // 手动触发
+	ScanTriggerStartup  = "startup"  // 服务启动
+	ScanTriggerSchedule = "schedule" // 定时扫描
+	ScanTriggerWatch    = "watch"    // 目录监听事件溢出
+	ScanTriggerOrganize = "organize" // 整理或撤销整理后刷新
+	ScanTriggerImport   = "import"   // 导入下载完成的影片后刷新
+)
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+)
+
+// ScheduledJob 定时任务的运行状态，调度规则来自配置，这里只保存暂停状态与最近一次执行结果
+type ScheduledJob struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	Name           string     `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Paused         bool       `gorm:"default:false" json:"paused"`
+	NextRunAt      *time.Time `json:"next_run_at"` // 服务停止期间错过该时间时，启动后按 catch_up 设置补跑
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+	LastTrigger    string     `gorm:"size:20" json:"last_trigger"`
+	LastStatus     string     `gorm:"size:20" json:"last_status"`
+	LastResult     string     `gorm:"type:text" json:"last_result,omitempty"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	RunCount       int        `json:"run_count"`
+	FailCount      int        `json:"fail_count"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName 表名
+func (ScheduledJob) TableName() string {
+	return "scheduled_jobs"
+}
+
+// 定时任务执行状态常量
+const (
+	JobStatusRunning = "running" // 执行中
+	JobStatusSuccess = "success" // 成功
+	JobStatusFailed  = "failed"  // 失败
+)
+
+// 定时任务触发方式常量
+const (
+	JobTriggerSchedule = "schedule" // 按调度规则执行
+	JobTriggerCatchUp  = "catch-up" // 补跑错过的执行
+	JobTriggerManual   = "manual"   // 手动触发
+)
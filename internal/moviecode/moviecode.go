@@ -0,0 +1,285 @@
+// Package moviecode 影片番号识别与标准化，爬虫、扫描、排行榜与搜索统一使用同一套规则
+//
+// 番号被解析为前缀、编号与变体后缀（-C、-UC、-4K 等），并给出标准写法（如 SSIS-123）
+// 与等价键（如 SSIS123）。规则按表驱动，可通过 Configure 追加自定义规则。
+package moviecode
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Code 解析出的番号
+type Code struct {
+	Prefix   string   `json:"prefix"`   // 前缀，如 SSIS、FC2-PPV、HEYZO，纯数字番号为空
+	Number   string   `json:"number"`   // 编号，已按规则补零或去掉多余的前导零
+	Variants []string `json:"variants"` // 变体后缀：C（中文字幕）、U（无码）、UC（无码中字）、4K 等
+	Rule     string   `json:"rule"`     // 匹配的规则名称
+	Start    int      `json:"-"`        // 番号在原文本中的起始位置
+	End      int      `json:"-"`        // 番号（含变体后缀）在原文本中的结束位置
+
+	separator string
+}
+
+// String 返回标准写法，如 SSIS-123、FC2-PPV-1234567、123456-789
+func (c Code) String() string {
+	if c.Prefix == "" {
+		return c.Number
+	}
+	return c.Prefix + c.separator + c.Number
+}
+
+// Key 返回等价键：去掉前缀中的分隔符与编号的前导零，如 SSIS-00123、ssis123 均为 SSIS123
+// 纯数字番号保留分隔符，避免 Caribbean（123456-789）与一本道（123456_789）混淆
+func (c Code) Key() string {
+	if c.Prefix == "" {
+		return c.Number
+	}
+	number := strings.TrimLeft(c.Number, "0")
+	if number == "" {
+		number = "0"
+	}
+	return strings.NewReplacer("-", "", "_", "").Replace(c.Prefix) + number
+}
+
+// HasVariant 判断是否带有指定的变体后缀
+func (c Code) HasVariant(variant string) bool {
+	for _, v := range c.Variants {
+		if v == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule 番号识别规则
+type Rule struct {
+	Name      string  `json:"name"`
+	Pattern   string  `json:"pattern"`             // 正则表达式（不区分大小写），必须包含 number 命名分组，可包含 prefix 分组
+	Prefix    string  `json:"prefix"`              // 固定前缀，为空时使用 prefix 分组
+	Separator *string `json:"separator,omitempty"` // 前缀与编号之间的分隔符，未设置时为 "-"，设置为空字符串时不使用分隔符
+	Digits    int     `json:"digits"`              // 编号位数：不足时补零，超出时去掉多余的前导零；0 表示保留原样
+
+	re        *regexp.Regexp
+	number    int
+	prefix    int
+	separator string
+}
+
+// DefaultRules 内置规则，按优先级排列
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "fc2", Pattern: `fc2[-_ ]?(?:ppv[-_ ]?)?(?P<number>\d{5,8})`, Prefix: "FC2-PPV"},
+		{Name: "heyzo", Pattern: `heyzo[-_ ]?(?:hd[-_ ]?)?(?P<number>\d{4})`, Prefix: "HEYZO", Digits: 4},
+		// Caribbean（123456-789）、一本道（123456_789）、天然むすめ（123456_01）等日期型番号
+		{Name: "date", Pattern: `(?P<number>\d{6}[-_]\d{2,3})`},
+		// 标准番号：SSIS-123、ssis00123、259LUXU-1234
+		{Name: "standard", Pattern: `(?P<prefix>\d{0,4}[a-z]{2,6})[-_]?(?P<number>\d{2,5})`, Digits: 3},
+		// 字母与数字混合的前缀：T28-123、S2M-123
+		{Name: "mixed", Pattern: `(?P<prefix>[a-z]\d{1,2}[a-z]{0,3})-(?P<number>\d{3,4})`, Digits: 3},
+	}
+}
+
+// DefaultIgnorePrefixes 不是番号的常见前缀（分辨率、编码、分段标记等）
+func DefaultIgnorePrefixes() []string {
+	return []string{
+		"CD", "PART", "PT", "DISC", "DISK", "HD", "FHD", "UHD", "SD", "HDR", "DTS", "AAC", "AC",
+		"AVC", "HEVC", "MP", "WEB", "BD", "DVD", "FPS", "BIT", "KBPS", "MBPS", "VOL", "EP", "NO",
+	}
+}
+
+// 文件名中常见的网站标记，如 hhd800.com@、[javdb.com]
+var sitePattern = regexp.MustCompile(`(?i)[a-z0-9][a-z0-9-]*\.(?:com|net|org|cc|tv|xyz|me|la|top|vip|info|cn)@?`)
+
+// 变体后缀，按从长到短的顺序匹配
+var variantPattern = regexp.MustCompile(`(?i)^[-_ .]?(uncensored|leak|流出|uc|ch|4k|8k|vr|c|u)(?:[^a-z0-9]|$)`)
+
+// variantAliases 变体后缀的标准写法，无码流出的各种写法都归为 U
+var variantAliases = map[string]string{
+	"CH":         "C",
+	"UNCENSORED": "U",
+	"LEAK":       "U",
+	"流出":         "U",
+}
+
+// Parser 番号解析器
+type Parser struct {
+	rules  []Rule
+	ignore map[string]bool
+}
+
+// NewParser 创建番号解析器，规则按顺序匹配，第一个匹配的规则生效
+func NewParser(rules []Rule, ignorePrefixes []string) (*Parser, error) {
+	p := &Parser{ignore: make(map[string]bool, len(ignorePrefixes))}
+	for _, prefix := range ignorePrefixes {
+		p.ignore[strings.ToUpper(prefix)] = true
+	}
+	for _, rule := range rules {
+		re, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("番号规则 %s 无效: %v", rule.Name, err)
+		}
+		rule.re = re
+		rule.number = re.SubexpIndex("number")
+		rule.prefix = re.SubexpIndex("prefix")
+		if rule.number < 0 {
+			return nil, fmt.Errorf("番号规则 %s 缺少 number 分组", rule.Name)
+		}
+		rule.separator = "-"
+		if rule.Separator != nil {
+			rule.separator = *rule.Separator
+		}
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+// Parse 从文本中识别番号
+func (p *Parser) Parse(text string) (Code, bool) {
+	cleaned := sitePattern.ReplaceAllStringFunc(text, func(m string) string {
+		return strings.Repeat(" ", len(m))
+	})
+	for i := range p.rules {
+		if code, ok := p.match(&p.rules[i], cleaned); ok {
+			return code, true
+		}
+	}
+	return Code{}, false
+}
+
+// match 使用单条规则匹配，番号前后必须是边界：前面不能是字母数字，编号后面不能是数字
+func (p *Parser) match(rule *Rule, text string) (Code, bool) {
+	for _, m := range rule.re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+		if start > 0 && isAlnum(text[start-1]) {
+			continue
+		}
+		if end < len(text) && isDigit(text[end]) {
+			continue
+		}
+
+		code := Code{Rule: rule.Name, Start: start, End: end, separator: rule.separator}
+		code.Number = normalizeNumber(text[m[2*rule.number]:m[2*rule.number+1]], rule.Digits)
+		switch {
+		case rule.Prefix != "":
+			code.Prefix = rule.Prefix
+		case rule.prefix >= 0 && m[2*rule.prefix] >= 0:
+			code.Prefix = strings.ToUpper(text[m[2*rule.prefix]:m[2*rule.prefix+1]])
+		}
+		if code.Prefix != "" && p.ignore[strings.TrimLeft(code.Prefix, "0123456789")] {
+			continue
+		}
+
+		// 变体后缀
+		rest := text[end:]
+		for {
+			v := variantPattern.FindStringSubmatchIndex(rest)
+			if v == nil {
+				break
+			}
+			variant := strings.ToUpper(rest[v[2]:v[3]])
+			if alias, ok := variantAliases[variant]; ok {
+				variant = alias
+			}
+			// 同义的后缀（如 uncensored leak）只记录一次
+			if !code.HasVariant(variant) {
+				code.Variants = append(code.Variants, variant)
+			}
+			code.End += v[3]
+			rest = rest[v[3]:]
+		}
+		return code, true
+	}
+	return Code{}, false
+}
+
+// normalizeNumber 按位数补零或去掉多余的前导零
+func normalizeNumber(number string, digits int) string {
+	if digits <= 0 {
+		return number
+	}
+	trimmed := strings.TrimLeft(number, "0")
+	for len(trimmed) < digits {
+		trimmed = "0" + trimmed
+	}
+	return trimmed
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// 全局解析器，Configure 后整体替换
+var defaultParser atomic.Pointer[Parser]
+
+func init() {
+	p, err := NewParser(DefaultRules(), DefaultIgnorePrefixes())
+	if err != nil {
+		panic(err)
+	}
+	defaultParser.Store(p)
+}
+
+// Configure 在内置规则之前追加自定义规则，并追加忽略的前缀
+func Configure(rules []Rule, ignorePrefixes []string) error {
+	p, err := NewParser(append(append([]Rule{}, rules...), DefaultRules()...),
+		append(DefaultIgnorePrefixes(), ignorePrefixes...))
+	if err != nil {
+		return err
+	}
+	defaultParser.Store(p)
+	return nil
+}
+
+// Parse 从文本中识别番号
+func Parse(text string) (Code, bool) {
+	return defaultParser.Load().Parse(text)
+}
+
+// Normalize 返回文本中番号的标准写法，无法识别时返回去除首尾空白的大写文本
+func Normalize(text string) string {
+	if code, ok := Parse(text); ok {
+		return code.String()
+	}
+	return strings.ToUpper(strings.TrimSpace(text))
+}
+
+// Key 返回文本中番号的等价键，无法识别时返回空字符串
+func Key(text string) string {
+	if code, ok := Parse(text); ok {
+		return code.Key()
+	}
+	return ""
+}
+
+// EquivalentKeys 返回等价键及其已知变体，用于集合匹配：
+// 素人系列常带数字厂牌前缀，259LUXU-123 与 LUXU-123 指向同一部影片
+func EquivalentKeys(key string) []string {
+	if key == "" {
+		return nil
+	}
+	keys := []string{key}
+	if trimmed := strings.TrimLeft(key, "0123456789"); trimmed != key && trimmed != "" && isAlnum(trimmed[0]) {
+		keys = append(keys, trimmed)
+	}
+	return keys
+}
+
+// Equal 判断两段文本是否指向同一番号
+func Equal(a, b string) bool {
+	key := Key(a)
+	return key != "" && key == Key(b)
+}
+
+// IsCode 判断文本整体是否为一个番号（允许带变体后缀，前缀与编号之间可以是空格）
+func IsCode(text string) bool {
+	text = strings.Join(strings.Fields(text), "-")
+	code, ok := Parse(text)
+	return ok && code.Start == 0 && code.End == len(text)
+}
@@ -0,0 +1,153 @@
+package moviecode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text     string
+		code     string
+		key      string
+		variants []string
+	}{
+		{"SSIS-123", "SSIS-123", "SSIS123", nil},
+		{"ssis123", "SSIS-123", "SSIS123", nil},
+		{"SSIS00123", "SSIS-123", "SSIS123", nil},
+		{"SSIS-0123", "SSIS-123", "SSIS123", nil},
+		{"IPX-001", "IPX-001", "IPX1", nil},
+		{"259LUXU-1234", "259LUXU-1234", "259LUXU1234", nil},
+		{"T28-567", "T28-567", "T28567", nil},
+		{"FC2-PPV-1234567", "FC2-PPV-1234567", "FC2PPV1234567", nil},
+		{"fc2ppv_1234567", "FC2-PPV-1234567", "FC2PPV1234567", nil},
+		{"FC2-1234567", "FC2-PPV-1234567", "FC2PPV1234567", nil},
+		{"HEYZO-1234", "HEYZO-1234", "HEYZO1234", nil},
+		{"heyzo_hd_0123", "HEYZO-0123", "HEYZO123", nil},
+		{"123456-789", "123456-789", "123456-789", nil},
+		{"123456_789", "123456_789", "123456_789", nil},
+		{"010120_01", "010120_01", "010120_01", nil},
+		{"SSIS-123-C", "SSIS-123", "SSIS123", []string{"C"}},
+		{"SSIS-123ch", "SSIS-123", "SSIS123", []string{"C"}},
+		{"SSIS-123-UC", "SSIS-123", "SSIS123", []string{"UC"}},
+		{"SSIS-123-U", "SSIS-123", "SSIS123", []string{"U"}},
+		{"SSIS-123-4K", "SSIS-123", "SSIS123", []string{"4K"}},
+		{"SSIS-123-4K-C", "SSIS-123", "SSIS123", []string{"4K", "C"}},
+		{"SSIS-123 uncensored leak", "SSIS-123", "SSIS123", []string{"U"}},
+		{"SSIS-123-流出", "SSIS-123", "SSIS123", []string{"U"}},
+		{"hhd800.com@SSIS-123.mp4", "SSIS-123", "SSIS123", nil},
+		{"[javdb.com] SSIS-123 1080p", "SSIS-123", "SSIS123", nil},
+		// 分辨率、分段标记等不是番号
+		{"CD-1 SSIS-123", "SSIS-123", "SSIS123", nil},
+		{"HD-720 ABP-456", "ABP-456", "ABP456", nil},
+	}
+	for _, tt := range tests {
+		code, ok := Parse(tt.text)
+		if !ok {
+			t.Errorf("Parse(%q) 未识别", tt.text)
+			continue
+		}
+		if code.String() != tt.code || code.Key() != tt.key || !reflect.DeepEqual(code.Variants, tt.variants) {
+			t.Errorf("Parse(%q) = %s（键 %s，变体 %v），期望 %s（键 %s，变体 %v）",
+				tt.text, code, code.Key(), code.Variants, tt.code, tt.key, tt.variants)
+		}
+	}
+}
+
+func TestParseNoCode(t *testing.T) {
+	for _, text := range []string{"", "random movie", "1080p", "CD-1", "PART-2", "x264-AAC", "ABCSSIS-123"} {
+		if code, ok := Parse(text); ok {
+			t.Errorf("Parse(%q) = %s，期望无法识别", text, code)
+		}
+	}
+}
+
+func TestNormalizeAndEqual(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"ssis00123", "SSIS-123"},
+		{"fc2ppv-1234567", "FC2-PPV-1234567"},
+		{"heyzo1234", "HEYZO-1234"},
+		{"  not a code ", "NOT A CODE"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.text); got != tt.want {
+			t.Errorf("Normalize(%q) = %q，期望 %q", tt.text, got, tt.want)
+		}
+	}
+
+	equal := []struct {
+		a, b string
+		want bool
+	}{
+		{"SSIS-123", "ssis00123", true},
+		{"SSIS-123-C", "SSIS-123", true},
+		{"FC2-PPV-1234567", "fc2 1234567", true},
+		{"123456-789", "123456_789", false},
+		{"SSIS-123", "SSIS-124", false},
+		{"foo", "foo", false},
+	}
+	for _, tt := range equal {
+		if got := Equal(tt.a, tt.b); got != tt.want {
+			t.Errorf("Equal(%q, %q) = %v，期望 %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestIsCode(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"SSIS-123", true},
+		{"SSIS 123", true},
+		{"SSIS-123-C", true},
+		{"FC2-PPV-1234567", true},
+		{"SSIS-123 字幕版", false},
+		{"hello", false},
+	}
+	for _, tt := range tests {
+		if got := IsCode(tt.text); got != tt.want {
+			t.Errorf("IsCode(%q) = %v，期望 %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEquivalentKeys(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"", nil},
+		{"SSIS123", []string{"SSIS123"}},
+		{"259LUXU1234", []string{"259LUXU1234", "LUXU1234"}},
+		{"123456-789", []string{"123456-789"}},
+	}
+	for _, tt := range tests {
+		if got := EquivalentKeys(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("EquivalentKeys(%q) = %v，期望 %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNewParserCustomRule(t *testing.T) {
+	empty := ""
+	p, err := NewParser(append([]Rule{
+		{Name: "tokyo-hot", Pattern: `tokyo[-_ ]?hot[-_ ]?(?P<number>n\d{4})`, Prefix: "TOKYO-HOT", Separator: &empty},
+	}, DefaultRules()...), DefaultIgnorePrefixes())
+	if err != nil {
+		t.Fatalf("NewParser 失败: %v", err)
+	}
+	code, ok := p.Parse("Tokyo-Hot n1234")
+	if !ok || code.Rule != "tokyo-hot" || code.String() != "TOKYO-HOTn1234" {
+		t.Errorf("Parse = %+v, %v", code, ok)
+	}
+
+	if _, err := NewParser([]Rule{{Name: "bad", Pattern: `(`}}, nil); err == nil {
+		t.Error("无效的正则应返回错误")
+	}
+	if _, err := NewParser([]Rule{{Name: "no-number", Pattern: `abc\d+`}}, nil); err == nil {
+		t.Error("缺少 number 分组应返回错误")
+	}
+}
@@ -0,0 +1,324 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// EBML 文件头标识
+var ebmlMagic = []byte{0x1A, 0x45, 0xDF, 0xA3}
+
+// Matroska 元素ID
+const (
+	idEBML           = 0x1A45DFA3
+	idDocType        = 0x4282
+	idSegment        = 0x18538067
+	idSeekHead       = 0x114D9B74
+	idSeek           = 0x4DBB
+	idSeekID         = 0x53AB
+	idSeekPosition   = 0x53AC
+	idInfo           = 0x1549A966
+	idTimecodeScale  = 0x2AD7B1
+	idDuration       = 0x4489
+	idTracks         = 0x1654AE6B
+	idTrackEntry     = 0xAE
+	idTrackType      = 0x83
+	idCodecID        = 0x86
+	idVideo          = 0xE0
+	idPixelWidth     = 0xB0
+	idPixelHeight    = 0xBA
+	idCluster        = 0x1F43B675
+	maxElementBuffer = 16 << 20 // Info、Tracks 等元素的最大读取大小
+)
+
+// 未知长度（所有数据位为1）
+const unknownSize = -1
+
+// Matroska 编码ID前缀与名称的对应关系，按顺序匹配
+var matroskaCodecs = []struct {
+	prefix string
+	name   string
+}{
+	{"V_MPEG4/ISO/AVC", "h264"},
+	{"V_MPEGH/ISO/HEVC", "hevc"},
+	{"V_AV1", "av1"},
+	{"V_VP9", "vp9"},
+	{"V_VP8", "vp8"},
+	{"V_MPEG4/", "mpeg4"},
+	{"V_MPEG2", "mpeg2"},
+	{"V_MS/VFW/FOURCC", "vfw"},
+	{"A_AAC", "aac"},
+	{"A_EAC3", "eac3"},
+	{"A_AC3", "ac3"},
+	{"A_DTS", "dts"},
+	{"A_TRUEHD", "truehd"},
+	{"A_OPUS", "opus"},
+	{"A_VORBIS", "vorbis"},
+	{"A_FLAC", "flac"},
+	{"A_MPEG/L3", "mp3"},
+	{"A_MPEG/L2", "mp2"},
+	{"A_PCM", "pcm"},
+}
+
+// ebmlElement 元素头信息
+type ebmlElement struct {
+	id     uint64
+	offset int64 // 元素数据的起始位置
+	size   int64 // 数据长度，未知时为 unknownSize
+}
+
+// readVint 读取 EBML 变长整数，keepMarker 为 true 时保留长度标记位（用于元素ID）
+func readVint(r io.ReaderAt, offset int64, keepMarker bool) (uint64, int, error) {
+	first := make([]byte, 1)
+	if _, err := r.ReadAt(first, offset); err != nil {
+		return 0, 0, err
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, errors.New("无效的EBML变长整数")
+	}
+
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return 0, 0, err
+	}
+	if !keepMarker {
+		buf[0] &= byte(0xFF >> length)
+	}
+	var value uint64
+	for _, b := range buf {
+		value = value<<8 | uint64(b)
+	}
+	return value, length, nil
+}
+
+// readElementHeader 读取 offset 处的元素头
+func readElementHeader(r io.ReaderAt, offset int64) (ebmlElement, error) {
+	id, idLen, err := readVint(r, offset, true)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+	size, sizeLen, err := readVint(r, offset+int64(idLen), false)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+	el := ebmlElement{id: id, offset: offset + int64(idLen+sizeLen), size: int64(size)}
+	if size == 1<<(7*uint(sizeLen))-1 {
+		el.size = unknownSize
+	}
+	return el, nil
+}
+
+// readElementData 读取元素数据
+func readElementData(r io.ReaderAt, el ebmlElement) ([]byte, error) {
+	if el.size < 0 || el.size > maxElementBuffer {
+		return nil, errors.New("元素长度无效")
+	}
+	data := make([]byte, el.size)
+	if _, err := r.ReadAt(data, el.offset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// probeMatroska 解析 EBML 头、Segment 中的 Info 与 Tracks
+func probeMatroska(r io.ReaderAt, size int64) (*Info, error) {
+	header, err := readElementHeader(r, 0)
+	if err != nil || header.id != idEBML || header.size < 0 {
+		return nil, errors.New("无效的EBML文件头")
+	}
+	info := &Info{Container: "matroska"}
+	if data, err := readElementData(r, header); err == nil {
+		eachElement(data, func(id uint64, body []byte) {
+			if id == idDocType && string(body) == "webm" {
+				info.Container = "webm"
+			}
+		})
+	}
+
+	segment, err := readElementHeader(r, header.offset+header.size)
+	if err != nil || segment.id != idSegment {
+		return nil, errors.New("未找到Segment")
+	}
+	end := size
+	if segment.size >= 0 && segment.offset+segment.size < end {
+		end = segment.offset + segment.size
+	}
+
+	var seekInfo, seekTracks int64 = -1, -1
+	var foundInfo, foundTracks bool
+	parse := func(el ebmlElement) {
+		data, err := readElementData(r, el)
+		if err != nil {
+			return
+		}
+		switch el.id {
+		case idInfo:
+			foundInfo = true
+			parseSegmentInfo(data, info)
+		case idTracks:
+			foundTracks = true
+			parseTracks(data, info)
+		case idSeekHead:
+			eachElement(data, func(id uint64, seek []byte) {
+				if id != idSeek {
+					return
+				}
+				var target uint64
+				position := int64(-1)
+				eachElement(seek, func(id uint64, body []byte) {
+					switch id {
+					case idSeekID:
+						target = readUint(body)
+					case idSeekPosition:
+						position = int64(readUint(body))
+					}
+				})
+				switch target {
+				case idInfo:
+					seekInfo = position
+				case idTracks:
+					seekTracks = position
+				}
+			})
+		}
+	}
+
+	// 顺序读取 Segment 的子元素，遇到 Cluster 后通过 SeekHead 跳转到剩余的元素
+	for offset := segment.offset; offset < end && !(foundInfo && foundTracks); {
+		el, err := readElementHeader(r, offset)
+		if err != nil || el.size < 0 {
+			break
+		}
+		if el.id == idCluster {
+			break
+		}
+		parse(el)
+		offset = el.offset + el.size
+	}
+	for _, position := range []int64{seekInfo, seekTracks} {
+		if position < 0 || foundInfo && foundTracks {
+			continue
+		}
+		if el, err := readElementHeader(r, segment.offset+position); err == nil &&
+			(el.id == idInfo && !foundInfo || el.id == idTracks && !foundTracks) {
+			parse(el)
+		}
+	}
+
+	if !foundInfo && !foundTracks {
+		return nil, errors.New("未找到Info与Tracks")
+	}
+	return info, nil
+}
+
+// eachElement 遍历内存中的元素序列
+func eachElement(data []byte, fn func(id uint64, body []byte)) {
+	r := bytes.NewReader(data)
+	for offset := int64(0); offset < int64(len(data)); {
+		el, err := readElementHeader(r, offset)
+		if err != nil || el.size < 0 || el.offset+el.size > int64(len(data)) {
+			return
+		}
+		fn(el.id, data[el.offset:el.offset+el.size])
+		offset = el.offset + el.size
+	}
+}
+
+// parseSegmentInfo 解析时长，Duration 以 TimecodeScale 纳秒为单位
+func parseSegmentInfo(data []byte, info *Info) {
+	scale := uint64(1000000)
+	var duration float64
+	eachElement(data, func(id uint64, body []byte) {
+		switch id {
+		case idTimecodeScale:
+			if v := readUint(body); v > 0 {
+				scale = v
+			}
+		case idDuration:
+			duration = readFloat(body)
+		}
+	})
+	if duration > 0 {
+		info.Duration = time.Duration(duration * float64(scale))
+	}
+}
+
+// parseTracks 解析第一条视频轨道与音频轨道
+func parseTracks(data []byte, info *Info) {
+	eachElement(data, func(id uint64, entry []byte) {
+		if id != idTrackEntry {
+			return
+		}
+		var trackType uint64
+		var codecID string
+		var width, height int
+		eachElement(entry, func(id uint64, body []byte) {
+			switch id {
+			case idTrackType:
+				trackType = readUint(body)
+			case idCodecID:
+				codecID = strings.TrimRight(string(body), "\x00")
+			case idVideo:
+				eachElement(body, func(id uint64, body []byte) {
+					switch id {
+					case idPixelWidth:
+						width = int(readUint(body))
+					case idPixelHeight:
+						height = int(readUint(body))
+					}
+				})
+			}
+		})
+
+		switch trackType {
+		case 1: // 视频
+			if info.VideoCodec == "" {
+				info.VideoCodec = matroskaCodecName(codecID)
+				info.Width, info.Height = width, height
+			}
+		case 2: // 音频
+			if info.AudioCodec == "" {
+				info.AudioCodec = matroskaCodecName(codecID)
+			}
+		}
+	})
+}
+
+// matroskaCodecName 将 Matroska 编码ID转换为通用名称
+func matroskaCodecName(codecID string) string {
+	for _, codec := range matroskaCodecs {
+		if strings.HasPrefix(codecID, codec.prefix) {
+			return codec.name
+		}
+	}
+	return strings.ToLower(codecID)
+}
+
+// readUint 读取大端无符号整数
+func readUint(body []byte) uint64 {
+	var v uint64
+	for _, b := range body {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// readFloat 读取 4 或 8 字节的浮点数
+func readFloat(body []byte) float64 {
+	switch len(body) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(body)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(body))
+	}
+	return 0
+}
@@ -0,0 +1,252 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// moov 盒子的最大读取大小，超过时视为文件损坏
+const maxMoovSize = 64 << 20
+
+// 常见的顶层盒子类型，用于识别没有 ftyp 的旧式 MOV 文件
+var mp4TopLevelBoxes = map[string]bool{
+	"ftyp": true, "moov": true, "mdat": true, "free": true, "skip": true, "wide": true, "pnot": true,
+}
+
+// MP4 样本描述中的编码标识
+var mp4Codecs = map[string]string{
+	"avc1": "h264", "avc3": "h264",
+	"hvc1": "hevc", "hev1": "hevc",
+	"av01": "av1",
+	"vp08": "vp8", "vp09": "vp9",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"ac-3": "ac3", "ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	".mp3": "mp3",
+	"alac": "alac",
+	"dtsc": "dts", "dtsh": "dts", "dtsl": "dts",
+}
+
+// isMP4Header 判断文件头是否为 ISO BMFF（MP4/MOV）格式
+func isMP4Header(header []byte) bool {
+	return len(header) >= 8 && mp4TopLevelBoxes[string(header[4:8])]
+}
+
+// mp4Box 盒子头信息
+type mp4Box struct {
+	typ        string
+	offset     int64 // 盒子数据的起始位置（不含盒子头）
+	size       int64 // 盒子数据的长度
+	headerSize int64
+}
+
+// readBoxHeader 读取 offset 处的盒子头，limit 为所在容器的结束位置
+func readBoxHeader(r io.ReaderAt, offset, limit int64) (mp4Box, error) {
+	buf := make([]byte, 16)
+	if _, err := r.ReadAt(buf[:8], offset); err != nil {
+		return mp4Box{}, err
+	}
+	box := mp4Box{typ: string(buf[4:8]), headerSize: 8}
+	size := int64(binary.BigEndian.Uint32(buf[:4]))
+	switch size {
+	case 0: // 延伸到容器末尾
+		size = limit - offset
+	case 1: // 64位长度
+		if _, err := r.ReadAt(buf[8:16], offset+8); err != nil {
+			return mp4Box{}, err
+		}
+		size = int64(binary.BigEndian.Uint64(buf[8:16]))
+		box.headerSize = 16
+	}
+	if size < box.headerSize || offset+size > limit {
+		return mp4Box{}, fmt.Errorf("盒子 %q 长度无效", box.typ)
+	}
+	box.offset = offset + box.headerSize
+	box.size = size - box.headerSize
+	return box, nil
+}
+
+// probeMP4 在顶层盒子中查找 moov 并解析
+func probeMP4(r io.ReaderAt, size int64) (*Info, error) {
+	container := "mp4"
+	for offset := int64(0); offset+8 <= size; {
+		box, err := readBoxHeader(r, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		switch box.typ {
+		case "ftyp":
+			brand := make([]byte, 4)
+			if box.size >= 4 {
+				if _, err := r.ReadAt(brand, box.offset); err == nil && string(brand) == "qt  " {
+					container = "mov"
+				}
+			}
+		case "moov":
+			if box.size > maxMoovSize {
+				return nil, errors.New("moov 盒子过大")
+			}
+			data := make([]byte, box.size)
+			if _, err := r.ReadAt(data, box.offset); err != nil {
+				return nil, fmt.Errorf("读取 moov 失败: %w", err)
+			}
+			info := parseMoov(data)
+			info.Container = container
+			return info, nil
+		}
+		offset = box.offset + box.size
+	}
+	return nil, errors.New("未找到 moov 盒子")
+}
+
+// eachBox 遍历内存中的盒子序列
+func eachBox(data []byte, fn func(typ string, body []byte)) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return
+		}
+		fn(typ, data[header:size])
+		data = data[size:]
+	}
+}
+
+// mp4Track 轨道信息
+type mp4Track struct {
+	handler  string // vide、soun
+	codec    string
+	width    int
+	height   int
+	duration time.Duration
+}
+
+// parseMoov 解析 moov 盒子中的影片头与各轨道
+func parseMoov(moov []byte) *Info {
+	info := &Info{}
+	var tracks []mp4Track
+	eachBox(moov, func(typ string, body []byte) {
+		switch typ {
+		case "mvhd":
+			info.Duration = parseMediaDuration(body)
+		case "trak":
+			tracks = append(tracks, parseTrak(body))
+		}
+	})
+
+	for _, track := range tracks {
+		switch track.handler {
+		case "vide":
+			if info.VideoCodec == "" {
+				info.VideoCodec = track.codec
+				info.Width, info.Height = track.width, track.height
+			}
+		case "soun":
+			if info.AudioCodec == "" {
+				info.AudioCodec = track.codec
+			}
+		}
+		if info.Duration == 0 {
+			info.Duration = track.duration
+		}
+	}
+	return info
+}
+
+// parseMediaDuration 解析 mvhd/mdhd 中的时间刻度与时长，两者的版本字段布局相同
+func parseMediaDuration(body []byte) time.Duration {
+	if len(body) < 4 {
+		return 0
+	}
+	var timescale, duration uint64
+	if body[0] == 1 {
+		if len(body) < 32 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(body[20:24]))
+		duration = binary.BigEndian.Uint64(body[24:32])
+	} else {
+		if len(body) < 20 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(body[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(body[16:20]))
+	}
+	// 全1表示时长未知
+	if timescale == 0 || duration == 0xFFFFFFFF || duration == 0xFFFFFFFFFFFFFFFF {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+// parseTrak 解析单个轨道
+func parseTrak(trak []byte) mp4Track {
+	var track mp4Track
+	eachBox(trak, func(typ string, body []byte) {
+		switch typ {
+		case "tkhd":
+			// 宽高为 16.16 定点数，位于盒子末尾
+			if len(body) >= 84 {
+				track.width = int(binary.BigEndian.Uint32(body[len(body)-8:]) >> 16)
+				track.height = int(binary.BigEndian.Uint32(body[len(body)-4:]) >> 16)
+			}
+		case "mdia":
+			eachBox(body, func(typ string, body []byte) {
+				switch typ {
+				case "mdhd":
+					track.duration = parseMediaDuration(body)
+				case "hdlr":
+					if len(body) >= 12 {
+						track.handler = string(body[8:12])
+					}
+				case "minf":
+					parseMinf(body, &track)
+				}
+			})
+		}
+	})
+	return track
+}
+
+// parseMinf 从 minf/stbl/stsd 中读取第一个样本描述的编码与尺寸
+func parseMinf(minf []byte, track *mp4Track) {
+	eachBox(minf, func(typ string, body []byte) {
+		if typ != "stbl" {
+			return
+		}
+		eachBox(body, func(typ string, body []byte) {
+			// stsd: 版本与标志(4) + 条目数(4) + 样本描述
+			if typ != "stsd" || len(body) < 16 {
+				return
+			}
+			entry := body[8:]
+			format := string(entry[4:8])
+			if codec, ok := mp4Codecs[format]; ok {
+				track.codec = codec
+			} else {
+				track.codec = format
+			}
+			// 视频样本描述：保留字段(6) + 数据引用索引(2) + 预定义与保留(16) + 宽(2) + 高(2)
+			if len(entry) >= 36 && track.width == 0 {
+				track.width = int(binary.BigEndian.Uint16(entry[32:34]))
+				track.height = int(binary.BigEndian.Uint16(entry[34:36]))
+			}
+		})
+	})
+}
@@ -0,0 +1,106 @@
+// Package probe 纯Go实现的视频容器探测，从 MP4/MOV 与 Matroska/WebM 文件头中读取时长、分辨率与编码信息
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported 不支持的容器格式
+var ErrUnsupported = errors.New("不支持的容器格式")
+
+// 支持探测的扩展名
+var supportedExts = map[string]bool{
+	".mp4":  true,
+	".m4v":  true,
+	".mov":  true,
+	".mkv":  true,
+	".webm": true,
+}
+
+// Info 视频文件的媒体信息
+type Info struct {
+	Container  string        `json:"container"` // mp4、mov、matroska、webm
+	Duration   time.Duration `json:"duration"`
+	Width      int           `json:"width"`
+	Height     int           `json:"height"`
+	VideoCodec string        `json:"video_codec"` // h264、hevc、av1 等
+	AudioCodec string        `json:"audio_codec"` // aac、ac3、opus 等
+	Bitrate    int64         `json:"bitrate"`     // 平均码率（bit/s），由文件大小与时长计算
+}
+
+// Supported 判断文件扩展名是否支持探测
+func Supported(path string) bool {
+	return supportedExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// File 探测视频文件的媒体信息
+func File(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Reader(f, stat.Size())
+}
+
+// Reader 从可随机读取的数据源探测媒体信息，size 为数据总长度
+func Reader(r io.ReaderAt, size int64) (*Info, error) {
+	header := make([]byte, 12)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("读取文件头失败: %w", err)
+	}
+
+	var info *Info
+	var err error
+	switch {
+	case bytes.Equal(header[:4], ebmlMagic):
+		info, err = probeMatroska(r, size)
+	case isMP4Header(header):
+		info, err = probeMP4(r, size)
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Duration > 0 {
+		info.Bitrate = int64(float64(size*8) / info.Duration.Seconds())
+	}
+	return info, nil
+}
+
+// Quality 根据分辨率返回画质标识，宽银幕影片按宽度判断
+func (i *Info) Quality() string {
+	return QualityLabel(i.Width, i.Height)
+}
+
+// QualityLabel 根据宽高返回画质标识（4K、1080p、720p、480p、SD），无分辨率信息时返回空
+func QualityLabel(width, height int) string {
+	switch {
+	case width <= 0 || height <= 0:
+		return ""
+	case width >= 3200 || height >= 2000:
+		return "4K"
+	case width >= 1800 || height >= 1000:
+		return "1080p"
+	case width >= 1200 || height >= 700:
+		return "720p"
+	case width >= 640 || height >= 480:
+		return "480p"
+	default:
+		return "SD"
+	}
+}
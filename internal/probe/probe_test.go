@@ -0,0 +1,227 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// box 构造 MP4 盒子
+func box(typ string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	buf := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(8+len(body)))
+	copy(buf[4:], typ)
+	return append(buf, body...)
+}
+
+// mediaHeader 构造版本 0 的 mvhd/mdhd 数据
+func mediaHeader(timescale, duration uint32) []byte {
+	body := make([]byte, 100)
+	binary.BigEndian.PutUint32(body[12:], timescale)
+	binary.BigEndian.PutUint32(body[16:], duration)
+	return body
+}
+
+func trackHeader(width, height int) []byte {
+	body := make([]byte, 84)
+	binary.BigEndian.PutUint32(body[76:], uint32(width)<<16)
+	binary.BigEndian.PutUint32(body[80:], uint32(height)<<16)
+	return body
+}
+
+func handler(typ string) []byte {
+	body := make([]byte, 24)
+	copy(body[8:], typ)
+	return body
+}
+
+func sampleDescription(format string, width, height int) []byte {
+	entry := make([]byte, 86)
+	binary.BigEndian.PutUint32(entry, uint32(len(entry)))
+	copy(entry[4:], format)
+	binary.BigEndian.PutUint16(entry[32:], uint16(width))
+	binary.BigEndian.PutUint16(entry[34:], uint16(height))
+	body := make([]byte, 8)
+	binary.BigEndian.PutUint32(body[4:], 1)
+	return append(body, entry...)
+}
+
+func mp4Trak(handlerType, format string, width, height int) []byte {
+	return box("trak",
+		box("tkhd", trackHeader(width, height)),
+		box("mdia",
+			box("mdhd", mediaHeader(1000, 60000)),
+			box("hdlr", handler(handlerType)),
+			box("minf", box("stbl", box("stsd", sampleDescription(format, width, height)))),
+		),
+	)
+}
+
+func buildMP4(brand string, timescale, duration uint32, traks ...[]byte) []byte {
+	moov := box("moov", append([][]byte{box("mvhd", mediaHeader(timescale, duration))}, traks...)...)
+	return bytes.Join([][]byte{box("ftyp", []byte(brand+"\x00\x00\x00\x00")), box("mdat", make([]byte, 64)), moov}, nil)
+}
+
+// element 构造 EBML 元素，长度统一使用 8 字节编码
+func element(id uint64, children ...[]byte) []byte {
+	var buf []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> uint(shift)); b != 0 || len(buf) > 0 {
+			buf = append(buf, b)
+		}
+	}
+	body := bytes.Join(children, nil)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01
+	return append(append(buf, size...), body...)
+}
+
+func uintBytes(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return bytes.TrimLeft(buf, "\x00")
+}
+
+func floatBytes(v float64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(v))
+	return buf
+}
+
+func mkvTrack(trackType uint64, codecID string, width, height uint64) []byte {
+	children := [][]byte{
+		element(idTrackType, uintBytes(trackType)),
+		element(idCodecID, []byte(codecID)),
+	}
+	if trackType == 1 {
+		children = append(children, element(idVideo,
+			element(idPixelWidth, uintBytes(width)),
+			element(idPixelHeight, uintBytes(height)),
+		))
+	}
+	return element(idTrackEntry, children...)
+}
+
+func buildMatroska(docType string, duration float64, tracks ...[]byte) []byte {
+	return bytes.Join([][]byte{
+		element(idEBML, element(idDocType, []byte(docType))),
+		element(idSegment,
+			element(idInfo,
+				element(idTimecodeScale, uintBytes(1000000)),
+				element(idDuration, floatBytes(duration)),
+			),
+			element(idTracks, tracks...),
+			element(idCluster, make([]byte, 32)),
+		),
+	}, nil)
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Info
+	}{
+		{
+			name: "mp4",
+			data: buildMP4("isom", 1000, 5400000,
+				mp4Trak("vide", "avc1", 1920, 1080),
+				mp4Trak("soun", "mp4a", 0, 0),
+			),
+			want: Info{Container: "mp4", Duration: 90 * time.Minute, Width: 1920, Height: 1080, VideoCodec: "h264", AudioCodec: "aac"},
+		},
+		{
+			name: "mov",
+			data: buildMP4("qt  ", 600, 72000,
+				mp4Trak("soun", "ac-3", 0, 0),
+				mp4Trak("vide", "hvc1", 3840, 2160),
+			),
+			want: Info{Container: "mov", Duration: 2 * time.Minute, Width: 3840, Height: 2160, VideoCodec: "hevc", AudioCodec: "ac3"},
+		},
+		{
+			// mvhd 没有时长时使用轨道时长
+			name: "mp4 轨道时长",
+			data: buildMP4("isom", 1000, 0, mp4Trak("vide", "av01", 1280, 720)),
+			want: Info{Container: "mp4", Duration: time.Minute, Width: 1280, Height: 720, VideoCodec: "av1"},
+		},
+		{
+			name: "matroska",
+			data: buildMatroska("matroska", 7200000,
+				mkvTrack(1, "V_MPEGH/ISO/HEVC", 3840, 2160),
+				mkvTrack(2, "A_AAC", 0, 0),
+			),
+			want: Info{Container: "matroska", Duration: 2 * time.Hour, Width: 3840, Height: 2160, VideoCodec: "hevc", AudioCodec: "aac"},
+		},
+		{
+			name: "webm",
+			data: buildMatroska("webm", 30000,
+				mkvTrack(2, "A_OPUS", 0, 0),
+				mkvTrack(1, "V_VP9", 854, 480),
+			),
+			want: Info{Container: "webm", Duration: 30 * time.Second, Width: 854, Height: 480, VideoCodec: "vp9", AudioCodec: "opus"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Reader(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Reader 失败: %v", err)
+			}
+			want := tt.want
+			want.Bitrate = int64(float64(len(tt.data)*8) / want.Duration.Seconds())
+			if *info != want {
+				t.Errorf("Reader = %+v，期望 %+v", *info, want)
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"未知格式", []byte("RIFF\x00\x00\x00\x00AVI LIST")},
+		{"缺少 moov", bytes.Join([][]byte{box("ftyp", []byte("isom\x00\x00\x00\x00")), box("mdat", make([]byte, 16))}, nil)},
+		{"盒子长度越界", append(box("ftyp", []byte("isom\x00\x00\x00\x00")), 0, 0, 1, 0, 'm', 'o', 'o', 'v')},
+		{"缺少 Segment", element(idEBML, element(idDocType, []byte("matroska")))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Reader(bytes.NewReader(tt.data), int64(len(tt.data))); err == nil {
+				t.Error("Reader 应返回错误")
+			}
+		})
+	}
+
+	data := []byte("RIFF\x00\x00\x00\x00AVI LIST")
+	if _, err := Reader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("未知格式应返回 ErrUnsupported，实际为 %v", err)
+	}
+}
+
+func TestQualityLabel(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          string
+	}{
+		{0, 0, ""},
+		{3840, 2160, "4K"},
+		{4096, 1716, "4K"},
+		{1920, 1080, "1080p"},
+		{1920, 800, "1080p"},
+		{1280, 720, "720p"},
+		{854, 480, "480p"},
+		{480, 360, "SD"},
+	}
+	for _, tt := range tests {
+		if got := QualityLabel(tt.width, tt.height); got != tt.want {
+			t.Errorf("QualityLabel(%d, %d) = %q，期望 %q", tt.width, tt.height, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"nsfw-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ActressRankingRepository 女优排行榜仓库接口
+type ActressRankingRepository interface {
+	ReplaceBoard(board string, rankings []model.ActressRanking) error
+	GetByBoard(board string, limit int) ([]*model.ActressRanking, error)
+}
+
+// actressRankingRepository 女优排行榜仓库实现
+type actressRankingRepository struct {
+	db *gorm.DB
+}
+
+// NewActressRankingRepository 创建女优排行榜仓库
+func NewActressRankingRepository(db *gorm.DB) ActressRankingRepository {
+	return &actressRankingRepository{db: db}
+}
+
+// ReplaceBoard 用最新一次爬取的结果替换榜单，并按名称关联本地女优
+func (r *actressRankingRepository) ReplaceBoard(board string, rankings []model.ActressRanking) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("board = ?", board).Delete(&model.ActressRanking{}).Error; err != nil {
+			return err
+		}
+		if len(rankings) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(rankings, 100).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE actress_rankings SET actress_id = (
+			SELECT id FROM actresses WHERE actresses.name = actress_rankings.name AND actresses.deleted_at IS NULL LIMIT 1
+		) WHERE board = ?`, board).Error
+	})
+}
+
+// GetByBoard 获取榜单的最新排名
+func (r *actressRankingRepository) GetByBoard(board string, limit int) ([]*model.ActressRanking, error) {
+	var rankings []*model.ActressRanking
+	err := r.db.Where("board = ?", board).
+		Order("position ASC").
+		Limit(limit).
+		Find(&rankings).Error
+	return rankings, err
+}
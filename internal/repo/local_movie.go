@@ -2,6 +2,7 @@ package repo
 
 import (
 	"nsfw-go/internal/model"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -13,17 +14,116 @@ type LocalMovieRepository interface {
 	Create(movie *model.LocalMovie) error
 	Update(movie *model.LocalMovie) error
 	Delete(id uint) error
+	GetByID(id uint) (*model.LocalMovie, error)
 	GetByPath(path string) (*model.LocalMovie, error)
-	List(offset, limit int, actress string) ([]*model.LocalMovie, int64, error)
+	List(offset, limit int, actress, root string) ([]*model.LocalMovie, int64, error)
+	ListTitles(offset, limit int, filter LocalMovieFilter) ([]*model.LocalTitle, int64, error)
 	Count() (int64, error)
-	CountByActress() (map[string]int64, error)
+	CountByActress(root string) (map[string]int64, error)
+	CountByRoot() (map[string]int64, error)
 	Clear() error // 清空所有本地影片记录
 	BulkCreate(movies []*model.LocalMovie) error
+	ListUnderPathWithDeleted(path string) ([]*model.LocalMovie, error)
+	ListByRootWithDeleted(root, rootPath string) ([]*model.LocalMovie, error)
+	Restore(movie *model.LocalMovie) error
+	SoftDeleteByIDs(ids []uint) error
 	GetLastScanTime() (time.Time, error)
 	UpdateLastScanTime() error
 	Search(query string, offset, limit int) ([]*model.LocalMovie, int64, error)
-	SearchByActress(actress string, offset, limit int) ([]*model.LocalMovie, int64, error)
+	SearchWithFilter(query string, filter LocalMovieFilter, offset, limit int) ([]*model.LocalMovie, int64, error)
+	SearchByActress(actress string, filter LocalMovieFilter, offset, limit int) ([]*model.LocalMovie, int64, error)
 	SearchByCode(code string) (*model.LocalMovie, error)
+	ListDuplicateHashes() ([]*model.LocalMovie, error)
+	ListDuplicateCodes() ([]*model.LocalMovie, error)
+	SetMovieID(id uint, movieID *uint) error
+	ListWithoutNFO(limit int) ([]*model.LocalMovie, error)
+	ListCodeKeys() ([]string, error)
+	ListWithoutCodeKey(afterID uint, limit int) ([]*model.LocalMovie, error)
+	UpdateCodeKey(id uint, codeKey string) error
+	ListByCodeKey(codeKey string) ([]*model.LocalMovie, error)
+	ListUpgradeCandidates(filter UpgradeFilter, limit int) ([]*model.LocalMovie, error)
+	MarkUpgradeChecked(ids []uint, checkedAt time.Time) error
+}
+
+// UpgradeFilter 需要搜索更高画质版本的本地影片条件，满足任一画质条件即可
+type UpgradeFilter struct {
+	MinHeight      int       // 分辨率高度低于该值（含未探测到分辨率），0 表示不按分辨率筛选
+	NeedSubtitle   bool      // 没有中文字幕
+	NeedUncensored bool      // 不是无码版本
+	CheckedBefore  time.Time // 上次检查早于该时间或从未检查
+}
+
+// LocalMovieFilter 本地影片筛选与排序条件
+type LocalMovieFilter struct {
+	Actress            string `json:"actress"`
+	Root               string `json:"root"` // 媒体库根目录，为空时不筛选
+	HasSubtitle        *bool  `json:"has_subtitle"`
+	HasChineseSubtitle *bool  `json:"has_chinese_subtitle"`
+	Quality            string `json:"quality"`      // 4K、1080p、720p、480p、SD
+	VideoCodec         string `json:"video_codec"`  // h264、hevc、av1 等
+	MinHeight          int    `json:"min_height"`   // 最小分辨率高度
+	MinDuration        int    `json:"min_duration"` // 最短时长（秒）
+	MaxDuration        int    `json:"max_duration"` // 最长时长（秒）
+	SortBy             string `json:"sort_by"`      // created_at, size, duration, height, bitrate, modified
+	SortOrder          string `json:"sort_order"`   // asc, desc
+}
+
+// localMovieSortColumns 允许排序的字段
+var localMovieSortColumns = map[string]bool{
+	"created_at": true,
+	"size":       true,
+	"duration":   true,
+	"height":     true,
+	"bitrate":    true,
+	"modified":   true,
+}
+
+// sortColumn 返回排序字段，未指定或不支持时按入库时间排序
+func (f LocalMovieFilter) sortColumn() string {
+	if localMovieSortColumns[f.SortBy] {
+		return f.SortBy
+	}
+	return "created_at"
+}
+
+// sortOrder 返回排序方向
+func (f LocalMovieFilter) sortOrder() string {
+	if strings.ToLower(f.SortOrder) == "asc" {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// apply 应用筛选条件
+func (f LocalMovieFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Actress != "" {
+		query = query.Where("actress = ?", f.Actress)
+	}
+	if f.Root != "" {
+		query = query.Where("root = ?", f.Root)
+	}
+	if f.Quality != "" {
+		query = query.Where("quality = ?", f.Quality)
+	}
+	if f.VideoCodec != "" {
+		query = query.Where("video_codec = ?", strings.ToLower(f.VideoCodec))
+	}
+	if f.MinHeight > 0 {
+		query = query.Where("height >= ?", f.MinHeight)
+	}
+	if f.MinDuration > 0 {
+		query = query.Where("duration >= ?", f.MinDuration)
+	}
+	if f.MaxDuration > 0 {
+		query = query.Where("duration <= ?", f.MaxDuration)
+	}
+	if f.HasSubtitle != nil {
+		query = query.Where("has_subtitle = ?", *f.HasSubtitle)
+	}
+	if f.HasChineseSubtitle != nil {
+		query = query.Where("has_chinese_subtitle = ?", *f.HasChineseSubtitle)
+	}
+	return query
 }
 
 // localMovieRepository 本地影片仓库实现
@@ -42,10 +142,15 @@ func (r *localMovieRepository) Create(movie *model.LocalMovie) error {
 	return r.db.Create(movie).Error
 }
 
-// Update 更新本地影片
+// Update 更新本地影片，分段与字幕记录整体替换
 func (r *localMovieRepository) Update(movie *model.LocalMovie) error {
 	movie.LastScanned = time.Now()
-	return r.db.Save(movie).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Parts", "Subtitles").Save(movie).Error; err != nil {
+			return err
+		}
+		return replaceAssociations(tx, movie)
+	})
 }
 
 // Delete 删除本地影片
@@ -53,6 +158,18 @@ func (r *localMovieRepository) Delete(id uint) error {
 	return r.db.Delete(&model.LocalMovie{}, id).Error
 }
 
+// GetByID 根据ID获取本地影片（含分段与字幕）
+func (r *localMovieRepository) GetByID(id uint) (*model.LocalMovie, error) {
+	var movie model.LocalMovie
+	err := r.db.Preload("Parts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("part")
+	}).Preload("Subtitles").First(&movie, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
 // GetByPath 根据路径获取本地影片
 func (r *localMovieRepository) GetByPath(path string) (*model.LocalMovie, error) {
 	var movie model.LocalMovie
@@ -63,13 +180,16 @@ func (r *localMovieRepository) GetByPath(path string) (*model.LocalMovie, error)
 	return &movie, nil
 }
 
-// List 获取本地影片列表
-func (r *localMovieRepository) List(offset, limit int, actress string) ([]*model.LocalMovie, int64, error) {
+// List 获取本地影片列表，root 为空时不按根目录筛选
+func (r *localMovieRepository) List(offset, limit int, actress, root string) ([]*model.LocalMovie, int64, error) {
 	query := r.db.Model(&model.LocalMovie{})
 
 	if actress != "" {
 		query = query.Where("actress = ?", actress)
 	}
+	if root != "" {
+		query = query.Where("root = ?", root)
+	}
 
 	// 计算总数
 	var total int64
@@ -77,6 +197,9 @@ func (r *localMovieRepository) List(offset, limit int, actress string) ([]*model
 	if actress != "" {
 		countQuery = countQuery.Where("actress = ?", actress)
 	}
+	if root != "" {
+		countQuery = countQuery.Where("root = ?", root)
+	}
 	if err := countQuery.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -94,6 +217,67 @@ func (r *localMovieRepository) List(offset, limit int, actress string) ([]*model
 	return movies, total, nil
 }
 
+// titleKeyExpr 逻辑影片分组表达式，尚未生成分组键的旧记录按路径各自成组
+const titleKeyExpr = "COALESCE(NULLIF(title_key, ''), path)"
+
+// ListTitles 按逻辑影片分页获取本地影片，同一番号的所有版本聚合在一起
+// 排序按每个逻辑影片中各版本的最大值（如最高分辨率、最长时长）进行
+func (r *localMovieRepository) ListTitles(offset, limit int, filter LocalMovieFilter) ([]*model.LocalTitle, int64, error) {
+	groupQuery := func() *gorm.DB {
+		return filter.apply(r.db.Model(&model.LocalMovie{})).
+			Select(titleKeyExpr + " AS title_key, MAX(" + filter.sortColumn() + ") AS sort_value").
+			Group(titleKeyExpr)
+	}
+
+	// 计算逻辑影片总数
+	var total int64
+	if err := r.db.Table("(?) AS titles", groupQuery()).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页获取分组键
+	var keys []string
+	err := r.db.Table("(?) AS titles", groupQuery()).
+		Order("sort_value "+filter.sortOrder()+", title_key").
+		Offset(offset).
+		Limit(limit).
+		Pluck("title_key", &keys).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(keys) == 0 {
+		return []*model.LocalTitle{}, total, nil
+	}
+
+	// 加载这些逻辑影片的所有版本
+	var movies []*model.LocalMovie
+	query := filter.apply(r.db.Preload("Parts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("part")
+	}).Preload("Subtitles").Where(titleKeyExpr+" IN ?", keys))
+	if err := query.Order("size DESC").Find(&movies).Error; err != nil {
+		return nil, 0, err
+	}
+
+	titleByKey := make(map[string]*model.LocalTitle, len(keys))
+	titles := make([]*model.LocalTitle, 0, len(keys))
+	for _, key := range keys {
+		title := &model.LocalTitle{TitleKey: key}
+		titleByKey[key] = title
+		titles = append(titles, title)
+	}
+	for _, movie := range movies {
+		key := movie.TitleKey
+		if key == "" {
+			key = movie.Path
+		}
+		if title, ok := titleByKey[key]; ok {
+			title.Versions = append(title.Versions, movie)
+		}
+	}
+
+	return titles, total, nil
+}
+
 // Count 获取本地影片总数
 func (r *localMovieRepository) Count() (int64, error) {
 	var total int64
@@ -101,14 +285,18 @@ func (r *localMovieRepository) Count() (int64, error) {
 	return total, err
 }
 
-// CountByActress 按女优统计影片数量
-func (r *localMovieRepository) CountByActress() (map[string]int64, error) {
+// CountByActress 按女优统计影片数量，root 为空时统计所有根目录
+func (r *localMovieRepository) CountByActress(root string) (map[string]int64, error) {
 	var results []struct {
 		Actress string
 		Count   int64
 	}
 
-	err := r.db.Model(&model.LocalMovie{}).
+	query := r.db.Model(&model.LocalMovie{})
+	if root != "" {
+		query = query.Where("root = ?", root)
+	}
+	err := query.
 		Select("actress, count(*) as count").
 		Group("actress").
 		Find(&results).Error
@@ -125,8 +313,38 @@ func (r *localMovieRepository) CountByActress() (map[string]int64, error) {
 	return countMap, nil
 }
 
+// CountByRoot 按媒体库根目录统计影片数量
+func (r *localMovieRepository) CountByRoot() (map[string]int64, error) {
+	var results []struct {
+		Root  string
+		Count int64
+	}
+
+	err := r.db.Model(&model.LocalMovie{}).
+		Select("root, count(*) as count").
+		Group("root").
+		Find(&results).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	countMap := make(map[string]int64)
+	for _, result := range results {
+		countMap[result.Root] = result.Count
+	}
+
+	return countMap, nil
+}
+
 // Clear 清空所有本地影片记录（物理删除）
 func (r *localMovieRepository) Clear() error {
+	if err := r.db.Where("1 = 1").Delete(&model.LocalMoviePart{}).Error; err != nil {
+		return err
+	}
+	if err := r.db.Where("1 = 1").Delete(&model.LocalMovieSubtitle{}).Error; err != nil {
+		return err
+	}
 	return r.db.Unscoped().Where("1 = 1").Delete(&model.LocalMovie{}).Error
 }
 
@@ -139,6 +357,82 @@ func (r *localMovieRepository) BulkCreate(movies []*model.LocalMovie) error {
 	return r.db.CreateInBatches(movies, 100).Error
 }
 
+// ListUnderPathWithDeleted 获取指定文件或目录下的本地影片记录（包含已软删除的）
+func (r *localMovieRepository) ListUnderPathWithDeleted(path string) ([]*model.LocalMovie, error) {
+	var movies []*model.LocalMovie
+	prefix := strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator)
+	err := r.db.Unscoped().Preload("Parts").Preload("Subtitles").
+		Where("path = ? OR path LIKE ?", path, escapeLike(prefix)+"%").
+		Find(&movies).Error
+	return movies, err
+}
+
+// ListByRootWithDeleted 获取指定根目录的本地影片记录（包含已软删除的）
+// 尚未记录根目录的旧数据按路径归属到该根目录
+func (r *localMovieRepository) ListByRootWithDeleted(root, rootPath string) ([]*model.LocalMovie, error) {
+	var movies []*model.LocalMovie
+	prefix := strings.TrimSuffix(rootPath, string(filepath.Separator)) + string(filepath.Separator)
+	err := r.db.Unscoped().Preload("Parts").Preload("Subtitles").
+		Where("root = ? OR (root = '' AND path LIKE ?)", root, escapeLike(prefix)+"%").
+		Find(&movies).Error
+	return movies, err
+}
+
+// Restore 恢复已软删除的本地影片并写入最新数据
+func (r *localMovieRepository) Restore(movie *model.LocalMovie) error {
+	movie.DeletedAt = gorm.DeletedAt{}
+	movie.LastScanned = time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Omit("Parts", "Subtitles").Save(movie).Error; err != nil {
+			return err
+		}
+		return replaceAssociations(tx, movie)
+	})
+}
+
+// replaceAssociations 用影片当前的分段与字幕列表替换数据库中的记录
+func replaceAssociations(tx *gorm.DB, movie *model.LocalMovie) error {
+	if err := tx.Where("local_movie_id = ?", movie.ID).Delete(&model.LocalMoviePart{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("local_movie_id = ?", movie.ID).Delete(&model.LocalMovieSubtitle{}).Error; err != nil {
+		return err
+	}
+	for i := range movie.Parts {
+		movie.Parts[i].ID = 0
+		movie.Parts[i].LocalMovieID = movie.ID
+	}
+	for i := range movie.Subtitles {
+		movie.Subtitles[i].ID = 0
+		movie.Subtitles[i].LocalMovieID = movie.ID
+	}
+	if len(movie.Parts) > 0 {
+		if err := tx.Create(&movie.Parts).Error; err != nil {
+			return err
+		}
+	}
+	if len(movie.Subtitles) > 0 {
+		return tx.Create(&movie.Subtitles).Error
+	}
+	return nil
+}
+
+// SoftDeleteByIDs 批量软删除本地影片
+func (r *localMovieRepository) SoftDeleteByIDs(ids []uint) error {
+	// 分批删除，避免超出PostgreSQL参数数量限制
+	const batchSize = 1000
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := r.db.Where("id IN ?", ids[start:end]).Delete(&model.LocalMovie{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetLastScanTime 获取最后扫描时间
 func (r *localMovieRepository) GetLastScanTime() (time.Time, error) {
 	var movie model.LocalMovie
@@ -160,6 +454,11 @@ func (r *localMovieRepository) UpdateLastScanTime() error {
 
 // Search 综合搜索本地影片
 func (r *localMovieRepository) Search(query string, offset, limit int) ([]*model.LocalMovie, int64, error) {
+	return r.SearchWithFilter(query, LocalMovieFilter{}, offset, limit)
+}
+
+// SearchWithFilter 按筛选条件综合搜索本地影片
+func (r *localMovieRepository) SearchWithFilter(query string, filter LocalMovieFilter, offset, limit int) ([]*model.LocalMovie, int64, error) {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return []*model.LocalMovie{}, 0, nil
@@ -170,20 +469,21 @@ func (r *localMovieRepository) Search(query string, offset, limit int) ([]*model
 
 	// 计算总数
 	var total int64
-	countQuery := r.db.Model(&model.LocalMovie{}).Where(
+	countQuery := filter.apply(r.db.Model(&model.LocalMovie{}).Where(
 		"title ILIKE ? OR code ILIKE ? OR actress ILIKE ?",
 		searchPattern, searchPattern, searchPattern,
-	)
+	))
 	if err := countQuery.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// 分页查询
 	var movies []*model.LocalMovie
-	query_db := r.db.Where(
+	query_db := filter.apply(r.db.Preload("Subtitles").Where(
 		"title ILIKE ? OR code ILIKE ? OR actress ILIKE ?",
 		searchPattern, searchPattern, searchPattern,
-	).Order("created_at DESC").
+	))
+	query_db = query_db.Order(filter.sortColumn() + " " + filter.sortOrder()).
 		Offset(offset).
 		Limit(limit)
 
@@ -196,7 +496,7 @@ func (r *localMovieRepository) Search(query string, offset, limit int) ([]*model
 }
 
 // SearchByActress 按女优搜索本地影片
-func (r *localMovieRepository) SearchByActress(actress string, offset, limit int) ([]*model.LocalMovie, int64, error) {
+func (r *localMovieRepository) SearchByActress(actress string, filter LocalMovieFilter, offset, limit int) ([]*model.LocalMovie, int64, error) {
 	actress = strings.TrimSpace(actress)
 	if actress == "" {
 		return []*model.LocalMovie{}, 0, nil
@@ -204,15 +504,15 @@ func (r *localMovieRepository) SearchByActress(actress string, offset, limit int
 
 	// 计算总数
 	var total int64
-	countQuery := r.db.Model(&model.LocalMovie{}).Where("actress ILIKE ?", "%"+actress+"%")
+	countQuery := filter.apply(r.db.Model(&model.LocalMovie{}).Where("actress ILIKE ?", "%"+actress+"%"))
 	if err := countQuery.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// 分页查询
 	var movies []*model.LocalMovie
-	err := r.db.Where("actress ILIKE ?", "%"+actress+"%").
-		Order("created_at DESC").
+	err := filter.apply(r.db.Preload("Subtitles").Where("actress ILIKE ?", "%"+actress+"%")).
+		Order(filter.sortColumn() + " " + filter.sortOrder()).
 		Offset(offset).
 		Limit(limit).
 		Find(&movies).Error
@@ -237,3 +537,139 @@ func (r *localMovieRepository) SearchByCode(code string) (*model.LocalMovie, err
 	}
 	return &movie, nil
 }
+
+// ListDuplicateHashes 获取内容摘要相同（同一文件存在多份）的本地影片
+func (r *localMovieRepository) ListDuplicateHashes() ([]*model.LocalMovie, error) {
+	duplicated := r.db.Model(&model.LocalMovie{}).
+		Select("content_hash").
+		Where("content_hash <> ''").
+		Group("content_hash").
+		Having("COUNT(*) > 1")
+
+	var movies []*model.LocalMovie
+	err := r.db.Preload("Parts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("part")
+	}).Where("content_hash IN (?)", duplicated).
+		Order("content_hash, created_at").
+		Find(&movies).Error
+	return movies, err
+}
+
+// ListDuplicateCodes 获取同一番号、同一版本存在多条记录的本地影片
+// 同一番号的不同版本（如 4K 与 1080p）是有意保留的，不视为重复
+func (r *localMovieRepository) ListDuplicateCodes() ([]*model.LocalMovie, error) {
+	duplicated := r.db.Model(&model.LocalMovie{}).
+		Select("UPPER(code), version").
+		Where("code <> ''").
+		Group("UPPER(code), version").
+		Having("COUNT(*) > 1")
+
+	var movies []*model.LocalMovie
+	err := r.db.Preload("Parts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("part")
+	}).Where("(UPPER(code), version) IN (?)", duplicated).
+		Order("UPPER(code), version, size DESC").
+		Find(&movies).Error
+	return movies, err
+}
+
+// SetMovieID 关联本地影片与影片元数据
+func (r *localMovieRepository) SetMovieID(id uint, movieID *uint) error {
+	return r.db.Model(&model.LocalMovie{}).Where("id = ?", id).Update("movie_id", movieID).Error
+}
+
+// ListWithoutNFO 获取没有NFO文件的本地影片，limit 为 0 时不限制数量
+func (r *localMovieRepository) ListWithoutNFO(limit int) ([]*model.LocalMovie, error) {
+	query := r.db.Preload("Parts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("part")
+	}).Where("nfo_path = ''").Order("id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var movies []*model.LocalMovie
+	err := query.Find(&movies).Error
+	return movies, err
+}
+
+// ListCodeKeys 获取所有本地影片的番号等价键（去重）
+func (r *localMovieRepository) ListCodeKeys() ([]string, error) {
+	var keys []string
+	err := r.db.Model(&model.LocalMovie{}).
+		Where("code_key <> ''").
+		Distinct("code_key").
+		Pluck("code_key", &keys).Error
+	return keys, err
+}
+
+// ListWithoutCodeKey 按ID顺序获取没有番号等价键的本地影片
+func (r *localMovieRepository) ListWithoutCodeKey(afterID uint, limit int) ([]*model.LocalMovie, error) {
+	var movies []*model.LocalMovie
+	err := r.db.Where("(code_key = '' OR code_key IS NULL) AND id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&movies).Error
+	return movies, err
+}
+
+// UpdateCodeKey 更新番号等价键
+func (r *localMovieRepository) UpdateCodeKey(id uint, codeKey string) error {
+	return r.db.Model(&model.LocalMovie{}).Where("id = ?", id).Update("code_key", codeKey).Error
+}
+
+// ListByCodeKey 获取同一番号等价键的所有本地影片（即同一影片的不同版本）
+func (r *localMovieRepository) ListByCodeKey(codeKey string) ([]*model.LocalMovie, error) {
+	var movies []*model.LocalMovie
+	if codeKey == "" {
+		return movies, nil
+	}
+	err := r.db.Where("code_key = ?", codeKey).Order("id").Find(&movies).Error
+	return movies, err
+}
+
+// ListUpgradeCandidates 获取画质低于要求的有番号本地影片，从未检查过的优先，其次按上次检查时间排序
+func (r *localMovieRepository) ListUpgradeCandidates(filter UpgradeFilter, limit int) ([]*model.LocalMovie, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.MinHeight > 0 {
+		conditions = append(conditions, "height < ?")
+		args = append(args, filter.MinHeight)
+	}
+	if filter.NeedSubtitle {
+		conditions = append(conditions, "(has_chinese_subtitle = false AND hard_subtitle = false)")
+	}
+	if filter.NeedUncensored {
+		conditions = append(conditions, "uncensored = false")
+	}
+
+	var movies []*model.LocalMovie
+	if len(conditions) == 0 {
+		return movies, nil
+	}
+	query := r.db.Preload("Parts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("part")
+	}).Preload("Subtitles").
+		Where("code <> ''").
+		Where("("+strings.Join(conditions, " OR ")+")", args...).
+		Where("upgrade_checked_at IS NULL OR upgrade_checked_at < ?", filter.CheckedBefore).
+		Order("upgrade_checked_at NULLS FIRST, id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&movies).Error
+	return movies, err
+}
+
+// MarkUpgradeChecked 记录影片的更高画质版本检查时间
+func (r *localMovieRepository) MarkUpgradeChecked(ids []uint, checkedAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.LocalMovie{}).Where("id IN ?", ids).Update("upgrade_checked_at", checkedAt).Error
+}
+
+// escapeLike 转义LIKE模式中的特殊字符（路径中常见的下划线等）
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
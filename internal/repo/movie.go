@@ -20,6 +20,9 @@ type MovieRepository interface {
 	GetRecentlyAdded(limit int) ([]*model.Movie, error)
 	GetPopular(limit int) ([]*model.Movie, error)
 	Count() (int64, error)
+	FillMediaInfo(code string, duration int, quality string) error
+	ReplaceRelations(movie *model.Movie, actresses []*model.Actress, tags []*model.Tag) error
+	SetLocalPath(id uint, path string) error
 }
 
 // MovieFilter 影片筛选条件
@@ -261,3 +264,37 @@ func (r *movieRepository) Count() (int64, error) {
 	err := r.db.Model(&model.Movie{}).Count(&total).Error
 	return total, err
 }
+
+// FillMediaInfo 补全影片缺失的时长（分钟）与画质，已有数据不会被覆盖
+func (r *movieRepository) FillMediaInfo(code string, duration int, quality string) error {
+	code = strings.ToUpper(code)
+	if duration > 0 {
+		err := r.db.Model(&model.Movie{}).
+			Where("code = ? AND (duration IS NULL OR duration = 0)", code).
+			Update("duration", duration).Error
+		if err != nil {
+			return err
+		}
+	}
+	if quality != "" {
+		return r.db.Model(&model.Movie{}).
+			Where("code = ? AND (quality IS NULL OR quality = '')", code).
+			Update("quality", quality).Error
+	}
+	return nil
+}
+
+// SetLocalPath 更新影片的本地文件路径
+func (r *movieRepository) SetLocalPath(id uint, path string) error {
+	return r.db.Model(&model.Movie{}).Where("id = ?", id).Update("local_path", path).Error
+}
+
+// ReplaceRelations 替换影片关联的演员与标签
+func (r *movieRepository) ReplaceRelations(movie *model.Movie, actresses []*model.Actress, tags []*model.Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(movie).Association("Actresses").Replace(actresses); err != nil {
+			return err
+		}
+		return tx.Model(movie).Association("Tags").Replace(tags)
+	})
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"nsfw-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// OrganizeRepository 媒体库整理记录仓库接口
+type OrganizeRepository interface {
+	CreateRun(run *model.OrganizeRun) error
+	UpdateRun(run *model.OrganizeRun) error
+	GetRun(id uint) (*model.OrganizeRun, error)
+	ListRuns(offset, limit int) ([]*model.OrganizeRun, int64, error)
+	CreateEntry(entry *model.OrganizeJournalEntry) error
+	UpdateEntry(entry *model.OrganizeJournalEntry) error
+}
+
+// organizeRepository 媒体库整理记录仓库实现
+type organizeRepository struct {
+	db *gorm.DB
+}
+
+// NewOrganizeRepository 创建媒体库整理记录仓库
+func NewOrganizeRepository(db *gorm.DB) OrganizeRepository {
+	return &organizeRepository{db: db}
+}
+
+// CreateRun 创建整理记录
+func (r *organizeRepository) CreateRun(run *model.OrganizeRun) error {
+	return r.db.Omit("Entries").Create(run).Error
+}
+
+// UpdateRun 更新整理记录（不含日志条目）
+func (r *organizeRepository) UpdateRun(run *model.OrganizeRun) error {
+	return r.db.Omit("Entries").Save(run).Error
+}
+
+// GetRun 根据ID获取整理记录，日志条目按执行顺序排列
+func (r *organizeRepository) GetRun(id uint) (*model.OrganizeRun, error) {
+	var run model.OrganizeRun
+	err := r.db.Preload("Entries", func(db *gorm.DB) *gorm.DB {
+		return db.Order("seq")
+	}).First(&run, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRuns 分页获取整理记录（不含日志条目）
+func (r *organizeRepository) ListRuns(offset, limit int) ([]*model.OrganizeRun, int64, error) {
+	var total int64
+	if err := r.db.Model(&model.OrganizeRun{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var runs []*model.OrganizeRun
+	err := r.db.Order("started_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&runs).Error
+	return runs, total, err
+}
+
+// CreateEntry 写入日志条目
+func (r *organizeRepository) CreateEntry(entry *model.OrganizeJournalEntry) error {
+	return r.db.Create(entry).Error
+}
+
+// UpdateEntry 更新日志条目状态
+func (r *organizeRepository) UpdateEntry(entry *model.OrganizeJournalEntry) error {
+	return r.db.Save(entry).Error
+}
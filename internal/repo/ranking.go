@@ -11,19 +11,35 @@ import (
 type RankingRepository interface {
 	Create(ranking *model.Ranking) error
 	BatchCreate(rankings []model.Ranking) error
-	GetByRankType(rankType string, limit int) ([]*model.Ranking, error)
-	GetLatestCrawlTime(rankType string) (*time.Time, error)
-	ClearOldRankings(rankType string, keepTime time.Time) error
-	GetPendingCheck(limit int) ([]*model.Ranking, error)
-	UpdateLocalExists(id uint, exists bool) error
+	GetByBoard(board string, limit int) ([]*model.Ranking, error)
+	GetLatestCrawlTime(board string) (*time.Time, error)
+	ClearOldRankings(board string, keepTime time.Time) error
+	BackfillBoards() error
+	ListForLocalCheck() ([]*model.Ranking, error)
+	MarkLocalExists(existsIDs, missingIDs []uint) error
+	MarkLocalByCodes(codes []string) (int64, error)
 	GetByCode(code string) (*model.Ranking, error)
-	GetByCodeAndType(code, rankType string) (*model.Ranking, error)
+	GetByCodeAndBoard(code, board string) (*model.Ranking, error)
 	Count() (int64, error)
 	Search(query string, offset, limit int) ([]*model.Ranking, int64, error)
 	SearchByCode(code string, offset, limit int) ([]*model.Ranking, int64, error)
-	CountByType(rankType string) (int64, error)
-	CountLocalExistsByType(rankType string) (int64, error)
-	GetStatsByType() (map[string]map[string]int64, error)
+	GetStatsByBoard() (map[string]map[string]int64, error)
+
+	// 历史快照
+	BatchCreateSnapshots(snapshots []model.RankingSnapshot) error
+	GetSnapshotTimes(board string, limit int) ([]time.Time, error)
+	GetSnapshot(board string, crawledAt time.Time) ([]*model.RankingSnapshot, error)
+	GetSnapshotsByCode(code string, since time.Time) ([]*model.RankingSnapshot, error)
+	GetChartStats(board string, codes []string) (map[string]RankingChartStats, error)
+}
+
+// RankingChartStats 番号在某个榜单上的历史统计
+type RankingChartStats struct {
+	Code         string    `json:"code"`
+	PeakPosition int       `json:"peak_position"` // 历史最高排名（数值最小）
+	DaysOnChart  int       `json:"days_on_chart"` // 上榜天数（按爬取日期去重）
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
 }
 
 // rankingRepository 排行榜仓库实现
@@ -49,10 +65,10 @@ func (r *rankingRepository) BatchCreate(rankings []model.Ranking) error {
 	return r.db.CreateInBatches(rankings, 50).Error
 }
 
-// GetByRankType 根据排行榜类型获取记录
-func (r *rankingRepository) GetByRankType(rankType string, limit int) ([]*model.Ranking, error) {
+// GetByBoard 根据榜单获取记录
+func (r *rankingRepository) GetByBoard(board string, limit int) ([]*model.Ranking, error) {
 	var rankings []*model.Ranking
-	err := r.db.Where("rank_type = ?", rankType).
+	err := r.db.Where("board = ?", board).
 		Order("position ASC").
 		Limit(limit).
 		Find(&rankings).Error
@@ -60,9 +76,9 @@ func (r *rankingRepository) GetByRankType(rankType string, limit int) ([]*model.
 }
 
 // GetLatestCrawlTime 获取最新爬取时间
-func (r *rankingRepository) GetLatestCrawlTime(rankType string) (*time.Time, error) {
+func (r *rankingRepository) GetLatestCrawlTime(board string) (*time.Time, error) {
 	var ranking model.Ranking
-	err := r.db.Where("rank_type = ?", rankType).
+	err := r.db.Where("board = ?", board).
 		Order("crawled_at DESC").
 		First(&ranking).Error
 
@@ -77,35 +93,56 @@ func (r *rankingRepository) GetLatestCrawlTime(rankType string) (*time.Time, err
 }
 
 // ClearOldRankings 清理旧的排行榜记录
-func (r *rankingRepository) ClearOldRankings(rankType string, keepTime time.Time) error {
-	return r.db.Where("rank_type = ? AND crawled_at < ?", rankType, keepTime).
+func (r *rankingRepository) ClearOldRankings(board string, keepTime time.Time) error {
+	return r.db.Where("board = ? AND crawled_at < ?", board, keepTime).
 		Delete(&model.Ranking{}).Error
 }
 
-// GetPendingCheck 获取需要检查的记录
-func (r *rankingRepository) GetPendingCheck(limit int) ([]*model.Ranking, error) {
-	var rankings []*model.Ranking
-
-	// 获取从未检查过的记录，或者1小时前检查过的记录
-	oneHourAgo := time.Now().Add(-time.Hour)
-
-	err := r.db.Where("last_checked IS NULL OR last_checked < ?", oneHourAgo).
-		Order("last_checked ASC NULLS FIRST").
-		Limit(limit).
-		Find(&rankings).Error
+// BackfillBoards 为没有榜单标识的旧记录补充标识：旧记录都来自有码榜，标识与排行榜周期相同
+func (r *rankingRepository) BackfillBoards() error {
+	if err := r.db.Model(&model.Ranking{}).
+		Where("board = '' OR board IS NULL").
+		Update("board", gorm.Expr("rank_type")).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&model.RankingSnapshot{}).
+		Where("board = '' OR board IS NULL").
+		Update("board", gorm.Expr("rank_type")).Error
+}
 
+// ListForLocalCheck 获取所有排行榜记录的番号与本地存在状态
+func (r *rankingRepository) ListForLocalCheck() ([]*model.Ranking, error) {
+	var rankings []*model.Ranking
+	err := r.db.Select("id", "code", "local_exists").Find(&rankings).Error
 	return rankings, err
 }
 
-// UpdateLocalExists 更新本地存在状态
-func (r *rankingRepository) UpdateLocalExists(id uint, exists bool) error {
+// MarkLocalExists 批量更新本地存在状态，并刷新所有记录的检查时间
+func (r *rankingRepository) MarkLocalExists(existsIDs, missingIDs []uint) error {
 	now := time.Now()
-	return r.db.Model(&model.Ranking{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"local_exists": exists,
-			"last_checked": &now,
-		}).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(existsIDs) > 0 {
+			if err := tx.Model(&model.Ranking{}).Where("id IN ?", existsIDs).
+				Update("local_exists", true).Error; err != nil {
+				return err
+			}
+		}
+		if len(missingIDs) > 0 {
+			if err := tx.Model(&model.Ranking{}).Where("id IN ?", missingIDs).
+				Update("local_exists", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.Ranking{}).Where("1 = 1").Update("last_checked", &now).Error
+	})
+}
+
+// MarkLocalByCodes 将指定番号的所有排行榜记录标记为本地存在
+func (r *rankingRepository) MarkLocalByCodes(codes []string) (int64, error) {
+	now := time.Now()
+	result := r.db.Model(&model.Ranking{}).Where("code IN ?", codes).
+		Updates(map[string]interface{}{"local_exists": true, "last_checked": &now})
+	return result.RowsAffected, result.Error
 }
 
 // GetByCode 根据番号获取记录
@@ -118,10 +155,10 @@ func (r *rankingRepository) GetByCode(code string) (*model.Ranking, error) {
 	return &ranking, nil
 }
 
-// GetByCodeAndType 根据番号和排行榜类型获取记录
-func (r *rankingRepository) GetByCodeAndType(code, rankType string) (*model.Ranking, error) {
+// GetByCodeAndBoard 根据番号和榜单获取记录
+func (r *rankingRepository) GetByCodeAndBoard(code, board string) (*model.Ranking, error) {
 	var ranking model.Ranking
-	err := r.db.Where("code = ? AND rank_type = ?", code, rankType).
+	err := r.db.Where("code = ? AND board = ?", code, board).
 		Order("crawled_at DESC").
 		First(&ranking).Error
 	if err != nil {
@@ -142,12 +179,12 @@ func (r *rankingRepository) Search(query string, offset, limit int) ([]*model.Ra
 	var rankings []*model.Ranking
 	var total int64
 	err := r.db.Model(&model.Ranking{}).
-		Where("rank_type LIKE ? OR code LIKE ? OR title LIKE ?", "%"+query+"%", "%"+query+"%", "%"+query+"%").
+		Where("board LIKE ? OR code LIKE ? OR title LIKE ?", "%"+query+"%", "%"+query+"%", "%"+query+"%").
 		Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
-	err = r.db.Where("rank_type LIKE ? OR code LIKE ? OR title LIKE ?", "%"+query+"%", "%"+query+"%", "%"+query+"%").
+	err = r.db.Where("board LIKE ? OR code LIKE ? OR title LIKE ?", "%"+query+"%", "%"+query+"%", "%"+query+"%").
 		Order("position ASC").
 		Offset(offset).
 		Limit(limit).
@@ -155,48 +192,28 @@ func (r *rankingRepository) Search(query string, offset, limit int) ([]*model.Ra
 	return rankings, total, err
 }
 
-// CountByType 按类型统计排行榜数量
-func (r *rankingRepository) CountByType(rankType string) (int64, error) {
-	var count int64
-	err := r.db.Model(&model.Ranking{}).Where("rank_type = ?", rankType).Count(&count).Error
-	return count, err
-}
-
-// CountLocalExistsByType 按类型统计本地存在的排行榜数量
-func (r *rankingRepository) CountLocalExistsByType(rankType string) (int64, error) {
-	var count int64
+// GetStatsByBoard 按榜单统计排行榜数量与本地已存在的数量
+func (r *rankingRepository) GetStatsByBoard() (map[string]map[string]int64, error) {
+	var rows []struct {
+		Board string
+		Total int64
+		Local int64
+	}
 	err := r.db.Model(&model.Ranking{}).
-		Where("rank_type = ? AND local_exists = ?", rankType, true).
-		Count(&count).Error
-	return count, err
-}
-
-// GetStatsByType 获取按类型的统计信息
-func (r *rankingRepository) GetStatsByType() (map[string]map[string]int64, error) {
-	stats := make(map[string]map[string]int64)
-
-	// 定义排行榜类型
-	rankTypes := []string{"daily", "weekly", "monthly"}
-
-	for _, rankType := range rankTypes {
-		// 获取该类型的总数
-		total, err := r.CountByType(rankType)
-		if err != nil {
-			return nil, err
-		}
-
-		// 获取该类型的本地存在数量
-		local, err := r.CountLocalExistsByType(rankType)
-		if err != nil {
-			return nil, err
-		}
+		Select("board, COUNT(*) AS total, SUM(CASE WHEN local_exists THEN 1 ELSE 0 END) AS local").
+		Group("board").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
 
-		stats[rankType] = map[string]int64{
-			"total": total,
-			"local": local,
+	stats := make(map[string]map[string]int64, len(rows))
+	for _, row := range rows {
+		stats[row.Board] = map[string]int64{
+			"total": row.Total,
+			"local": row.Local,
 		}
 	}
-
 	return stats, nil
 }
 
@@ -217,3 +234,67 @@ func (r *rankingRepository) SearchByCode(code string, offset, limit int) ([]*mod
 		Find(&rankings).Error
 	return rankings, total, err
 }
+
+// BatchCreateSnapshots 批量保存排行榜快照
+func (r *rankingRepository) BatchCreateSnapshots(snapshots []model.RankingSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(snapshots, 100).Error
+}
+
+// GetSnapshotTimes 获取最近的快照时间，按时间倒序
+func (r *rankingRepository) GetSnapshotTimes(board string, limit int) ([]time.Time, error) {
+	var times []time.Time
+	err := r.db.Model(&model.RankingSnapshot{}).
+		Where("board = ?", board).
+		Distinct("crawled_at").
+		Order("crawled_at DESC").
+		Limit(limit).
+		Pluck("crawled_at", &times).Error
+	return times, err
+}
+
+// GetSnapshot 获取指定时间的整榜快照，按排名排序
+func (r *rankingRepository) GetSnapshot(board string, crawledAt time.Time) ([]*model.RankingSnapshot, error) {
+	var snapshots []*model.RankingSnapshot
+	err := r.db.Where("board = ? AND crawled_at = ?", board, crawledAt).
+		Order("position ASC").
+		Find(&snapshots).Error
+	return snapshots, err
+}
+
+// GetSnapshotsByCode 获取番号在各榜单上的历史快照，按榜单和时间排序
+func (r *rankingRepository) GetSnapshotsByCode(code string, since time.Time) ([]*model.RankingSnapshot, error) {
+	var snapshots []*model.RankingSnapshot
+	query := r.db.Where("code = ?", code)
+	if !since.IsZero() {
+		query = query.Where("crawled_at >= ?", since)
+	}
+	err := query.Order("board ASC, crawled_at ASC").Find(&snapshots).Error
+	return snapshots, err
+}
+
+// GetChartStats 统计番号在指定榜单上的最高排名与上榜天数
+func (r *rankingRepository) GetChartStats(board string, codes []string) (map[string]RankingChartStats, error) {
+	stats := make(map[string]RankingChartStats, len(codes))
+	if len(codes) == 0 {
+		return stats, nil
+	}
+
+	var rows []RankingChartStats
+	err := r.db.Model(&model.RankingSnapshot{}).
+		Select("code, MIN(position) AS peak_position, COUNT(DISTINCT DATE(crawled_at)) AS days_on_chart, "+
+			"MIN(crawled_at) AS first_seen, MAX(crawled_at) AS last_seen").
+		Where("board = ? AND code IN ?", board, codes).
+		Group("code").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		stats[row.Code] = row
+	}
+	return stats, nil
+}
@@ -14,15 +14,23 @@ type RankingDownloadTaskRepository interface {
 	GetByID(id uint) (*model.RankingDownloadTask, error)
 	GetByCode(code string) (*model.RankingDownloadTask, error)
 	Update(task *model.RankingDownloadTask) error
+	UpdateIfStatus(task *model.RankingDownloadTask, statuses []string) (bool, error)
 	Delete(id uint) error
 	HardDelete(id uint) error
 	
 	// 查询方法
 	GetActiveTaskByCode(code string) (*model.RankingDownloadTask, error)
 	GetTasksByStatus(status string) ([]*model.RankingDownloadTask, error)
+	GetTasksByStatuses(statuses []string) ([]*model.RankingDownloadTask, error)
 	GetTasksBySource(source string) ([]*model.RankingDownloadTask, error)
 	GetTasksByRankType(rankType string) ([]*model.RankingDownloadTask, error)
 	
+	// 下载队列
+	GetQueuedTasks(limit int) ([]*model.RankingDownloadTask, error)
+	GetQueuePosition(task *model.RankingDownloadTask) (int, error)
+	RequeueTasks(statuses []string) (int64, error)
+	GetTasksByImportStatus(importStatus string) ([]*model.RankingDownloadTask, error)
+	
 	// 分页查询
 	GetTasks(limit, offset int) ([]*model.RankingDownloadTask, int64, error)
 	GetTasksWithFilter(status, source, rankType string, limit, offset int) ([]*model.RankingDownloadTask, int64, error)
@@ -78,10 +86,10 @@ func (r *rankingDownloadTaskRepo) GetByID(id uint) (*model.RankingDownloadTask,
 	return &task, nil
 }
 
-// GetByCode 根据番号获取任务（不包括软删除）
+// GetByCode 根据番号获取最新的任务（不包括软删除），画质升级会为同一番号保留多条任务记录
 func (r *rankingDownloadTaskRepo) GetByCode(code string) (*model.RankingDownloadTask, error) {
 	var task model.RankingDownloadTask
-	err := r.db.Where("code = ? AND deleted_at IS NULL", code).First(&task).Error
+	err := r.db.Where("code = ? AND deleted_at IS NULL", code).Order("created_at DESC, id DESC").Take(&task).Error
 	if err != nil {
 		return nil, err
 	}
@@ -108,6 +116,13 @@ func (r *rankingDownloadTaskRepo) Update(task *model.RankingDownloadTask) error
 	return r.db.Save(task).Error
 }
 
+// UpdateIfStatus 仅当数据库中的任务仍处于指定状态之一时更新，返回是否已更新
+// 用于后台流程保存状态，避免覆盖期间被取消等外部修改
+func (r *rankingDownloadTaskRepo) UpdateIfStatus(task *model.RankingDownloadTask, statuses []string) (bool, error) {
+	result := r.db.Model(task).Where("status IN ?", statuses).Select("*").Updates(task)
+	return result.RowsAffected > 0, result.Error
+}
+
 // Delete 删除任务（软删除）
 func (r *rankingDownloadTaskRepo) Delete(id uint) error {
 	return r.db.Delete(&model.RankingDownloadTask{}, id).Error
@@ -125,6 +140,13 @@ func (r *rankingDownloadTaskRepo) GetTasksByStatus(status string) ([]*model.Rank
 	return tasks, err
 }
 
+// GetTasksByStatuses 获取处于任一指定状态的任务
+func (r *rankingDownloadTaskRepo) GetTasksByStatuses(statuses []string) ([]*model.RankingDownloadTask, error) {
+	var tasks []*model.RankingDownloadTask
+	err := r.db.Where("status IN ?", statuses).Order("created_at ASC").Find(&tasks).Error
+	return tasks, err
+}
+
 // GetTasksBySource 根据来源获取任务
 func (r *rankingDownloadTaskRepo) GetTasksBySource(source string) ([]*model.RankingDownloadTask, error) {
 	var tasks []*model.RankingDownloadTask
@@ -139,6 +161,52 @@ func (r *rankingDownloadTaskRepo) GetTasksByRankType(rankType string) ([]*model.
 	return tasks, err
 }
 
+// GetQueuedTasks 按队列顺序获取等待中的任务：优先级高的优先，同优先级先创建的优先；未到重试时间的任务不返回
+func (r *rankingDownloadTaskRepo) GetQueuedTasks(limit int) ([]*model.RankingDownloadTask, error) {
+	var tasks []*model.RankingDownloadTask
+	err := r.db.Where("status = ?", model.RankingDownloadStatusPending).
+		Where("next_retry_at IS NULL OR next_retry_at <= ?", time.Now()).
+		Order("priority DESC, created_at ASC, id ASC").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// GetQueuePosition 获取等待中的任务在队列中的位置（从1开始），等待重试的任务不计入
+func (r *rankingDownloadTaskRepo) GetQueuePosition(task *model.RankingDownloadTask) (int, error) {
+	var ahead int64
+	err := r.db.Model(&model.RankingDownloadTask{}).
+		Where("status = ?", model.RankingDownloadStatusPending).
+		Where("next_retry_at IS NULL OR next_retry_at <= ?", time.Now()).
+		Where("priority > ? OR (priority = ? AND (created_at < ? OR (created_at = ? AND id < ?)))",
+			task.Priority, task.Priority, task.CreatedAt, task.CreatedAt, task.ID).
+		Count(&ahead).Error
+	if err != nil {
+		return 0, err
+	}
+	return int(ahead) + 1, nil
+}
+
+// RequeueTasks 将处于指定状态的任务重新放回队列
+func (r *rankingDownloadTaskRepo) RequeueTasks(statuses []string) (int64, error) {
+	result := r.db.Model(&model.RankingDownloadTask{}).
+		Where("status IN ?", statuses).
+		Updates(map[string]interface{}{
+			"status":     model.RankingDownloadStatusPending,
+			"started_at": nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// GetTasksByImportStatus 根据导入状态获取已完成的任务
+func (r *rankingDownloadTaskRepo) GetTasksByImportStatus(importStatus string) ([]*model.RankingDownloadTask, error) {
+	var tasks []*model.RankingDownloadTask
+	err := r.db.Where("status = ? AND import_status = ?", model.RankingDownloadStatusCompleted, importStatus).
+		Order("completed_at ASC, id ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
 // GetTasks 获取任务列表（分页）
 func (r *rankingDownloadTaskRepo) GetTasks(limit, offset int) ([]*model.RankingDownloadTask, int64, error) {
 	var tasks []*model.RankingDownloadTask
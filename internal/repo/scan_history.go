@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"nsfw-go/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScanHistoryRepository 扫描记录仓库接口
+type ScanHistoryRepository interface {
+	Create(history *model.ScanHistory) error
+	Update(history *model.ScanHistory) error
+	GetByID(id uint) (*model.ScanHistory, error)
+	List(offset, limit int, root string) ([]*model.ScanHistory, int64, error)
+	GetLastCompleted(root string) (*model.ScanHistory, error)
+	MarkInterrupted() error
+}
+
+// scanHistoryRepository 扫描记录仓库实现
+type scanHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewScanHistoryRepository 创建扫描记录仓库
+func NewScanHistoryRepository(db *gorm.DB) ScanHistoryRepository {
+	return &scanHistoryRepository{db: db}
+}
+
+// Create 创建扫描记录
+func (r *scanHistoryRepository) Create(history *model.ScanHistory) error {
+	return r.db.Create(history).Error
+}
+
+// Update 更新扫描记录
+func (r *scanHistoryRepository) Update(history *model.ScanHistory) error {
+	return r.db.Save(history).Error
+}
+
+// GetByID 根据ID获取扫描记录（含变更文件列表）
+func (r *scanHistoryRepository) GetByID(id uint) (*model.ScanHistory, error) {
+	var history model.ScanHistory
+	err := r.db.First(&history, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// List 分页获取扫描记录（不含变更文件列表），root 为空时不按根目录筛选
+func (r *scanHistoryRepository) List(offset, limit int, root string) ([]*model.ScanHistory, int64, error) {
+	query := r.db.Model(&model.ScanHistory{})
+	if root != "" {
+		query = query.Where("root = ?", root)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var histories []*model.ScanHistory
+	err := query.Omit("added_paths", "removed_paths", "changed_paths").
+		Order("started_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&histories).Error
+	return histories, total, err
+}
+
+// GetLastCompleted 获取指定范围最近一次完成的扫描，用于估算剩余时间
+func (r *scanHistoryRepository) GetLastCompleted(root string) (*model.ScanHistory, error) {
+	var history model.ScanHistory
+	err := r.db.Omit("added_paths", "removed_paths", "changed_paths").
+		Where("root = ? AND status = ?", root, model.ScanStatusCompleted).
+		Order("started_at DESC").
+		First(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// MarkInterrupted 将服务重启前未结束的扫描标记为失败
+func (r *scanHistoryRepository) MarkInterrupted() error {
+	return r.db.Model(&model.ScanHistory{}).
+		Where("status = ?", model.ScanStatusRunning).
+		Updates(map[string]interface{}{
+			"status":      model.ScanStatusFailed,
+			"error":       "服务重启，扫描中断",
+			"finished_at": time.Now(),
+		}).Error
+}
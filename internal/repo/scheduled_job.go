@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"errors"
+	"nsfw-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ScheduledJobRepository 定时任务状态仓库接口
+type ScheduledJobRepository interface {
+	GetOrCreate(name string) (*model.ScheduledJob, error)
+	Save(job *model.ScheduledJob) error
+}
+
+// scheduledJobRepository 定时任务状态仓库实现
+type scheduledJobRepository struct {
+	db *gorm.DB
+}
+
+// NewScheduledJobRepository 创建定时任务状态仓库
+func NewScheduledJobRepository(db *gorm.DB) ScheduledJobRepository {
+	return &scheduledJobRepository{db: db}
+}
+
+// GetOrCreate 根据名称获取任务状态，不存在时创建
+func (r *scheduledJobRepository) GetOrCreate(name string) (*model.ScheduledJob, error) {
+	var job model.ScheduledJob
+	err := r.db.Where("name = ?", name).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		job = model.ScheduledJob{Name: name}
+		err = r.db.Create(&job).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Save 保存任务状态
+func (r *scheduledJobRepository) Save(job *model.ScheduledJob) error {
+	return r.db.Save(job).Error
+}
@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"errors"
+	"strings"
+
+	"nsfw-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// TorrentBlocklistRepository 种子黑名单仓储接口
+type TorrentBlocklistRepository interface {
+	Add(entry *model.TorrentBlocklist) error
+	Delete(id uint) error
+	List(limit, offset int) ([]*model.TorrentBlocklist, int64, error)
+	// FilterBlocked 返回哈希中已在黑名单中的部分
+	FilterBlocked(hashes []string) (map[string]bool, error)
+}
+
+// torrentBlocklistRepo 种子黑名单仓储实现
+type torrentBlocklistRepo struct {
+	db *gorm.DB
+}
+
+// NewTorrentBlocklistRepository 创建种子黑名单仓储
+func NewTorrentBlocklistRepository(db *gorm.DB) TorrentBlocklistRepository {
+	return &torrentBlocklistRepo{db: db}
+}
+
+// Add 加入黑名单，哈希已存在时更新原因
+func (r *torrentBlocklistRepo) Add(entry *model.TorrentBlocklist) error {
+	entry.InfoHash = strings.ToLower(entry.InfoHash)
+	var existing model.TorrentBlocklist
+	err := r.db.Where("info_hash = ?", entry.InfoHash).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.db.Create(entry).Error
+	}
+	if err != nil {
+		return err
+	}
+	existing.Code = entry.Code
+	existing.Title = entry.Title
+	existing.Reason = entry.Reason
+	*entry = existing
+	return r.db.Save(entry).Error
+}
+
+// Delete 从黑名单中移除（硬删除，便于之后重新加入）
+func (r *torrentBlocklistRepo) Delete(id uint) error {
+	return r.db.Unscoped().Delete(&model.TorrentBlocklist{}, id).Error
+}
+
+// List 分页获取黑名单，最近加入的在前
+func (r *torrentBlocklistRepo) List(limit, offset int) ([]*model.TorrentBlocklist, int64, error) {
+	var entries []*model.TorrentBlocklist
+	var total int64
+	query := r.db.Model(&model.TorrentBlocklist{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, total, err
+}
+
+// FilterBlocked 返回哈希中已在黑名单中的部分
+func (r *torrentBlocklistRepo) FilterBlocked(hashes []string) (map[string]bool, error) {
+	blocked := make(map[string]bool)
+	if len(hashes) == 0 {
+		return blocked, nil
+	}
+	var found []string
+	err := r.db.Model(&model.TorrentBlocklist{}).Where("info_hash IN ?", hashes).Pluck("info_hash", &found).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range found {
+		blocked[hash] = true
+	}
+	return blocked, nil
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
+	"nsfw-go/internal/repo"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// CatalogService 影片元数据服务，将本地NFO导入影片、演员、制作商、系列与标签
+type CatalogService struct {
+	movieRepo   repo.MovieRepository
+	actressRepo *repo.ActressRepository
+	studioRepo  repo.StudioRepository
+	seriesRepo  repo.SeriesRepository
+	tagRepo     repo.TagRepository
+	logService  *LogService
+}
+
+// NewCatalogService 创建影片元数据服务
+func NewCatalogService(
+	movieRepo repo.MovieRepository,
+	actressRepo *repo.ActressRepository,
+	studioRepo repo.StudioRepository,
+	seriesRepo repo.SeriesRepository,
+	tagRepo repo.TagRepository,
+	logService *LogService,
+) *CatalogService {
+	return &CatalogService{
+		movieRepo:   movieRepo,
+		actressRepo: actressRepo,
+		studioRepo:  studioRepo,
+		seriesRepo:  seriesRepo,
+		tagRepo:     tagRepo,
+		logService:  logService,
+	}
+}
+
+// ImportLocalMovie 解析本地影片的NFO并写入影片元数据，返回对应的影片记录
+// 没有NFO或无法确定番号时返回 nil
+func (s *CatalogService) ImportLocalMovie(local *model.LocalMovie) (*model.Movie, error) {
+	if local.NFOPath == "" {
+		return nil, nil
+	}
+	nfo, err := parseNFO(local.NFOPath)
+	if err != nil {
+		return nil, fmt.Errorf("解析NFO失败: %v", err)
+	}
+
+	code := moviecode.Normalize(nfo.MovieCode())
+	if code == "" {
+		code = strings.ToUpper(local.Code)
+	}
+	if code == "" {
+		return nil, nil
+	}
+
+	movie, err := s.movieRepo.GetByCode(code)
+	isNew := false
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		movie = &model.Movie{Code: code}
+		isNew = true
+	} else if err != nil {
+		return nil, err
+	}
+	// 关联关系单独维护，避免保存时级联写入预加载的数据
+	movie.Studio, movie.Series, movie.Actresses, movie.Tags = nil, nil, nil, nil
+
+	s.applyNFO(movie, nfo, local)
+
+	// 制作商与系列
+	movie.StudioID, movie.SeriesID = nil, nil
+	if name := nfo.StudioName(); name != "" {
+		studio, err := s.upsertStudio(name)
+		if err != nil {
+			return nil, err
+		}
+		movie.StudioID = &studio.ID
+	}
+	if name := nfo.SetName(); name != "" {
+		var studioID uint
+		if movie.StudioID != nil {
+			studioID = *movie.StudioID
+		}
+		series, err := s.upsertSeries(name, studioID)
+		if err != nil {
+			return nil, err
+		}
+		movie.SeriesID = &series.ID
+	}
+
+	if isNew {
+		err = s.movieRepo.Create(movie)
+	} else {
+		err = s.movieRepo.Update(movie)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("保存影片失败: %v", err)
+	}
+
+	// 演员与标签
+	var actresses []*model.Actress
+	seen := make(map[string]bool)
+	for _, actor := range nfo.Actors {
+		if actor.Name == "" || seen[actor.Name] {
+			continue
+		}
+		seen[actor.Name] = true
+		actress, err := s.upsertActress(actor)
+		if err != nil {
+			return nil, err
+		}
+		actresses = append(actresses, actress)
+	}
+
+	var tags []*model.Tag
+	seen = make(map[string]bool)
+	addTags := func(names []string, category string) error {
+		for _, name := range names {
+			name = truncateRunes(name, 50)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			tag, err := s.upsertTag(name, category)
+			if err != nil {
+				return err
+			}
+			tags = append(tags, tag)
+		}
+		return nil
+	}
+	if err := addTags(nfo.Genres, model.TagCategoryGenre); err != nil {
+		return nil, err
+	}
+	if err := addTags(nfo.Tags, model.TagCategoryOther); err != nil {
+		return nil, err
+	}
+
+	if err := s.movieRepo.ReplaceRelations(movie, actresses, tags); err != nil {
+		return nil, fmt.Errorf("保存演员与标签失败: %v", err)
+	}
+	return movie, nil
+}
+
+// applyNFO 将NFO与本地文件信息写入影片字段，NFO中缺失的字段保留原值
+func (s *CatalogService) applyNFO(movie *model.Movie, nfo *NFOMovie, local *model.LocalMovie) {
+	if nfo.Title != "" {
+		movie.Title = nfo.Title
+	}
+	if movie.Title == "" {
+		movie.Title = local.Title
+	}
+	if nfo.OriginalTitle != "" {
+		movie.OriginalTitle = nfo.OriginalTitle
+	}
+	if description := nfo.Description(); description != "" {
+		movie.Description = description
+	}
+	if releaseDate := nfo.ReleaseTime(); releaseDate != nil {
+		movie.ReleaseDate = releaseDate
+	}
+	if rating := nfo.RatingValue(); rating > 0 {
+		movie.Rating = float32(math.Round(float64(rating)*10) / 10)
+	}
+	if runtime := nfo.RuntimeMinutes(); runtime > 0 {
+		movie.Duration = runtime
+	} else if movie.Duration == 0 && local.Duration > 0 {
+		movie.Duration = int(math.Round(float64(local.Duration) / 60))
+	}
+	if poster := nfo.PosterURL(); poster != "" {
+		movie.CoverURL = poster
+	}
+	if fanart := nfo.FanartURL(); fanart != "" {
+		movie.FanartURL = fanart
+	} else if movie.FanartURL == "" {
+		movie.FanartURL = local.FanartURL
+	}
+	if urls := nfo.ExtraFanartURLs(); len(urls) > 0 {
+		movie.SampleImages = urls
+	}
+	if isRemoteURL(nfo.Trailer) {
+		movie.TrailerURL = nfo.Trailer
+	}
+	if ids := nfo.UniqueIDMap(); len(ids) > 0 {
+		movie.UniqueIDs = ids
+	}
+
+	// 本地文件信息
+	movie.LocalPath = local.Path
+	movie.FileSize = local.Size
+	movie.FileFormat = local.Format
+	if local.Quality != "" {
+		movie.Quality = local.Quality
+	}
+	movie.HasSubtitle = local.HasSubtitle
+	movie.SubtitleLanguages = splitSubtitleLanguages(local.SubtitleLanguages)
+	movie.IsDownloaded = true
+	movie.DownloadStatus = model.DownloadStatusCompleted
+	movie.DownloadProgress = 100
+}
+
+// upsertStudio 按名称查找或创建制作商
+func (s *CatalogService) upsertStudio(name string) (*model.Studio, error) {
+	name = truncateRunes(name, 100)
+	studio, err := s.studioRepo.GetByName(name)
+	if err == nil {
+		return studio, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	studio = &model.Studio{Name: name}
+	if err := s.studioRepo.Create(studio); err != nil {
+		return nil, fmt.Errorf("创建制作商失败: %v", err)
+	}
+	return studio, nil
+}
+
+// upsertSeries 按名称查找或创建系列，已有系列缺少制作商时补全
+func (s *CatalogService) upsertSeries(name string, studioID uint) (*model.Series, error) {
+	name = truncateRunes(name, 100)
+	series, err := s.seriesRepo.GetByName(name)
+	if err == nil {
+		if series.StudioID == 0 && studioID != 0 {
+			series.StudioID = studioID
+			if err := s.seriesRepo.Update(series); err != nil {
+				return nil, err
+			}
+		}
+		return series, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	series = &model.Series{Name: name, StudioID: studioID}
+	if err := s.seriesRepo.Create(series); err != nil {
+		return nil, fmt.Errorf("创建系列失败: %v", err)
+	}
+	return series, nil
+}
+
+// upsertActress 按姓名查找或创建演员，缺少头像时使用NFO中的图片
+func (s *CatalogService) upsertActress(actor NFOActor) (*model.Actress, error) {
+	name := truncateRunes(actor.Name, 100)
+	actress, err := s.actressRepo.GetByName(name)
+	if err == nil {
+		if actress.AvatarURL == "" && isRemoteURL(actor.Thumb) {
+			actress.AvatarURL = actor.Thumb
+			actress.Movies = nil
+			if err := s.actressRepo.Update(actress); err != nil {
+				return nil, err
+			}
+		}
+		return actress, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	actress = &model.Actress{Name: name}
+	if isRemoteURL(actor.Thumb) {
+		actress.AvatarURL = actor.Thumb
+	}
+	if err := s.actressRepo.Create(actress); err != nil {
+		return nil, fmt.Errorf("创建演员失败: %v", err)
+	}
+	return actress, nil
+}
+
+// upsertTag 按名称查找或创建标签
+func (s *CatalogService) upsertTag(name, category string) (*model.Tag, error) {
+	tag, err := s.tagRepo.GetByName(name)
+	if err == nil {
+		return tag, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	tag = &model.Tag{Name: name, Category: category}
+	if err := s.tagRepo.Create(tag); err != nil {
+		return nil, fmt.Errorf("创建标签失败: %v", err)
+	}
+	return tag, nil
+}
+
+// truncateRunes 按字符数截断，避免超出字段长度
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// splitSubtitleLanguages 拆分逗号分隔的字幕语言
+func splitSubtitleLanguages(languages string) model.StringArray {
+	if languages == "" {
+		return model.StringArray{}
+	}
+	return strings.Split(languages, ",")
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsfw-go/internal/model"
+)
+
+// DefaultMaxConcurrentDownloads 默认同时执行的下载任务数（搜索种子并添加到下载器）
+const DefaultMaxConcurrentDownloads = 3
+
+// queuePollInterval 定期检查队列的间隔，用于处理直接写入数据库的等待任务
+const queuePollInterval = time.Minute
+
+// interruptedDownloadStatuses 服务重启时被中断、需要重新排队的任务状态
+var interruptedDownloadStatuses = []string{
+	model.RankingDownloadStatusSearching,
+	model.RankingDownloadStatusFound,
+}
+
+// SetMaxConcurrent 设置同时执行的下载任务数，小于1时使用默认值
+func (s *RankingDownloadService) SetMaxConcurrent(n int) {
+	if n <= 0 {
+		n = DefaultMaxConcurrentDownloads
+	}
+	s.queueMu.Lock()
+	s.maxConcurrent = n
+	s.queueMu.Unlock()
+	s.signalQueue()
+}
+
+// StartQueue 启动下载队列：先将上次运行时中断的任务重新排队，再按优先级与创建时间依次执行等待中的任务
+func (s *RankingDownloadService) StartQueue(ctx context.Context) {
+	count, err := s.taskRepo.RequeueTasks(interruptedDownloadStatuses)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogError("torrent", "download-queue", fmt.Sprintf("恢复中断的下载任务失败: %v", err))
+		}
+	} else if count > 0 && s.logService != nil {
+		s.logService.LogInfo("torrent", "download-queue", fmt.Sprintf("已将 %d 个中断的下载任务重新排队", count))
+	}
+
+	go s.runQueue(ctx)
+}
+
+// runQueue 收到入队通知或定期检查时调度等待中的任务
+func (s *RankingDownloadService) runQueue(ctx context.Context) {
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.dispatchQueue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.queueSignal:
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchQueue 按空闲名额取出队首的任务并执行
+func (s *RankingDownloadService) dispatchQueue() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	free := s.maxConcurrent - len(s.running)
+	if free <= 0 {
+		return
+	}
+	// 执行中的任务刚开始时可能还是等待状态，多取一些以免名额被占用
+	tasks, err := s.taskRepo.GetQueuedTasks(free + len(s.running))
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogError("torrent", "download-queue", fmt.Sprintf("获取等待中的下载任务失败: %v", err))
+		}
+		return
+	}
+
+	for _, task := range tasks {
+		if free == 0 {
+			break
+		}
+		if s.running[task.ID] {
+			continue
+		}
+		s.running[task.ID] = true
+		free--
+		go func(task *model.RankingDownloadTask) {
+			defer func() {
+				s.queueMu.Lock()
+				delete(s.running, task.ID)
+				s.queueMu.Unlock()
+				s.signalQueue()
+			}()
+			// 任务在排队期间可能已被取消或删除
+			if current, err := s.taskRepo.GetByID(task.ID); err != nil || current.Status != model.RankingDownloadStatusPending {
+				return
+			}
+			s.executeDownload(task)
+		}(task)
+	}
+}
+
+// signalQueue 通知队列有新的任务或空闲名额
+func (s *RankingDownloadService) signalQueue() {
+	select {
+	case s.queueSignal <- struct{}{}:
+	default:
+	}
+}
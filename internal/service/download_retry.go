@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nsfw-go/internal/downloader"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/repo"
+)
+
+// maxDownloadCandidates 每个任务保存的候选种子数
+const maxDownloadCandidates = 10
+
+// maxRetryDelay 指数退避的最长等待时间
+const maxRetryDelay = 24 * time.Hour
+
+// DownloadRetryPolicy 下载失败的自动重试策略
+type DownloadRetryPolicy struct {
+	MaxRetries   int           // 自动重试次数，0 表示不重试
+	Delay        time.Duration // 第一次重试的等待时间，之后每次翻倍
+	StallTimeout time.Duration // 下载进度超过该时间没有增长时视为停滞，0 表示不检测
+}
+
+// DefaultDownloadRetryPolicy 默认重试策略：重试3次，等待5分钟起，下载停滞2小时后换种
+func DefaultDownloadRetryPolicy() DownloadRetryPolicy {
+	return DownloadRetryPolicy{
+		MaxRetries:   3,
+		Delay:        5 * time.Minute,
+		StallTimeout: 2 * time.Hour,
+	}
+}
+
+// Backoff 第 n 次重试前的等待时间（n 从1开始）
+func (p DownloadRetryPolicy) Backoff(n int) time.Duration {
+	delay := p.Delay
+	for i := 1; i < n && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// SetRetryPolicy 设置下载失败的自动重试策略
+func (s *RankingDownloadService) SetRetryPolicy(policy DownloadRetryPolicy) {
+	s.retryPolicy = policy
+}
+
+// SetBlocklistRepo 设置种子黑名单仓储，未设置时不记录黑名单
+func (s *RankingDownloadService) SetBlocklistRepo(blocklistRepo repo.TorrentBlocklistRepository) {
+	s.blocklistRepo = blocklistRepo
+}
+
+// nextCandidate 选择任务的下一个候选种子：优先使用上次搜索保存的候选，全部放弃后重新搜索
+// 黑名单中的种子会被跳过；画质升级任务只选择比本地版本更好的种子
+func (s *RankingDownloadService) nextCandidate(task *model.RankingDownloadTask) (*model.DownloadCandidate, error) {
+	task.Candidates = s.filterBlocked(task.Candidates)
+	if len(task.Candidates) == 0 {
+		var torrents []JackettResult
+		var err error
+		if task.UpgradeOf != nil {
+			torrents, err = s.searchUpgradeTorrents(task)
+		} else {
+			torrents, err = s.torrentService.SearchTorrentsForCode(task.Code)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("搜索种子失败: %v", err)
+		}
+		task.Candidates = s.filterBlocked(downloadCandidates(torrents))
+		if len(task.Candidates) > maxDownloadCandidates {
+			task.Candidates = task.Candidates[:maxDownloadCandidates]
+		}
+	}
+	if len(task.Candidates) == 0 {
+		return nil, fmt.Errorf("未找到符合选择规则的种子")
+	}
+	return &task.Candidates[0], nil
+}
+
+// downloadCandidates 将搜索结果转换为候选种子，跳过没有下载链接的结果
+func downloadCandidates(torrents []JackettResult) []model.DownloadCandidate {
+	candidates := make([]model.DownloadCandidate, 0, len(torrents))
+	for _, torrent := range torrents {
+		link := torrent.Link
+		if link == "" {
+			link = torrent.MagnetURI
+		}
+		if link == "" {
+			continue
+		}
+		candidates = append(candidates, model.DownloadCandidate{
+			Title:    torrent.Title,
+			Link:     link,
+			InfoHash: strings.ToLower(torrent.InfoHash),
+			Size:     torrent.Size,
+			Seeders:  torrent.Seeders,
+			Tracker:  torrent.Tracker,
+		})
+	}
+	return candidates
+}
+
+// filterBlocked 移除黑名单中的候选种子
+func (s *RankingDownloadService) filterBlocked(candidates []model.DownloadCandidate) []model.DownloadCandidate {
+	if s.blocklistRepo == nil || len(candidates) == 0 {
+		return candidates
+	}
+	var hashes []string
+	for _, c := range candidates {
+		if c.InfoHash != "" {
+			hashes = append(hashes, c.InfoHash)
+		}
+	}
+	blocked, err := s.blocklistRepo.FilterBlocked(hashes)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogWarn("torrent", "download-service", fmt.Sprintf("查询种子黑名单失败: %v", err))
+		}
+		return candidates
+	}
+	filtered := candidates[:0:0]
+	for _, c := range candidates {
+		if !blocked[c.InfoHash] {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// retryOrFail 记录失败的尝试并放弃当前种子，未超过重试次数时按指数退避重新排队，否则标记任务失败
+// blockTorrent 为 true 表示种子本身有问题（下载器报错、停滞、找不到），本服务添加的种子会连同文件从下载器中删除并加入黑名单
+func (s *RankingDownloadService) retryOrFail(task *model.RankingDownloadTask, errorMsg string, blockTorrent bool) {
+	// 只按番号匹配到的种子可能是用户手动添加的，不动下载器，只清除任务记录的哈希
+	// 需要在结束本次尝试之前判断，结束后无法再区分下载器解析出的哈希
+	hash := strings.ToLower(task.TorrentHash)
+	owned := hash != "" && hash == addedTorrentHash(task)
+
+	now := time.Now()
+	s.finishAttempt(task, errorMsg, now)
+
+	if blockTorrent && owned {
+		if err := s.torrentService.DownloadClient().Remove(context.Background(), hash, true); err != nil && s.logService != nil {
+			s.logService.LogWarn("torrent", "download-service", fmt.Sprintf("从下载器删除种子失败: %s - %v", task.Code, err))
+		}
+		if s.blocklistRepo != nil {
+			entry := &model.TorrentBlocklist{InfoHash: hash, Code: task.Code, Title: s.currentCandidateTitle(task), Reason: errorMsg}
+			if err := s.blocklistRepo.Add(entry); err != nil && s.logService != nil {
+				s.logService.LogWarn("torrent", "download-service", fmt.Sprintf("种子加入黑名单失败: %s - %v", hash, err))
+			}
+		}
+	}
+	s.dropCurrentCandidate(task)
+
+	// 清除当前种子的下载状态，重试时重新选择种子
+	task.TorrentURL = ""
+	task.TorrentHash = ""
+	task.Progress = 0
+	task.DownloadedSize = 0
+	task.DownloadSpeed = 0
+	task.ETA = 0
+	task.ClientState = ""
+	task.ContentPath = ""
+	task.ProgressChangedAt = nil
+
+	if task.RetryCount >= s.retryPolicy.MaxRetries {
+		s.markTaskFailed(task, errorMsg)
+		return
+	}
+
+	task.RetryCount++
+	delay := s.retryPolicy.Backoff(task.RetryCount)
+	nextRetryAt := now.Add(delay)
+	task.Status = model.RankingDownloadStatusPending
+	task.ErrorMsg = errorMsg
+	task.StartedAt = nil
+	task.NextRetryAt = &nextRetryAt
+	if !s.saveActiveTask(task) {
+		return
+	}
+
+	if s.logService != nil {
+		s.logService.LogWarn("torrent", "download-service", fmt.Sprintf("任务失败，%s 后第 %d/%d 次重试: %s - %s",
+			delay, task.RetryCount, s.retryPolicy.MaxRetries, task.Code, errorMsg))
+	}
+}
+
+// addedTorrentHash 本服务添加到下载器的种子哈希：当前尝试记录了由本服务添加时，
+// 为磁力链接中的哈希、索引器提供的候选种子哈希或 HTTP 种子链接添加后同步时记录的哈希；
+// 其他情况为空，此时无法确认下载器中的种子是本服务添加的
+func addedTorrentHash(task *model.RankingDownloadTask) string {
+	attempt := currentAttempt(task)
+	if attempt == nil || !attempt.Added || task.TorrentURL == "" {
+		return ""
+	}
+	if hash := downloader.MagnetInfoHash(task.TorrentURL); hash != "" {
+		return hash
+	}
+	return attempt.InfoHash
+}
+
+// addedBefore 判断之前的尝试是否已由本服务将同一个种子添加到下载器
+func addedBefore(task *model.RankingDownloadTask, candidate *model.DownloadCandidate) bool {
+	if candidate.InfoHash == "" {
+		return false
+	}
+	for _, attempt := range task.Attempts[:len(task.Attempts)-1] {
+		if attempt.Added && attempt.InfoHash == candidate.InfoHash {
+			return true
+		}
+	}
+	return false
+}
+
+// currentAttempt 进行中的尝试，没有时为 nil
+func currentAttempt(task *model.RankingDownloadTask) *model.DownloadAttempt {
+	if n := len(task.Attempts); n > 0 && task.Attempts[n-1].EndedAt == nil {
+		return &task.Attempts[n-1]
+	}
+	return nil
+}
+
+// abandonedTorrentHashes 之前的尝试已放弃的种子哈希
+func abandonedTorrentHashes(task *model.RankingDownloadTask) map[string]bool {
+	hashes := make(map[string]bool)
+	for _, attempt := range task.Attempts {
+		if attempt.EndedAt != nil && attempt.InfoHash != "" {
+			hashes[attempt.InfoHash] = true
+		}
+	}
+	return hashes
+}
+
+// startAttempt 记录一次新的尝试
+func (s *RankingDownloadService) startAttempt(task *model.RankingDownloadTask, candidate *model.DownloadCandidate) {
+	attempt := model.DownloadAttempt{Number: len(task.Attempts) + 1, StartedAt: time.Now()}
+	if candidate != nil {
+		attempt.Title = candidate.Title
+		attempt.InfoHash = candidate.InfoHash
+		attempt.Tracker = candidate.Tracker
+	}
+	task.Attempts = append(task.Attempts, attempt)
+}
+
+// finishAttempt 记录最近一次尝试失败，没有进行中的尝试时（如搜索失败）新增一条记录
+func (s *RankingDownloadService) finishAttempt(task *model.RankingDownloadTask, errorMsg string, now time.Time) {
+	if n := len(task.Attempts); n == 0 || task.Attempts[n-1].EndedAt != nil {
+		s.startAttempt(task, nil)
+	}
+	attempt := &task.Attempts[len(task.Attempts)-1]
+	if attempt.InfoHash == "" {
+		attempt.InfoHash = strings.ToLower(task.TorrentHash)
+	}
+	attempt.EndedAt = &now
+	attempt.Error = errorMsg
+}
+
+// currentCandidateTitle 当前种子的标题
+func (s *RankingDownloadService) currentCandidateTitle(task *model.RankingDownloadTask) string {
+	if n := len(task.Attempts); n > 0 {
+		return task.Attempts[n-1].Title
+	}
+	return ""
+}
+
+// dropCurrentCandidate 从候选中移除当前使用的种子
+func (s *RankingDownloadService) dropCurrentCandidate(task *model.RankingDownloadTask) {
+	if task.TorrentURL == "" && task.TorrentHash == "" {
+		return
+	}
+	hash := strings.ToLower(task.TorrentHash)
+	remaining := task.Candidates[:0:0]
+	for _, c := range task.Candidates {
+		if c.Link == task.TorrentURL || (hash != "" && c.InfoHash == hash) {
+			continue
+		}
+		remaining = append(remaining, c)
+	}
+	task.Candidates = remaining
+}
+
+// GetBlocklist 分页获取种子黑名单
+func (s *RankingDownloadService) GetBlocklist(limit, offset int) ([]*model.TorrentBlocklist, int64, error) {
+	if s.blocklistRepo == nil {
+		return nil, 0, nil
+	}
+	return s.blocklistRepo.List(limit, offset)
+}
+
+// RemoveFromBlocklist 从种子黑名单中移除
+func (s *RankingDownloadService) RemoveFromBlocklist(id uint) error {
+	if s.blocklistRepo == nil {
+		return fmt.Errorf("种子黑名单未启用")
+	}
+	return s.blocklistRepo.Delete(id)
+}
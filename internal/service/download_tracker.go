@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nsfw-go/internal/downloader"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
+)
+
+// missingTorrentGrace 添加后在下载器中一直找不到种子的任务，超过该时间后标记为失败
+// HTTP 种子链接需要下载器先获取种子文件，刚添加时可能还不在列表中
+const missingTorrentGrace = 30 * time.Minute
+
+// trackedDownloadStatuses 需要同步下载器状态的任务状态
+var trackedDownloadStatuses = []string{
+	model.RankingDownloadStatusStarted,
+	model.RankingDownloadStatusProgress,
+}
+
+// SyncDownloadProgress 从下载器同步已开始下载的任务的进度、速度、已下载大小、剩余时间与状态
+// 任务按种子哈希匹配；没有哈希的任务先按磁力链接中的哈希、再按种子名称中的番号匹配，匹配后保存哈希
+// 下载完成的任务标记为完成并发送通知，下载器报错、下载停滞或长时间找不到种子的任务放弃当前种子并按重试策略换种重试
+func (s *RankingDownloadService) SyncDownloadProgress(ctx context.Context) (string, error) {
+	tasks, err := s.taskRepo.GetTasksByStatuses(trackedDownloadStatuses)
+	if err != nil {
+		return "", fmt.Errorf("获取下载任务失败: %v", err)
+	}
+	if len(tasks) == 0 {
+		return "没有正在下载的任务", nil
+	}
+
+	torrents, err := s.torrentService.ListTorrents(ctx)
+	if err != nil {
+		return "", err
+	}
+	byHash := make(map[string]downloader.Torrent, len(torrents))
+	for _, torrent := range torrents {
+		byHash[torrent.Hash] = torrent
+	}
+
+	now := time.Now()
+	var synced, completed, failed, missing int
+	for _, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		torrent, ok := s.matchTorrent(task, byHash, torrents)
+		if !ok {
+			missing++
+			if task.StartedAt != nil && now.Sub(*task.StartedAt) > missingTorrentGrace {
+				s.retryOrFail(task, "下载器中找不到对应的种子", true)
+				failed++
+			}
+			continue
+		}
+
+		if task.ProgressChangedAt == nil || torrent.Progress > task.Progress {
+			task.ProgressChangedAt = &now
+		}
+		// HTTP 种子链接添加时不知道哈希，记录下载器解析出的哈希，失败时才能确认是本服务添加的种子
+		if attempt := currentAttempt(task); attempt != nil && attempt.InfoHash == "" && task.TorrentURL != "" {
+			attempt.InfoHash = torrent.Hash
+		}
+		task.TorrentHash = torrent.Hash
+		task.Progress = torrent.Progress
+		task.DownloadedSize = torrent.Downloaded
+		if torrent.Size > 0 {
+			task.FileSize = torrent.Size
+		}
+		task.DownloadSpeed = torrent.DownloadSpeed
+		task.ETA = torrent.ETA
+		task.ClientState = torrent.RawState
+		task.ContentPath = torrent.ContentPath
+		task.LastSyncedAt = &now
+
+		switch {
+		case torrent.Failed():
+			reason := fmt.Sprintf("下载器报告错误，种子状态: %s", torrent.RawState)
+			if torrent.ErrorMessage != "" {
+				reason += "，" + torrent.ErrorMessage
+			}
+			s.retryOrFail(task, reason, true)
+			failed++
+		case s.stalled(task, torrent, now):
+			s.retryOrFail(task, fmt.Sprintf("下载停滞，%s 内进度没有增长", s.retryPolicy.StallTimeout), true)
+			failed++
+		case torrent.Done():
+			task.Progress = 1
+			task.DownloadSpeed = 0
+			task.ETA = 0
+			s.completeTask(task)
+			completed++
+		default:
+			task.Status = model.RankingDownloadStatusProgress
+			s.saveActiveTask(task)
+		}
+		synced++
+	}
+
+	return fmt.Sprintf("同步 %d 个任务，完成 %d 个，失败 %d 个，未找到种子 %d 个", synced, completed, failed, missing), nil
+}
+
+// stalled 种子处于下载状态，但进度超过停滞时间没有增长
+func (s *RankingDownloadService) stalled(task *model.RankingDownloadTask, torrent downloader.Torrent, now time.Time) bool {
+	if s.retryPolicy.StallTimeout <= 0 || torrent.State != downloader.StateDownloading || task.ProgressChangedAt == nil {
+		return false
+	}
+	return now.Sub(*task.ProgressChangedAt) > s.retryPolicy.StallTimeout
+}
+
+// matchTorrent 查找任务对应的种子
+func (s *RankingDownloadService) matchTorrent(task *model.RankingDownloadTask, byHash map[string]downloader.Torrent, torrents []downloader.Torrent) (downloader.Torrent, bool) {
+	for _, hash := range []string{strings.ToLower(task.TorrentHash), downloader.MagnetInfoHash(task.TorrentURL)} {
+		if hash == "" {
+			continue
+		}
+		if torrent, ok := byHash[hash]; ok {
+			return torrent, true
+		}
+	}
+
+	// 没有哈希或哈希不一致（如 HTTP 种子链接）时按番号匹配，只有唯一匹配时才采用
+	// 之前尝试已放弃的种子可能还留在下载器中，跳过这些种子以免匹配不唯一
+	abandoned := abandonedTorrentHashes(task)
+	var found []downloader.Torrent
+	for _, torrent := range torrents {
+		if abandoned[torrent.Hash] {
+			continue
+		}
+		if moviecode.Equal(torrent.Name, task.Code) {
+			found = append(found, torrent)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	return downloader.Torrent{}, false
+}
+
+// completeTask 标记任务下载完成；启用导入时在后台导入媒体库后发送完成通知，否则直接发送
+func (s *RankingDownloadService) completeTask(task *model.RankingDownloadTask) error {
+	task.Status = model.RankingDownloadStatusCompleted
+	task.CompletedAt = &[]time.Time{time.Now()}[0]
+	importing := s.importService != nil && s.importService.Enabled()
+	if importing {
+		task.ImportStatus = model.ImportStatusImporting
+	}
+	// 同步期间任务可能已被取消，只在任务仍活跃时保存，已取消的任务不再导入或通知
+	updated, err := s.taskRepo.UpdateIfStatus(task, activeDownloadStatuses)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogError("torrent", "download-tracker", fmt.Sprintf("保存下载完成状态失败: %s - %v", task.Code, err))
+		}
+		return err
+	}
+	if !updated {
+		if s.logService != nil {
+			s.logService.LogInfo("torrent", "download-tracker", fmt.Sprintf("任务已取消，不再处理下载完成: %s", task.Code))
+		}
+		return nil
+	}
+
+	if s.logService != nil {
+		s.logService.LogInfo("torrent", "download-tracker", fmt.Sprintf("下载完成: %s", task.Code))
+	}
+
+	if importing {
+		go s.importTask(context.Background(), task)
+		return nil
+	}
+	s.sendCompleteNotification(task, task.ContentPath)
+	return nil
+}
+
+// sendCompleteNotification 发送下载完成通知，path 为媒体库中的路径或下载目录中的路径
+func (s *RankingDownloadService) sendCompleteNotification(task *model.RankingDownloadTask, path string) {
+	// 发送增强的完成通知
+	if s.telegramService != nil {
+		err := s.telegramService.SendDownloadCompleteNotification(
+			task.Code,
+			task.Title,
+			path,
+			task.FileSize,
+		)
+		if err != nil && s.logService != nil {
+			s.logService.LogWarn("torrent", "download-service", fmt.Sprintf("Telegram完成通知发送失败: %v", err))
+		}
+	}
+}
+
+// SetImportService 设置下载导入服务，设置后下载完成的任务会导入媒体库
+func (s *RankingDownloadService) SetImportService(importService *ImportService) {
+	s.importService = importService
+}
+
+// ResumeImports 重新导入上次运行时被中断（仍为导入中）的任务，未启用导入时标记为导入失败以便手动重试
+// 需要在设置导入服务之后调用，任务在后台依次导入
+func (s *RankingDownloadService) ResumeImports(ctx context.Context) {
+	tasks, err := s.taskRepo.GetTasksByImportStatus(model.ImportStatusImporting)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogError("torrent", "download-import", fmt.Sprintf("获取中断的导入任务失败: %v", err))
+		}
+		return
+	}
+	if len(tasks) == 0 {
+		return
+	}
+
+	if s.importService == nil || !s.importService.Enabled() {
+		for _, task := range tasks {
+			task.ImportStatus = model.ImportStatusFailed
+			task.ImportError = "导入被服务重启中断"
+			if err := s.taskRepo.Update(task); err != nil && s.logService != nil {
+				s.logService.LogError("torrent", "download-import", fmt.Sprintf("保存导入状态失败: %s - %v", task.Code, err))
+			}
+		}
+		return
+	}
+
+	if s.logService != nil {
+		s.logService.LogInfo("torrent", "download-import", fmt.Sprintf("重新导入 %d 个被中断的任务", len(tasks)))
+	}
+	go func() {
+		for _, task := range tasks {
+			if ctx.Err() != nil {
+				return
+			}
+			s.importTask(ctx, task)
+		}
+	}()
+}
+
+// ImportTask 手动将已完成的任务导入媒体库，用于自动导入失败后重试
+func (s *RankingDownloadService) ImportTask(ctx context.Context, id uint) (*ImportResult, error) {
+	if s.importService == nil {
+		return nil, ErrImportDisabled
+	}
+	task, err := s.taskRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status != model.RankingDownloadStatusCompleted {
+		return nil, fmt.Errorf("只能导入已完成的任务，当前状态: %s", task.Status)
+	}
+	if task.ImportStatus == model.ImportStatusImporting {
+		return nil, fmt.Errorf("任务 %s 正在导入", task.Code)
+	}
+	task.ImportStatus = model.ImportStatusImporting
+	if err := s.taskRepo.Update(task); err != nil {
+		return nil, err
+	}
+	return s.importTask(ctx, task)
+}
+
+// importTask 导入下载完成的任务并记录结果，画质升级任务同时替换旧文件，导入成功后发送完成通知
+func (s *RankingDownloadService) importTask(ctx context.Context, task *model.RankingDownloadTask) (*ImportResult, error) {
+	result, err := s.importService.Import(ctx, task.Code, task.ContentPath, s.upgradeImportOptions(task))
+	if err != nil {
+		task.ImportStatus = model.ImportStatusFailed
+		task.ImportError = err.Error()
+		if updateErr := s.taskRepo.Update(task); updateErr != nil && s.logService != nil {
+			s.logService.LogError("torrent", "download-import", fmt.Sprintf("保存导入状态失败: %s - %v", task.Code, updateErr))
+		}
+		if s.logService != nil {
+			s.logService.LogError("torrent", "download-import", fmt.Sprintf("导入媒体库失败: %s - %v", task.Code, err))
+		}
+		// 导入失败时仍然通知下载完成，路径为下载目录
+		s.sendCompleteNotification(task, task.ContentPath)
+		return nil, err
+	}
+
+	// 移动后下载器中的种子已没有文件，删除种子（文件已在媒体库中）
+	// 只按番号匹配到的种子可能是用户手动添加的，只删除本服务添加的种子
+	if hash := strings.ToLower(task.TorrentHash); result.Mode == ImportModeMove && hash != "" && hash == addedTorrentHash(task) {
+		if err := s.torrentService.DownloadClient().Remove(ctx, hash, false); err != nil && s.logService != nil {
+			s.logService.LogWarn("torrent", "download-import", fmt.Sprintf("从下载器删除种子失败: %s - %v", task.Code, err))
+		}
+	}
+
+	now := time.Now()
+	task.ImportStatus = model.ImportStatusImported
+	task.ImportedPath = result.Target
+	task.ImportError = ""
+	task.ImportedAt = &now
+	if err := s.taskRepo.Update(task); err != nil && s.logService != nil {
+		s.logService.LogError("torrent", "download-import", fmt.Sprintf("保存导入状态失败: %s - %v", task.Code, err))
+	}
+	if task.UpgradeOf != nil && s.logService != nil {
+		s.logService.LogInfo("torrent", "download-upgrade", fmt.Sprintf("已升级 %s（%s），旧文件: %s",
+			task.Code, task.UpgradeReason, strings.Join(result.Retired, ", ")))
+	}
+	s.sendCompleteNotification(task, result.Target)
+	return result, nil
+}
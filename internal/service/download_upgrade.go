@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
+	"nsfw-go/internal/repo"
+)
+
+// 识别中文字幕与无码版本的种子偏好标签名称，与 DefaultTorrentTags 一致
+const (
+	chineseSubtitleTag = "chinese-subtitle"
+	uncensoredLeakTag  = "uncensored-leak"
+)
+
+// 种子标题中的分辨率标识
+var torrentResolutionPattern = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(2160p|4k|uhd|1080p|fhd|720p|480p)(?:$|[^a-z0-9])`)
+
+// UpgradePolicy 已有影片的画质升级策略：本地版本低于要求时搜索更好的版本，下载导入后替换或归档旧文件
+type UpgradePolicy struct {
+	Enabled          bool
+	MinResolution    int           // 分辨率下限（高度，如 1080），低于该值时寻找达到下限的版本，0 表示不按分辨率升级
+	PreferSubtitle   bool          // 没有中文字幕时寻找中文字幕版本
+	PreferUncensored bool          // 不是无码版本时寻找无码流出版本
+	Action           string        // 旧文件的处理方式：replace 删除，archive 移动到归档目录
+	ArchiveDir       string        // 归档目录，不能位于媒体库中
+	BatchSize        int           // 每次检查的影片数
+	RecheckInterval  time.Duration // 同一部影片再次检查的间隔
+}
+
+// DefaultUpgradePolicy 默认升级策略：不启用；低于1080p或没有中文字幕时升级，旧文件归档；每次检查20部，7天后再次检查
+func DefaultUpgradePolicy() UpgradePolicy {
+	return UpgradePolicy{
+		MinResolution:   1080,
+		PreferSubtitle:  true,
+		Action:          RetireActionArchive,
+		BatchSize:       20,
+		RecheckInterval: 7 * 24 * time.Hour,
+	}
+}
+
+// MediaQuality 影片画质
+type MediaQuality struct {
+	Resolution int  `json:"resolution"` // 分辨率高度，未知时为 0
+	Subtitle   bool `json:"subtitle"`   // 中文字幕
+	Uncensored bool `json:"uncensored"` // 无码
+}
+
+// String 画质描述，如 1080p 中字 无码
+func (q MediaQuality) String() string {
+	parts := []string{resolutionLabel(q.Resolution)}
+	if q.Subtitle {
+		parts = append(parts, "中字")
+	}
+	if q.Uncensored {
+		parts = append(parts, "无码")
+	}
+	return strings.Join(parts, " ")
+}
+
+// resolutionLabel 分辨率高度的显示名称
+func resolutionLabel(height int) string {
+	switch {
+	case height == 0:
+		return "未知分辨率"
+	case height >= 2160:
+		return "4K"
+	}
+	return fmt.Sprintf("%dp", height)
+}
+
+// LocalMovieQuality 本地影片的画质，分辨率优先使用探测结果，其次使用文件名中的画质标识
+func LocalMovieQuality(movie *model.LocalMovie) MediaQuality {
+	resolution := movie.Height
+	if resolution == 0 {
+		resolution = qualityHeight(movie.Quality)
+	}
+	return MediaQuality{
+		Resolution: resolution,
+		Subtitle:   movie.HasChineseSubtitle || movie.HardSubtitle,
+		Uncensored: movie.Uncensored,
+	}
+}
+
+// Wants 本地画质是否低于策略要求；分辨率未知时不按分辨率判断
+func (p UpgradePolicy) Wants(owned MediaQuality) bool {
+	return (p.MinResolution > 0 && owned.Resolution > 0 && owned.Resolution < p.MinResolution) ||
+		(p.PreferSubtitle && !owned.Subtitle) ||
+		(p.PreferUncensored && !owned.Uncensored)
+}
+
+// Improves 候选版本是否值得替换本地版本：不能降低分辨率或丢失要求的字幕、无码，
+// 且至少补上一项本地缺少的要求；本地分辨率已达标时不接受分辨率未知的候选，返回升级原因
+func (p UpgradePolicy) Improves(owned, candidate MediaQuality) (string, bool) {
+	if candidate.Resolution > 0 && candidate.Resolution < owned.Resolution {
+		return "", false
+	}
+	if candidate.Resolution == 0 && owned.Resolution > 0 && owned.Resolution >= p.MinResolution {
+		return "", false
+	}
+	if (p.PreferSubtitle && owned.Subtitle && !candidate.Subtitle) ||
+		(p.PreferUncensored && owned.Uncensored && !candidate.Uncensored) {
+		return "", false
+	}
+
+	var gains []string
+	if p.MinResolution > 0 && owned.Resolution > 0 && owned.Resolution < p.MinResolution && candidate.Resolution >= p.MinResolution {
+		gains = append(gains, resolutionLabel(owned.Resolution)+" → "+resolutionLabel(candidate.Resolution))
+	}
+	if p.PreferSubtitle && !owned.Subtitle && candidate.Subtitle {
+		gains = append(gains, "中文字幕")
+	}
+	if p.PreferUncensored && !owned.Uncensored && candidate.Uncensored {
+		gains = append(gains, "无码")
+	}
+	if len(gains) == 0 {
+		return "", false
+	}
+	return strings.Join(gains, "、"), true
+}
+
+// SetLibraryRoots 设置媒体库根目录，用于校验归档目录，需要在 SetUpgradePolicy 之前调用
+func (s *RankingDownloadService) SetLibraryRoots(roots []model.MediaRoot) {
+	s.libraryRoots = roots
+}
+
+// SetUpgradePolicy 设置画质升级策略，旧文件处理方式无效、启用归档时归档目录未配置或位于媒体库中返回错误
+// 归档目录在媒体库中时旧文件会被重新扫描入库，再次成为升级对象
+func (s *RankingDownloadService) SetUpgradePolicy(policy UpgradePolicy) error {
+	defaults := DefaultUpgradePolicy()
+	switch policy.Action {
+	case "":
+		policy.Action = defaults.Action
+	case RetireActionReplace, RetireActionArchive:
+	default:
+		return fmt.Errorf("不支持的旧文件处理方式: %s", policy.Action)
+	}
+	if policy.Enabled && policy.Action == RetireActionArchive && !filepath.IsAbs(policy.ArchiveDir) {
+		return fmt.Errorf("旧文件归档需要配置绝对路径的归档目录")
+	}
+	if policy.Enabled && policy.Action == RetireActionArchive {
+		archiveDir := filepath.Clean(policy.ArchiveDir)
+		for _, root := range s.libraryRoots {
+			if rootPath := filepath.Clean(root.Path); archiveDir == rootPath || isWithin(archiveDir, rootPath) {
+				return fmt.Errorf("归档目录不能位于媒体库 %s 中: %s", root.Name, policy.ArchiveDir)
+			}
+		}
+	}
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = defaults.BatchSize
+	}
+	if policy.RecheckInterval <= 0 {
+		policy.RecheckInterval = defaults.RecheckInterval
+	}
+	s.upgradePolicy = policy
+	return nil
+}
+
+// CheckUpgrades 为画质低于升级策略的本地影片搜索更好的版本并创建升级下载任务，供定时任务调用
+// 每次检查 BatchSize 部影片，从未检查过的优先，检查过的影片在 RecheckInterval 之后才会再次检查
+func (s *RankingDownloadService) CheckUpgrades(ctx context.Context) (string, error) {
+	policy := s.upgradePolicy
+	if !policy.Enabled {
+		return "未启用画质升级", nil
+	}
+	if s.importService == nil || !s.importService.Enabled() {
+		return "", fmt.Errorf("画质升级需要启用下载完成后导入媒体库")
+	}
+
+	filter := repo.UpgradeFilter{
+		MinHeight:      policy.MinResolution,
+		NeedSubtitle:   policy.PreferSubtitle,
+		NeedUncensored: policy.PreferUncensored,
+		CheckedBefore:  time.Now().Add(-policy.RecheckInterval),
+	}
+	movies, err := s.localMovieRepo.ListUpgradeCandidates(filter, policy.BatchSize)
+	if err != nil {
+		return "", fmt.Errorf("获取待升级影片失败: %v", err)
+	}
+
+	var checked []uint
+	var queued, failed int
+	for _, movie := range movies {
+		if ctx.Err() != nil {
+			break
+		}
+		checked = append(checked, movie.ID)
+		task, err := s.checkUpgrade(movie)
+		if err != nil {
+			failed++
+			if s.logService != nil {
+				s.logService.LogWarn("torrent", "download-upgrade", fmt.Sprintf("搜索 %s 的升级版本失败: %v", movie.Code, err))
+			}
+			continue
+		}
+		if task != nil {
+			queued++
+		}
+	}
+	if err := s.localMovieRepo.MarkUpgradeChecked(checked, time.Now()); err != nil && s.logService != nil {
+		s.logService.LogWarn("torrent", "download-upgrade", fmt.Sprintf("保存升级检查时间失败: %v", err))
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("检查 %d 部影片，创建 %d 个升级任务，%d 部搜索失败", len(checked), queued, failed), nil
+}
+
+// checkUpgrade 搜索影片的更好版本，找到时创建升级任务；已有进行中的任务时跳过
+func (s *RankingDownloadService) checkUpgrade(movie *model.LocalMovie) (*model.RankingDownloadTask, error) {
+	owned := s.ownedQuality(movie)
+	if !s.upgradePolicy.Wants(owned) {
+		return nil, nil
+	}
+	if active, _ := s.taskRepo.GetActiveTaskByCode(movie.Code); active != nil {
+		return nil, nil
+	}
+
+	torrents, reason, err := s.findUpgrade(movie.Code, owned)
+	if err != nil {
+		return nil, err
+	}
+	candidates := s.filterBlocked(downloadCandidates(torrents))
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	if len(candidates) > maxDownloadCandidates {
+		candidates = candidates[:maxDownloadCandidates]
+	}
+	return s.startUpgradeTask(movie, candidates, reason)
+}
+
+// ownedQuality 本地已有的最好画质，同一番号的多个版本按各项取最好
+func (s *RankingDownloadService) ownedQuality(movie *model.LocalMovie) MediaQuality {
+	owned := LocalMovieQuality(movie)
+	versions, err := s.localMovieRepo.ListByCodeKey(movie.CodeKey)
+	if err != nil {
+		return owned
+	}
+	for _, version := range versions {
+		quality := LocalMovieQuality(version)
+		if quality.Resolution > owned.Resolution {
+			owned.Resolution = quality.Resolution
+		}
+		owned.Subtitle = owned.Subtitle || quality.Subtitle
+		owned.Uncensored = owned.Uncensored || quality.Uncensored
+	}
+	return owned
+}
+
+// findUpgrade 搜索番号的种子，只保留比本地版本更好的种子（按选择规则得分排序），返回得分最高的种子的升级原因
+func (s *RankingDownloadService) findUpgrade(code string, owned MediaQuality) ([]JackettResult, string, error) {
+	torrents, err := s.torrentService.SearchTorrentsForUpgrade(code)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tags := s.upgradeTags()
+	var better []JackettResult
+	var reason string
+	for _, torrent := range torrents {
+		gain, ok := s.upgradePolicy.Improves(owned, torrentQuality(torrent.Title, tags))
+		if !ok {
+			continue
+		}
+		if reason == "" {
+			reason = gain
+		}
+		better = append(better, torrent)
+	}
+	return better, reason, nil
+}
+
+// searchUpgradeTorrents 升级任务的候选种子用完后重新搜索，按被升级影片当前的画质筛选
+func (s *RankingDownloadService) searchUpgradeTorrents(task *model.RankingDownloadTask) ([]JackettResult, error) {
+	movie, err := s.localMovieRepo.GetByID(*task.UpgradeOf)
+	if err != nil {
+		return nil, fmt.Errorf("要升级的本地影片已不存在: %v", err)
+	}
+	torrents, _, err := s.findUpgrade(task.Code, s.ownedQuality(movie))
+	return torrents, err
+}
+
+// upgradeTags 识别中文字幕与无码的标签，优先使用种子选择规则中的同名标签
+func (s *RankingDownloadService) upgradeTags() []TorrentTag {
+	tags := make(map[string]TorrentTag)
+	for _, tag := range DefaultTorrentTags() {
+		tags[tag.Name] = tag
+	}
+	for _, tag := range s.torrentService.selectionRules.PreferredTags {
+		if _, ok := tags[tag.Name]; ok {
+			tags[tag.Name] = tag
+		}
+	}
+	return []TorrentTag{tags[chineseSubtitleTag], tags[uncensoredLeakTag]}
+}
+
+// torrentQuality 从种子标题识别画质：分辨率标识、中文字幕与无码标签
+func torrentQuality(title string, tags []TorrentTag) MediaQuality {
+	var quality MediaQuality
+	if match := torrentResolutionPattern.FindStringSubmatch(title); match != nil {
+		switch strings.ToLower(match[1]) {
+		case "2160p", "4k", "uhd":
+			quality.Resolution = 2160
+		case "1080p", "fhd":
+			quality.Resolution = 1080
+		case "720p":
+			quality.Resolution = 720
+		case "480p":
+			quality.Resolution = 480
+		}
+	}
+
+	code, hasCode := moviecode.Parse(title)
+	lower := strings.ToLower(title)
+	for _, tag := range tags {
+		if _, ok := tag.match(lower, code, hasCode); !ok {
+			continue
+		}
+		switch tag.Name {
+		case chineseSubtitleTag:
+			quality.Subtitle = true
+		case uncensoredLeakTag:
+			quality.Uncensored = true
+		}
+	}
+	return quality
+}
+
+// startUpgradeTask 创建画质升级下载任务；已结束的历史任务原样保留，番号仍有进行中的任务时不创建
+func (s *RankingDownloadService) startUpgradeTask(movie *model.LocalMovie, candidates []model.DownloadCandidate, reason string) (*model.RankingDownloadTask, error) {
+	if active, _ := s.taskRepo.GetActiveTaskByCode(movie.Code); active != nil {
+		return nil, nil
+	}
+	if history, _ := s.taskRepo.GetByCode(movie.Code); history != nil && history.ImportStatus == model.ImportStatusImporting {
+		return nil, fmt.Errorf("番号 %s 正在导入", movie.Code)
+	}
+
+	task := &model.RankingDownloadTask{
+		Code:          movie.Code,
+		Title:         movie.Title,
+		Status:        model.RankingDownloadStatusPending,
+		Source:        model.DownloadSourceUpgrade,
+		Priority:      model.DownloadPriorityForSource(model.DownloadSourceUpgrade),
+		Candidates:    candidates,
+		UpgradeOf:     &movie.ID,
+		UpgradeReason: reason,
+	}
+	if err := s.taskRepo.Create(task); err != nil {
+		return nil, fmt.Errorf("创建升级任务失败: %v", err)
+	}
+
+	if s.logService != nil {
+		s.logService.LogInfo("torrent", "download-upgrade", fmt.Sprintf("创建升级任务: %s（当前 %s，升级 %s）", movie.Code, LocalMovieQuality(movie), reason))
+	}
+	s.signalQueue()
+	return task, nil
+}
+
+// upgradeImportOptions 升级任务导入时按策略替换或归档被升级影片的文件，被升级影片已不存在时按普通任务导入
+func (s *RankingDownloadService) upgradeImportOptions(task *model.RankingDownloadTask) ImportOptions {
+	if task.UpgradeOf == nil {
+		return ImportOptions{}
+	}
+	movie, err := s.localMovieRepo.GetByID(*task.UpgradeOf)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogWarn("torrent", "download-upgrade", fmt.Sprintf("被升级的本地影片已不存在，按普通任务导入: %s", task.Code))
+		}
+		return ImportOptions{}
+	}
+	policy := s.upgradePolicy
+	return ImportOptions{
+		Replaces:     movie,
+		RetireAction: policy.Action,
+		ArchiveDir:   policy.ArchiveDir,
+		Policy:       &policy,
+		Expected:     torrentQuality(s.currentCandidateTitle(task), s.upgradeTags()),
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/repo"
+	"sort"
+	"strings"
+)
+
+// DuplicateService 重复影片检测服务
+type DuplicateService struct {
+	localMovieRepo repo.LocalMovieRepository
+}
+
+// NewDuplicateService 创建重复影片检测服务
+func NewDuplicateService(localMovieRepo repo.LocalMovieRepository) *DuplicateService {
+	return &DuplicateService{localMovieRepo: localMovieRepo}
+}
+
+// DuplicateGroup 一组重复的本地影片
+type DuplicateGroup struct {
+	Key         string              // 内容摘要或番号
+	Movies      []*model.LocalMovie // 组内影片，保留项在最前
+	TotalSize   int64               // 组内文件总大小
+	Reclaimable int64               // 只保留一份时可释放的空间
+}
+
+// DuplicateReport 重复影片报告
+type DuplicateReport struct {
+	Identical            []DuplicateGroup // 内容完全相同的文件
+	SameCode             []DuplicateGroup // 番号与版本相同但文件不同的记录
+	IdenticalReclaimable int64
+	SameCodeReclaimable  int64
+	TotalReclaimable     int64
+}
+
+// Report 生成重复影片报告
+func (s *DuplicateService) Report() (*DuplicateReport, error) {
+	report := &DuplicateReport{
+		Identical: []DuplicateGroup{},
+		SameCode:  []DuplicateGroup{},
+	}
+
+	// 内容相同的文件：保留最早入库的一份，其余均可删除
+	byHash, err := s.localMovieRepo.ListDuplicateHashes()
+	if err != nil {
+		return nil, fmt.Errorf("查询相同文件失败: %v", err)
+	}
+	for _, movies := range groupMovies(byHash, func(m *model.LocalMovie) string { return m.ContentHash }) {
+		group := DuplicateGroup{Key: movies[0].ContentHash, Movies: movies}
+		for i, movie := range movies {
+			group.TotalSize += movie.Size
+			if i > 0 {
+				group.Reclaimable += movie.Size
+			}
+		}
+		report.Identical = append(report.Identical, group)
+		report.IdenticalReclaimable += group.Reclaimable
+	}
+
+	// 番号与版本相同的不同文件：保留最大的一份，相同文件已在上面统计过，不重复计算
+	// 同一番号的不同版本是有意保留的，分别成组，不计入可释放空间
+	byCode, err := s.localMovieRepo.ListDuplicateCodes()
+	if err != nil {
+		return nil, fmt.Errorf("查询相同番号失败: %v", err)
+	}
+	for _, movies := range groupMovies(byCode, sameCodeKey) {
+		seen := make(map[string]bool, len(movies))
+		var distinct []*model.LocalMovie
+		for _, movie := range movies {
+			if movie.ContentHash != "" {
+				if seen[movie.ContentHash] {
+					continue
+				}
+				seen[movie.ContentHash] = true
+			}
+			distinct = append(distinct, movie)
+		}
+		if len(distinct) < 2 {
+			continue
+		}
+
+		sort.SliceStable(distinct, func(i, j int) bool { return distinct[i].Size > distinct[j].Size })
+		group := DuplicateGroup{Key: sameCodeKey(distinct[0]), Movies: distinct}
+		for i, movie := range distinct {
+			group.TotalSize += movie.Size
+			if i > 0 {
+				group.Reclaimable += movie.Size
+			}
+		}
+		report.SameCode = append(report.SameCode, group)
+		report.SameCodeReclaimable += group.Reclaimable
+	}
+
+	sortGroups(report.Identical)
+	sortGroups(report.SameCode)
+	report.TotalReclaimable = report.IdenticalReclaimable + report.SameCodeReclaimable
+	return report, nil
+}
+
+// sameCodeKey 番号重复的分组键：番号，带版本标识时附加版本，如 SSIS-123 (4K)
+func sameCodeKey(movie *model.LocalMovie) string {
+	key := strings.ToUpper(movie.Code)
+	if movie.Version != "" {
+		key += " (" + movie.Version + ")"
+	}
+	return key
+}
+
+// groupMovies 按分组键聚合影片，保持查询结果中的顺序
+func groupMovies(movies []*model.LocalMovie, key func(*model.LocalMovie) string) [][]*model.LocalMovie {
+	var keys []string
+	groups := make(map[string][]*model.LocalMovie)
+	for _, movie := range movies {
+		k := key(movie)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], movie)
+	}
+
+	result := make([][]*model.LocalMovie, 0, len(keys))
+	for _, k := range keys {
+		if len(groups[k]) > 1 {
+			result = append(result, groups[k])
+		}
+	}
+	return result
+}
+
+// sortGroups 按可释放空间从大到小排序
+func sortGroups(groups []DuplicateGroup) {
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Reclaimable > groups[j].Reclaimable })
+}
@@ -0,0 +1,504 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
+	"nsfw-go/internal/probe"
+	"nsfw-go/internal/repo"
+)
+
+// 导入方式
+const (
+	ImportModeMove     = "move"     // 移动，下载器中的种子随后被删除（不删除文件）
+	ImportModeCopy     = "copy"     // 复制，保留下载目录中的文件继续做种
+	ImportModeHardlink = "hardlink" // 硬链接，不占用额外空间并继续做种；跨文件系统时改为复制
+)
+
+// 被替换影片的旧文件处理方式
+const (
+	RetireActionReplace = "replace" // 删除旧文件
+	RetireActionArchive = "archive" // 移动到归档目录
+)
+
+var (
+	// ErrImportDisabled 未启用下载导入
+	ErrImportDisabled = errors.New("未启用下载完成后导入媒体库")
+	// ErrNoMainVideo 下载内容中找不到正片视频
+	ErrNoMainVideo = errors.New("下载内容中找不到正片视频")
+)
+
+// ImportPathMapping 下载器与本服务看到的路径不同时（如运行在不同容器中）的路径映射
+type ImportPathMapping struct {
+	From string `json:"from"` // 下载器中的路径前缀，如 /downloads
+	To   string `json:"to"`   // 本服务中的路径前缀，如 /data/downloads
+}
+
+// ImportSettings 下载导入设置
+type ImportSettings struct {
+	Enabled      bool
+	Mode         string              // move、copy 或 hardlink
+	Root         string              // 导入的媒体库根目录名称，为空时使用第一个根目录
+	MinVideoSize int64               // 正片视频的最小字节数，更小的视频视为预览
+	PathMappings []ImportPathMapping // 下载路径映射
+}
+
+// DefaultImportSettings 默认导入设置：不启用，硬链接，忽略小于 100MB 的视频
+func DefaultImportSettings() ImportSettings {
+	return ImportSettings{
+		Enabled:      false,
+		Mode:         ImportModeHardlink,
+		MinVideoSize: 100 << 20,
+	}
+}
+
+// ImportOptions 单次导入的选项
+type ImportOptions struct {
+	Replaces     *model.LocalMovie // 画质升级时被替换的本地影片（含分段与字幕），为空表示普通导入
+	RetireAction string            // 旧文件的处理方式：replace 或 archive
+	ArchiveDir   string            // 归档目录，旧文件放在以番号命名的子目录中
+	Policy       *UpgradePolicy    // 画质升级策略，不为空时导入前探测新文件，确认比旧文件更好才替换
+	Expected     MediaQuality      // 种子标题表明的画质，分辨率以探测结果为准
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Code         string          `json:"code"`
+	Source       string          `json:"source"`    // 下载目录中的正片视频
+	Target       string          `json:"target"`    // 媒体库中的视频路径
+	Mode         string          `json:"mode"`      // 实际使用的导入方式
+	Subtitles    []string        `json:"subtitles"` // 一并导入的字幕
+	Metadata     string          `json:"metadata"`  // 元数据来源，找不到元数据时为空
+	NFO          *NFOWriteResult `json:"nfo,omitempty"`
+	LocalMovieID uint            `json:"local_movie_id,omitempty"`
+	Retired      []string        `json:"retired,omitempty"` // 被替换的旧文件：删除时为原路径，归档时为归档后的路径
+}
+
+// ImportService 下载完成后的导入服务：挑选正片视频，按整理模板以番号重命名并放入媒体库，
+// 写入NFO与图片，刷新对应目录并将排行榜记录标记为本地存在
+type ImportService struct {
+	localMovieRepo   repo.LocalMovieRepository
+	rankingRepo      repo.RankingRepository
+	scannerService   *ScannerService
+	organizerService *OrganizerService
+	nfoWriterService *NFOWriterService
+	logService       *LogService
+	settings         ImportSettings
+}
+
+// NewImportService 创建下载导入服务
+func NewImportService(
+	localMovieRepo repo.LocalMovieRepository,
+	rankingRepo repo.RankingRepository,
+	scannerService *ScannerService,
+	organizerService *OrganizerService,
+	nfoWriterService *NFOWriterService,
+	logService *LogService,
+) *ImportService {
+	return &ImportService{
+		localMovieRepo:   localMovieRepo,
+		rankingRepo:      rankingRepo,
+		scannerService:   scannerService,
+		organizerService: organizerService,
+		nfoWriterService: nfoWriterService,
+		logService:       logService,
+		settings:         DefaultImportSettings(),
+	}
+}
+
+// SetSettings 设置导入选项，导入方式无效时返回错误
+func (s *ImportService) SetSettings(settings ImportSettings) error {
+	switch settings.Mode {
+	case "":
+		settings.Mode = ImportModeHardlink
+	case ImportModeMove, ImportModeCopy, ImportModeHardlink:
+	default:
+		return fmt.Errorf("不支持的导入方式: %s", settings.Mode)
+	}
+	s.settings = settings
+	return nil
+}
+
+// Enabled 是否启用下载完成后自动导入
+func (s *ImportService) Enabled() bool {
+	return s.settings.Enabled
+}
+
+// Mode 导入方式
+func (s *ImportService) Mode() string {
+	return s.settings.Mode
+}
+
+// Import 将下载完成的内容导入媒体库，contentPath 为下载器报告的内容路径（单文件或目录）
+// opts.Replaces 不为空时，新文件放入媒体库前先移走旧文件，导入失败时恢复
+func (s *ImportService) Import(ctx context.Context, code, contentPath string, opts ImportOptions) (*ImportResult, error) {
+	rootPath, err := s.targetRoot()
+	if err != nil {
+		return nil, err
+	}
+	if contentPath == "" {
+		return nil, errors.New("下载器没有提供内容路径")
+	}
+	source := s.mapPath(contentPath)
+	video, err := s.findMainVideo(source)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Replaces != nil && opts.Policy != nil {
+		if err := verifyUpgrade(video, opts); err != nil {
+			return nil, err
+		}
+	}
+	result := &ImportResult{Code: moviecode.Normalize(code), Source: video, Subtitles: []string{}}
+
+	// 元数据：影片库优先，其次在线抓取；都没有时只按番号命名
+	movie, metadata, err := s.nfoWriterService.resolveMetadata(ctx, &model.LocalMovie{Code: result.Code}, NFOWriteOptions{Scrape: true})
+	if err != nil {
+		s.logWarn(fmt.Sprintf("导入 %s 时找不到元数据，仅按番号命名: %v", result.Code, err))
+		movie = nil
+	}
+	result.Metadata = metadata
+
+	values := map[string]string{"code": result.Code}
+	if movie != nil {
+		applyMovieValues(values, movie)
+	}
+	values = finishTemplateValues(values)
+	values["ext"] = strings.TrimPrefix(strings.ToLower(filepath.Ext(video)), ".")
+	result.Target = s.organizerService.targetPath(rootPath, values)
+
+	if err := os.MkdirAll(filepath.Dir(result.Target), 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %v", err)
+	}
+	if opts.Replaces == nil {
+		if result.Mode, err = s.transfer(video, result.Target); err != nil {
+			return nil, err
+		}
+	} else if err := s.scannerService.Exclusive(func() error {
+		// 替换旧文件期间独占媒体库，避免整理或扫描同时移动、重新入库旧文件
+		return s.replace(video, result, opts)
+	}); err != nil {
+		return nil, err
+	}
+
+	// 同名字幕随视频一起导入
+	videoStem := strings.TrimSuffix(filepath.Base(video), filepath.Ext(video))
+	targetStem := strings.TrimSuffix(result.Target, filepath.Ext(result.Target))
+	if entries, err := os.ReadDir(filepath.Dir(video)); err == nil {
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !isSubtitleFile(name) || !subtitleMatchesVideo(name, videoStem) {
+				continue
+			}
+			target := targetStem + name[len(videoStem):]
+			if _, err := s.transfer(filepath.Join(filepath.Dir(video), name), target); err != nil {
+				s.logWarn(fmt.Sprintf("导入字幕失败 [%s]: %v", name, err))
+				continue
+			}
+			result.Subtitles = append(result.Subtitles, target)
+		}
+	}
+
+	if movie != nil {
+		result.NFO = s.nfoWriterService.write(ctx, &model.LocalMovie{Code: result.Code, Path: result.Target}, movie, metadata, NFOWriteOptions{})
+		if result.NFO.Error != "" {
+			s.logWarn(fmt.Sprintf("导入 %s 时写入NFO失败: %s", result.Code, result.NFO.Error))
+		}
+	}
+
+	// 只刷新影片所在目录，不触发整个媒体库的扫描；旧文件在其他目录时一并刷新以移除旧记录
+	dirs := []string{filepath.Dir(result.Target)}
+	if opts.Replaces != nil && filepath.Dir(opts.Replaces.Path) != dirs[0] {
+		dirs = append(dirs, filepath.Dir(opts.Replaces.Path))
+	}
+	for _, dir := range dirs {
+		if err := s.scannerService.RescanPath(dir); err != nil {
+			s.logWarn(fmt.Sprintf("导入 %s 后刷新媒体库失败: %v", result.Code, err))
+		}
+	}
+	if local, err := s.localMovieRepo.GetByPath(result.Target); err == nil {
+		result.LocalMovieID = local.ID
+	}
+	if _, err := s.rankingRepo.MarkLocalByCodes([]string{result.Code, code}); err != nil {
+		s.logWarn(fmt.Sprintf("更新排行榜本地状态失败 [%s]: %v", result.Code, err))
+	}
+
+	if s.logService != nil {
+		s.logService.LogInfo("torrent", "download-import", fmt.Sprintf("已导入 %s (%s): %s", result.Code, result.Mode, result.Target))
+	}
+	return result, nil
+}
+
+// targetRoot 导入的媒体库根目录
+func (s *ImportService) targetRoot() (string, error) {
+	roots := s.scannerService.Roots()
+	for _, root := range roots {
+		if s.settings.Root == "" || root.Name == s.settings.Root {
+			return filepath.Clean(root.Path), nil
+		}
+	}
+	if s.settings.Root != "" {
+		return "", fmt.Errorf("%w: %s", ErrOrganizeRootNotFound, s.settings.Root)
+	}
+	return "", ErrOrganizeRootNotFound
+}
+
+// mapPath 将下载器中的路径转换为本服务中的路径，匹配最长的映射前缀
+func (s *ImportService) mapPath(p string) string {
+	best := -1
+	for i, mapping := range s.settings.PathMappings {
+		from := strings.TrimRight(mapping.From, `/\`)
+		if from == "" || (p != from && !strings.HasPrefix(p, from+"/") && !strings.HasPrefix(p, from+`\`)) {
+			continue
+		}
+		if best < 0 || len(from) > len(strings.TrimRight(s.settings.PathMappings[best].From, `/\`)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return filepath.FromSlash(p)
+	}
+	mapping := s.settings.PathMappings[best]
+	rest := strings.TrimLeft(p[len(strings.TrimRight(mapping.From, `/\`)):], `/\`)
+	return filepath.Join(mapping.To, filepath.FromSlash(strings.ReplaceAll(rest, `\`, "/")))
+}
+
+// findMainVideo 在下载内容中挑选正片：跳过预览、花絮等附加内容与过小的视频，取最大的视频文件
+func (s *ImportService) findMainVideo(source string) (string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", fmt.Errorf("下载内容不存在: %v", err)
+	}
+	if !info.IsDir() {
+		if !isVideoExt(source) {
+			return "", ErrNoMainVideo
+		}
+		return source, nil
+	}
+
+	var best string
+	var bestSize int64
+	err = filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := strings.ToLower(d.Name())
+		if d.IsDir() {
+			if p != source && extraDirNames[name] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isVideoExt(name) || strings.Contains(name, "sample") || strings.Contains(name, "trailer") || strings.Contains(name, "preview") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() < s.settings.MinVideoSize {
+			return nil
+		}
+		if info.Size() > bestSize {
+			best, bestSize = p, info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if best == "" {
+		return "", ErrNoMainVideo
+	}
+	return best, nil
+}
+
+// transfer 按导入方式放置文件，返回实际使用的方式；目标已是同一文件时视为已导入
+func (s *ImportService) transfer(src, dst string) (string, error) {
+	if info, err := os.Stat(dst); err == nil {
+		if srcInfo, err := os.Stat(src); err == nil && os.SameFile(info, srcInfo) {
+			return s.settings.Mode, nil
+		}
+		return "", fmt.Errorf("目标已存在: %s", dst)
+	}
+
+	switch s.settings.Mode {
+	case ImportModeMove:
+		if err := moveFile(src, dst); err != nil {
+			return "", fmt.Errorf("移动文件失败: %v", err)
+		}
+		return ImportModeMove, nil
+	case ImportModeHardlink:
+		if err := os.Link(src, dst); err == nil {
+			return ImportModeHardlink, nil
+		}
+	}
+	if err := copyFile(src, dst); err != nil {
+		return "", fmt.Errorf("复制文件失败: %v", err)
+	}
+	return ImportModeCopy, nil
+}
+
+// moveFile 移动文件，跨文件系统时改为复制后删除
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		if err = copyFile(src, dst); err == nil {
+			err = os.Remove(src)
+		}
+	}
+	return err
+}
+
+// copyFile 复制文件，先写入临时文件再重命名，避免媒体库监听收录不完整的文件
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".part"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// replace 画质升级导入：先移走旧文件再放置新文件（整理模板相同时新旧文件同名），放置失败时恢复旧文件
+func (s *ImportService) replace(video string, result *ImportResult, opts ImportOptions) error {
+	retired, err := s.stageRetired(opts)
+	if err != nil {
+		return err
+	}
+	if result.Mode, err = s.transfer(video, result.Target); err != nil {
+		s.restoreRetired(retired)
+		return err
+	}
+	result.Retired = s.finishRetired(retired, opts.RetireAction)
+	return nil
+}
+
+// verifyUpgrade 按下载的视频实际探测到的分辨率确认新版本比被替换的影片更好，
+// 字幕与无码沿用种子标题的识别结果；无法探测时使用种子标题中的分辨率
+func verifyUpgrade(video string, opts ImportOptions) error {
+	quality := opts.Expected
+	if probe.Supported(video) {
+		if info, err := probe.File(video); err == nil {
+			if height := qualityHeight(info.Quality()); height > 0 {
+				quality.Resolution = height
+			}
+		}
+	}
+	owned := LocalMovieQuality(opts.Replaces)
+	if _, ok := opts.Policy.Improves(owned, quality); !ok {
+		return fmt.Errorf("下载的版本（%s）没有比本地版本（%s）更好，保留旧文件", quality, owned)
+	}
+	return nil
+}
+
+// retiredFile 被替换影片的旧文件
+type retiredFile struct {
+	original string // 原路径
+	staged   string // 暂存路径：归档时为归档目录中的路径，删除时为原目录中的临时文件
+}
+
+// stageRetired 移走被替换影片的视频与外挂字幕：归档时直接移动到归档目录，删除时先改名，导入成功后再删除
+func (s *ImportService) stageRetired(opts ImportOptions) ([]retiredFile, error) {
+	movie := opts.Replaces
+	if opts.RetireAction == RetireActionArchive && !filepath.IsAbs(opts.ArchiveDir) {
+		return nil, fmt.Errorf("旧文件归档需要配置绝对路径的归档目录")
+	}
+	files := []string{movie.Path}
+	if len(movie.Parts) > 0 {
+		files = files[:0]
+		for _, part := range movie.Parts {
+			files = append(files, part.Path)
+		}
+	}
+	for _, subtitle := range movie.Subtitles {
+		files = append(files, subtitle.Path)
+	}
+
+	var staged []retiredFile
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		target := file + ".replaced"
+		if opts.RetireAction == RetireActionArchive {
+			dir := filepath.Join(opts.ArchiveDir, moviecode.Normalize(movie.Code))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				s.restoreRetired(staged)
+				return nil, fmt.Errorf("创建归档目录失败: %v", err)
+			}
+			target = availablePath(filepath.Join(dir, filepath.Base(file)))
+		}
+		if err := moveFile(file, target); err != nil {
+			s.restoreRetired(staged)
+			return nil, fmt.Errorf("移走旧文件失败 [%s]: %v", file, err)
+		}
+		staged = append(staged, retiredFile{original: file, staged: target})
+	}
+	return staged, nil
+}
+
+// restoreRetired 导入失败时将旧文件放回原处
+func (s *ImportService) restoreRetired(staged []retiredFile) {
+	for i := len(staged) - 1; i >= 0; i-- {
+		if err := moveFile(staged[i].staged, staged[i].original); err != nil {
+			s.logWarn(fmt.Sprintf("恢复旧文件失败 [%s]: %v", staged[i].original, err))
+		}
+	}
+}
+
+// finishRetired 导入成功后删除暂存的旧文件，归档的文件保留在归档目录
+func (s *ImportService) finishRetired(staged []retiredFile, action string) []string {
+	retired := make([]string, 0, len(staged))
+	for _, file := range staged {
+		if action == RetireActionArchive {
+			retired = append(retired, file.staged)
+			continue
+		}
+		if err := os.Remove(file.staged); err != nil {
+			s.logWarn(fmt.Sprintf("删除旧文件失败 [%s]: %v", file.staged, err))
+			continue
+		}
+		retired = append(retired, file.original)
+	}
+	return retired
+}
+
+// availablePath 路径已存在时在文件名后追加序号
+func availablePath(p string) string {
+	ext := filepath.Ext(p)
+	stem := strings.TrimSuffix(p, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			return p
+		}
+		p = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+}
+
+func (s *ImportService) logWarn(message string) {
+	if s.logService != nil {
+		s.logService.LogWarn("torrent", "download-import", message)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"nsfw-go/internal/crawler"
+	"nsfw-go/internal/moviecode"
 
 	"github.com/gocolly/colly/v2"
 )
@@ -121,11 +122,9 @@ func (s *JAVDbSearchService) SearchMovieByCode(ctx context.Context, code string)
 
 		// 从标题中提取番号
 		extractedCode := s.extractMovieCode(title)
-		normalizedExtracted := s.normalizeCode(extractedCode)
-		normalizedInput := s.normalizeCode(code)
 
-		// 检查番号是否完全匹配
-		if normalizedExtracted == normalizedInput {
+		// 检查番号是否匹配（忽略大小写、分隔符与补零差异）
+		if moviecode.Equal(extractedCode, code) {
 			movieResult := &MovieSearchResult{
 				Code:      extractedCode,
 				Title:     title,
@@ -460,48 +459,14 @@ func (s *JAVDbSearchService) buildURL(path string) (string, error) {
 	return base.ResolveReference(relative).String(), nil
 }
 
-// extractMovieCode 从标题中提取番号
+// extractMovieCode 从标题中提取番号（标准写法）
 func (s *JAVDbSearchService) extractMovieCode(title string) string {
-	// 常见的番号格式正则表达式
-	patterns := []string{
-		`([A-Z]{2,10}-\d{3,5})`, // ABC-123, ABCD-1234
-		`([A-Z]{2,10}\d{3,5})`,  // ABC123, ABCD1234
-		`(\d{6}_\d{3})`,         // 123456_789
-		`([A-Z]+\s*\d+)`,        // ABC 123, ABC123
+	if code, ok := moviecode.Parse(title); ok {
+		return code.String()
 	}
-
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		matches := re.FindStringSubmatch(title)
-		if len(matches) > 1 {
-			code := strings.ReplaceAll(matches[1], " ", "")
-			return strings.ToUpper(code)
-		}
-	}
-
 	return ""
 }
 
-// normalizeCode 标准化番号格式
-func (s *JAVDbSearchService) normalizeCode(code string) string {
-	if code == "" {
-		return ""
-	}
-
-	// 转换为大写
-	code = strings.ToUpper(strings.TrimSpace(code))
-
-	// 移除特殊字符，保留字母数字和连字符
-	var result strings.Builder
-	for _, r := range code {
-		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
-			result.WriteRune(r)
-		}
-	}
-
-	return result.String()
-}
-
 // parseReleaseDate 解析发布日期
 func (s *JAVDbSearchService) parseReleaseDate(dateText string) time.Time {
 	if dateText == "" {
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NFOMovie Kodi/Jellyfin 影片NFO文件结构
+type NFOMovie struct {
+	XMLName       xml.Name      `xml:"movie"`
+	Title         string        `xml:"title,omitempty"`
+	OriginalTitle string        `xml:"originaltitle,omitempty"`
+	SortTitle     string        `xml:"sorttitle,omitempty"`
+	Code          string        `xml:"num,omitempty"`
+	Year          string        `xml:"year,omitempty"`
+	Premiered     string        `xml:"premiered,omitempty"`
+	ReleaseDate   string        `xml:"releasedate,omitempty"`
+	Runtime       string        `xml:"runtime,omitempty"` // 分钟
+	Plot          string        `xml:"plot,omitempty"`
+	Outline       string        `xml:"outline,omitempty"`
+	Tagline       string        `xml:"tagline,omitempty"`
+	Rating        string        `xml:"rating,omitempty"`
+	Ratings       []NFORating   `xml:"ratings>rating,omitempty"`
+	Studios       []string      `xml:"studio,omitempty"`
+	Maker         string        `xml:"maker,omitempty"`
+	Label         string        `xml:"label,omitempty"`
+	Director      string        `xml:"director,omitempty"`
+	Set           *NFOSet       `xml:"set,omitempty"`
+	Genres        []string      `xml:"genre,omitempty"`
+	Tags          []string      `xml:"tag,omitempty"`
+	Actors        []NFOActor    `xml:"actor,omitempty"`
+	UniqueIDs     []NFOUniqueID `xml:"uniqueid,omitempty"`
+	Thumbs        []NFOThumb    `xml:"thumb,omitempty"`
+	Fanart        []NFOThumb    `xml:"fanart>thumb,omitempty"`
+	Trailer       string        `xml:"trailer,omitempty"`
+}
+
+// NFORating Kodi v17+ 的多来源评分
+type NFORating struct {
+	Name    string  `xml:"name,attr"`
+	Max     float64 `xml:"max,attr,omitempty"`
+	Default bool    `xml:"default,attr,omitempty"`
+	Value   float64 `xml:"value"`
+	Votes   int     `xml:"votes,omitempty"`
+}
+
+// NFOSet 影片合集，兼容 <set>名称</set> 与 <set><name>名称</name></set> 两种写法
+type NFOSet struct {
+	Name     string `xml:"name,omitempty"`
+	Overview string `xml:"overview,omitempty"`
+	Text     string `xml:",chardata"`
+}
+
+// NFOActor 演员信息
+type NFOActor struct {
+	Name  string `xml:"name"`
+	Role  string `xml:"role,omitempty"`
+	Order int    `xml:"order"`
+	Thumb string `xml:"thumb,omitempty"`
+}
+
+// NFOUniqueID 外部站点ID
+type NFOUniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// NFOThumb 图片地址
+type NFOThumb struct {
+	Aspect  string `xml:"aspect,attr,omitempty"`
+	Preview string `xml:"preview,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// 无法按XML解析时，从CDATA中提取标题
+var nfoCDATATitlePattern = regexp.MustCompile(`<title><!\[CDATA\[(.*?)\]\]></title>`)
+
+// parseNFO 解析NFO文件
+func parseNFO(path string) (*NFOMovie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var nfo NFOMovie
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// 部分刮削工具声明了 GBK 等编码但内容仍为 UTF-8，按原样读取
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&nfo); err != nil {
+		if matches := nfoCDATATitlePattern.FindSubmatch(data); len(matches) > 1 {
+			return &NFOMovie{Title: string(matches[1])}, nil
+		}
+		return nil, err
+	}
+	nfo.normalize()
+	return &nfo, nil
+}
+
+// normalize 去除各字段首尾空白
+func (n *NFOMovie) normalize() {
+	trim := func(values []string) []string {
+		var result []string
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+		return result
+	}
+	n.Title = strings.TrimSpace(n.Title)
+	n.OriginalTitle = strings.TrimSpace(n.OriginalTitle)
+	n.Code = strings.TrimSpace(n.Code)
+	n.Plot = strings.TrimSpace(n.Plot)
+	n.Outline = strings.TrimSpace(n.Outline)
+	n.Studios = trim(n.Studios)
+	n.Genres = trim(n.Genres)
+	n.Tags = trim(n.Tags)
+	if n.Set != nil {
+		n.Set.Name = strings.TrimSpace(n.Set.Name)
+		n.Set.Text = strings.TrimSpace(n.Set.Text)
+	}
+	for i := range n.Actors {
+		n.Actors[i].Name = strings.TrimSpace(n.Actors[i].Name)
+		n.Actors[i].Thumb = strings.TrimSpace(n.Actors[i].Thumb)
+	}
+	for i := range n.UniqueIDs {
+		n.UniqueIDs[i].Type = strings.ToLower(strings.TrimSpace(n.UniqueIDs[i].Type))
+		n.UniqueIDs[i].Value = strings.TrimSpace(n.UniqueIDs[i].Value)
+	}
+	for i := range n.Thumbs {
+		n.Thumbs[i].Value = strings.TrimSpace(n.Thumbs[i].Value)
+	}
+	for i := range n.Fanart {
+		n.Fanart[i].Value = strings.TrimSpace(n.Fanart[i].Value)
+	}
+}
+
+// MovieCode 返回番号，<num> 缺失时使用 num/code 类型的 uniqueid
+func (n *NFOMovie) MovieCode() string {
+	if n.Code != "" {
+		return n.Code
+	}
+	for _, id := range n.UniqueIDs {
+		if (id.Type == "num" || id.Type == "code") && id.Value != "" {
+			return id.Value
+		}
+	}
+	return ""
+}
+
+// SetName 返回合集名称
+func (n *NFOMovie) SetName() string {
+	if n.Set == nil {
+		return ""
+	}
+	if n.Set.Name != "" {
+		return n.Set.Name
+	}
+	return n.Set.Text
+}
+
+// StudioName 返回制作商，<studio> 缺失时使用 <maker>
+func (n *NFOMovie) StudioName() string {
+	if len(n.Studios) > 0 {
+		return n.Studios[0]
+	}
+	return strings.TrimSpace(n.Maker)
+}
+
+// Description 返回简介，<plot> 缺失时使用 <outline>
+func (n *NFOMovie) Description() string {
+	if n.Plot != "" {
+		return n.Plot
+	}
+	return n.Outline
+}
+
+// RuntimeMinutes 返回时长（分钟），兼容 "120" 与 "120 min" 写法
+func (n *NFOMovie) RuntimeMinutes() int {
+	fields := strings.Fields(n.Runtime)
+	if len(fields) == 0 {
+		return 0
+	}
+	minutes, _ := strconv.Atoi(fields[0])
+	return minutes
+}
+
+// RatingValue 返回十分制评分，优先使用默认评分来源
+func (n *NFOMovie) RatingValue() float32 {
+	var value, max float64
+	for _, rating := range n.Ratings {
+		if rating.Default || value == 0 {
+			value, max = rating.Value, rating.Max
+		}
+	}
+	if value == 0 {
+		value, _ = strconv.ParseFloat(strings.TrimSpace(n.Rating), 64)
+	}
+	if max > 0 && max != 10 {
+		value = value / max * 10
+	}
+	if value < 0 || value > 10 {
+		return 0
+	}
+	return float32(value)
+}
+
+// ReleaseTime 返回发行日期，只有年份时取当年1月1日
+func (n *NFOMovie) ReleaseTime() *time.Time {
+	for _, value := range []string{n.Premiered, n.ReleaseDate} {
+		value = strings.TrimSpace(value)
+		if len(value) >= 10 {
+			if t, err := time.Parse("2006-01-02", value[:10]); err == nil {
+				return &t
+			}
+		}
+	}
+	if year, err := strconv.Atoi(strings.TrimSpace(n.Year)); err == nil && year > 1900 {
+		t := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	return nil
+}
+
+// UniqueIDMap 返回外部站点ID
+func (n *NFOMovie) UniqueIDMap() map[string]string {
+	ids := make(map[string]string, len(n.UniqueIDs))
+	for _, id := range n.UniqueIDs {
+		if id.Value == "" {
+			continue
+		}
+		key := id.Type
+		if key == "" {
+			key = "unknown"
+		}
+		ids[key] = id.Value
+	}
+	return ids
+}
+
+// PosterURL 返回海报地址（只使用远程地址）
+func (n *NFOMovie) PosterURL() string {
+	var fallback string
+	for _, thumb := range n.Thumbs {
+		if !isRemoteURL(thumb.Value) {
+			continue
+		}
+		if thumb.Aspect == "poster" {
+			return thumb.Value
+		}
+		if fallback == "" {
+			fallback = thumb.Value
+		}
+	}
+	return fallback
+}
+
+// FanartURL 返回背景图地址（只使用远程地址）
+func (n *NFOMovie) FanartURL() string {
+	for _, thumb := range n.Fanart {
+		if isRemoteURL(thumb.Value) {
+			return thumb.Value
+		}
+	}
+	for _, thumb := range n.Thumbs {
+		if thumb.Aspect == "landscape" && isRemoteURL(thumb.Value) {
+			return thumb.Value
+		}
+	}
+	return ""
+}
+
+// ExtraFanartURLs 返回背景图之外的剧照地址（只使用远程地址）
+func (n *NFOMovie) ExtraFanartURLs() []string {
+	var urls []string
+	for i, thumb := range n.Fanart {
+		if i > 0 && isRemoteURL(thumb.Value) {
+			urls = append(urls, thumb.Value)
+		}
+	}
+	return urls
+}
+
+// isRemoteURL 判断是否为 http(s) 地址
+func isRemoteURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
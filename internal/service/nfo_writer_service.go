@@ -0,0 +1,587 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"net/http"
+	"nsfw-go/internal/crawler"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/repo"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NFO写入的文件类型
+const (
+	NFOFileNFO         = "nfo"
+	NFOFilePoster      = "poster"
+	NFOFileFanart      = "fanart"
+	NFOFileExtraFanart = "extrafanart"
+)
+
+// NFO写入动作
+const (
+	NFOActionCreate    = "create"
+	NFOActionOverwrite = "overwrite"
+	NFOActionSkip      = "skip"
+)
+
+// 元数据来源
+const (
+	NFOSourceCatalog = "catalog" // 影片库
+	NFOSourceCrawler = "crawler" // 在线抓取
+)
+
+var (
+	// ErrLocalMovieNotFound 本地影片不存在
+	ErrLocalMovieNotFound = errors.New("本地影片不存在")
+	// ErrNoMetadata 找不到影片元数据
+	ErrNoMetadata = errors.New("找不到影片元数据")
+)
+
+const (
+	maxArtworkSize = 20 << 20 // 单张图片的最大下载大小
+	posterRatio    = 0.475    // 横版封面右侧为正面海报，约占宽度的 47.5%
+)
+
+// NFOWriteOptions 写入选项
+type NFOWriteOptions struct {
+	DryRun      bool `json:"dry_run"`      // 只返回计划写入的文件，不写入磁盘
+	Overwrite   bool `json:"overwrite"`    // 覆盖已存在的文件
+	SkipArtwork bool `json:"skip_artwork"` // 只写入NFO，不下载图片
+	Scrape      bool `json:"scrape"`       // 影片库中没有元数据时在线抓取
+}
+
+// NFOWriteFile 单个文件的写入结果
+type NFOWriteFile struct {
+	Type   string `json:"type"`
+	Path   string `json:"path"`
+	URL    string `json:"url,omitempty"` // 图片来源地址
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NFOWriteResult 单部影片的写入结果
+type NFOWriteResult struct {
+	LocalMovieID uint           `json:"local_movie_id"`
+	Code         string         `json:"code"`
+	Dir          string         `json:"dir"`
+	Source       string         `json:"source,omitempty"`
+	Files        []NFOWriteFile `json:"files"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// NFOBatchResult 批量写入结果
+type NFOBatchResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Total   int               `json:"total"`
+	Written int               `json:"written"` // 写入（或计划写入）了至少一个文件
+	Skipped int               `json:"skipped"` // 所有文件都已存在
+	Failed  int               `json:"failed"`
+	Results []*NFOWriteResult `json:"results"`
+}
+
+// NFOWriterService NFO与图片写入服务，为本地影片生成 Kodi/Jellyfin 可识别的元数据文件
+type NFOWriterService struct {
+	localMovieRepo repo.LocalMovieRepository
+	movieRepo      repo.MovieRepository
+	crawler        crawler.Crawler // 可选，用于在线抓取元数据
+	rootPaths      map[string]bool // 媒体库根目录，直接位于根目录下的影片按共用目录处理
+	client         *http.Client
+	logService     *LogService
+	batchMu        sync.Mutex // 同一时间只允许一个批量任务
+}
+
+// NewNFOWriterService 创建NFO写入服务
+func NewNFOWriterService(localMovieRepo repo.LocalMovieRepository, movieRepo repo.MovieRepository, logService *LogService) *NFOWriterService {
+	return &NFOWriterService{
+		localMovieRepo: localMovieRepo,
+		movieRepo:      movieRepo,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		logService:     logService,
+	}
+}
+
+// SetCrawler 设置在线抓取使用的爬虫
+func (s *NFOWriterService) SetCrawler(c crawler.Crawler) {
+	s.crawler = c
+}
+
+// SetLibraryRoots 设置媒体库根目录
+func (s *NFOWriterService) SetLibraryRoots(roots []model.MediaRoot) {
+	s.rootPaths = make(map[string]bool, len(roots))
+	for _, root := range roots {
+		s.rootPaths[filepath.Clean(root.Path)] = true
+	}
+}
+
+// WriteLocalMovie 为指定的本地影片写入NFO与图片
+func (s *NFOWriterService) WriteLocalMovie(ctx context.Context, id uint, opts NFOWriteOptions) (*NFOWriteResult, error) {
+	local, err := s.localMovieRepo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLocalMovieNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	movie, source, err := s.resolveMetadata(ctx, local, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.write(ctx, local, movie, source, opts), nil
+}
+
+// WriteMovieData 使用爬虫抓取到的元数据为本地影片写入NFO与图片
+func (s *NFOWriterService) WriteMovieData(ctx context.Context, local *model.LocalMovie, data *crawler.MovieData, opts NFOWriteOptions) *NFOWriteResult {
+	return s.write(ctx, local, movieFromCrawlerData(data), NFOSourceCrawler, opts)
+}
+
+// WriteMissing 为所有没有NFO的本地影片写入NFO与图片，limit 为 0 时不限制数量
+func (s *NFOWriterService) WriteMissing(ctx context.Context, limit int, opts NFOWriteOptions) (*NFOBatchResult, error) {
+	if !s.batchMu.TryLock() {
+		return nil, errors.New("已有批量写入任务正在执行")
+	}
+	defer s.batchMu.Unlock()
+
+	locals, err := s.localMovieRepo.ListWithoutNFO(limit)
+	if err != nil {
+		return nil, fmt.Errorf("获取本地影片失败: %v", err)
+	}
+
+	batch := &NFOBatchResult{DryRun: opts.DryRun, Total: len(locals), Results: []*NFOWriteResult{}}
+	for _, local := range locals {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var result *NFOWriteResult
+		movie, source, err := s.resolveMetadata(ctx, local, opts)
+		if err != nil {
+			result = &NFOWriteResult{LocalMovieID: local.ID, Code: local.Code, Dir: filepath.Dir(local.Path), Error: err.Error()}
+		} else {
+			result = s.write(ctx, local, movie, source, opts)
+		}
+		batch.Results = append(batch.Results, result)
+
+		switch {
+		case result.Error != "":
+			batch.Failed++
+		case result.hasWrites():
+			batch.Written++
+		default:
+			batch.Skipped++
+		}
+	}
+
+	if s.logService != nil && !opts.DryRun {
+		s.logService.LogInfo("scanner", "nfo-write", fmt.Sprintf("批量写入NFO完成，共 %d 部，写入 %d 部，跳过 %d 部，失败 %d 部",
+			batch.Total, batch.Written, batch.Skipped, batch.Failed))
+	}
+	return batch, nil
+}
+
+// resolveMetadata 查找本地影片的元数据：优先使用关联的影片，其次按番号查找，最后在线抓取
+func (s *NFOWriterService) resolveMetadata(ctx context.Context, local *model.LocalMovie, opts NFOWriteOptions) (*model.Movie, string, error) {
+	if local.MovieID != nil {
+		if movie, err := s.movieRepo.GetByID(*local.MovieID); err == nil {
+			return movie, NFOSourceCatalog, nil
+		}
+	}
+	if local.Code == "" {
+		return nil, "", fmt.Errorf("%w: 影片没有番号", ErrNoMetadata)
+	}
+
+	movie, err := s.movieRepo.GetByCode(local.Code)
+	if err == nil {
+		return movie, NFOSourceCatalog, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", err
+	}
+
+	if !opts.Scrape || s.crawler == nil {
+		return nil, "", fmt.Errorf("%w: 影片库中没有番号 %s", ErrNoMetadata, local.Code)
+	}
+	data, err := s.crawler.GetMovieByCode(ctx, local.Code)
+	if err != nil {
+		return nil, "", fmt.Errorf("抓取元数据失败: %v", err)
+	}
+	return movieFromCrawlerData(data), NFOSourceCrawler, nil
+}
+
+// write 生成NFO并下载图片，DryRun 时只返回计划写入的文件
+func (s *NFOWriterService) write(ctx context.Context, local *model.LocalMovie, movie *model.Movie, source string, opts NFOWriteOptions) *NFOWriteResult {
+	targets := s.targetsFor(local)
+	result := &NFOWriteResult{
+		LocalMovieID: local.ID,
+		Code:         movie.Code,
+		Dir:          targets.dir,
+		Source:       source,
+		Files:        []NFOWriteFile{},
+	}
+
+	data, err := encodeNFO(buildNFO(movie))
+	if err != nil {
+		result.Error = fmt.Sprintf("生成NFO失败: %v", err)
+		return result
+	}
+	result.Files = append(result.Files, s.writeFile(NFOWriteFile{Type: NFOFileNFO, Path: targets.nfo}, opts, func() ([]byte, error) {
+		return data, nil
+	}))
+
+	if !opts.SkipArtwork {
+		cover := movie.CoverURL
+		fanart := movie.FanartURL
+		if fanart == "" {
+			fanart = cover
+		}
+		if cover == "" {
+			cover = fanart
+		}
+		if cover != "" {
+			result.Files = append(result.Files, s.writeFile(NFOWriteFile{Type: NFOFilePoster, Path: targets.poster, URL: cover}, opts, func() ([]byte, error) {
+				data, err := s.download(ctx, cover)
+				if err != nil {
+					return nil, err
+				}
+				return posterFromCover(data), nil
+			}))
+		}
+		if fanart != "" {
+			result.Files = append(result.Files, s.writeFile(NFOWriteFile{Type: NFOFileFanart, Path: targets.fanart, URL: fanart}, opts, func() ([]byte, error) {
+				return s.download(ctx, fanart)
+			}))
+		}
+		if targets.extraFanart != "" {
+			for i, url := range movie.SampleImages {
+				url := url
+				path := filepath.Join(targets.extraFanart, "fanart"+strconv.Itoa(i+1)+".jpg")
+				result.Files = append(result.Files, s.writeFile(NFOWriteFile{Type: NFOFileExtraFanart, Path: path, URL: url}, opts, func() ([]byte, error) {
+					return s.download(ctx, url)
+				}))
+			}
+		}
+	}
+
+	for _, file := range result.Files {
+		if file.Error != "" {
+			result.Error = fmt.Sprintf("%s 写入失败: %s", file.Path, file.Error)
+			break
+		}
+	}
+	if s.logService != nil && !opts.DryRun && result.hasWrites() {
+		s.logService.LogInfo("scanner", "nfo-write", fmt.Sprintf("已写入NFO [%s] %s", movie.Code, targets.dir))
+	}
+	return result
+}
+
+// writeFile 按选项写入单个文件，已存在且不覆盖时跳过
+func (s *NFOWriterService) writeFile(file NFOWriteFile, opts NFOWriteOptions, content func() ([]byte, error)) NFOWriteFile {
+	file.Action = NFOActionCreate
+	if _, err := os.Stat(file.Path); err == nil {
+		if !opts.Overwrite {
+			file.Action = NFOActionSkip
+			return file
+		}
+		file.Action = NFOActionOverwrite
+	}
+	if opts.DryRun {
+		return file
+	}
+
+	data, err := content()
+	if err == nil {
+		err = writeFileAtomic(file.Path, data)
+	}
+	if err != nil {
+		file.Error = err.Error()
+	}
+	return file
+}
+
+// download 下载图片
+func (s *NFOWriterService) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载图片失败: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxArtworkSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %v", err)
+	}
+	if len(data) > maxArtworkSize {
+		return nil, errors.New("图片过大")
+	}
+	return data, nil
+}
+
+// hasWrites 是否写入（或计划写入）了至少一个文件
+func (r *NFOWriteResult) hasWrites() bool {
+	for _, file := range r.Files {
+		if file.Action != NFOActionSkip && file.Error == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// nfoTargets 影片的NFO与图片文件路径
+type nfoTargets struct {
+	dir         string
+	nfo         string
+	poster      string
+	fanart      string
+	extraFanart string // 与其他影片共用目录时为空
+}
+
+// targetsFor 计算写入路径：独占目录时使用 movie.nfo、poster.jpg，
+// 与其他影片共用目录时使用与视频同名的 <文件名>.nfo、<文件名>-poster.jpg
+func (s *NFOWriterService) targetsFor(local *model.LocalMovie) nfoTargets {
+	dir := filepath.Dir(local.Path)
+	if !s.rootPaths[filepath.Clean(dir)] && !sharesDirectory(local, dir) {
+		return nfoTargets{
+			dir:         dir,
+			nfo:         filepath.Join(dir, "movie.nfo"),
+			poster:      filepath.Join(dir, "poster.jpg"),
+			fanart:      filepath.Join(dir, "fanart.jpg"),
+			extraFanart: filepath.Join(dir, "extrafanart"),
+		}
+	}
+	base := strings.TrimSuffix(filepath.Base(local.Path), filepath.Ext(local.Path))
+	return nfoTargets{
+		dir:    dir,
+		nfo:    filepath.Join(dir, base+".nfo"),
+		poster: filepath.Join(dir, base+"-poster.jpg"),
+		fanart: filepath.Join(dir, base+"-fanart.jpg"),
+	}
+}
+
+// sharesDirectory 判断目录中（含子目录）是否还有其他影片的视频文件
+func sharesDirectory(local *model.LocalMovie, dir string) bool {
+	own := map[string]bool{local.Path: true}
+	for _, part := range local.Parts {
+		own[part.Path] = true
+	}
+	exts := make(map[string]bool, len(defaultVideoExts))
+	for _, ext := range defaultVideoExts {
+		exts[ext] = true
+	}
+	extra := func(name string) bool {
+		return strings.Contains(name, "sample") || strings.Contains(name, "trailer") || strings.Contains(name, "preview")
+	}
+
+	shared := false
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := strings.ToLower(entry.Name())
+		if entry.IsDir() {
+			if path != dir && extra(name) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !exts[filepath.Ext(name)] || extra(name) || own[path] {
+			return nil
+		}
+		shared = true
+		return filepath.SkipAll
+	})
+	return shared || err != nil
+}
+
+// buildNFO 将影片元数据转换为NFO结构
+func buildNFO(movie *model.Movie) *NFOMovie {
+	nfo := &NFOMovie{
+		Title:         movie.Title,
+		OriginalTitle: movie.OriginalTitle,
+		SortTitle:     movie.Code,
+		Code:          movie.Code,
+		Plot:          movie.Description,
+		Outline:       movie.Description,
+		Trailer:       movie.TrailerURL,
+	}
+	if nfo.Title == "" {
+		nfo.Title = movie.Code
+	}
+	if movie.ReleaseDate != nil && !movie.ReleaseDate.IsZero() {
+		date := movie.ReleaseDate.Format("2006-01-02")
+		nfo.Year = strconv.Itoa(movie.ReleaseDate.Year())
+		nfo.Premiered = date
+		nfo.ReleaseDate = date
+	}
+	if movie.Duration > 0 {
+		nfo.Runtime = strconv.Itoa(movie.Duration)
+	}
+	if movie.Rating > 0 {
+		rating := math.Round(float64(movie.Rating)*10) / 10
+		nfo.Rating = strconv.FormatFloat(rating, 'f', 1, 64)
+		nfo.Ratings = []NFORating{{Name: "default", Max: 10, Default: true, Value: rating}}
+	}
+	if movie.Studio != nil && movie.Studio.Name != "" {
+		nfo.Studios = []string{movie.Studio.Name}
+		nfo.Maker = movie.Studio.Name
+	}
+	if movie.Series != nil && movie.Series.Name != "" {
+		nfo.Set = &NFOSet{Name: movie.Series.Name}
+	}
+	for _, tag := range movie.Tags {
+		if tag.Category == model.TagCategoryGenre || tag.Category == "" {
+			nfo.Genres = append(nfo.Genres, tag.Name)
+		} else {
+			nfo.Tags = append(nfo.Tags, tag.Name)
+		}
+	}
+	for i, actress := range movie.Actresses {
+		nfo.Actors = append(nfo.Actors, NFOActor{Name: actress.Name, Order: i, Thumb: actress.AvatarURL})
+	}
+
+	nfo.UniqueIDs = append(nfo.UniqueIDs, NFOUniqueID{Type: "num", Default: true, Value: movie.Code})
+	keys := make([]string, 0, len(movie.UniqueIDs))
+	for key, value := range movie.UniqueIDs {
+		if key != "num" && value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		nfo.UniqueIDs = append(nfo.UniqueIDs, NFOUniqueID{Type: key, Value: movie.UniqueIDs[key]})
+	}
+
+	if movie.CoverURL != "" {
+		nfo.Thumbs = append(nfo.Thumbs, NFOThumb{Aspect: "poster", Value: movie.CoverURL})
+	}
+	fanart := movie.FanartURL
+	if fanart == "" {
+		fanart = movie.CoverURL
+	}
+	if fanart != "" {
+		nfo.Fanart = append(nfo.Fanart, NFOThumb{Value: fanart})
+		for _, url := range movie.SampleImages {
+			nfo.Fanart = append(nfo.Fanart, NFOThumb{Value: url})
+		}
+	}
+	return nfo
+}
+
+// encodeNFO 序列化NFO，带 XML 声明
+func encodeNFO(nfo *NFOMovie) ([]byte, error) {
+	data, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	buf.Write(data)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// movieFromCrawlerData 将爬虫数据转换为影片模型（不入库）
+func movieFromCrawlerData(data *crawler.MovieData) *model.Movie {
+	movie := &model.Movie{
+		Code:         strings.ToUpper(data.Code),
+		Title:        data.Title,
+		Duration:     data.Duration,
+		Description:  data.Description,
+		Rating:       data.Rating,
+		CoverURL:     data.CoverURL,
+		FanartURL:    data.FanartURL,
+		TrailerURL:   data.TrailerURL,
+		SampleImages: data.SampleImages,
+	}
+	if !data.ReleaseDate.IsZero() {
+		releaseDate := data.ReleaseDate
+		movie.ReleaseDate = &releaseDate
+	}
+	if data.Studio != nil {
+		movie.Studio = &model.Studio{Name: data.Studio.Name}
+	}
+	if data.Series != nil {
+		movie.Series = &model.Series{Name: data.Series.Name}
+	}
+	for _, actress := range data.Actresses {
+		movie.Actresses = append(movie.Actresses, model.Actress{Name: actress.Name, AvatarURL: actress.AvatarURL})
+	}
+	for _, tag := range data.Tags {
+		movie.Tags = append(movie.Tags, model.Tag{Name: tag.Name, Category: tag.Category})
+	}
+	return movie
+}
+
+// posterFromCover 从横版封面中裁剪右侧的正面海报，无法解码或已是竖版时原样返回
+func posterFromCover(data []byte) []byte {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() <= bounds.Dy() {
+		return data
+	}
+	sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return data
+	}
+
+	width := int(float64(bounds.Dx()) * posterRatio)
+	poster := sub.SubImage(image.Rect(bounds.Max.X-width, bounds.Min.Y, bounds.Max.X, bounds.Max.Y))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, poster, &jpeg.Options{Quality: 95}); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免媒体服务器读到不完整的文件
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,952 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/repo"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// DefaultOrganizeTemplate 默认整理路径模板
+const DefaultOrganizeTemplate = "{actress}/[{code}] {title}/{code}.{ext}"
+
+const (
+	maxOrganizeSegment = 200 // 路径中单个目录或文件名的最大字节数
+	maxOrganizeTitle   = 80  // 标题的最大字符数
+)
+
+var (
+	// ErrOrganizeInProgress 已有整理任务正在执行
+	ErrOrganizeInProgress = errors.New("已有整理或撤销任务正在执行")
+	// ErrOrganizeRunNotFound 整理记录不存在
+	ErrOrganizeRunNotFound = errors.New("整理记录不存在")
+	// ErrOrganizeAlreadyUndone 整理已撤销
+	ErrOrganizeAlreadyUndone = errors.New("整理已撤销")
+	// ErrInvalidOrganizeTemplate 整理模板无效
+	ErrInvalidOrganizeTemplate = errors.New("整理模板无效")
+	// ErrOrganizeRootNotFound 媒体库根目录不存在或未启用
+	ErrOrganizeRootNotFound = errors.New("媒体库根目录不存在或未启用")
+)
+
+// 模板占位符
+var organizePlaceholderPattern = regexp.MustCompile(`\{([a-z]+)\}`)
+
+var organizePlaceholders = map[string]bool{
+	"actress": true,
+	"code":    true,
+	"title":   true,
+	"year":    true,
+	"studio":  true,
+	"ext":     true,
+}
+
+// OrganizeRequest 整理请求
+type OrganizeRequest struct {
+	Root     string `json:"root"`     // 媒体库根目录名称，为空时整理所有根目录
+	Template string `json:"template"` // 路径模板，为空时使用配置的模板
+	IDs      []uint `json:"ids"`      // 只整理指定的本地影片
+	Limit    int    `json:"limit"`    // 最多整理的影片数，0 表示不限制
+}
+
+// OrganizeOperation 单个文件操作
+type OrganizeOperation struct {
+	Op   string `json:"op"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+// OrganizePlanItem 单部影片的整理计划
+type OrganizePlanItem struct {
+	LocalMovieID uint                `json:"local_movie_id"`
+	Code         string              `json:"code"`
+	Root         string              `json:"root"`
+	From         string              `json:"from"` // 当前视频路径
+	To           string              `json:"to"`   // 整理后的视频路径
+	Operations   []OrganizeOperation `json:"operations,omitempty"`
+	Skipped      string              `json:"skipped,omitempty"` // 跳过原因
+
+	rootPath string
+	srcDir   string
+}
+
+// OrganizePlan 整理计划
+type OrganizePlan struct {
+	Template  string              `json:"template"`
+	Total     int                 `json:"total"`     // 检查的影片数
+	Changes   int                 `json:"changes"`   // 需要整理的影片数
+	Unchanged int                 `json:"unchanged"` // 已符合模板的影片数
+	Skipped   int                 `json:"skipped"`   // 无法整理的影片数（番号缺失、目标已存在等）
+	Items     []*OrganizePlanItem `json:"items"`     // 需要整理与被跳过的影片
+}
+
+// OrganizerService 媒体库整理服务，按路径模板重命名并移动影片及其NFO、图片与字幕
+// 执行时每一步文件操作都先写入日志，可按日志撤销
+type OrganizerService struct {
+	localMovieRepo repo.LocalMovieRepository
+	movieRepo      repo.MovieRepository
+	organizeRepo   repo.OrganizeRepository
+	scannerService *ScannerService
+	logService     *LogService
+	template       *organizeTemplate
+	mu             sync.Mutex // 同一时间只允许一个整理或撤销任务
+}
+
+// NewOrganizerService 创建媒体库整理服务
+func NewOrganizerService(
+	localMovieRepo repo.LocalMovieRepository,
+	movieRepo repo.MovieRepository,
+	organizeRepo repo.OrganizeRepository,
+	scannerService *ScannerService,
+	logService *LogService,
+) *OrganizerService {
+	tpl, _ := parseOrganizeTemplate(DefaultOrganizeTemplate)
+	return &OrganizerService{
+		localMovieRepo: localMovieRepo,
+		movieRepo:      movieRepo,
+		organizeRepo:   organizeRepo,
+		scannerService: scannerService,
+		logService:     logService,
+		template:       tpl,
+	}
+}
+
+// SetTemplate 设置默认的整理路径模板
+func (s *OrganizerService) SetTemplate(spec string) error {
+	tpl, err := parseOrganizeTemplate(spec)
+	if err != nil {
+		return err
+	}
+	s.template = tpl
+	return nil
+}
+
+// Template 返回默认的整理路径模板
+func (s *OrganizerService) Template() string {
+	return s.template.spec
+}
+
+// Plan 生成整理计划（不修改任何文件）
+func (s *OrganizerService) Plan(req OrganizeRequest) (*OrganizePlan, error) {
+	tpl := s.template
+	if req.Template != "" {
+		var err error
+		if tpl, err = parseOrganizeTemplate(req.Template); err != nil {
+			return nil, err
+		}
+	}
+
+	var roots []model.MediaRoot
+	for _, root := range s.scannerService.Roots() {
+		if req.Root == "" || root.Name == req.Root {
+			roots = append(roots, root)
+		}
+	}
+	if req.Root != "" && len(roots) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrOrganizeRootNotFound, req.Root)
+	}
+
+	ids := make(map[uint]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		ids[id] = true
+	}
+
+	plan := &OrganizePlan{Template: tpl.spec, Items: []*OrganizePlanItem{}}
+	p := &organizePlanner{
+		service: s,
+		tpl:     tpl,
+		claimed: make(map[string]bool),
+		created: make(map[string]bool),
+	}
+	for _, root := range roots {
+		rootPath := filepath.Clean(root.Path)
+		locals, err := s.localMovieRepo.ListByRootWithDeleted(root.Name, rootPath)
+		if err != nil {
+			return nil, fmt.Errorf("获取本地影片失败: %v", err)
+		}
+		sort.Slice(locals, func(i, j int) bool { return locals[i].Path < locals[j].Path })
+
+		for _, local := range locals {
+			if local.DeletedAt.Valid || (len(ids) > 0 && !ids[local.ID]) {
+				continue
+			}
+			if req.Limit > 0 && plan.Changes >= req.Limit {
+				return plan, nil
+			}
+
+			plan.Total++
+			item := p.planMovie(root.Name, rootPath, local)
+			switch {
+			case item.Skipped != "":
+				plan.Skipped++
+			case len(item.Operations) == 0:
+				plan.Unchanged++
+				continue
+			default:
+				plan.Changes++
+			}
+			plan.Items = append(plan.Items, item)
+		}
+	}
+	return plan, nil
+}
+
+// Execute 按计划整理文件并更新数据库中的路径，返回整理记录
+// 执行期间暂停媒体库扫描，完成后重新扫描以刷新图片与分组信息
+func (s *OrganizerService) Execute(req OrganizeRequest) (*model.OrganizeRun, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrOrganizeInProgress
+	}
+	defer s.mu.Unlock()
+
+	var run *model.OrganizeRun
+	err := s.scannerService.Exclusive(func() error {
+		// 在扫描锁内生成计划，避免使用过期的文件列表
+		plan, err := s.Plan(req)
+		if err != nil {
+			return err
+		}
+
+		run = &model.OrganizeRun{
+			Root:      req.Root,
+			Template:  plan.Template,
+			Status:    model.OrganizeStatusRunning,
+			StartedAt: time.Now(),
+		}
+		if err := s.organizeRepo.CreateRun(run); err != nil {
+			return fmt.Errorf("保存整理记录失败: %v", err)
+		}
+
+		seq := 0
+		var errs []string
+		for _, item := range plan.Items {
+			if item.Skipped != "" {
+				continue
+			}
+			done, err := s.executeItem(run.ID, item, &seq)
+			run.Operations += len(done)
+			if err != nil {
+				run.Failed++
+				errs = append(errs, fmt.Sprintf("%s: %v", item.From, err))
+			} else {
+				run.Movies++
+			}
+			if len(done) > 0 {
+				s.relocate(item.LocalMovieID, done)
+			}
+		}
+
+		now := time.Now()
+		run.FinishedAt = &now
+		run.Status = model.OrganizeStatusCompleted
+		if len(errs) > 0 {
+			run.Status = model.OrganizeStatusFailed
+			run.Error = strings.Join(errs, "\n")
+		}
+		if err := s.organizeRepo.UpdateRun(run); err != nil {
+			return fmt.Errorf("保存整理记录失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.logService != nil {
+		s.logService.LogInfo("scanner", "media-organize", fmt.Sprintf("整理 #%d 完成，整理 %d 部，失败 %d 部，文件操作 %d 项",
+			run.ID, run.Movies, run.Failed, run.Operations))
+	}
+	if run.Operations > 0 {
+		s.scannerService.startBackgroundScan(req.Root, model.ScanTriggerOrganize)
+	}
+	return s.organizeRepo.GetRun(run.ID)
+}
+
+// Undo 按日志倒序撤销整理，已撤销的条目会被跳过，部分失败时可再次撤销
+func (s *OrganizerService) Undo(id uint) (*model.OrganizeRun, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrOrganizeInProgress
+	}
+	defer s.mu.Unlock()
+
+	run, err := s.organizeRepo.GetRun(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizeRunNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if run.Status == model.OrganizeStatusUndone {
+		return nil, ErrOrganizeAlreadyUndone
+	}
+
+	err = s.scannerService.Exclusive(func() error {
+		var errs []string
+		reverted := make(map[uint][]model.OrganizeJournalEntry)
+		for i := len(run.Entries) - 1; i >= 0; i-- {
+			entry := &run.Entries[i]
+			if !needsUndo(entry) {
+				continue
+			}
+			if err := undoOrganizeOp(entry); err != nil {
+				entry.Error = err.Error()
+				errs = append(errs, fmt.Sprintf("%s: %v", entry.To, err))
+			} else {
+				entry.Status = model.OrganizeEntryUndone
+				entry.Error = ""
+				if entry.Op == model.OrganizeOpMove {
+					// 撤销的移动操作反向应用到数据库路径
+					reverted[entry.LocalMovieID] = append(reverted[entry.LocalMovieID], model.OrganizeJournalEntry{
+						Op: entry.Op, From: entry.To, To: entry.From,
+					})
+				}
+			}
+			if err := s.organizeRepo.UpdateEntry(entry); err != nil {
+				errs = append(errs, fmt.Sprintf("更新整理日志失败: %v", err))
+			}
+		}
+
+		for localID, entries := range reverted {
+			s.relocate(localID, entries)
+		}
+
+		if len(errs) > 0 {
+			run.Error = "撤销未完成:\n" + strings.Join(errs, "\n")
+		} else {
+			now := time.Now()
+			run.Status = model.OrganizeStatusUndone
+			run.UndoneAt = &now
+			run.Error = ""
+		}
+		return s.organizeRepo.UpdateRun(run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.logService != nil {
+		if run.Status == model.OrganizeStatusUndone {
+			s.logService.LogInfo("scanner", "media-organize", fmt.Sprintf("已撤销整理 #%d", run.ID))
+		} else {
+			s.logService.LogWarn("scanner", "media-organize", fmt.Sprintf("撤销整理 #%d 未完成: %s", run.ID, run.Error))
+		}
+	}
+	s.scannerService.startBackgroundScan(run.Root, model.ScanTriggerOrganize)
+	return s.organizeRepo.GetRun(run.ID)
+}
+
+// ListRuns 分页获取整理记录
+func (s *OrganizerService) ListRuns(offset, limit int) ([]*model.OrganizeRun, int64, error) {
+	return s.organizeRepo.ListRuns(offset, limit)
+}
+
+// GetRun 获取整理记录详情（含日志）
+func (s *OrganizerService) GetRun(id uint) (*model.OrganizeRun, error) {
+	run, err := s.organizeRepo.GetRun(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizeRunNotFound
+	}
+	return run, err
+}
+
+// executeItem 依次执行单部影片的文件操作，每一步执行前写入日志，返回已完成的操作
+func (s *OrganizerService) executeItem(runID uint, item *OrganizePlanItem, seq *int) ([]model.OrganizeJournalEntry, error) {
+	var done []model.OrganizeJournalEntry
+	apply := func(op OrganizeOperation) error {
+		*seq++
+		entry := &model.OrganizeJournalEntry{
+			RunID:        runID,
+			Seq:          *seq,
+			LocalMovieID: item.LocalMovieID,
+			Op:           op.Op,
+			From:         op.From,
+			To:           op.To,
+			Status:       model.OrganizeEntryPending,
+		}
+		if err := s.organizeRepo.CreateEntry(entry); err != nil {
+			return fmt.Errorf("写入整理日志失败: %v", err)
+		}
+
+		err := applyOrganizeOp(op)
+		if err != nil {
+			entry.Status = model.OrganizeEntryFailed
+			entry.Error = err.Error()
+		} else {
+			entry.Status = model.OrganizeEntryDone
+			done = append(done, *entry)
+		}
+		if updateErr := s.organizeRepo.UpdateEntry(entry); updateErr != nil && err == nil {
+			err = fmt.Errorf("更新整理日志失败: %v", updateErr)
+		}
+		return err
+	}
+
+	for _, op := range item.Operations {
+		if err := apply(op); err != nil {
+			return done, err
+		}
+	}
+
+	// 删除整理后变空的原目录，直到根目录为止
+	for dir := item.srcDir; dir != item.rootPath && isWithin(dir, item.rootPath); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		if err := apply(OrganizeOperation{Op: model.OrganizeOpRmdir, To: dir}); err != nil {
+			break
+		}
+	}
+	return done, nil
+}
+
+// relocate 将已完成的移动操作按顺序应用到本地影片的各个路径并保存
+func (s *OrganizerService) relocate(localID uint, entries []model.OrganizeJournalEntry) {
+	local, err := s.localMovieRepo.GetByID(localID)
+	if err != nil {
+		s.logWarn(fmt.Sprintf("更新本地影片路径失败 [#%d]: %v", localID, err))
+		return
+	}
+
+	mapPath := func(p string) string {
+		if p == "" {
+			return p
+		}
+		for _, entry := range entries {
+			if entry.Op != model.OrganizeOpMove {
+				continue
+			}
+			if p == entry.From {
+				p = entry.To
+			} else if rel, ok := strings.CutPrefix(p, entry.From+string(filepath.Separator)); ok {
+				p = filepath.Join(entry.To, rel)
+			}
+		}
+		return p
+	}
+
+	local.Path = mapPath(local.Path)
+	local.NFOPath = mapPath(local.NFOPath)
+	local.FanartPath = mapPath(local.FanartPath)
+	for i := range local.Parts {
+		local.Parts[i].Path = mapPath(local.Parts[i].Path)
+	}
+	for i := range local.Subtitles {
+		local.Subtitles[i].Path = mapPath(local.Subtitles[i].Path)
+	}
+	if err := s.localMovieRepo.Update(local); err != nil {
+		s.logWarn(fmt.Sprintf("更新本地影片路径失败 [%s]: %v", local.Path, err))
+		return
+	}
+	if local.MovieID != nil && s.movieRepo != nil {
+		if err := s.movieRepo.SetLocalPath(*local.MovieID, local.Path); err != nil {
+			s.logWarn(fmt.Sprintf("更新影片本地路径失败 [%s]: %v", local.Path, err))
+		}
+	}
+}
+
+// hasOtherMovies 判断目录下（含子目录）是否有其他已记录的本地影片，包括已软删除的记录
+func (s *OrganizerService) hasOtherMovies(local *model.LocalMovie, dir string) bool {
+	movies, err := s.localMovieRepo.ListUnderPathWithDeleted(dir)
+	if err != nil {
+		return true
+	}
+	for _, movie := range movies {
+		if movie.ID != local.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// logWarn 记录整理警告
+func (s *OrganizerService) logWarn(message string) {
+	if s.logService != nil {
+		s.logService.LogWarn("scanner", "media-organize", message)
+	}
+}
+
+// organizePlanner 生成整理计划，记录计划中将被占用的路径与将创建的目录，避免多部影片互相冲突
+type organizePlanner struct {
+	service *OrganizerService
+	tpl     *organizeTemplate
+	claimed map[string]bool // 计划中的目标路径
+	created map[string]bool // 计划中将创建的目录
+}
+
+// videoStem 目录中视频文件名（不含扩展名）与整理后的名称，newStem 为空表示属于其他影片
+type videoStem struct {
+	stem    string
+	newStem string
+}
+
+// planMovie 计算单部影片的整理操作
+// 影片独占目录时整体移动目录后重命名其中的视频与同名附属文件；与其他影片共用目录时只移动视频与同名附属文件
+func (p *organizePlanner) planMovie(rootName, rootPath string, local *model.LocalMovie) *OrganizePlanItem {
+	item := &OrganizePlanItem{
+		LocalMovieID: local.ID,
+		Code:         local.Code,
+		Root:         rootName,
+		From:         local.Path,
+		rootPath:     rootPath,
+		srcDir:       filepath.Dir(local.Path),
+	}
+	if !isWithin(local.Path, rootPath) {
+		item.Skipped = "影片不在根目录下"
+		return item
+	}
+
+	values := p.service.templateValues(local)
+	if values["code"] == "" {
+		item.Skipped = "无法识别番号"
+		return item
+	}
+	item.Code = values["code"]
+	dirs, base := p.tpl.render(values)
+	targetDir := filepath.Join(append([]string{rootPath}, dirs...)...)
+
+	// 视频文件的新名称：<模板文件名>[-版本][-C][-cdN].<扩展名>
+	suffix := ""
+	if local.Version != "" {
+		suffix += "-" + sanitizeSegment(local.Version)
+	}
+	if local.HardSubtitle {
+		suffix += "-C"
+	}
+	videos := []string{local.Path}
+	if len(local.Parts) > 1 {
+		parts := append([]model.LocalMoviePart(nil), local.Parts...)
+		sort.Slice(parts, func(i, j int) bool { return parts[i].Part < parts[j].Part })
+		videos = videos[:0]
+		for _, part := range parts {
+			videos = append(videos, part.Path)
+		}
+	}
+
+	var stems []videoStem
+	renames := make(map[string]string) // 原文件名 -> 新文件名
+	for i, video := range videos {
+		name := filepath.Base(video)
+		ext := filepath.Ext(name)
+		newStem := base + suffix
+		if len(videos) > 1 {
+			newStem += "-cd" + strconv.Itoa(i+1)
+			// 附属文件常以去掉分段后缀的名称命名
+			if pf := parsePartFile(video); pf.base != pf.stem {
+				stems = append(stems, videoStem{stem: pf.base, newStem: base + suffix})
+			}
+		}
+		stems = append(stems, videoStem{stem: strings.TrimSuffix(name, ext), newStem: newStem})
+		renames[name] = newStem + strings.ToLower(ext)
+	}
+	item.To = filepath.Join(targetDir, renames[filepath.Base(local.Path)])
+
+	entries, err := os.ReadDir(item.srcDir)
+	if err != nil {
+		item.Skipped = fmt.Sprintf("读取目录失败: %v", err)
+		return item
+	}
+	// 目录中其他影片的视频文件，用于区分同名前缀的附属文件归属
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && isVideoExt(name) && renames[name] == "" {
+			stems = append(stems, videoStem{stem: strings.TrimSuffix(name, filepath.Ext(name))})
+		}
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || renames[name] != "" {
+			continue
+		}
+		if newName, ok := renameSidecar(name, stems); ok {
+			renames[name] = newName
+		}
+	}
+
+	// 目录下（含子目录）还有其他影片时不能整体移动，否则会把其他影片一起带走
+	dedicated := item.srcDir != rootPath && !sharesDirectory(local, item.srcDir) && !p.service.hasOtherMovies(local, item.srcDir)
+	var ops []OrganizeOperation
+	if dedicated && targetDir != item.srcDir && !p.exists(targetDir) &&
+		!isWithin(targetDir, item.srcDir) && !isWithin(item.srcDir, targetDir) {
+		// 整体移动目录，再在新目录中重命名
+		ops = append(ops, p.mkdirs(filepath.Dir(targetDir), rootPath)...)
+		ops = append(ops, OrganizeOperation{Op: model.OrganizeOpMove, From: item.srcDir, To: targetDir})
+		p.claimed[targetDir] = true
+
+		remaining := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			remaining[entry.Name()] = true
+		}
+		for _, name := range sortedKeys(renames) {
+			newName := renames[name]
+			if newName == name {
+				continue
+			}
+			if remaining[newName] && renames[newName] == "" {
+				item.Skipped = fmt.Sprintf("目标文件已存在: %s", filepath.Join(targetDir, newName))
+				return item
+			}
+			ops = append(ops, OrganizeOperation{Op: model.OrganizeOpMove, From: filepath.Join(targetDir, name), To: filepath.Join(targetDir, newName)})
+		}
+		item.Operations = ops
+		return item
+	}
+
+	// 逐个移动文件：视频与同名附属文件，独占目录时还包括目录中的其他文件
+	if targetDir != item.srcDir {
+		ops = append(ops, p.mkdirs(targetDir, rootPath)...)
+	}
+	moves := make(map[string]string)
+	for name, newName := range renames {
+		moves[filepath.Join(item.srcDir, name)] = filepath.Join(targetDir, newName)
+	}
+	if dedicated && targetDir != item.srcDir {
+		for _, entry := range entries {
+			from := filepath.Join(item.srcDir, entry.Name())
+			to := filepath.Join(targetDir, entry.Name())
+			if _, ok := moves[from]; ok || p.exists(to) {
+				// 与目标目录中的文件同名时保留在原目录
+				continue
+			}
+			moves[from] = to
+		}
+	}
+	// 不在影片目录中的字幕（如 Subs 子目录）在共用目录时单独移动
+	if !dedicated {
+		for _, subtitle := range local.Subtitles {
+			if filepath.Dir(subtitle.Path) == item.srcDir {
+				continue
+			}
+			name := filepath.Base(subtitle.Path)
+			if newName, ok := renameSidecar(name, stems); ok {
+				name = newName
+			}
+			moves[subtitle.Path] = filepath.Join(targetDir, name)
+		}
+	}
+
+	for _, from := range sortedKeys(moves) {
+		to := moves[from]
+		if to == from {
+			continue
+		}
+		if p.claimed[to] || (p.exists(to) && !sameFile(from, to)) {
+			item.Skipped = fmt.Sprintf("目标文件已存在: %s", to)
+			return item
+		}
+		ops = append(ops, OrganizeOperation{Op: model.OrganizeOpMove, From: from, To: to})
+	}
+	if len(ops) == 0 || ops[len(ops)-1].Op != model.OrganizeOpMove {
+		// 只有建目录操作说明文件已在正确位置
+		for _, op := range ops {
+			delete(p.created, op.To)
+		}
+		return item
+	}
+	for _, op := range ops {
+		if op.Op == model.OrganizeOpMove {
+			p.claimed[op.To] = true
+		}
+	}
+	item.Operations = ops
+	return item
+}
+
+// exists 判断路径在计划执行后是否存在
+func (p *organizePlanner) exists(path string) bool {
+	if p.claimed[path] || p.created[path] {
+		return true
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// mkdirs 生成创建目录及其缺失的上级目录的操作
+func (p *organizePlanner) mkdirs(dir, rootPath string) []OrganizeOperation {
+	var missing []string
+	for d := dir; d != rootPath && isWithin(d, rootPath) && !p.exists(d); d = filepath.Dir(d) {
+		missing = append(missing, d)
+	}
+	ops := make([]OrganizeOperation, 0, len(missing))
+	for i := len(missing) - 1; i >= 0; i-- {
+		p.created[missing[i]] = true
+		ops = append(ops, OrganizeOperation{Op: model.OrganizeOpMkdir, To: missing[i]})
+	}
+	return ops
+}
+
+// templateValues 计算模板占位符的值：优先使用关联影片的元数据，其次使用扫描时识别的信息
+func (s *OrganizerService) templateValues(local *model.LocalMovie) map[string]string {
+	values := map[string]string{
+		"code":    strings.ToUpper(local.Code),
+		"title":   local.Title,
+		"actress": local.Actress,
+	}
+	if values["code"] == "" {
+		name := filepath.Base(local.Path)
+		code, title := s.scannerService.extractCodeAndTitle("", strings.TrimSuffix(name, filepath.Ext(name)))
+		values["code"], values["title"] = code, title
+	}
+
+	if local.MovieID != nil && s.movieRepo != nil {
+		if movie, err := s.movieRepo.GetByID(*local.MovieID); err == nil {
+			applyMovieValues(values, movie)
+		}
+	}
+	return finishTemplateValues(values)
+}
+
+// applyMovieValues 使用影片元数据填充模板占位符
+func applyMovieValues(values map[string]string, movie *model.Movie) {
+	if movie.Title != "" {
+		values["title"] = movie.Title
+	}
+	if len(movie.Actresses) > 0 {
+		values["actress"] = movie.Actresses[0].Name
+	}
+	if movie.Studio != nil {
+		values["studio"] = movie.Studio.Name
+	}
+	if movie.ReleaseDate != nil && !movie.ReleaseDate.IsZero() {
+		values["year"] = strconv.Itoa(movie.ReleaseDate.Year())
+	}
+}
+
+// finishTemplateValues 截断标题并为缺失的占位符填充默认值
+func finishTemplateValues(values map[string]string) map[string]string {
+	values["title"] = truncateRunes(stripCodePrefix(values["title"], values["code"]), maxOrganizeTitle)
+	if values["title"] == "" {
+		values["title"] = values["code"]
+	}
+	if values["actress"] == "" {
+		values["actress"] = "未知演员"
+	}
+	if values["studio"] == "" {
+		values["studio"] = "未知制作商"
+	}
+	if values["year"] == "" {
+		values["year"] = "未知年份"
+	}
+	return values
+}
+
+// organizeTemplate 解析后的路径模板，最后一段为文件名且必须以 .{ext} 结尾
+type organizeTemplate struct {
+	spec string
+	dirs []string
+	file string // 不含 .{ext}
+}
+
+// parseOrganizeTemplate 解析并校验路径模板
+func parseOrganizeTemplate(spec string) (*organizeTemplate, error) {
+	spec = strings.Trim(strings.TrimSpace(strings.ReplaceAll(spec, "\\", "/")), "/")
+	if spec == "" {
+		return nil, fmt.Errorf("%w: 模板不能为空", ErrInvalidOrganizeTemplate)
+	}
+	for _, m := range organizePlaceholderPattern.FindAllStringSubmatch(spec, -1) {
+		if !organizePlaceholders[m[1]] {
+			return nil, fmt.Errorf("%w: 未知占位符 {%s}", ErrInvalidOrganizeTemplate, m[1])
+		}
+	}
+	if !strings.Contains(spec, "{code}") {
+		return nil, fmt.Errorf("%w: 必须包含 {code}", ErrInvalidOrganizeTemplate)
+	}
+
+	segments := strings.Split(spec, "/")
+	for _, segment := range segments {
+		if strings.TrimSpace(segment) == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("%w: 包含空路径或 . ..", ErrInvalidOrganizeTemplate)
+		}
+	}
+	file := segments[len(segments)-1]
+	if !strings.HasSuffix(file, ".{ext}") || strings.Count(spec, "{ext}") != 1 {
+		return nil, fmt.Errorf("%w: 文件名必须以 .{ext} 结尾", ErrInvalidOrganizeTemplate)
+	}
+	return &organizeTemplate{
+		spec: spec,
+		dirs: segments[:len(segments)-1],
+		file: strings.TrimSuffix(file, ".{ext}"),
+	}, nil
+}
+
+// render 替换占位符，返回目录各级名称与文件名（不含扩展名）
+func (t *organizeTemplate) render(values map[string]string) ([]string, string) {
+	replace := func(segment string) string {
+		return sanitizeSegment(organizePlaceholderPattern.ReplaceAllStringFunc(segment, func(m string) string {
+			return values[m[1:len(m)-1]]
+		}))
+	}
+	dirs := make([]string, len(t.dirs))
+	for i, dir := range t.dirs {
+		dirs[i] = replace(dir)
+	}
+	return dirs, replace(t.file)
+}
+
+// targetPath 按默认模板计算影片在根目录下的视频路径，values 需包含 ext
+func (s *OrganizerService) targetPath(rootPath string, values map[string]string) string {
+	dirs, file := s.template.render(values)
+	return filepath.Join(append(append([]string{rootPath}, dirs...), file+"."+values["ext"])...)
+}
+
+// sanitizeSegment 替换文件名中的非法字符，合并空白并限制长度
+func sanitizeSegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxOrganizeSegment {
+		s = s[:maxOrganizeSegment]
+		for !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+	}
+	s = strings.TrimRight(s, ". ")
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// stripCodePrefix 去掉标题开头的番号，如 "[ABC-123] 标题"、"ABC-123 标题"
+func stripCodePrefix(title, code string) string {
+	title = strings.TrimSpace(title)
+	if code == "" {
+		return title
+	}
+	for _, prefix := range []string{"[" + code + "]", code} {
+		if len(title) >= len(prefix) && strings.EqualFold(title[:len(prefix)], prefix) {
+			return strings.TrimLeft(title[len(prefix):], " -_.")
+		}
+	}
+	return title
+}
+
+// renameSidecar 按最长匹配的视频文件名判断附属文件的归属，属于当前影片时返回新文件名
+func renameSidecar(name string, stems []videoStem) (string, bool) {
+	var best *videoStem
+	for i := range stems {
+		stem := stems[i].stem
+		if len(name) <= len(stem) || !strings.EqualFold(name[:len(stem)], stem) {
+			continue
+		}
+		// 文件名在番号之后必须是分隔符，避免 ABC-123 匹配 ABC-1234
+		if c := name[len(stem)]; c != '.' && c != '-' && c != '_' && c != ' ' {
+			continue
+		}
+		if best == nil || len(stem) > len(best.stem) {
+			best = &stems[i]
+		}
+	}
+	if best == nil || best.newStem == "" {
+		return "", false
+	}
+	return best.newStem + name[len(best.stem):], true
+}
+
+// applyOrganizeOp 执行单个文件操作
+func applyOrganizeOp(op OrganizeOperation) error {
+	switch op.Op {
+	case model.OrganizeOpMkdir:
+		return os.Mkdir(op.To, 0755)
+	case model.OrganizeOpMove:
+		if _, err := os.Lstat(op.To); err == nil && !sameFile(op.From, op.To) {
+			return fmt.Errorf("目标已存在: %s", op.To)
+		}
+		return os.Rename(op.From, op.To)
+	case model.OrganizeOpRmdir:
+		return os.Remove(op.To)
+	}
+	return fmt.Errorf("未知的整理操作: %s", op.Op)
+}
+
+// needsUndo 判断日志条目是否需要撤销；服务中断时停留在 pending 的移动操作按文件实际位置判断
+func needsUndo(entry *model.OrganizeJournalEntry) bool {
+	switch entry.Status {
+	case model.OrganizeEntryDone:
+		return true
+	case model.OrganizeEntryPending:
+		if entry.Op != model.OrganizeOpMove {
+			return false
+		}
+		_, toErr := os.Lstat(entry.To)
+		_, fromErr := os.Lstat(entry.From)
+		return toErr == nil && os.IsNotExist(fromErr)
+	}
+	return false
+}
+
+// undoOrganizeOp 撤销单个文件操作
+func undoOrganizeOp(entry *model.OrganizeJournalEntry) error {
+	switch entry.Op {
+	case model.OrganizeOpMkdir:
+		return os.Remove(entry.To)
+	case model.OrganizeOpMove:
+		if _, err := os.Lstat(entry.From); err == nil && !sameFile(entry.From, entry.To) {
+			return fmt.Errorf("原路径已存在: %s", entry.From)
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.From), 0755); err != nil {
+			return err
+		}
+		return os.Rename(entry.To, entry.From)
+	case model.OrganizeOpRmdir:
+		return os.MkdirAll(entry.To, 0755)
+	}
+	return fmt.Errorf("未知的整理操作: %s", entry.Op)
+}
+
+// sameFile 判断两个路径是否指向同一个文件，用于在不区分大小写的文件系统上只改变大小写的重命名
+// 区分大小写的文件系统上 abc-123.nfo 与 ABC-123.nfo 是两个文件，不能互相覆盖
+func sameFile(a, b string) bool {
+	infoA, err := os.Lstat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Lstat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
+
+// isWithin 判断 path 是否位于 dir 之下（不含 dir 本身）
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isVideoExt 判断文件是否为视频文件
+func isVideoExt(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, videoExt := range defaultVideoExts {
+		if ext == videoExt {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys 返回按字典序排列的键，保证计划的顺序稳定
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
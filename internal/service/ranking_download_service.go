@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"nsfw-go/internal/model"
@@ -19,6 +21,17 @@ type RankingDownloadService struct {
 	torrentService   *TorrentService
 	telegramService  *TelegramService
 	logService       *LogService
+	blocklistRepo    repo.TorrentBlocklistRepository
+	importService    *ImportService
+	retryPolicy      DownloadRetryPolicy
+	upgradePolicy    UpgradePolicy
+	libraryRoots     []model.MediaRoot
+
+	// 下载队列
+	queueMu       sync.Mutex
+	maxConcurrent int
+	running       map[uint]bool
+	queueSignal   chan struct{}
 }
 
 // NewRankingDownloadService 创建排行榜下载服务
@@ -39,6 +52,11 @@ func NewRankingDownloadService(
 		torrentService:   torrentService,
 		telegramService:  telegramService,
 		logService:       logService,
+		retryPolicy:      DefaultDownloadRetryPolicy(),
+		upgradePolicy:    DefaultUpgradePolicy(),
+		maxConcurrent:    DefaultMaxConcurrentDownloads,
+		running:          make(map[uint]bool),
+		queueSignal:      make(chan struct{}, 1),
 	}
 }
 
@@ -49,8 +67,17 @@ func (s *RankingDownloadService) StartDownloadTask(code, title, coverURL, source
 		return nil, fmt.Errorf("影片 %s 已在本地库中", code)
 	}
 	
-	// 检查是否有活跃的下载任务
+	priority := model.DownloadPriorityForSource(source)
+
+	// 检查是否有活跃的下载任务，等待中的任务按新的来源提升优先级
 	if existingTask, _ := s.taskRepo.GetActiveTaskByCode(code); existingTask != nil {
+		if existingTask.Status == model.RankingDownloadStatusPending && existingTask.Priority < priority {
+			existingTask.Priority = priority
+			if err := s.taskRepo.Update(existingTask); err != nil {
+				return nil, fmt.Errorf("更新任务优先级失败: %v", err)
+			}
+		}
+		s.fillQueuePosition(existingTask)
 		return existingTask, nil
 	}
 	
@@ -83,6 +110,7 @@ func (s *RankingDownloadService) StartDownloadTask(code, title, coverURL, source
 		Source:   source,
 		RankType: rankType,
 		CoverURL: coverURL, // 保存传递的封面URL
+		Priority: priority,
 	}
 	
 	if err := s.taskRepo.Create(task); err != nil {
@@ -93,51 +121,72 @@ func (s *RankingDownloadService) StartDownloadTask(code, title, coverURL, source
 		s.logService.LogInfo("torrent", "download-service", fmt.Sprintf("创建下载任务: %s (%s)", code, title))
 	}
 
-	// 异步开始下载流程
-	go s.executeDownload(task)
+	// 加入下载队列，由队列按优先级与并发数执行
+	s.signalQueue()
+	s.fillQueuePosition(task)
 
 	return task, nil
 }
 
-// executeDownload 执行下载流程
+// executeDownload 执行下载流程：选择下一个候选种子并添加到下载器，失败时按重试策略重新排队
 func (s *RankingDownloadService) executeDownload(task *model.RankingDownloadTask) {
 	// 更新状态为搜索中
 	task.Status = model.RankingDownloadStatusSearching
 	task.StartedAt = &[]time.Time{time.Now()}[0]
-	s.taskRepo.Update(task)
+	task.NextRetryAt = nil
+	if !s.saveActiveTask(task) {
+		return
+	}
 
 	// 搜索种子
 	if s.logService != nil {
 		s.logService.LogInfo("torrent", "download-service", fmt.Sprintf("开始搜索种子: %s", task.Code))
 	}
 
-	torrents, err := s.torrentService.SearchTorrentsForCode(task.Code)
-	if err != nil || len(torrents) == 0 {
-		s.markTaskFailed(task, "未找到可用种子")
+	candidate, err := s.nextCandidate(task)
+	if err != nil {
+		s.retryOrFail(task, err.Error(), false)
 		return
 	}
 
-	// 选择最优种子（第一个，已按优先级排序）
-	bestTorrent := torrents[0]
-	task.TorrentURL = bestTorrent.Link
-	task.TorrentHash = bestTorrent.InfoHash
-	task.FileSize = int64(bestTorrent.Size)
+	// 使用得分最高且未被放弃的种子
+	task.TorrentURL = candidate.Link
+	task.TorrentHash = candidate.InfoHash
+	task.FileSize = candidate.Size
 	task.Status = model.RankingDownloadStatusFound
-	s.taskRepo.Update(task)
+	s.startAttempt(task, candidate)
+	if !s.saveActiveTask(task) {
+		return
+	}
 
+	sizeFormatted := formatFileSize(candidate.Size)
 	if s.logService != nil {
-		s.logService.LogInfo("torrent", "download-service", fmt.Sprintf("找到种子: %s (%s)", task.Code, bestTorrent.SizeFormatted))
+		s.logService.LogInfo("torrent", "download-service", fmt.Sprintf("找到种子: %s (%s)", task.Code, sizeFormatted))
 	}
 
-	// 添加到 qBittorrent
-	err = s.torrentService.DownloadTorrent(bestTorrent.Link)
-	if err != nil {
-		s.markTaskFailed(task, fmt.Sprintf("添加到下载器失败: %v", err))
+	// 添加到下载器
+	// 种子已在下载器中时继续跟踪；只有之前的尝试记录过由本服务添加时才视为本服务的种子，
+	// 否则可能是用户手动添加的，失败或取消时不会删除或加入黑名单
+	err = s.torrentService.DownloadTorrent(candidate.Link, candidate.InfoHash)
+	if errors.Is(err, ErrTorrentExists) {
+		currentAttempt(task).Added = addedBefore(task, candidate)
+		if s.logService != nil {
+			s.logService.LogInfo("torrent", "download-service", fmt.Sprintf("种子已在下载器中，继续跟踪: %s", task.Code))
+		}
+	} else if err != nil {
+		s.retryOrFail(task, fmt.Sprintf("添加到下载器失败: %v", err), false)
 		return
+	} else {
+		currentAttempt(task).Added = true
 	}
 
 	task.Status = model.RankingDownloadStatusStarted
-	s.taskRepo.Update(task)
+	task.ErrorMsg = ""
+	if !s.saveActiveTask(task) {
+		// 添加期间任务被取消，删除刚添加的种子
+		s.removeAddedTorrent(task)
+		return
+	}
 
 	if s.logService != nil {
 		s.logService.LogInfo("torrent", "download-service", fmt.Sprintf("已添加到下载器: %s", task.Code))
@@ -146,7 +195,7 @@ func (s *RankingDownloadService) executeDownload(task *model.RankingDownloadTask
 	// 获取封面图片URL（优先使用任务中保存的，否则从排行榜获取）
 	var coverURL string = task.CoverURL
 	if coverURL == "" && task.RankType != "" {
-		if ranking, err := s.rankingRepo.GetByCodeAndType(task.Code, task.RankType); err == nil && ranking != nil {
+		if ranking, err := s.rankingRepo.GetByCodeAndBoard(task.Code, task.RankType); err == nil && ranking != nil {
 			coverURL = ranking.CoverURL
 		}
 	}
@@ -157,8 +206,8 @@ func (s *RankingDownloadService) executeDownload(task *model.RankingDownloadTask
 			task.Code,
 			task.Title,
 			coverURL,
-			bestTorrent.SizeFormatted,
-			bestTorrent.Tracker,
+			sizeFormatted,
+			candidate.Tracker,
 		)
 		if err != nil {
 			if s.logService != nil {
@@ -168,12 +217,50 @@ func (s *RankingDownloadService) executeDownload(task *model.RankingDownloadTask
 	}
 }
 
+// activeDownloadStatuses 活跃任务的状态，后台流程只在任务仍处于这些状态时保存
+var activeDownloadStatuses = []string{
+	model.RankingDownloadStatusPending,
+	model.RankingDownloadStatusSearching,
+	model.RankingDownloadStatusFound,
+	model.RankingDownloadStatusStarted,
+	model.RankingDownloadStatusProgress,
+}
+
+// saveActiveTask 保存后台流程中的任务状态，任务已被取消时不覆盖并返回 false，调用方应停止处理
+// 保存出错时只记录日志并继续
+func (s *RankingDownloadService) saveActiveTask(task *model.RankingDownloadTask) bool {
+	updated, err := s.taskRepo.UpdateIfStatus(task, activeDownloadStatuses)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogError("torrent", "download-service", fmt.Sprintf("保存任务状态失败: %s - %v", task.Code, err))
+		}
+		return true
+	}
+	if !updated && s.logService != nil {
+		s.logService.LogInfo("torrent", "download-service", fmt.Sprintf("任务已取消，停止处理: %s", task.Code))
+	}
+	return updated
+}
+
+// removeAddedTorrent 从下载器中删除本服务为任务添加的种子及已下载的文件
+func (s *RankingDownloadService) removeAddedTorrent(task *model.RankingDownloadTask) {
+	hash := addedTorrentHash(task)
+	if hash == "" {
+		return
+	}
+	if err := s.torrentService.DownloadClient().Remove(context.Background(), hash, true); err != nil && s.logService != nil {
+		s.logService.LogWarn("torrent", "download-service", fmt.Sprintf("从下载器删除种子失败: %s - %v", task.Code, err))
+	}
+}
+
 // markTaskFailed 标记任务失败
 func (s *RankingDownloadService) markTaskFailed(task *model.RankingDownloadTask, errorMsg string) {
 	task.Status = model.RankingDownloadStatusFailed
 	task.ErrorMsg = errorMsg
 	task.CompletedAt = &[]time.Time{time.Now()}[0]
-	s.taskRepo.Update(task)
+	if !s.saveActiveTask(task) {
+		return
+	}
 
 	if s.logService != nil {
 		s.logService.LogError("torrent", "download-service", fmt.Sprintf("任务失败: %s - %s", task.Code, errorMsg))
@@ -194,9 +281,24 @@ func (s *RankingDownloadService) markTaskFailed(task *model.RankingDownloadTask,
 	}
 }
 
-// GetTaskByCode 根据番号获取任务状态
+// GetTaskByCode 根据番号获取任务状态，等待中的任务包含队列位置
 func (s *RankingDownloadService) GetTaskByCode(code string) (*model.RankingDownloadTask, error) {
-	return s.taskRepo.GetByCode(code)
+	task, err := s.taskRepo.GetByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	s.fillQueuePosition(task)
+	return task, nil
+}
+
+// fillQueuePosition 为等待中的任务填充队列位置
+func (s *RankingDownloadService) fillQueuePosition(task *model.RankingDownloadTask) {
+	if task.Status != model.RankingDownloadStatusPending || (task.NextRetryAt != nil && task.NextRetryAt.After(time.Now())) {
+		return
+	}
+	if position, err := s.taskRepo.GetQueuePosition(task); err == nil {
+		task.QueuePosition = position
+	}
 }
 
 // GetTasks 获取下载任务列表
@@ -225,7 +327,13 @@ func (s *RankingDownloadService) CancelTask(id uint) error {
 	
 	task.Status = model.RankingDownloadStatusCancelled
 	task.CompletedAt = &[]time.Time{time.Now()}[0]
-	return s.taskRepo.Update(task)
+	if err := s.taskRepo.Update(task); err != nil {
+		return err
+	}
+
+	// 已添加到下载器的种子一并删除；正在执行的任务保存状态时会发现已取消，并删除随后添加的种子
+	s.removeAddedTorrent(task)
+	return nil
 }
 
 // RetryTask 重试失败的任务
@@ -239,19 +347,21 @@ func (s *RankingDownloadService) RetryTask(id uint) error {
 		return fmt.Errorf("只能重试失败的任务，当前状态: %s", task.Status)
 	}
 	
-	// 重置任务状态
+	// 重置任务状态，重新计算自动重试次数；已放弃的种子不会再被选择
 	task.Status = model.RankingDownloadStatusPending
 	task.ErrorMsg = ""
 	task.Progress = 0
 	task.StartedAt = nil
 	task.CompletedAt = nil
+	task.RetryCount = 0
+	task.NextRetryAt = nil
 	
 	if err := s.taskRepo.Update(task); err != nil {
 		return err
 	}
 	
-	// 异步重新执行
-	go s.executeDownload(task)
+	// 重新加入下载队列
+	s.signalQueue()
 	
 	return nil
 }
@@ -265,23 +375,8 @@ func (s *RankingDownloadService) UpdateTaskProgress(code string, progress float6
 	
 	task.Progress = progress
 	if progress >= 1.0 {
-		task.Status = model.RankingDownloadStatusCompleted
-		task.CompletedAt = &[]time.Time{time.Now()}[0]
-
-		// 发送增强的完成通知
-		if s.telegramService != nil {
-			err := s.telegramService.SendDownloadCompleteNotification(
-				task.Code,
-				task.Title,
-				"", // 暂时为空，可以后续添加文件路径获取逻辑
-				task.FileSize,
-			)
-			if err != nil {
-				if s.logService != nil {
-					s.logService.LogWarn("torrent", "download-service", fmt.Sprintf("Telegram完成通知发送失败: %v", err))
-				}
-			}
-		}
+		task.Progress = 1
+		return s.completeTask(task)
 	} else if progress > 0 {
 		task.Status = model.RankingDownloadStatusProgress
 	}
@@ -353,7 +448,7 @@ func (s *RankingDownloadService) ExecuteSubscriptionDownload(rankType string) er
 	}
 	
 	// 获取排行榜中未在本地的影片
-	rankings, err := s.rankingRepo.GetByRankType(rankType, 50)
+	rankings, err := s.rankingRepo.GetByBoard(rankType, 50)
 	if err != nil {
 		return fmt.Errorf("获取排行榜失败: %v", err)
 	}
@@ -393,7 +488,6 @@ func (s *RankingDownloadService) ExecuteSubscriptionDownload(rankType string) er
 		s.subscriptionRepo.IncrementLimitCount(rankType, model.LimitTypeDaily)
 		
 		downloadCount++
-		time.Sleep(2 * time.Second) // 避免过于频繁的请求
 	}
 	
 	// 更新订阅运行时间
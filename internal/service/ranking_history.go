@@ -0,0 +1,265 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"nsfw-go/internal/crawler"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
+	"nsfw-go/internal/repo"
+)
+
+// ErrNoRankingSnapshot 排行榜还没有历史快照
+var ErrNoRankingSnapshot = errors.New("排行榜暂无历史快照")
+
+// 排行榜趋势排序方式
+const (
+	RankingTrendSortPosition = "position" // 按当前排名
+	RankingTrendSortChange   = "change"   // 按排名上升幅度，新上榜的排在上升条目之后
+)
+
+// RankingTrendItem 排行榜条目及其相对上一次爬取的变化
+type RankingTrendItem struct {
+	Code             string    `json:"code"`
+	Title            string    `json:"title"`
+	CoverURL         string    `json:"cover_url"`
+	Position         int       `json:"position"`                    // 当前排名，已跌出榜单时为 0
+	PreviousPosition int       `json:"previous_position,omitempty"` // 上一次爬取的排名，新上榜时为 0
+	Change           int       `json:"change"`                      // 排名变化，正数表示上升
+	IsNew            bool      `json:"is_new"`                      // 上一次爬取时不在榜上
+	PeakPosition     int       `json:"peak_position"`               // 历史最高排名
+	DaysOnChart      int       `json:"days_on_chart"`               // 累计上榜天数
+	FirstSeen        time.Time `json:"first_seen"`
+	LocalExists      bool      `json:"local_exists"`
+}
+
+// RankingTrend 排行榜趋势：最新一次爬取与上一次爬取的对比
+type RankingTrend struct {
+	Board             string              `json:"board"`
+	CrawledAt         time.Time           `json:"crawled_at"`
+	PreviousCrawledAt *time.Time          `json:"previous_crawled_at"` // 只有一次快照时为空
+	Items             []*RankingTrendItem `json:"items"`
+	Dropped           []*RankingTrendItem `json:"dropped"` // 上一次在榜、本次跌出的条目
+	Summary           RankingTrendSummary `json:"summary"`
+}
+
+// RankingTrendSummary 排行榜趋势统计
+type RankingTrendSummary struct {
+	Total   int `json:"total"`
+	Rising  int `json:"rising"`
+	Falling int `json:"falling"`
+	Steady  int `json:"steady"`
+	New     int `json:"new"`
+	Dropped int `json:"dropped"`
+}
+
+// RankingHistoryPoint 番号某次爬取时的排名
+type RankingHistoryPoint struct {
+	CrawledAt time.Time `json:"crawled_at"`
+	Position  int       `json:"position"`
+}
+
+// RankingBoardHistory 番号在单个榜单上的历史
+type RankingBoardHistory struct {
+	Board        string                `json:"board"`
+	PeakPosition int                   `json:"peak_position"`
+	DaysOnChart  int                   `json:"days_on_chart"`
+	FirstSeen    time.Time             `json:"first_seen"`
+	LastSeen     time.Time             `json:"last_seen"`
+	Points       []RankingHistoryPoint `json:"points"`
+}
+
+// RankingCodeHistory 番号的排行榜历史
+type RankingCodeHistory struct {
+	Code   string                 `json:"code"`
+	Title  string                 `json:"title"`
+	Boards []*RankingBoardHistory `json:"boards"`
+}
+
+// saveSnapshots 保存一次爬取的整榜快照，失败只记录日志，不影响当前榜单的更新
+func (rs *RankingService) saveSnapshots(board crawler.RankingBoard, items []crawler.RankingItem, crawledAt time.Time) {
+	snapshots := make([]model.RankingSnapshot, 0, len(items))
+	for _, item := range items {
+		snapshots = append(snapshots, model.RankingSnapshot{
+			Code:      moviecode.Normalize(item.Code),
+			Title:     item.Title,
+			CoverURL:  item.CoverURL,
+			Board:     board.Key,
+			RankType:  board.Period,
+			Position:  item.Position,
+			CrawledAt: crawledAt,
+		})
+	}
+
+	if err := rs.rankingRepo.BatchCreateSnapshots(snapshots); err != nil && rs.logService != nil {
+		rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("保存 %s 排行榜快照失败: %v", board.Key, err))
+	}
+}
+
+// GetRankingTrends 获取排行榜趋势：排名变化、新上榜、跌出榜单、历史最高排名与上榜天数
+func (rs *RankingService) GetRankingTrends(board, sortBy string) (*RankingTrend, error) {
+	times, err := rs.rankingRepo.GetSnapshotTimes(board, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return nil, ErrNoRankingSnapshot
+	}
+
+	current, err := rs.rankingRepo.GetSnapshot(board, times[0])
+	if err != nil {
+		return nil, err
+	}
+	previous := make(map[string]*model.RankingSnapshot)
+	trend := &RankingTrend{Board: board, CrawledAt: times[0]}
+	if len(times) > 1 {
+		trend.PreviousCrawledAt = &times[1]
+		snapshots, err := rs.rankingRepo.GetSnapshot(board, times[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots {
+			previous[snapshot.Code] = snapshot
+		}
+	}
+
+	codes := make([]string, 0, len(current)+len(previous))
+	inCurrent := make(map[string]bool, len(current))
+	for _, snapshot := range current {
+		codes = append(codes, snapshot.Code)
+		inCurrent[snapshot.Code] = true
+	}
+	for code := range previous {
+		if !inCurrent[code] {
+			codes = append(codes, code)
+		}
+	}
+	stats, err := rs.rankingRepo.GetChartStats(board, codes)
+	if err != nil {
+		return nil, err
+	}
+	localExists := rs.currentLocalExists(board)
+
+	for _, snapshot := range current {
+		item := newRankingTrendItem(snapshot, stats[snapshot.Code], localExists[snapshot.Code])
+		item.Position = snapshot.Position
+		if prev, ok := previous[snapshot.Code]; ok {
+			item.PreviousPosition = prev.Position
+			item.Change = prev.Position - snapshot.Position
+		} else if trend.PreviousCrawledAt != nil {
+			item.IsNew = true
+		}
+		trend.Items = append(trend.Items, item)
+
+		switch {
+		case item.IsNew:
+			trend.Summary.New++
+		case item.Change > 0:
+			trend.Summary.Rising++
+		case item.Change < 0:
+			trend.Summary.Falling++
+		default:
+			trend.Summary.Steady++
+		}
+	}
+
+	for code, snapshot := range previous {
+		if inCurrent[code] {
+			continue
+		}
+		item := newRankingTrendItem(snapshot, stats[code], localExists[code])
+		item.PreviousPosition = snapshot.Position
+		trend.Dropped = append(trend.Dropped, item)
+	}
+	sort.Slice(trend.Dropped, func(i, j int) bool {
+		return trend.Dropped[i].PreviousPosition < trend.Dropped[j].PreviousPosition
+	})
+
+	if sortBy == RankingTrendSortChange {
+		sort.SliceStable(trend.Items, func(i, j int) bool {
+			return trendRank(trend.Items[i]) > trendRank(trend.Items[j])
+		})
+	}
+
+	trend.Summary.Total = len(trend.Items)
+	trend.Summary.Dropped = len(trend.Dropped)
+	return trend, nil
+}
+
+// GetCodeHistory 获取番号在各榜单上的排名历史，days 为 0 时返回全部历史
+func (rs *RankingService) GetCodeHistory(code string, days int) (*RankingCodeHistory, error) {
+	code = moviecode.Normalize(code)
+	var since time.Time
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	snapshots, err := rs.rankingRepo.GetSnapshotsByCode(code, since)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &RankingCodeHistory{Code: code, Boards: []*RankingBoardHistory{}}
+	var board *RankingBoardHistory
+	for _, snapshot := range snapshots {
+		if board == nil || board.Board != snapshot.Board {
+			board = &RankingBoardHistory{Board: snapshot.Board}
+			history.Boards = append(history.Boards, board)
+		}
+		board.Points = append(board.Points, RankingHistoryPoint{CrawledAt: snapshot.CrawledAt, Position: snapshot.Position})
+		history.Title = snapshot.Title
+	}
+
+	// 最高排名与上榜天数按全部历史统计，不受 days 限制
+	for _, board := range history.Boards {
+		stats, err := rs.rankingRepo.GetChartStats(board.Board, []string{code})
+		if err != nil {
+			return nil, err
+		}
+		board.PeakPosition = stats[code].PeakPosition
+		board.DaysOnChart = stats[code].DaysOnChart
+		board.FirstSeen = stats[code].FirstSeen
+		board.LastSeen = stats[code].LastSeen
+	}
+	return history, nil
+}
+
+// currentLocalExists 当前榜单中各番号的本地存在状态
+func (rs *RankingService) currentLocalExists(board string) map[string]bool {
+	result := make(map[string]bool)
+	rankings, err := rs.rankingRepo.GetByBoard(board, 1000)
+	if err != nil {
+		return result
+	}
+	for _, ranking := range rankings {
+		result[ranking.Code] = ranking.LocalExists
+	}
+	return result
+}
+
+// newRankingTrendItem 由快照和历史统计构造趋势条目
+func newRankingTrendItem(snapshot *model.RankingSnapshot, stats repo.RankingChartStats, localExists bool) *RankingTrendItem {
+	return &RankingTrendItem{
+		Code:         snapshot.Code,
+		Title:        snapshot.Title,
+		CoverURL:     snapshot.CoverURL,
+		PeakPosition: stats.PeakPosition,
+		DaysOnChart:  stats.DaysOnChart,
+		FirstSeen:    stats.FirstSeen,
+		LocalExists:  localExists,
+	}
+}
+
+// trendRank 按上升幅度排序的权重：上升的在前，新上榜的紧随其后，下降的最后
+func trendRank(item *RankingTrendItem) int {
+	if item.IsNew {
+		return 0
+	}
+	if item.Change > 0 {
+		return item.Change * 2
+	}
+	return item.Change*2 - 1
+}
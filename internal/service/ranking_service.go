@@ -3,359 +3,307 @@ package service
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"nsfw-go/internal/crawler"
 	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
 	"nsfw-go/internal/repo"
 )
 
-// RankingService 排行榜服务
+// RankingService 排行榜服务，定时爬取与本地检查由 SchedulerService 调度
 type RankingService struct {
-	rankingCrawler *crawler.RankingCrawler
-	rankingRepo    repo.RankingRepository
-	localMovieRepo repo.LocalMovieRepository
-	logService     *LogService
-	stopChan       chan struct{}
-	crawlScheduled bool
-	checkScheduled bool
+	rankingCrawler     *crawler.RankingCrawler
+	rankingRepo        repo.RankingRepository
+	actressRankingRepo repo.ActressRankingRepository
+	localMovieRepo     repo.LocalMovieRepository
+	logService         *LogService
+	boards             []crawler.RankingBoard
 }
 
-// NewRankingService 创建排行榜服务
+// NewRankingService 创建排行榜服务，默认爬取 crawler.DefaultRankingBoards 中的榜单
 func NewRankingService(
 	config *crawler.CrawlerConfig,
 	rankingRepo repo.RankingRepository,
+	actressRankingRepo repo.ActressRankingRepository,
 	localMovieRepo repo.LocalMovieRepository,
 	logService *LogService,
 ) *RankingService {
 	return &RankingService{
-		rankingCrawler: crawler.NewRankingCrawler(config),
-		rankingRepo:    rankingRepo,
-		localMovieRepo: localMovieRepo,
-		logService:     logService,
-		stopChan:       make(chan struct{}),
+		rankingCrawler:     crawler.NewRankingCrawler(config),
+		rankingRepo:        rankingRepo,
+		actressRankingRepo: actressRankingRepo,
+		localMovieRepo:     localMovieRepo,
+		logService:         logService,
+		boards:             crawler.DefaultRankingBoards(),
 	}
 }
 
-// Start 启动服务
-func (rs *RankingService) Start() {
-	if rs.logService != nil {
-		rs.logService.LogInfo("crawler", "ranking-service", "排行榜服务启动")
-	}
-
-	// 启动定时爬取任务（每天中午12:00）
-	if !rs.crawlScheduled {
-		go rs.startCrawlScheduler()
-		rs.crawlScheduled = true
+// SetBoards 设置要爬取的榜单，为空时使用默认榜单
+func (rs *RankingService) SetBoards(boards []crawler.RankingBoard) error {
+	if len(boards) == 0 {
+		rs.boards = crawler.DefaultRankingBoards()
+		return nil
 	}
-
-	// 启动定时检查任务（每小时）
-	if !rs.checkScheduled {
-		go rs.startCheckScheduler()
-		rs.checkScheduled = true
+	resolved, err := crawler.ResolveRankingBoards(boards)
+	if err != nil {
+		return err
 	}
-
-	// 立即执行一次爬取（如果今天还没有爬取过）
-	go func() {
-		ctx := context.Background()
-		if rs.shouldCrawlToday() {
-			if rs.logService != nil {
-				rs.logService.LogInfo("crawler", "ranking-service", "执行初始爬取")
-			}
-			rs.CrawlAndSaveRankings(ctx)
-		}
-
-		// 执行一次检查
-		if rs.logService != nil {
-			rs.logService.LogInfo("crawler", "ranking-service", "执行初始本地检查")
-		}
-		rs.CheckLocalExists(ctx, 100)
-	}()
+	rs.boards = resolved
+	return nil
 }
 
-// Stop 停止服务
-func (rs *RankingService) Stop() {
-	if rs.logService != nil {
-		rs.logService.LogInfo("crawler", "ranking-service", "排行榜服务停止")
-	}
-	close(rs.stopChan)
+// Boards 获取已配置的榜单
+func (rs *RankingService) Boards() []crawler.RankingBoard {
+	return rs.boards
 }
 
-// startCrawlScheduler 启动爬取调度器
-func (rs *RankingService) startCrawlScheduler() {
-	ticker := time.NewTicker(time.Minute) // 每分钟检查一次
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			now := time.Now()
-			// 检查是否是中午12:00
-			if now.Hour() == 12 && now.Minute() == 0 {
-				if rs.logService != nil {
-					rs.logService.LogInfo("crawler", "ranking-service", "定时爬取开始")
-				}
-				ctx := context.Background()
-				rs.CrawlAndSaveRankings(ctx)
-			}
-		case <-rs.stopChan:
-			return
+// Board 根据标识查找已配置的榜单
+func (rs *RankingService) Board(key string) (crawler.RankingBoard, bool) {
+	for _, board := range rs.boards {
+		if board.Key == key {
+			return board, true
 		}
 	}
+	return crawler.RankingBoard{}, false
+}
+
+// IsMovieBoard 判断标识是否为已配置的影片榜单
+func (rs *RankingService) IsMovieBoard(key string) bool {
+	board, ok := rs.Board(key)
+	return ok && board.Kind == crawler.BoardKindMovies
 }
 
-// startCheckScheduler 启动检查调度器
-func (rs *RankingService) startCheckScheduler() {
-	ticker := time.NewTicker(time.Hour) // 每小时检查一次
-	defer ticker.Stop()
+// IsActressBoard 判断标识是否为已配置的女优榜单
+func (rs *RankingService) IsActressBoard(key string) bool {
+	board, ok := rs.Board(key)
+	return ok && board.Kind == crawler.BoardKindActors
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			if rs.logService != nil {
-				rs.logService.LogInfo("crawler", "ranking-service", "定时检查本地存在开始")
-			}
-			ctx := context.Background()
-			rs.CheckLocalExists(ctx, 100)
-		case <-rs.stopChan:
-			return
+// MovieBoardKeys 已配置的影片榜单标识
+func (rs *RankingService) MovieBoardKeys() []string {
+	var keys []string
+	for _, board := range rs.boards {
+		if board.Kind == crawler.BoardKindMovies {
+			keys = append(keys, board.Key)
 		}
 	}
+	return keys
 }
 
-// CrawlAndSaveRankings 爬取并保存排行榜
+// CrawlAndSaveRankings 依次爬取并保存所有已配置的榜单，单个榜单失败不影响其他榜单
 func (rs *RankingService) CrawlAndSaveRankings(ctx context.Context) error {
 	if rs.logService != nil {
-		rs.logService.LogInfo("crawler", "ranking-service", "开始爬取所有排行榜")
+		rs.logService.LogInfo("crawler", "ranking-service", fmt.Sprintf("开始爬取排行榜，共 %d 个榜单", len(rs.boards)))
 	}
 
-	// 爬取所有排行榜
-	rankings, err := rs.rankingCrawler.CrawlAllRankings(ctx)
-	if err != nil {
-		if rs.logService != nil {
-			rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("爬取失败: %v", err))
-		}
-		return err
-	}
-
-	crawledAt := time.Now()
 	totalSaved := 0
-
-	// 保存每种类型的排行榜
-	for rankType, items := range rankings {
-		if len(items) == 0 {
-			continue
-		}
-
-		// 清理该类型今天的旧数据（避免重复数据）
-		todayStart := time.Date(crawledAt.Year(), crawledAt.Month(), crawledAt.Day(), 0, 0, 0, 0, crawledAt.Location())
-		if err := rs.rankingRepo.ClearOldRankings(rankType, todayStart.Add(24*time.Hour)); err != nil {
-			if rs.logService != nil {
-				rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("清理 %s 今天的旧数据失败: %v", rankType, err))
+	failed := 0
+	for i, board := range rs.boards {
+		if i > 0 {
+			// 添加延时，避免请求过于频繁
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(2 * time.Second):
 			}
 		}
 
-		// 同时清理过期数据（保留最近7天的数据）
-		keepTime := crawledAt.AddDate(0, 0, -7)
-		if err := rs.rankingRepo.ClearOldRankings(rankType, keepTime); err != nil {
-			if rs.logService != nil {
-				rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("清理 %s 过期数据失败: %v", rankType, err))
-			}
+		var saved int
+		var err error
+		if board.Kind == crawler.BoardKindActors {
+			saved, err = rs.crawlActressBoard(ctx, board)
+		} else {
+			saved, err = rs.crawlMovieBoard(ctx, board)
 		}
-
-		// 转换为数据库模型
-		var rankingModels []model.Ranking
-		for _, item := range items {
-			ranking := model.Ranking{
-				Code:      rs.normalizeCode(item.Code),
-				Title:     item.Title,
-				CoverURL:  item.CoverURL,
-				RankType:  rankType,
-				Position:  item.Position,
-				CrawledAt: crawledAt,
-			}
-			rankingModels = append(rankingModels, ranking)
-		}
-
-		// 批量保存
-		if err := rs.rankingRepo.BatchCreate(rankingModels); err != nil {
+		if err != nil {
+			failed++
 			if rs.logService != nil {
-				rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("保存 %s 排行榜失败: %v", rankType, err))
+				rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("爬取 %s 失败: %v", board.Key, err))
 			}
 			continue
 		}
-
-		totalSaved += len(rankingModels)
-		if rs.logService != nil {
-			rs.logService.LogInfo("crawler", "ranking-service", fmt.Sprintf("保存 %s 排行榜成功，共 %d 条", rankType, len(rankingModels)))
-		}
+		totalSaved += saved
 	}
 
 	if rs.logService != nil {
 		rs.logService.LogInfo("crawler", "ranking-service", fmt.Sprintf("爬取完成，共保存 %d 条记录", totalSaved))
 	}
+	// 新爬取的记录立即标记本地存在状态
+	if totalSaved > 0 {
+		rs.CheckLocalExists(ctx)
+	}
+	if failed == len(rs.boards) && failed > 0 {
+		return fmt.Errorf("所有榜单爬取失败")
+	}
 	return nil
 }
 
-// CheckLocalExists 检查本地存在状态
-func (rs *RankingService) CheckLocalExists(ctx context.Context, batchSize int) error {
-	if rs.logService != nil {
-		rs.logService.LogInfo("crawler", "ranking-service", "开始检查本地存在状态")
+// crawlMovieBoard 爬取并保存影片榜单
+func (rs *RankingService) crawlMovieBoard(ctx context.Context, board crawler.RankingBoard) (int, error) {
+	items, err := rs.rankingCrawler.CrawlBoard(ctx, board)
+	if err != nil {
+		return 0, err
 	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+	crawledAt := time.Now()
 
-	// 获取需要检查的记录
-	pendingRankings, err := rs.rankingRepo.GetPendingCheck(batchSize)
-	if err != nil {
+	// 保存整榜快照，用于追踪排名变化（当前榜单表只保留最新一次爬取）
+	rs.saveSnapshots(board, items, crawledAt)
+
+	// 清理该榜单今天的旧数据（避免重复数据）
+	todayStart := time.Date(crawledAt.Year(), crawledAt.Month(), crawledAt.Day(), 0, 0, 0, 0, crawledAt.Location())
+	if err := rs.rankingRepo.ClearOldRankings(board.Key, todayStart.Add(24*time.Hour)); err != nil {
 		if rs.logService != nil {
-			rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("获取待检查记录失败: %v", err))
+			rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("清理 %s 今天的旧数据失败: %v", board.Key, err))
 		}
-		return err
 	}
 
-	if len(pendingRankings) == 0 {
+	// 同时清理过期数据（保留最近7天的数据）
+	keepTime := crawledAt.AddDate(0, 0, -7)
+	if err := rs.rankingRepo.ClearOldRankings(board.Key, keepTime); err != nil {
 		if rs.logService != nil {
-			rs.logService.LogInfo("crawler", "ranking-service", "没有需要检查的记录")
+			rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("清理 %s 过期数据失败: %v", board.Key, err))
 		}
-		return nil
 	}
 
-	checkedCount := 0
-	existsCount := 0
-
-	for _, ranking := range pendingRankings {
-		// 检查本地是否存在
-		exists := rs.checkLocalMovieExists(ranking.Code)
-
-		// 更新状态
-		if err := rs.rankingRepo.UpdateLocalExists(ranking.ID, exists); err != nil {
-			if rs.logService != nil {
-				rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("更新 %s 本地存在状态失败: %v", ranking.Code, err))
-			}
-			continue
-		}
-
-		checkedCount++
-		if exists {
-			existsCount++
+	// 转换为数据库模型
+	var rankingModels []model.Ranking
+	for _, item := range items {
+		ranking := model.Ranking{
+			Code:      moviecode.Normalize(item.Code),
+			Title:     item.Title,
+			CoverURL:  item.CoverURL,
+			Board:     board.Key,
+			RankType:  board.Period,
+			Position:  item.Position,
+			CrawledAt: crawledAt,
 		}
+		rankingModels = append(rankingModels, ranking)
+	}
 
-		// 添加小延时
-		time.Sleep(10 * time.Millisecond)
+	// 批量保存
+	if err := rs.rankingRepo.BatchCreate(rankingModels); err != nil {
+		return 0, fmt.Errorf("保存排行榜失败: %w", err)
 	}
 
 	if rs.logService != nil {
-		rs.logService.LogInfo("crawler", "ranking-service", fmt.Sprintf("检查完成，共检查 %d 条，本地存在 %d 条", checkedCount, existsCount))
+		rs.logService.LogInfo("crawler", "ranking-service", fmt.Sprintf("保存 %s 排行榜成功，共 %d 条", board.Key, len(rankingModels)))
 	}
-	return nil
+	return len(rankingModels), nil
 }
 
-// checkLocalMovieExists 检查本地影片是否存在
-func (rs *RankingService) checkLocalMovieExists(code string) bool {
-	if code == "" {
-		return false
+// crawlActressBoard 爬取并保存女优榜单，只保留最新一次爬取的结果
+func (rs *RankingService) crawlActressBoard(ctx context.Context, board crawler.RankingBoard) (int, error) {
+	items, err := rs.rankingCrawler.CrawlActressBoard(ctx, board)
+	if err != nil {
+		return 0, err
+	}
+	if len(items) == 0 {
+		return 0, nil
 	}
 
-	// 标准化番号
-	normalizedCode := rs.normalizeCode(code)
+	crawledAt := time.Now()
+	rankings := make([]model.ActressRanking, 0, len(items))
+	for _, item := range items {
+		rankings = append(rankings, model.ActressRanking{
+			Board:     board.Key,
+			JAVDbID:   item.JAVDbID,
+			Name:      item.Name,
+			AvatarURL: item.AvatarURL,
+			Position:  item.Position,
+			CrawledAt: crawledAt,
+		})
+	}
+	if err := rs.actressRankingRepo.ReplaceBoard(board.Key, rankings); err != nil {
+		return 0, fmt.Errorf("保存女优排行榜失败: %w", err)
+	}
+
+	if rs.logService != nil {
+		rs.logService.LogInfo("crawler", "ranking-service", fmt.Sprintf("保存 %s 女优排行榜成功，共 %d 条", board.Key, len(rankings)))
+	}
+	return len(rankings), nil
+}
 
-	// 从本地影视库检查
-	localMovies, _, err := rs.localMovieRepo.List(0, 1000, "") // 获取所有本地影片，不按女优筛选
+// CheckLocalExists 按番号等价键集合一次性更新所有排行榜记录的本地存在状态
+// 本地影片的等价键在扫描时写入索引列，匹配时只需读取一次等价键集合与一次排行榜记录，
+// 耗时与本地影片数量、排行榜记录数量成线性关系，可以在每次扫描后执行
+func (rs *RankingService) CheckLocalExists(ctx context.Context) error {
+	keys, err := rs.localMovieRepo.ListCodeKeys()
 	if err != nil {
 		if rs.logService != nil {
-			rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("获取本地影片列表失败: %v", err))
+			rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("获取本地番号失败: %v", err))
 		}
-		return false
+		return err
 	}
-
-	for _, localMovie := range localMovies {
-		// 从文件路径中提取番号进行比较
-		if rs.extractCodeFromPath(localMovie.Path) == normalizedCode {
-			return true
+	local := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		for _, k := range moviecode.EquivalentKeys(key) {
+			local[k] = true
 		}
+	}
 
-		// 也可以从标题中提取
-		if rs.extractCodeFromFilename(localMovie.Title) == normalizedCode {
-			return true
+	rankings, err := rs.rankingRepo.ListForLocalCheck()
+	if err != nil {
+		if rs.logService != nil {
+			rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("获取排行榜记录失败: %v", err))
 		}
+		return err
 	}
-
-	return false
-}
-
-// normalizeCode 标准化番号
-func (rs *RankingService) normalizeCode(code string) string {
-	if code == "" {
-		return ""
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// 转换为大写
-	code = strings.ToUpper(strings.TrimSpace(code))
-
-	// 移除特殊字符，保留字母数字和连字符
-	var result strings.Builder
-	for _, r := range code {
-		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
-			result.WriteRune(r)
+	var existsIDs, missingIDs []uint
+	existsCount := 0
+	for _, ranking := range rankings {
+		exists := matchLocalKeys(local, ranking.Code)
+		if exists {
+			existsCount++
+		}
+		// 只更新状态有变化的记录
+		switch {
+		case exists && !ranking.LocalExists:
+			existsIDs = append(existsIDs, ranking.ID)
+		case !exists && ranking.LocalExists:
+			missingIDs = append(missingIDs, ranking.ID)
 		}
 	}
 
-	return result.String()
-}
-
-// extractCodeFromPath 从路径中提取番号
-func (rs *RankingService) extractCodeFromPath(path string) string {
-	// 从路径中提取番号，例如: /path/to/[SSIS-001]Title/video.mp4
-	parts := strings.Split(path, "/")
-	for _, part := range parts {
-		if strings.Contains(part, "[") && strings.Contains(part, "]") {
-			start := strings.Index(part, "[")
-			end := strings.Index(part, "]")
-			if start >= 0 && end > start {
-				code := part[start+1 : end]
-				return rs.normalizeCode(code)
-			}
+	if err := rs.rankingRepo.MarkLocalExists(existsIDs, missingIDs); err != nil {
+		if rs.logService != nil {
+			rs.logService.LogError("crawler", "ranking-service", fmt.Sprintf("更新本地存在状态失败: %v", err))
 		}
+		return err
 	}
 
-	// 如果没有找到中括号格式，尝试正则表达式
-	baseCrawler := crawler.NewBaseCrawler("temp", &crawler.CrawlerConfig{})
-	return baseCrawler.ExtractMovieCode(path)
-}
-
-// extractCodeFromFilename 从文件名中提取番号
-func (rs *RankingService) extractCodeFromFilename(filename string) string {
-	baseCrawler := crawler.NewBaseCrawler("temp", &crawler.CrawlerConfig{})
-	return rs.normalizeCode(baseCrawler.ExtractMovieCode(filename))
+	if rs.logService != nil {
+		rs.logService.LogInfo("crawler", "ranking-service", fmt.Sprintf("检查完成，共检查 %d 条，本地存在 %d 条，状态变化 %d 条",
+			len(rankings), existsCount, len(existsIDs)+len(missingIDs)))
+	}
+	return nil
 }
 
-// shouldCrawlToday 检查今天是否应该爬取
-func (rs *RankingService) shouldCrawlToday() bool {
-	// 检查每种类型的最新爬取时间
-	rankTypes := []string{model.RankTypeDaily, model.RankTypeWeekly, model.RankTypeMonthly}
-
-	today := time.Now().Truncate(24 * time.Hour)
-
-	for _, rankType := range rankTypes {
-		lastCrawl, err := rs.rankingRepo.GetLatestCrawlTime(rankType)
-		if err != nil || lastCrawl == nil {
-			// 如果没有爬取记录，应该爬取
-			return true
-		}
-
-		lastCrawlDay := lastCrawl.Truncate(24 * time.Hour)
-		if lastCrawlDay.Before(today) {
-			// 如果最后一次爬取不是今天，应该爬取
+// matchLocalKeys 判断番号是否在本地番号集合中，按等价键比较（SSIS-123、ssis00123、SSIS-123-C、259LUXU-123 与 LUXU-123 均可匹配）
+func matchLocalKeys(local map[string]bool, code string) bool {
+	for _, key := range moviecode.EquivalentKeys(moviecode.Key(code)) {
+		if local[key] {
 			return true
 		}
 	}
-
 	return false
 }
 
-// GetRankings 获取排行榜数据
-func (rs *RankingService) GetRankings(rankType string, limit int) ([]*model.Ranking, error) {
-	return rs.rankingRepo.GetByRankType(rankType, limit)
+// GetRankings 获取影片榜单数据
+func (rs *RankingService) GetRankings(board string, limit int) ([]*model.Ranking, error) {
+	return rs.rankingRepo.GetByBoard(board, limit)
+}
+
+// GetActressRankings 获取女优榜单数据
+func (rs *RankingService) GetActressRankings(board string, limit int) ([]*model.ActressRanking, error) {
+	return rs.actressRankingRepo.GetByBoard(board, limit)
 }
 
 // GetRankingStats 获取排行榜统计信息
@@ -370,9 +318,8 @@ func (rs *RankingService) GetRankingStats() (map[string]interface{}, error) {
 		"rank_types":     make(map[string]interface{}),
 	}
 
-	rankTypes := []string{model.RankTypeDaily, model.RankTypeWeekly, model.RankTypeMonthly}
-	for _, rankType := range rankTypes {
-		rankings, err := rs.rankingRepo.GetByRankType(rankType, 50)
+	for _, board := range rs.MovieBoardKeys() {
+		rankings, err := rs.rankingRepo.GetByBoard(board, 50)
 		if err != nil {
 			continue
 		}
@@ -384,9 +331,9 @@ func (rs *RankingService) GetRankingStats() (map[string]interface{}, error) {
 			}
 		}
 
-		lastCrawl, _ := rs.rankingRepo.GetLatestCrawlTime(rankType)
+		lastCrawl, _ := rs.rankingRepo.GetLatestCrawlTime(board)
 
-		stats["rank_types"].(map[string]interface{})[rankType] = map[string]interface{}{
+		stats["rank_types"].(map[string]interface{})[board] = map[string]interface{}{
 			"total":        len(rankings),
 			"local_exists": localExists,
 			"last_crawl":   lastCrawl,
@@ -396,6 +343,22 @@ func (rs *RankingService) GetRankingStats() (map[string]interface{}, error) {
 	return stats, nil
 }
 
+// ScheduledCrawl 定时爬取排行榜的任务函数
+func (rs *RankingService) ScheduledCrawl(ctx context.Context) (string, error) {
+	if err := rs.CrawlAndSaveRankings(ctx); err != nil {
+		return "", err
+	}
+	return "排行榜爬取完成", nil
+}
+
+// ScheduledCheck 定时检查本地存在状态的任务函数
+func (rs *RankingService) ScheduledCheck(ctx context.Context) (string, error) {
+	if err := rs.CheckLocalExists(ctx); err != nil {
+		return "", err
+	}
+	return "本地存在状态检查完成", nil
+}
+
 // TriggerManualCrawl 手动触发爬取
 func (rs *RankingService) TriggerManualCrawl(ctx context.Context) error {
 	if rs.logService != nil {
@@ -409,5 +372,5 @@ func (rs *RankingService) TriggerManualCheck(ctx context.Context) error {
 	if rs.logService != nil {
 		rs.logService.LogInfo("crawler", "ranking-service", "手动触发本地检查")
 	}
-	return rs.CheckLocalExists(ctx, 200)
+	return rs.CheckLocalExists(ctx)
 }
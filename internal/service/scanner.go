@@ -2,17 +2,16 @@ package service
 
 import (
 	"context"
-	"encoding/xml"
+	"errors"
 	"fmt"
-	"io"
-	"io/fs"
-	"net/url"
 	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
 	"nsfw-go/internal/repo"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,68 +19,134 @@ const (
 	ScanInterval = 15 * time.Minute // 15分钟扫描一次
 )
 
-// NFO文件结构
-type NFOMovie struct {
-	XMLName xml.Name `xml:"movie"`
-	Title   string   `xml:"title"`
-	Code    string   `xml:"num"`
-	Year    string   `xml:"year"`
-	Studio  string   `xml:"studio"`
-	Plot    string   `xml:"plot"`
-}
-
 // ScannerService 扫描服务
 type ScannerService struct {
-	localMovieRepo   repo.LocalMovieRepository
-	mediaLibraryPath string
-	ctx              context.Context
-	cancel           context.CancelFunc
-	logService       *LogService
+	localMovieRepo repo.LocalMovieRepository
+	movieRepo      repo.MovieRepository // 可选，用于补全影片元数据的时长与画质
+	catalogService *CatalogService      // 可选，用于导入NFO元数据
+	rootConfigs    []model.MediaRoot
+	roots          []*libraryScanner // 已启用的媒体库根目录
+	ctx            context.Context
+	cancel         context.CancelFunc
+	logService     *LogService
+	watchEnabled   bool       // 是否启用实时目录监听
+	scanMu         sync.Mutex // 保证完整扫描与目录增量扫描不会同时写库
+	libraryChanged []func()   // 媒体库有变化后的回调，如更新排行榜的本地存在状态
+
+	historyRepo repo.ScanHistoryRepository // 可选，用于保存扫描记录
+	jobMu       sync.Mutex
+	currentJob  *scanJob // 正在执行的扫描任务，同一时间只允许一个
+	jobSeq      uint     // 未启用扫描记录时的任务编号
 }
 
 // NewScannerService 创建扫描服务
-func NewScannerService(localMovieRepo repo.LocalMovieRepository, mediaLibraryPath string, logService *LogService) *ScannerService {
+func NewScannerService(localMovieRepo repo.LocalMovieRepository, roots []model.MediaRoot, logService *LogService) *ScannerService {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &ScannerService{
-		localMovieRepo:   localMovieRepo,
-		mediaLibraryPath: mediaLibraryPath,
-		ctx:              ctx,
-		cancel:           cancel,
-		logService:       logService,
+	s := &ScannerService{
+		localMovieRepo: localMovieRepo,
+		rootConfigs:    roots,
+		ctx:            ctx,
+		cancel:         cancel,
+		logService:     logService,
+	}
+	s.SetScanOptions(DefaultScanOptions())
+	return s
+}
+
+// SetMovieRepository 设置影片元数据仓库，探测到的时长与画质会补全到同番号的影片
+func (s *ScannerService) SetMovieRepository(movieRepo repo.MovieRepository) {
+	s.movieRepo = movieRepo
+}
+
+// SetCatalogService 设置影片元数据服务，扫描到的NFO会导入影片、演员与标签
+func (s *ScannerService) SetCatalogService(catalogService *CatalogService) {
+	s.catalogService = catalogService
+}
+
+// SetScanOptions 设置目录布局、排除规则与文件过滤条件，配置无效的根目录会被跳过
+func (s *ScannerService) SetScanOptions(opts ScanOptions) error {
+	var roots []*libraryScanner
+	var errs []string
+	for _, root := range s.rootConfigs {
+		if !root.Enabled || root.Path == "" {
+			continue
+		}
+		library, err := newLibraryScanner(root, opts)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", root.Name, err))
+			continue
+		}
+		roots = append(roots, library)
+	}
+	s.roots = roots
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Roots 返回已启用的媒体库根目录
+func (s *ScannerService) Roots() []model.MediaRoot {
+	enabled := make([]model.MediaRoot, 0, len(s.roots))
+	for _, root := range s.rootConfigs {
+		if s.findRoot(root.Name) != nil {
+			enabled = append(enabled, root)
+		}
+	}
+	return enabled
+}
+
+// findRoot 根据名称查找已启用的根目录
+func (s *ScannerService) findRoot(name string) *libraryScanner {
+	for _, root := range s.roots {
+		if root.name == name {
+			return root
+		}
+	}
+	return nil
+}
+
+// rootForPath 查找路径所属的根目录
+func (s *ScannerService) rootForPath(p string) *libraryScanner {
+	for _, root := range s.roots {
+		if root.relParts(p) != nil {
+			return root
+		}
 	}
+	return nil
 }
 
-// Start 启动定时扫描
+// Start 启动扫描服务：立即扫描一次并开始监听目录变化，定时扫描由 SchedulerService 调度（见 ScheduledScan）
 func (s *ScannerService) Start() {
-	if s.mediaLibraryPath == "" {
+	if len(s.roots) == 0 {
 		if s.logService != nil {
 			s.logService.LogWarn("scanner", "media-scan", "媒体库路径未配置，跳过本地影片扫描")
 		}
 		return
 	}
-	if s.logService != nil {
-		s.logService.LogInfo("scanner", "media-scan", fmt.Sprintf("启动本地影片扫描服务，媒体库路径: %s，每15分钟扫描一次", s.mediaLibraryPath))
+
+	// 服务重启前未结束的扫描记录标记为中断
+	if s.historyRepo != nil {
+		if err := s.historyRepo.MarkInterrupted(); err != nil && s.logService != nil {
+			s.logService.LogWarn("scanner", "media-scan", fmt.Sprintf("更新扫描记录失败: %v", err))
+		}
 	}
 
 	// 立即执行一次扫描
-	go s.scanAndStore()
+	s.startBackgroundScan("", model.ScanTriggerStartup)
 
-	// 启动定时扫描
-	ticker := time.NewTicker(ScanInterval)
 	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				s.scanAndStore()
-			case <-s.ctx.Done():
-				ticker.Stop()
-				if s.logService != nil {
-					s.logService.LogInfo("scanner", "media-scan", "本地影片扫描服务已停止")
-				}
-				return
-			}
+		<-s.ctx.Done()
+		if s.logService != nil {
+			s.logService.LogInfo("scanner", "media-scan", "本地影片扫描服务已停止")
 		}
 	}()
+
+	// 启动实时目录监听
+	if s.watchEnabled {
+		s.startWatcher()
+	}
 }
 
 // Stop 停止扫描服务
@@ -91,249 +156,449 @@ func (s *ScannerService) Stop() {
 	}
 }
 
-// scanAndStore 扫描并存储到数据库
-func (s *ScannerService) scanAndStore() {
-	if s.logService != nil {
-		s.logService.LogInfo("scanner", "media-scan", "开始扫描本地影片库...")
-	}
-	startTime := time.Now()
+// ScanResult 扫描结果统计
+type ScanResult struct {
+	Total     int    `json:"total"`           // 本次扫描到的影片总数
+	Added     int    `json:"added"`           // 新增影片数
+	Removed   int    `json:"removed"`         // 移除影片数
+	Changed   int    `json:"changed"`         // 变更影片数
+	Unchanged int    `json:"unchanged"`       // 未变化影片数
+	Duration  string `json:"duration"`        // 扫描耗时
+	Error     string `json:"error,omitempty"` // 部分根目录扫描失败时的错误信息
+
+	// 新增、移除、变更的文件路径，保存到扫描记录中
+	AddedPaths   []string `json:"-"`
+	RemovedPaths []string `json:"-"`
+	ChangedPaths []string `json:"-"`
+}
 
-	// 扫描文件系统
-	movies, err := s.scanDirectory(s.mediaLibraryPath)
-	if err != nil {
-		if s.logService != nil {
-			s.logService.LogError("scanner", "media-scan", fmt.Sprintf("扫描失败: %v", err))
-		}
-		return
+// startBackgroundScan 启动定时或自动触发的扫描，已有任务在执行时跳过
+func (s *ScannerService) startBackgroundScan(rootName, trigger string) {
+	if _, err := s.StartScan(rootName, trigger); err != nil && s.logService != nil {
+		s.logService.LogInfo("scanner", "media-scan", fmt.Sprintf("跳过%s扫描: %v", trigger, err))
 	}
+}
 
-	// 清空旧数据
-	if err := s.localMovieRepo.Clear(); err != nil {
-		if s.logService != nil {
-			s.logService.LogError("scanner", "media-scan", fmt.Sprintf("清空旧数据失败: %v", err))
+// scanAndStore 执行扫描任务并增量同步到数据库，任务的根目录为空时扫描所有根目录
+// 取消时返回已同步部分的结果与 ErrScanCancelled
+func (s *ScannerService) scanAndStore(job *scanJob) (*ScanResult, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	rootName := job.status.Root
+	roots := s.roots
+	if rootName != "" {
+		root := s.findRoot(rootName)
+		if root == nil {
+			return nil, fmt.Errorf("媒体库根目录不存在或未启用: %s", rootName)
 		}
-		return
+		roots = []*libraryScanner{root}
 	}
 
-	// 逐个插入新数据（避免重复路径错误）
-	if len(movies) > 0 {
-		successCount := 0
-		skipCount := 0
-		for _, movie := range movies {
-			err := s.localMovieRepo.Create(movie)
-			if err != nil {
-				if strings.Contains(err.Error(), "duplicate key value") {
-					skipCount++
-					continue
-				}
-				if s.logService != nil {
-					s.logService.LogWarn("scanner", "media-scan", fmt.Sprintf("插入影片失败 [%s]: %v", movie.Path, err))
-				}
-				continue
-			}
-			successCount++
+	startTime := time.Now()
+	total := &ScanResult{}
+	var errs []string
+	for _, root := range roots {
+		job.startRoot(root.name)
+		result, err := s.scanRoot(root, job)
+		if result != nil {
+			total.merge(result)
 		}
-		if s.logService != nil {
-			s.logService.LogInfo("scanner", "media-scan", fmt.Sprintf("成功插入 %d 部影片，跳过重复 %d 部，总计扫描 %d 部", successCount, skipCount, len(movies)))
+		if errors.Is(err, ErrScanCancelled) {
+			total.Duration = time.Since(startTime).String()
+			return total, err
 		}
+		if err != nil {
+			if s.logService != nil {
+				s.logService.LogError("scanner", "media-scan", fmt.Sprintf("扫描媒体库 [%s] 失败: %v", root.name, err))
+			}
+			// 只扫描单个根目录时直接返回错误，否则继续扫描其他根目录
+			if rootName != "" {
+				return nil, err
+			}
+			errs = append(errs, fmt.Sprintf("%s: %v", root.name, err))
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(roots) {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
 	}
+	total.Error = strings.Join(errs, "; ")
 
-	duration := time.Since(startTime)
-	if s.logService != nil {
-		s.logService.LogInfo("scanner", "media-scan", fmt.Sprintf("扫描完成，共找到 %d 部影片，耗时 %v", len(movies), duration))
+	// 刷新未变化记录的扫描时间
+	if err := s.localMovieRepo.UpdateLastScanTime(); err != nil && s.logService != nil {
+		s.logService.LogWarn("scanner", "media-scan", fmt.Sprintf("更新扫描时间失败: %v", err))
 	}
+
+	total.Duration = time.Since(startTime).String()
+	return total, nil
 }
 
-// ForceRescan 强制重新扫描
-func (s *ScannerService) ForceRescan() error {
+// scanRoot 扫描单个根目录并增量同步到数据库
+func (s *ScannerService) scanRoot(root *libraryScanner, job *scanJob) (*ScanResult, error) {
 	if s.logService != nil {
-		s.logService.LogInfo("scanner", "media-scan", "手动触发重新扫描...")
+		s.logService.LogInfo("scanner", "media-scan", fmt.Sprintf("开始扫描本地影片库 [%s]...", root.name))
 	}
-	s.scanAndStore()
-	return nil
-}
+	startTime := time.Now()
 
-// scanDirectory 扫描指定目录
-func (s *ScannerService) scanDirectory(rootPath string) ([]*model.LocalMovie, error) {
-	var movies []*model.LocalMovie
+	// 扫描文件系统
+	if _, err := os.Stat(root.root); err != nil {
+		return nil, fmt.Errorf("媒体库目录不存在: %s", root.root)
+	}
+	movies := root.scan(root.root, s.parseMovieInfo, job)
+	if job.cancelled() {
+		return nil, ErrScanCancelled
+	}
+	job.startSync(len(movies))
 
-	// 检查目录是否存在
-	if _, err := os.Stat(rootPath); os.IsNotExist(err) {
-		return movies, fmt.Errorf("媒体库目录不存在: %s", rootPath)
+	// 加载现有记录（包括软删除的，路径唯一约束对其同样生效）
+	existing, err := s.localMovieRepo.ListByRootWithDeleted(root.name, root.root)
+	if err != nil {
+		return nil, fmt.Errorf("加载现有影片记录失败: %v", err)
 	}
 
-	// 遍历女优目录
-	actressDirs, err := os.ReadDir(rootPath)
+	result, err := s.syncMovies(movies, existing, job)
+	if errors.Is(err, ErrScanCancelled) {
+		return result, err
+	}
 	if err != nil {
-		return movies, err
+		return nil, fmt.Errorf("同步数据库失败: %v", err)
 	}
 
-	for _, actressDir := range actressDirs {
-		if !actressDir.IsDir() || strings.HasPrefix(actressDir.Name(), ".") {
-			continue
-		}
+	result.Duration = time.Since(startTime).String()
+	if s.logService != nil {
+		s.logService.LogInfo("scanner", "media-scan", fmt.Sprintf("扫描完成 [%s]，共找到 %d 部影片，新增 %d 部，移除 %d 部，变更 %d 部，耗时 %s",
+			root.name, result.Total, result.Added, result.Removed, result.Changed, result.Duration))
+	}
+	return result, nil
+}
 
-		actressPath := filepath.Join(rootPath, actressDir.Name())
-		actressName := actressDir.Name()
+// syncMovies 将扫描结果与数据库现有记录比对，只写入有变化的部分
+// existing 为本次扫描范围内的现有记录，范围内未被扫描到的记录会被软删除
+// 任务被取消时停止同步且不移除任何记录，返回已同步部分的结果与 ErrScanCancelled
+func (s *ScannerService) syncMovies(movies, existing []*model.LocalMovie, job *scanJob) (*ScanResult, error) {
+	result := &ScanResult{Total: len(movies)}
 
-		// 遍历女优目录下的影片
-		movieDirs, err := os.ReadDir(actressPath)
-		if err != nil {
+	existingByPath := make(map[string]*model.LocalMovie, len(existing))
+	for _, movie := range existing {
+		existingByPath[movie.Path] = movie
+	}
+
+	seen := make(map[string]bool, len(movies))
+	for _, movie := range movies {
+		if job.cancelled() {
+			return result, ErrScanCancelled
+		}
+		job.processed()
+		if seen[movie.Path] {
 			continue
 		}
+		seen[movie.Path] = true
 
-		for _, movieDir := range movieDirs {
-			if !movieDir.IsDir() || strings.HasPrefix(movieDir.Name(), ".") {
+		old, ok := existingByPath[movie.Path]
+		if !ok {
+			s.analyzeMovie(movie)
+			if err := s.localMovieRepo.Create(movie); err != nil {
+				if s.logService != nil {
+					s.logService.LogWarn("scanner", "media-scan", fmt.Sprintf("插入影片失败 [%s]: %v", movie.Path, err))
+				}
 				continue
 			}
+			s.fillMovieMediaInfo(movie)
+			s.importCatalog(movie)
+			result.Added++
+			result.AddedPaths = append(result.AddedPaths, movie.Path)
+			continue
+		}
 
-			moviePath := filepath.Join(actressPath, movieDir.Name())
+		// 文件有变化或尚未分析时重新读取媒体信息与内容摘要
+		reanalyze := needAnalyze(old, movie)
+		reimport := s.needImport(old, movie)
 
-			// 扫描影片目录中的视频文件
-			videoFiles, err := s.findVideoFiles(moviePath)
-			if err != nil {
+		// 之前被移除的文件重新出现，恢复原记录以保持ID不变
+		if old.DeletedAt.Valid {
+			applyScannedFields(old, movie)
+			if reanalyze {
+				s.analyzeMovie(old)
+			}
+			if err := s.localMovieRepo.Restore(old); err != nil {
+				if s.logService != nil {
+					s.logService.LogWarn("scanner", "media-scan", fmt.Sprintf("恢复影片失败 [%s]: %v", movie.Path, err))
+				}
 				continue
 			}
+			if reanalyze {
+				s.fillMovieMediaInfo(old)
+			}
+			if reimport {
+				s.importCatalog(old)
+			}
+			result.Added++
+			result.AddedPaths = append(result.AddedPaths, old.Path)
+			continue
+		}
 
-			for _, videoFile := range videoFiles {
-				movie := s.parseMovieInfo(videoFile, actressName, movieDir.Name())
-				if movie != nil {
-					movies = append(movies, movie)
-				}
+		if !reanalyze && !movieChanged(old, movie) {
+			if reimport {
+				s.importCatalog(old)
 			}
+			result.Unchanged++
+			continue
+		}
+
+		applyScannedFields(old, movie)
+		if reanalyze {
+			s.analyzeMovie(old)
+		}
+		if err := s.localMovieRepo.Update(old); err != nil {
+			if s.logService != nil {
+				s.logService.LogWarn("scanner", "media-scan", fmt.Sprintf("更新影片失败 [%s]: %v", movie.Path, err))
+			}
+			continue
 		}
+		if reanalyze {
+			s.fillMovieMediaInfo(old)
+		}
+		if reimport {
+			s.importCatalog(old)
+		}
+		result.Changed++
+		result.ChangedPaths = append(result.ChangedPaths, old.Path)
+	}
+
+	// 软删除本次扫描中已不存在的文件
+	var removedIDs []uint
+	for _, old := range existing {
+		if !old.DeletedAt.Valid && !seen[old.Path] {
+			removedIDs = append(removedIDs, old.ID)
+			result.RemovedPaths = append(result.RemovedPaths, old.Path)
+		}
+	}
+	if err := s.localMovieRepo.SoftDeleteByIDs(removedIDs); err != nil {
+		return nil, fmt.Errorf("移除已删除影片失败: %v", err)
 	}
+	result.Removed = len(removedIDs)
 
-	return movies, nil
+	return result, nil
 }
 
-// findVideoFiles 查找视频文件（只查找主视频，排除花絮等）
-func (s *ScannerService) findVideoFiles(dirPath string) ([]string, error) {
-	var videoFiles []string
-	videoExtensions := []string{".mp4", ".mkv", ".avi", ".mov", ".wmv", ".m4v", ".flv", ".webm"}
-	
-	// 需要排除的目录名
-	excludeDirs := []string{"behind the scenes", "extrafanart", "trailers", "extras", "sample", "samples"}
-	
-	// 最小文件大小（100MB，排除预览等小文件）
-	minFileSize := int64(100 * 1024 * 1024)
-
-	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // 忽略错误，继续处理
+// fileChanged 判断视频文件本身（大小、修改时间、分段）是否有变化
+func fileChanged(old, scanned *model.LocalMovie) bool {
+	return old.Size != scanned.Size || !old.Modified.Equal(scanned.Modified) || partsChanged(old.Parts, scanned.Parts)
+}
+
+// movieChanged 判断扫描结果相对数据库记录是否有变化
+func movieChanged(old, scanned *model.LocalMovie) bool {
+	if fileChanged(old, scanned) {
+		return true
+	}
+	// 文件未变化时，NFO、图片或字幕等元数据也可能被修改
+	if subtitlesChanged(old.Subtitles, scanned.Subtitles) {
+		return true
+	}
+	return old.Title != scanned.Title ||
+		old.Code != scanned.Code ||
+		old.CodeKey != scanned.CodeKey ||
+		old.Actress != scanned.Actress ||
+		old.Root != scanned.Root ||
+		old.TitleKey != scanned.TitleKey ||
+		old.Version != scanned.Version ||
+		old.FanartPath != scanned.FanartPath ||
+		old.FanartURL != scanned.FanartURL ||
+		old.HasFanart != scanned.HasFanart ||
+		old.HasSubtitle != scanned.HasSubtitle ||
+		old.HasChineseSubtitle != scanned.HasChineseSubtitle ||
+		old.SubtitleLanguages != scanned.SubtitleLanguages ||
+		old.HardSubtitle != scanned.HardSubtitle ||
+		old.Uncensored != scanned.Uncensored ||
+		old.NFOPath != scanned.NFOPath ||
+		!old.NFOModified.Equal(scanned.NFOModified)
+}
+
+// partsChanged 判断分段文件是否有变化
+func partsChanged(old, scanned []model.LocalMoviePart) bool {
+	if len(old) != len(scanned) {
+		return true
+	}
+	sort.Slice(old, func(i, j int) bool { return old[i].Part < old[j].Part })
+	for i := range old {
+		if old[i].Part != scanned[i].Part ||
+			old[i].Path != scanned[i].Path ||
+			old[i].Size != scanned[i].Size ||
+			!old[i].Modified.Equal(scanned[i].Modified) {
+			return true
 		}
+	}
+	return false
+}
 
-		// 检查是否在排除的目录中
-		for _, excludeDir := range excludeDirs {
-			if strings.Contains(strings.ToLower(path), excludeDir) {
-				if d.IsDir() {
-					return filepath.SkipDir // 跳过整个目录
-				}
-				return nil // 跳过文件
+// applyScannedFields 将扫描得到的字段写入已有记录（保留ID与创建时间）
+func applyScannedFields(dst, scanned *model.LocalMovie) {
+	dst.Title = scanned.Title
+	dst.Code = scanned.Code
+	dst.CodeKey = scanned.CodeKey
+	dst.Actress = scanned.Actress
+	dst.Root = scanned.Root
+	dst.TitleKey = scanned.TitleKey
+	dst.Version = scanned.Version
+	dst.PartCount = scanned.PartCount
+	// 未变化的分段沿用已计算的内容摘要
+	for i := range scanned.Parts {
+		for _, part := range dst.Parts {
+			if part.Path == scanned.Parts[i].Path && part.Size == scanned.Parts[i].Size &&
+				part.Modified.Equal(scanned.Parts[i].Modified) {
+				scanned.Parts[i].ContentHash = part.ContentHash
+				break
 			}
 		}
+	}
+	dst.Parts = scanned.Parts
+	dst.Size = scanned.Size
+	dst.Modified = scanned.Modified
+	dst.Format = scanned.Format
+	dst.FanartPath = scanned.FanartPath
+	dst.FanartURL = scanned.FanartURL
+	dst.HasFanart = scanned.HasFanart
+	dst.HasSubtitle = scanned.HasSubtitle
+	dst.HasChineseSubtitle = scanned.HasChineseSubtitle
+	dst.SubtitleLanguages = scanned.SubtitleLanguages
+	dst.HardSubtitle = scanned.HardSubtitle
+	dst.Uncensored = scanned.Uncensored
+	dst.Subtitles = scanned.Subtitles
+	dst.NFOPath = scanned.NFOPath
+	dst.NFOModified = scanned.NFOModified
+}
 
-		if d.IsDir() {
-			return nil
-		}
+// pathInfo 根据目录布局从路径中解析出的影片信息
+type pathInfo struct {
+	root         *libraryScanner // 所属媒体库根目录
+	unit         string          // 影片所在的目录，单个文件即一部影片时为去掉分段后缀的文件路径
+	actress      string
+	code         string
+	title        string
+	dirName      string // 影片目录名，单个文件即一部影片时为空
+	parentName   string // 单个文件即一部影片时所在的目录名，用于补全番号
+	base         string // 去掉分段后缀的视频文件名（不含扩展名）
+	multiVersion bool   // 影片目录中存在多个版本
+}
 
-		// 获取文件信息
-		fileInfo, err := d.Info()
+// parseMovieInfo 解析影片信息，files 为同一版本按顺序排列的分段文件
+func (s *ScannerService) parseMovieInfo(files []string, info pathInfo) *model.LocalMovie {
+	// 获取文件信息，总大小为所有分段之和
+	filePath := files[0]
+	var totalSize int64
+	var modified time.Time
+	var parts []model.LocalMoviePart
+	for i, file := range files {
+		fileInfo, err := os.Stat(file)
 		if err != nil {
 			return nil
 		}
-		
-		// 排除小文件
-		if fileInfo.Size() < minFileSize {
-			return nil
+		partModified := fileInfo.ModTime().Truncate(time.Microsecond) // 与数据库精度一致，便于增量比对
+		totalSize += fileInfo.Size()
+		if partModified.After(modified) {
+			modified = partModified
 		}
-
-		// 检查文件扩展名
-		ext := strings.ToLower(filepath.Ext(path))
-		fileName := strings.ToLower(filepath.Base(path))
-		
-		// 排除包含 sample、trailer、preview 等关键词的文件
-		if strings.Contains(fileName, "sample") || 
-		   strings.Contains(fileName, "trailer") || 
-		   strings.Contains(fileName, "preview") ||
-		   strings.Contains(fileName, "fanart") {
-			return nil
-		}
-		
-		for _, validExt := range videoExtensions {
-			if ext == validExt {
-				// 优先匹配番号格式的文件名（如 START-395.mp4）
-				if matched, _ := regexp.MatchString(`^[A-Z]+-\d+\.[a-z]+$`, fileName); matched {
-					// 这是主视频文件，添加到列表前面
-					videoFiles = append([]string{path}, videoFiles...)
-				} else {
-					// 其他视频文件添加到后面
-					videoFiles = append(videoFiles, path)
-				}
-				break
-			}
+		if len(files) > 1 {
+			parts = append(parts, model.LocalMoviePart{
+				Part:     i + 1,
+				Path:     file,
+				Size:     fileInfo.Size(),
+				Modified: partModified,
+			})
 		}
+	}
 
-		return nil
-	})
-	
-	// 如果找到了主视频文件（番号格式），只返回第一个
-	if len(videoFiles) > 0 {
-		// 检查第一个是否是番号格式
-		fileName := filepath.Base(videoFiles[0])
-		if matched, _ := regexp.MatchString(`^[A-Z]+-\d+\.[a-z]+$`, fileName); matched {
-			return []string{videoFiles[0]}, err
+	// 提取番号和标题，布局模板中未给出的字段再从目录名或文件名中解析
+	baseName := info.base
+	code, title := info.code, info.title
+	if code == "" || title == "" {
+		parsedCode, parsedTitle := s.extractCodeAndTitle(info.dirName, baseName)
+		if code == "" {
+			code = parsedCode
+		}
+		if title == "" {
+			title = parsedTitle
 		}
-		// 否则返回第一个大文件作为主视频
-		return []string{videoFiles[0]}, err
 	}
-
-	return videoFiles, err
-}
-
-// parseMovieInfo 解析影片信息
-func (s *ScannerService) parseMovieInfo(filePath, actress, dirName string) *model.LocalMovie {
-	// 获取文件信息
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return nil
+	if code == "" && info.parentName != "" {
+		code, _ = s.parseNameForCode(info.parentName)
+	}
+	if title == "" {
+		title = baseName
 	}
 
-	// 提取番号和标题
-	code, title := s.extractCodeAndTitle(dirName, filepath.Base(filePath))
-	
-	// 尝试从NFO文件读取详细信息
+	// 多部影片共用一个目录时，只使用与视频同名的NFO和图片
 	movieDir := filepath.Dir(filePath)
-	if nfoTitle, nfoCode := s.readNFOFile(movieDir); nfoTitle != "" {
-		title = nfoTitle
-		if nfoCode != "" && code == "" {
-			code = nfoCode
+	shared := info.dirName == ""
+
+	// 尝试从NFO文件读取详细信息，完整内容在入库后写入影片元数据
+	nfo, nfoPath := s.readNFOFile(movieDir, baseName, shared)
+	var nfoModified time.Time
+	if nfo != nil {
+		if nfo.Title != "" {
+			title = nfo.Title
+		}
+		if nfoCode := nfo.MovieCode(); nfoCode != "" && code == "" {
+			code = moviecode.Normalize(nfoCode)
+		}
+		if info, err := os.Stat(nfoPath); err == nil {
+			nfoModified = info.ModTime().Truncate(time.Microsecond)
 		}
 	}
 
 	// 查找fanart图片
-	fanartPath, fanartURL, hasFanart := s.findFanart(movieDir)
+	fanartPath, fanartURL, hasFanart := s.findFanart(info.root, movieDir, baseName, shared)
+
+	// 查找外挂字幕，并识别文件名中的中文字幕标记
+	subtitles := findSubtitles(movieDir, baseName, shared)
+	hardSubtitle := hasChineseTag(baseName, info.dirName)
+	languages := subtitleLanguages(subtitles, hardSubtitle)
+	uncensored := hasUncensoredTag(baseName, info.dirName)
+
+	// 同一番号的不同版本归为同一逻辑影片，无番号时按影片所在位置区分
+	titleKey := strings.ToUpper(code)
+	if titleKey == "" {
+		titleKey = info.unit
+	}
 
 	return &model.LocalMovie{
 		Title:       title,
 		Code:        code,
-		Actress:     actress,
+		CodeKey:     movieCodeKey(code, baseName, info.dirName, info.parentName, title),
+		Actress:     info.actress,
 		Path:        filePath,
-		Size:        fileInfo.Size(),
-		Modified:    fileInfo.ModTime(),
+		Root:        info.root.name,
+		TitleKey:    titleKey,
+		Version:     versionLabel(baseName, code, info.multiVersion),
+		PartCount:   len(files),
+		Size:        totalSize,
+		Modified:    modified,
 		Format:      strings.ToUpper(strings.TrimPrefix(filepath.Ext(filePath), ".")),
 		FanartPath:  fanartPath,
 		FanartURL:   fanartURL,
 		HasFanart:   hasFanart,
 		LastScanned: time.Now(),
+		Parts:       parts,
+
+		HasSubtitle:        hardSubtitle || len(subtitles) > 0,
+		HasChineseSubtitle: hasLanguage(languages, "zh"),
+		SubtitleLanguages:  strings.Join(languages, ","),
+		HardSubtitle:       hardSubtitle,
+		Subtitles:          subtitles,
+		Uncensored:         uncensored,
+
+		NFOPath:     nfoPath,
+		NFOModified: nfoModified,
 	}
 }
 
 // extractCodeAndTitle 从目录名或文件名中提取番号和标题
 func (s *ScannerService) extractCodeAndTitle(dirName, fileName string) (string, string) {
 	// 优先从目录名提取
-	if code, title := s.parseNameForCode(dirName); code != "" {
-		return code, title
+	if dirName != "" {
+		if code, title := s.parseNameForCode(dirName); code != "" {
+			return code, title
+		}
 	}
 
 	// 如果目录名没有番号，从文件名提取
@@ -342,38 +607,32 @@ func (s *ScannerService) extractCodeAndTitle(dirName, fileName string) (string,
 	}
 
 	// 如果都没有，使用目录名作为标题
+	if dirName == "" {
+		return "", fileName
+	}
 	return "", dirName
 }
 
-// parseNameForCode 解析名称中的番号
+// parseNameForCode 解析名称中的番号，返回标准番号与番号之后的标题
+// 番号之后没有文字时使用番号之前的部分，都没有时标题为原名称
 func (s *ScannerService) parseNameForCode(name string) (string, string) {
-	// 匹配 [CODE-123] 格式
-	re1 := regexp.MustCompile(`\[([A-Z]+[-_]?\d+)\](.*)`)
-	if matches := re1.FindStringSubmatch(name); len(matches) >= 2 {
-		code := strings.ToUpper(matches[1])
-		title := strings.TrimSpace(matches[2])
-		if title == "" {
-			title = name
-		}
-		return code, title
+	code, ok := moviecode.Parse(name)
+	if !ok {
+		return "", name
 	}
 
-	// 匹配 CODE-123 格式（不在括号中）
-	re2 := regexp.MustCompile(`^([A-Z]+[-_]?\d+)\s*(.*)`)
-	if matches := re2.FindStringSubmatch(name); len(matches) >= 2 {
-		code := strings.ToUpper(matches[1])
-		title := strings.TrimSpace(matches[2])
-		if title == "" {
-			title = name
-		}
-		return code, title
+	title := strings.Trim(name[code.End:], " -_.]）)】")
+	if title == "" {
+		title = strings.Trim(name[:code.Start], " -_.[（(【")
 	}
-
-	return "", name
+	if title == "" {
+		title = name
+	}
+	return code.String(), title
 }
 
-// findFanart 查找fanart图片（优先fanart.jpg）
-func (s *ScannerService) findFanart(movieDir string) (string, string, bool) {
+// findFanart 查找fanart图片（优先fanart.jpg），shared 为 true 时只匹配 <视频名>-fanart.jpg 这类同名图片
+func (s *ScannerService) findFanart(root *libraryScanner, movieDir, baseName string, shared bool) (string, string, bool) {
 	imageExtensions := []string{".jpg", ".jpeg", ".png", ".webp", ".bmp"}
 	// 按优先级排序：fanart.jpg 优先级最高
 	fanartNames := []string{"fanart", "poster", "thumb", "cover", "thumbnail"}
@@ -384,6 +643,25 @@ func (s *ScannerService) findFanart(movieDir string) (string, string, bool) {
 		return "", "", false
 	}
 
+	// 与视频同名的图片（Kodi命名规范）
+	prefix := strings.ToLower(baseName) + "-"
+	for _, fanartName := range fanartNames {
+		for _, ext := range imageExtensions {
+			targetFile := prefix + fanartName + ext
+			for _, file := range files {
+				if !file.IsDir() && strings.ToLower(file.Name()) == targetFile {
+					fullPath := filepath.Join(movieDir, file.Name())
+					if urlPath := root.imageURL(fullPath); urlPath != "" {
+						return fullPath, urlPath, true
+					}
+				}
+			}
+		}
+	}
+	if shared {
+		return "", "", false
+	}
+
 	// 优先查找完全匹配的文件
 	for _, fanartName := range fanartNames {
 		for _, ext := range imageExtensions {
@@ -395,13 +673,10 @@ func (s *ScannerService) findFanart(movieDir string) (string, string, bool) {
 				
 				if strings.ToLower(file.Name()) == targetFile {
 					fullPath := filepath.Join(movieDir, file.Name())
-					// 生成相对于媒体库的URL路径
-					relPath, err := filepath.Rel(s.mediaLibraryPath, fullPath)
-					if err != nil {
+					urlPath := root.imageURL(fullPath)
+					if urlPath == "" {
 						continue
 					}
-					// 将路径转换为URL格式并进行编码
-					urlPath := "/api/v1/local/image/" + url.PathEscape(strings.ReplaceAll(relPath, "\\", "/"))
 					return fullPath, urlPath, true
 				}
 			}
@@ -434,13 +709,10 @@ func (s *ScannerService) findFanart(movieDir string) (string, string, bool) {
 		for _, fanartName := range fanartNames {
 			if strings.Contains(fileName, fanartName) {
 				fullPath := filepath.Join(movieDir, file.Name())
-				// 生成相对于媒体库的URL路径
-				relPath, err := filepath.Rel(s.mediaLibraryPath, fullPath)
-				if err != nil {
+				urlPath := root.imageURL(fullPath)
+				if urlPath == "" {
 					continue
 				}
-				// 将路径转换为URL格式并进行编码
-				urlPath := "/api/v1/local/image/" + url.PathEscape(strings.ReplaceAll(relPath, "\\", "/"))
 				return fullPath, urlPath, true
 			}
 		}
@@ -449,51 +721,32 @@ func (s *ScannerService) findFanart(movieDir string) (string, string, bool) {
 	return "", "", false
 }
 
-// readNFOFile 读取NFO文件获取影片信息
-func (s *ScannerService) readNFOFile(movieDir string) (string, string) {
+// readNFOFile 读取NFO文件获取影片信息，优先与视频同名的NFO，shared 为 true 时不使用其他NFO
+func (s *ScannerService) readNFOFile(movieDir, baseName string, shared bool) (*NFOMovie, string) {
 	// 查找NFO文件
 	files, err := os.ReadDir(movieDir)
 	if err != nil {
-		return "", ""
+		return nil, ""
 	}
-	
+
+	var candidates []string
 	for _, file := range files {
-		if file.IsDir() {
+		if file.IsDir() || strings.ToLower(filepath.Ext(file.Name())) != ".nfo" {
 			continue
 		}
-		
-		// 检查是否是NFO文件
-		if strings.ToLower(filepath.Ext(file.Name())) == ".nfo" {
-			nfoPath := filepath.Join(movieDir, file.Name())
-			
-			// 读取NFO文件
-			nfoFile, err := os.Open(nfoPath)
-			if err != nil {
-				continue
-			}
-			defer nfoFile.Close()
-			
-			// 读取文件内容
-			data, err := io.ReadAll(nfoFile)
-			if err != nil {
-				continue
-			}
-			
-			// 解析XML
-			var nfoMovie NFOMovie
-			err = xml.Unmarshal(data, &nfoMovie)
-			if err != nil {
-				// 如果XML解析失败，尝试提取CDATA中的内容
-				titlePattern := regexp.MustCompile(`<title><!\[CDATA\[(.*?)\]\]></title>`)
-				if matches := titlePattern.FindSubmatch(data); len(matches) > 1 {
-					return string(matches[1]), ""
-				}
-				continue
-			}
-			
-			return nfoMovie.Title, nfoMovie.Code
+		nfoPath := filepath.Join(movieDir, file.Name())
+		if strings.EqualFold(strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())), baseName) {
+			candidates = append([]string{nfoPath}, candidates...)
+		} else if !shared {
+			candidates = append(candidates, nfoPath)
 		}
 	}
-	
-	return "", ""
+
+	for _, nfoPath := range candidates {
+		if nfo, err := parseNFO(nfoPath); err == nil {
+			return nfo, nfoPath
+		}
+	}
+
+	return nil, ""
 }
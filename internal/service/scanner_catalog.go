@@ -0,0 +1,38 @@
+package service
+
+import (
+	"fmt"
+	"nsfw-go/internal/model"
+)
+
+// needImport 判断是否需要将NFO重新导入影片元数据：NFO有变化或尚未关联影片
+func (s *ScannerService) needImport(old, scanned *model.LocalMovie) bool {
+	if s.catalogService == nil || scanned.NFOPath == "" {
+		return false
+	}
+	return old.MovieID == nil || old.NFOPath != scanned.NFOPath || !old.NFOModified.Equal(scanned.NFOModified)
+}
+
+// importCatalog 导入本地影片的NFO元数据，并记录关联的影片ID
+func (s *ScannerService) importCatalog(local *model.LocalMovie) {
+	if s.catalogService == nil || local.NFOPath == "" {
+		return
+	}
+	movie, err := s.catalogService.ImportLocalMovie(local)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogWarn("scanner", "nfo-import", fmt.Sprintf("导入NFO失败 [%s]: %v", local.NFOPath, err))
+		}
+		return
+	}
+	if movie == nil || (local.MovieID != nil && *local.MovieID == movie.ID) {
+		return
+	}
+	if err := s.localMovieRepo.SetMovieID(local.ID, &movie.ID); err != nil {
+		if s.logService != nil {
+			s.logService.LogWarn("scanner", "nfo-import", fmt.Sprintf("关联影片失败 [%s]: %v", local.Path, err))
+		}
+		return
+	}
+	local.MovieID = &movie.ID
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"nsfw-go/internal/moviecode"
+	"path/filepath"
+	"strings"
+)
+
+// codeKeyBatchSize 补充番号等价键时每批处理的记录数
+const codeKeyBatchSize = 500
+
+// movieCodeKey 依次尝试各段文本，返回第一个能识别出的番号等价键
+func movieCodeKey(texts ...string) string {
+	for _, text := range texts {
+		if key := moviecode.Key(text); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// OnLibraryChanged 注册媒体库变化后的回调，完整扫描或目录增量扫描有新增、移除或变更时调用
+func (s *ScannerService) OnLibraryChanged(fn func()) {
+	s.libraryChanged = append(s.libraryChanged, fn)
+}
+
+// notifyLibraryChanged 扫描结果有变化时调用已注册的回调
+func (s *ScannerService) notifyLibraryChanged(result *ScanResult) {
+	if result == nil || result.Added+result.Removed+result.Changed == 0 {
+		return
+	}
+	for _, fn := range s.libraryChanged {
+		fn()
+	}
+}
+
+// BackfillCodeKeys 为没有番号等价键的本地影片补充等价键，返回补充的数量
+func (s *ScannerService) BackfillCodeKeys() (int, error) {
+	updated := 0
+	var afterID uint
+	for {
+		movies, err := s.localMovieRepo.ListWithoutCodeKey(afterID, codeKeyBatchSize)
+		if err != nil {
+			return updated, err
+		}
+		for _, movie := range movies {
+			afterID = movie.ID
+			base := strings.TrimSuffix(filepath.Base(movie.Path), filepath.Ext(movie.Path))
+			key := movieCodeKey(movie.Code, base, filepath.Base(filepath.Dir(movie.Path)), movie.Title)
+			if key == "" {
+				continue
+			}
+			if err := s.localMovieRepo.UpdateCodeKey(movie.ID, key); err != nil {
+				return updated, fmt.Errorf("更新番号等价键失败 [%s]: %v", movie.Path, err)
+			}
+			updated++
+		}
+		if len(movies) < codeKeyBatchSize {
+			return updated, nil
+		}
+	}
+}
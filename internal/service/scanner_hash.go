@@ -0,0 +1,96 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"nsfw-go/internal/model"
+	"os"
+)
+
+// 计算内容摘要时读取的首尾数据块大小
+const hashChunkSize = 64 * 1024
+
+// fileContentHash 计算文件的快速内容摘要：文件大小 + 开头与结尾各 64KB 数据
+// 与 OpenSubtitles 哈希思路相同，无需读取整个文件即可识别相同的发布版本
+func fileContentHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := stat.Size()
+
+	h := sha256.New()
+	binary.Write(h, binary.LittleEndian, uint64(size))
+
+	chunk := int64(hashChunkSize)
+	if size < chunk {
+		chunk = size
+	}
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, chunk)); err != nil {
+		return "", err
+	}
+	if size > chunk {
+		tail := size - chunk
+		if tail < chunk {
+			// 文件不足两个数据块时只读取剩余部分，避免重复计算
+			chunk = tail
+		}
+		if _, err := io.Copy(h, io.NewSectionReader(f, size-chunk, chunk)); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// hashMovie 计算影片的内容摘要，多分段影片分别计算各分段后再组合
+func (s *ScannerService) hashMovie(movie *model.LocalMovie) {
+	if len(movie.Parts) == 0 {
+		hash, err := fileContentHash(movie.Path)
+		if err != nil {
+			s.logHashError(movie.Path, err)
+		}
+		movie.ContentHash = hash
+		return
+	}
+
+	h := sha256.New()
+	for i := range movie.Parts {
+		hash, err := fileContentHash(movie.Parts[i].Path)
+		if err != nil {
+			s.logHashError(movie.Parts[i].Path, err)
+			movie.ContentHash = ""
+			return
+		}
+		movie.Parts[i].ContentHash = hash
+		io.WriteString(h, hash)
+	}
+	movie.ContentHash = hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// logHashError 记录摘要计算失败
+func (s *ScannerService) logHashError(path string, err error) {
+	if s.logService != nil {
+		s.logService.LogWarn("scanner", "media-hash", fmt.Sprintf("计算文件摘要失败 [%s]: %v", path, err))
+	}
+}
+
+// analyzeMovie 读取影片的媒体信息与内容摘要，只在文件新增或变化时执行
+func (s *ScannerService) analyzeMovie(movie *model.LocalMovie) {
+	s.probeMovie(movie)
+	s.hashMovie(movie)
+}
+
+// needAnalyze 判断已有记录是否需要重新读取媒体信息与内容摘要
+func needAnalyze(old, scanned *model.LocalMovie) bool {
+	return fileChanged(old, scanned) || !old.Probed || old.ContentHash == ""
+}
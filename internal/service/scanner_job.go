@@ -0,0 +1,434 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/repo"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrScanInProgress 已有扫描任务正在执行
+	ErrScanInProgress = errors.New("已有扫描任务正在执行")
+	// ErrScanCancelled 扫描已取消
+	ErrScanCancelled = errors.New("扫描已取消")
+	// ErrScanJobNotFound 扫描任务不存在或已结束
+	ErrScanJobNotFound = errors.New("扫描任务不存在或已结束")
+	// ErrScanHistoryDisabled 未启用扫描记录
+	ErrScanHistoryDisabled = errors.New("未启用扫描记录")
+	// ErrPathOutsideLibrary 路径不在任何已启用的媒体库根目录下
+	ErrPathOutsideLibrary = errors.New("路径不在媒体库根目录下")
+)
+
+// 扫描阶段
+const (
+	ScanPhaseWalking = "walking" // 遍历目录
+	ScanPhaseSyncing = "syncing" // 同步数据库
+)
+
+// ScanJobStatus 扫描任务状态
+type ScanJobStatus struct {
+	ID          uint        `json:"id"`
+	Root        string      `json:"root"` // 空表示所有根目录
+	Trigger     string      `json:"trigger"`
+	Status      string      `json:"status"`
+	Phase       string      `json:"phase"`
+	CurrentRoot string      `json:"current_root"`
+	DirsVisited int         `json:"dirs_visited"`
+	FilesFound  int         `json:"files_found"`
+	MoviesFound int         `json:"movies_found"` // 当前根目录找到的影片数
+	Processed   int         `json:"processed"`    // 当前根目录已同步的影片数
+	Progress    float64     `json:"progress"`     // 估计进度（0-100），无法估计时为 -1
+	ETASeconds  int         `json:"eta_seconds"`  // 估计剩余秒数，无法估计时为 -1
+	StartedAt   time.Time   `json:"started_at"`
+	FinishedAt  *time.Time  `json:"finished_at"`
+	Elapsed     string      `json:"elapsed"`
+	Result      *ScanResult `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// scanJob 运行中的扫描任务
+type scanJob struct {
+	mu        sync.Mutex
+	status    ScanJobStatus
+	syncStart time.Time
+	// 上次同范围扫描的耗时与目录数，用于估算进度与剩余时间
+	lastDuration time.Duration
+	lastDirs     int
+	persisted    bool // 是否已创建扫描记录
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// cancelled 任务是否已被取消，job 为空（目录增量扫描）时始终返回 false
+func (j *scanJob) cancelled() bool {
+	return j != nil && j.ctx.Err() != nil
+}
+
+// visitDir 记录遍历的目录
+func (j *scanJob) visitDir() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.status.DirsVisited++
+	j.mu.Unlock()
+}
+
+// foundFile 记录找到的视频文件
+func (j *scanJob) foundFile() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.status.FilesFound++
+	j.mu.Unlock()
+}
+
+// startRoot 开始扫描一个根目录
+func (j *scanJob) startRoot(name string) {
+	j.mu.Lock()
+	j.status.CurrentRoot = name
+	j.status.Phase = ScanPhaseWalking
+	j.status.MoviesFound = 0
+	j.status.Processed = 0
+	j.mu.Unlock()
+}
+
+// startSync 目录遍历完成，开始同步数据库
+func (j *scanJob) startSync(movies int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.status.Phase = ScanPhaseSyncing
+	j.status.MoviesFound = movies
+	j.status.Processed = 0
+	j.syncStart = time.Now()
+	j.mu.Unlock()
+}
+
+// processed 记录已同步的影片
+func (j *scanJob) processed() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.status.Processed++
+	j.mu.Unlock()
+}
+
+// snapshot 返回任务状态的副本，并估算进度与剩余时间
+func (j *scanJob) snapshot() *ScanJobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := j.status
+	now := time.Now()
+	if status.FinishedAt != nil {
+		now = *status.FinishedAt
+	}
+	elapsed := now.Sub(status.StartedAt)
+	status.Elapsed = elapsed.Round(time.Second).String()
+	status.Progress, status.ETASeconds = -1, -1
+
+	if status.Status != model.ScanStatusRunning {
+		if status.Status == model.ScanStatusCompleted {
+			status.Progress = 100
+		}
+		status.ETASeconds = 0
+		return &status
+	}
+
+	// 进度按遍历的目录数与上次扫描比较，遍历通常占扫描的大部分时间
+	if j.lastDirs > 0 {
+		status.Progress = float64(status.DirsVisited) / float64(j.lastDirs) * 100
+		if status.Progress > 99 {
+			status.Progress = 99
+		}
+	}
+
+	var eta time.Duration = -1
+	if j.lastDuration > 0 {
+		eta = j.lastDuration - elapsed
+		if eta < 0 {
+			eta = 0
+		}
+	}
+	if status.Phase == ScanPhaseSyncing && status.Processed > 0 {
+		perMovie := now.Sub(j.syncStart) / time.Duration(status.Processed)
+		if syncETA := perMovie * time.Duration(status.MoviesFound-status.Processed); syncETA > eta {
+			eta = syncETA
+		}
+	}
+	if eta >= 0 {
+		status.ETASeconds = int(eta.Round(time.Second).Seconds())
+	}
+	return &status
+}
+
+// SetHistoryRepository 设置扫描记录仓库，完成的扫描会保存耗时与新增、移除、变更的文件列表
+func (s *ScannerService) SetHistoryRepository(historyRepo repo.ScanHistoryRepository) {
+	s.historyRepo = historyRepo
+}
+
+// StartScan 在后台启动扫描任务，rootName 为空时扫描所有根目录；已有任务在执行时返回 ErrScanInProgress
+func (s *ScannerService) StartScan(rootName, trigger string) (*ScanJobStatus, error) {
+	job, err := s.startJob(rootName, trigger)
+	if err != nil {
+		return nil, err
+	}
+	return job.snapshot(), nil
+}
+
+// RunScan 启动扫描任务并等待其结束
+func (s *ScannerService) RunScan(rootName, trigger string) (*ScanJobStatus, error) {
+	job, err := s.startJob(rootName, trigger)
+	if err != nil {
+		return nil, err
+	}
+	<-job.done
+	return job.snapshot(), nil
+}
+
+// CurrentScan 返回正在执行的扫描任务，没有时返回 nil
+func (s *ScannerService) CurrentScan() *ScanJobStatus {
+	s.jobMu.Lock()
+	job := s.currentJob
+	s.jobMu.Unlock()
+	if job == nil {
+		return nil
+	}
+	return job.snapshot()
+}
+
+// CancelScan 取消正在执行的扫描任务，id 为 0 时取消当前任务
+func (s *ScannerService) CancelScan(id uint) (*ScanJobStatus, error) {
+	s.jobMu.Lock()
+	job := s.currentJob
+	s.jobMu.Unlock()
+	if job == nil || (id != 0 && job.status.ID != id) {
+		return nil, ErrScanJobNotFound
+	}
+
+	job.cancel()
+	if s.logService != nil {
+		s.logService.LogInfo("scanner", "media-scan", fmt.Sprintf("扫描任务 #%d 已请求取消", job.status.ID))
+	}
+	<-job.done
+	return job.snapshot(), nil
+}
+
+// Exclusive 在扫描锁内执行 fn，用于整理文件等需要避免与扫描同时修改媒体库的操作
+// 已有扫描任务在执行时返回 ErrScanInProgress
+func (s *ScannerService) Exclusive(fn func() error) error {
+	if s.CurrentScan() != nil {
+		return ErrScanInProgress
+	}
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	return fn()
+}
+
+// RescanPath 增量同步媒体库中的指定目录，用于导入影片等已知位置的变化；
+// 目录为根目录本身时启动整个根目录的扫描
+func (s *ScannerService) RescanPath(dir string) error {
+	dir = filepath.Clean(dir)
+	if root := s.rootForPath(dir); root != nil {
+		s.rescanSubtree(dir)
+		return nil
+	}
+	for _, root := range s.roots {
+		if root.root == dir {
+			_, err := s.StartScan(root.name, model.ScanTriggerImport)
+			return err
+		}
+	}
+	return ErrPathOutsideLibrary
+}
+
+// ScanInterval 根目录的默认定时扫描间隔（media.roots[].scan_interval），根目录不存在时返回 0
+func (s *ScannerService) ScanInterval(rootName string) time.Duration {
+	root := s.findRoot(rootName)
+	if root == nil {
+		return 0
+	}
+	return root.interval
+}
+
+// ScheduledScan 返回定时扫描指定根目录的任务函数，等待扫描结束并返回结果统计
+func (s *ScannerService) ScheduledScan(rootName string) ScheduledJobFunc {
+	return func(ctx context.Context) (string, error) {
+		status, err := s.RunScan(rootName, model.ScanTriggerSchedule)
+		if err != nil {
+			return "", err
+		}
+		if status.Error != "" {
+			return "", errors.New(status.Error)
+		}
+		if status.Result == nil {
+			return status.Status, nil
+		}
+		return fmt.Sprintf("扫描到 %d 部影片，新增 %d，移除 %d，变更 %d",
+			status.Result.Total, status.Result.Added, status.Result.Removed, status.Result.Changed), nil
+	}
+}
+
+// ScanHistory 分页获取扫描记录
+func (s *ScannerService) ScanHistory(offset, limit int, root string) ([]*model.ScanHistory, int64, error) {
+	if s.historyRepo == nil {
+		return nil, 0, ErrScanHistoryDisabled
+	}
+	return s.historyRepo.List(offset, limit, root)
+}
+
+// GetScanHistory 获取扫描记录详情（含变更文件列表）
+func (s *ScannerService) GetScanHistory(id uint) (*model.ScanHistory, error) {
+	if s.historyRepo == nil {
+		return nil, ErrScanHistoryDisabled
+	}
+	return s.historyRepo.GetByID(id)
+}
+
+// startJob 创建扫描任务并在后台执行
+func (s *ScannerService) startJob(rootName, trigger string) (*scanJob, error) {
+	if rootName != "" && s.findRoot(rootName) == nil {
+		return nil, fmt.Errorf("媒体库根目录不存在或未启用: %s", rootName)
+	}
+
+	s.jobMu.Lock()
+	defer s.jobMu.Unlock()
+	if s.currentJob != nil {
+		return nil, ErrScanInProgress
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	job := &scanJob{
+		status: ScanJobStatus{
+			Root:      rootName,
+			Trigger:   trigger,
+			Status:    model.ScanStatusRunning,
+			Phase:     ScanPhaseWalking,
+			StartedAt: time.Now(),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if s.historyRepo != nil {
+		if last, err := s.historyRepo.GetLastCompleted(rootName); err == nil {
+			job.lastDuration = time.Duration(last.DurationMs) * time.Millisecond
+			job.lastDirs = last.DirsVisited
+		}
+		history := &model.ScanHistory{
+			Root:      rootName,
+			Trigger:   trigger,
+			Status:    model.ScanStatusRunning,
+			StartedAt: job.status.StartedAt,
+		}
+		if err := s.historyRepo.Create(history); err != nil {
+			if s.logService != nil {
+				s.logService.LogWarn("scanner", "media-scan", fmt.Sprintf("保存扫描记录失败: %v", err))
+			}
+		} else {
+			job.status.ID = history.ID
+			job.persisted = true
+		}
+	}
+	if job.status.ID == 0 {
+		s.jobSeq++
+		job.status.ID = s.jobSeq
+	}
+
+	s.currentJob = job
+	go s.runJob(job)
+	return job, nil
+}
+
+// runJob 执行扫描任务，结束后保存扫描记录
+func (s *ScannerService) runJob(job *scanJob) {
+	defer close(job.done)
+	defer job.cancel()
+
+	result, err := s.scanAndStore(job)
+
+	now := time.Now()
+	job.mu.Lock()
+	job.status.FinishedAt = &now
+	job.status.Result = result
+	switch {
+	case errors.Is(err, ErrScanCancelled):
+		job.status.Status = model.ScanStatusCancelled
+	case err != nil:
+		job.status.Status = model.ScanStatusFailed
+		job.status.Error = err.Error()
+	default:
+		job.status.Status = model.ScanStatusCompleted
+		if result != nil && result.Error != "" {
+			job.status.Error = result.Error
+		}
+	}
+	status := job.status
+	job.mu.Unlock()
+
+	if job.persisted {
+		s.saveHistory(&status)
+	}
+
+	s.jobMu.Lock()
+	s.currentJob = nil
+	s.jobMu.Unlock()
+
+	s.notifyLibraryChanged(result)
+
+	if s.logService != nil && status.Status == model.ScanStatusCancelled {
+		s.logService.LogInfo("scanner", "media-scan", fmt.Sprintf("扫描任务 #%d 已取消", status.ID))
+	}
+}
+
+// saveHistory 保存扫描记录
+func (s *ScannerService) saveHistory(status *ScanJobStatus) {
+	history, err := s.historyRepo.GetByID(status.ID)
+	if err != nil {
+		return
+	}
+
+	history.Status = status.Status
+	history.FinishedAt = status.FinishedAt
+	history.DurationMs = status.FinishedAt.Sub(status.StartedAt).Milliseconds()
+	history.DirsVisited = status.DirsVisited
+	history.FilesFound = status.FilesFound
+	history.Error = status.Error
+	if result := status.Result; result != nil {
+		history.Total = result.Total
+		history.Added = result.Added
+		history.Removed = result.Removed
+		history.Changed = result.Changed
+		history.Unchanged = result.Unchanged
+		history.AddedPaths = result.AddedPaths
+		history.RemovedPaths = result.RemovedPaths
+		history.ChangedPaths = result.ChangedPaths
+	}
+	if err := s.historyRepo.Update(history); err != nil && s.logService != nil {
+		s.logService.LogWarn("scanner", "media-scan", fmt.Sprintf("保存扫描记录失败: %v", err))
+	}
+}
+
+// merge 合并单个根目录的扫描结果
+func (r *ScanResult) merge(other *ScanResult) {
+	r.Total += other.Total
+	r.Added += other.Added
+	r.Removed += other.Removed
+	r.Changed += other.Changed
+	r.Unchanged += other.Unchanged
+	r.AddedPaths = append(r.AddedPaths, other.AddedPaths...)
+	r.RemovedPaths = append(r.RemovedPaths, other.RemovedPaths...)
+	r.ChangedPaths = append(r.ChangedPaths, other.ChangedPaths...)
+}
@@ -0,0 +1,461 @@
+package service
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/moviecode"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 布局模式
+const (
+	LayoutModeTemplate  = "template"  // 按路径模板解析，如 {actress}/{code} {title}
+	LayoutModeFlat      = "flat"      // 根目录下每个文件或目录即一部影片
+	LayoutModeRecursive = "recursive" // 递归查找，每个视频文件即一部影片
+)
+
+// DefaultLayout 默认布局：女优目录/影片目录/视频文件
+const DefaultLayout = "{actress}/{dir}"
+
+// 默认支持的视频格式
+var defaultVideoExts = []string{".mp4", ".mkv", ".avi", ".mov", ".wmv", ".m4v", ".flv", ".webm"}
+
+// 影片目录内需要跳过的附加内容目录
+var extraDirNames = map[string]bool{
+	"behind the scenes": true,
+	"extrafanart":       true,
+	"trailers":          true,
+	"extras":            true,
+	"sample":            true,
+	"samples":           true,
+}
+
+// 模板占位符对应的匹配规则
+var layoutPlaceholders = map[string]string{
+	"actress": `.+?`,
+	"studio":  `.+?`,
+	"title":   `.*?`,
+	"code":    `[^/]+?`, // 番号格式多样（FC2-PPV-1234567、123456-789），匹配后用 moviecode 校验
+	"year":    `\d{4}`,
+	"dir":     `.+`,
+	"*":       `.*?`,
+}
+
+var layoutPlaceholderPattern = regexp.MustCompile(`\{([a-z*]+)\}`)
+
+// 番号格式的主视频文件名（如 START-395.mp4）
+var mainVideoPattern = regexp.MustCompile(`(?i)^[A-Z]+-\d+\.[a-z0-9]+$`)
+
+// LibraryLayout 媒体库目录布局
+type LibraryLayout struct {
+	Mode     string `json:"mode"`
+	Template string `json:"template,omitempty"`
+	segments []*regexp.Regexp
+}
+
+// ParseLibraryLayout 解析布局定义，支持 "flat"、"recursive" 或路径模板
+func ParseLibraryLayout(spec string) (*LibraryLayout, error) {
+	spec = strings.Trim(strings.TrimSpace(spec), "/")
+	switch spec {
+	case "":
+		spec = DefaultLayout
+	case LayoutModeFlat, LayoutModeRecursive:
+		return &LibraryLayout{Mode: spec}, nil
+	}
+
+	layout := &LibraryLayout{Mode: LayoutModeTemplate, Template: spec}
+	for _, segment := range strings.Split(spec, "/") {
+		re, err := compileLayoutSegment(segment)
+		if err != nil {
+			return nil, fmt.Errorf("无效的布局模板 %q: %v", spec, err)
+		}
+		layout.segments = append(layout.segments, re)
+	}
+	return layout, nil
+}
+
+// compileLayoutSegment 将模板的一级目录编译为正则，占位符转换为命名分组
+func compileLayoutSegment(segment string) (*regexp.Regexp, error) {
+	if segment == "" {
+		return nil, fmt.Errorf("模板中存在空目录")
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString(`^`)
+	last := 0
+	for _, loc := range layoutPlaceholderPattern.FindAllStringSubmatchIndex(segment, -1) {
+		pattern.WriteString(regexp.QuoteMeta(segment[last:loc[0]]))
+		name := segment[loc[2]:loc[3]]
+		rule, ok := layoutPlaceholders[name]
+		if !ok {
+			return nil, fmt.Errorf("未知占位符 {%s}", name)
+		}
+		if name == "*" {
+			pattern.WriteString(`(?:` + rule + `)`)
+		} else {
+			pattern.WriteString(`(?P<` + name + `>` + rule + `)`)
+		}
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(segment[last:]))
+	pattern.WriteString(`$`)
+	return regexp.Compile(pattern.String())
+}
+
+// depth 模板模式下影片目录相对根目录的层级
+func (l *LibraryLayout) depth() int {
+	return len(l.segments)
+}
+
+// match 按模板解析影片目录路径，未匹配的层级不提供任何字段
+func (l *LibraryLayout) match(parts []string) map[string]string {
+	fields := make(map[string]string)
+	for i, re := range l.segments {
+		if i >= len(parts) {
+			break
+		}
+		matches := re.FindStringSubmatch(parts[i])
+		if matches == nil {
+			continue
+		}
+		for j, name := range re.SubexpNames() {
+			if name == "" || matches[j] == "" {
+				continue
+			}
+			value := strings.TrimSpace(matches[j])
+			if _, ok := moviecode.Parse(value); name == "code" && !ok {
+				continue
+			}
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// ScanOptions 扫描选项
+type ScanOptions struct {
+	Layout        string            `json:"layout"`         // 布局名称或路径模板
+	Layouts       map[string]string `json:"layouts"`        // 自定义布局：名称 -> 布局定义
+	Exclude       []string          `json:"exclude"`        // 排除规则（glob），含 / 时匹配相对路径，否则匹配文件/目录名
+	SupportedExts []string          `json:"supported_exts"` // 支持的视频格式
+	MinFileSize   int64             `json:"min_file_size"`  // 最小文件大小（MB）
+	MaxFileSize   int64             `json:"max_file_size"`  // 最大文件大小（MB），0 表示不限制
+}
+
+// DefaultScanOptions 默认扫描选项
+func DefaultScanOptions() ScanOptions {
+	return ScanOptions{
+		Layout:        DefaultLayout,
+		SupportedExts: defaultVideoExts,
+		MinFileSize:   100, // 排除预览等小文件
+	}
+}
+
+// libraryScanner 按布局与过滤规则扫描单个媒体库根目录
+type libraryScanner struct {
+	name     string
+	root     string
+	interval time.Duration
+	layout   *LibraryLayout
+	exclude  []string
+	exts     map[string]bool
+	minSize  int64
+	maxSize  int64
+}
+
+// newLibraryScanner 根据根目录配置与扫描选项创建目录扫描器
+func newLibraryScanner(root model.MediaRoot, opts ScanOptions) (*libraryScanner, error) {
+	spec := opts.Layout
+	if root.Layout != "" {
+		spec = root.Layout
+	}
+	if profile, ok := opts.Layouts[spec]; ok {
+		spec = profile
+	}
+	layout, err := ParseLibraryLayout(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	exts := opts.SupportedExts
+	if len(exts) == 0 {
+		exts = defaultVideoExts
+	}
+	extSet := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extSet[ext] = true
+	}
+
+	var exclude []string
+	for _, pattern := range append(append([]string{}, opts.Exclude...), root.Exclude...) {
+		pattern = strings.ToLower(strings.Trim(filepath.ToSlash(strings.TrimSpace(pattern)), "/"))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("无效的排除规则 %q: %v", pattern, err)
+		}
+		exclude = append(exclude, pattern)
+	}
+
+	interval := ScanInterval
+	if root.ScanInterval > 0 {
+		interval = time.Duration(root.ScanInterval) * time.Minute
+	}
+
+	return &libraryScanner{
+		name:     root.Name,
+		root:     filepath.Clean(root.Path),
+		interval: interval,
+		layout:   layout,
+		exclude:  exclude,
+		exts:     extSet,
+		minSize:  opts.MinFileSize * 1024 * 1024,
+		maxSize:  opts.MaxFileSize * 1024 * 1024,
+	}, nil
+}
+
+// relParts 返回相对根目录的路径分段，不在根目录下时返回nil
+func (l *libraryScanner) relParts(p string) []string {
+	rel, err := filepath.Rel(l.root, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	return strings.Split(filepath.ToSlash(rel), "/")
+}
+
+// isExcluded 判断路径是否被隐藏或命中排除规则
+func (l *libraryScanner) isExcluded(parts []string) bool {
+	for _, part := range parts {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+
+	name := strings.ToLower(parts[len(parts)-1])
+	for _, pattern := range l.exclude {
+		if !strings.Contains(pattern, "/") {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+			continue
+		}
+		// 含路径的规则同时匹配其下所有内容
+		for i := len(parts); i > 0; i-- {
+			prefix := strings.ToLower(strings.Join(parts[:i], "/"))
+			if matched, _ := path.Match(pattern, prefix); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unitDepth 返回影片单元相对根目录的层级，0 表示单个文件即一部影片
+func (l *libraryScanner) unitDepth(fileParts []string) int {
+	switch l.layout.Mode {
+	case LayoutModeFlat:
+		if len(fileParts) == 1 {
+			return 0
+		}
+		return 1
+	case LayoutModeRecursive:
+		return 0
+	default:
+		return l.layout.depth()
+	}
+}
+
+// insideUnit 判断目录是否位于影片目录内部
+func (l *libraryScanner) insideUnit(dirParts []string) bool {
+	switch l.layout.Mode {
+	case LayoutModeFlat:
+		return len(dirParts) > 1
+	case LayoutModeRecursive:
+		return true
+	default:
+		return len(dirParts) > l.layout.depth()
+	}
+}
+
+// watchTarget 返回路径变化时需要重新扫描的范围，无需处理时返回空字符串
+// 单个文件即一部影片时重扫其所在目录，以便同目录下的分段与版本一起重新分组
+func (l *libraryScanner) watchTarget(p string) string {
+	parts := l.relParts(p)
+	if parts == nil || l.isExcluded(parts) {
+		return ""
+	}
+
+	n := len(parts)
+	switch l.layout.Mode {
+	case LayoutModeFlat:
+		n = 1
+		if info, err := os.Stat(p); len(parts) == 1 && (err != nil || !info.IsDir()) {
+			n = 0
+		}
+	case LayoutModeRecursive:
+		n--
+		// 字幕子目录中的变化归属到上级影片目录
+		if n > 0 && subtitleDirNames[strings.ToLower(parts[n-1])] {
+			n--
+		}
+	default:
+		if n > l.layout.depth() {
+			n = l.layout.depth()
+		}
+	}
+	return filepath.Join(append([]string{l.root}, parts[:n]...)...)
+}
+
+// scan 扫描根目录下的指定范围（文件或目录），返回其中的影片
+// job 不为空时记录遍历进度，任务被取消时停止遍历
+func (l *libraryScanner) scan(start string, parse func(files []string, info pathInfo) *model.LocalMovie, job *scanJob) []*model.LocalMovie {
+	var units, fileDirs []string
+	unitFiles := make(map[string][]string) // 影片目录 -> 视频文件
+	dirFiles := make(map[string][]string)  // 单个文件即一部影片时：所在目录 -> 视频文件
+
+	filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // 忽略错误，继续处理
+		}
+		if job.cancelled() {
+			return filepath.SkipAll
+		}
+
+		parts := l.relParts(p)
+		if parts == nil {
+			return nil
+		}
+		if l.isExcluded(parts) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			// 影片目录内的花絮、预告等附加内容
+			if l.insideUnit(parts) && extraDirNames[strings.ToLower(d.Name())] {
+				return filepath.SkipDir
+			}
+			job.visitDir()
+			return nil
+		}
+
+		if !l.isVideoFile(d) {
+			return nil
+		}
+		job.foundFile()
+
+		depth := l.unitDepth(parts)
+		if len(parts)-1 < depth {
+			return nil // 层级比布局浅的文件不属于任何影片
+		}
+		if depth == 0 {
+			dir := filepath.Dir(p)
+			if _, ok := dirFiles[dir]; !ok {
+				fileDirs = append(fileDirs, dir)
+			}
+			dirFiles[dir] = append(dirFiles[dir], p)
+			return nil
+		}
+
+		unit := filepath.Join(append([]string{l.root}, parts[:depth]...)...)
+		if _, ok := unitFiles[unit]; !ok {
+			units = append(units, unit)
+		}
+		unitFiles[unit] = append(unitFiles[unit], p)
+		return nil
+	})
+
+	var movies []*model.LocalMovie
+
+	// 目录即影片：目录内的视频按分段与版本分组
+	for _, unit := range units {
+		info := pathInfo{root: l, unit: unit}
+		unitParts := l.relParts(unit)
+		info.dirName = unitParts[len(unitParts)-1]
+		if l.layout.Mode == LayoutModeTemplate {
+			fields := l.layout.match(unitParts)
+			info.actress = fields["actress"]
+			info.code = moviecode.Normalize(fields["code"])
+			info.title = fields["title"]
+		}
+
+		versions := groupVideoFiles(unitFiles[unit])
+		info.multiVersion = len(versions) > 1
+		for _, version := range versions {
+			info.base = version.base
+			if movie := parse(version.files, info); movie != nil {
+				movies = append(movies, movie)
+			}
+		}
+	}
+
+	// 单个文件即影片：同目录下的分段合并为一部，不同版本各自独立
+	for _, dir := range fileDirs {
+		for _, version := range groupVideoFiles(dirFiles[dir]) {
+			info := pathInfo{root: l, base: version.base}
+			info.unit = filepath.Join(dir, version.base)
+			if dir != l.root {
+				info.parentName = filepath.Base(dir)
+			}
+			if movie := parse(version.files, info); movie != nil {
+				movies = append(movies, movie)
+			}
+		}
+	}
+	return movies
+}
+
+// isVideoFile 按格式、大小与文件名关键词判断是否为正片视频
+func (l *libraryScanner) isVideoFile(d fs.DirEntry) bool {
+	if !l.exts[strings.ToLower(filepath.Ext(d.Name()))] {
+		return false
+	}
+
+	// 排除包含 sample、trailer、preview 等关键词的文件
+	fileName := strings.ToLower(d.Name())
+	if strings.Contains(fileName, "sample") ||
+		strings.Contains(fileName, "trailer") ||
+		strings.Contains(fileName, "preview") ||
+		strings.Contains(fileName, "fanart") {
+		return false
+	}
+
+	fileInfo, err := d.Info()
+	if err != nil {
+		return false
+	}
+	if fileInfo.Size() < l.minSize {
+		return false
+	}
+	if l.maxSize > 0 && fileInfo.Size() > l.maxSize {
+		return false
+	}
+	return true
+}
+
+// imageURL 生成图片的访问URL，路径以根目录名称开头
+func (l *libraryScanner) imageURL(fullPath string) string {
+	relPath, err := filepath.Rel(l.root, fullPath)
+	if err != nil {
+		return ""
+	}
+	// 将路径转换为URL格式并进行编码
+	return "/api/v1/local/image/" + url.PathEscape(l.name) + "/" + url.PathEscape(filepath.ToSlash(relPath))
+}
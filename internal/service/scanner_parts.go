@@ -0,0 +1,146 @@
+package service
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 数字分段后缀：CD1、part2、disc1、pt1 等
+var numericPartPattern = regexp.MustCompile(`(?i)^(.*?)[-_. ]*(?:cd|part|pt|disc|disk)[-_. ]?(\d{1,2})$`)
+
+// 字母分段后缀：ABC-123-A、ABC-123B
+var letterPartPattern = regexp.MustCompile(`(?i)^(.*\d)[-_ ]?([a-f])$`)
+
+// 版本中的分辨率标识
+var resolutionPattern = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(2160p|4k|uhd|1080p|fhd|720p|480p)(?:$|[^a-z0-9])`)
+
+// videoVersion 同一影片的一个版本，按顺序包含所有分段
+type videoVersion struct {
+	base  string   // 去掉分段后缀的文件名（不含扩展名）
+	files []string // 分段文件，按分段序号排序
+}
+
+// partFile 解析出分段信息的视频文件
+type partFile struct {
+	path   string
+	stem   string
+	base   string
+	part   int
+	letter bool
+}
+
+// parsePartFile 解析文件名中的分段后缀，无分段时 part 为 0
+func parsePartFile(path string) partFile {
+	name := filepath.Base(path)
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	pf := partFile{path: path, stem: stem, base: stem}
+
+	if m := numericPartPattern.FindStringSubmatch(stem); m != nil && m[1] != "" {
+		pf.base = strings.TrimRight(m[1], "-_. ")
+		pf.part, _ = strconv.Atoi(m[2])
+		return pf
+	}
+	if m := letterPartPattern.FindStringSubmatch(stem); m != nil {
+		pf.base = strings.TrimRight(m[1], "-_. ")
+		pf.part = int(strings.ToUpper(m[2])[0]-'A') + 1
+		pf.letter = true
+	}
+	return pf
+}
+
+// groupVideoFiles 将同一目录下的视频文件分组为版本，每个版本的分段按序排列
+// 字母后缀只有在同组存在 -A 且不少于两个文件时才视为分段（避免把 -C 中文字幕标记当作分段）
+func groupVideoFiles(files []string) []videoVersion {
+	var keys []string
+	groups := make(map[string][]partFile)
+	add := func(key string, pf partFile) {
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], pf)
+	}
+
+	// 扩展名不同的同名文件属于不同版本
+	groupKey := func(base, path string) string {
+		return strings.ToLower(base + filepath.Ext(path))
+	}
+
+	for _, file := range files {
+		pf := parsePartFile(file)
+		add(groupKey(pf.base, pf.path), pf)
+	}
+
+	var versions []videoVersion
+	for _, key := range keys {
+		members := groups[key]
+		if !validPartGroup(members) {
+			// 不构成分段的文件各自作为独立版本
+			for _, pf := range members {
+				versions = append(versions, videoVersion{base: pf.stem, files: []string{pf.path}})
+			}
+			continue
+		}
+
+		sort.SliceStable(members, func(i, j int) bool { return members[i].part < members[j].part })
+		version := videoVersion{base: members[0].base}
+		for _, pf := range members {
+			version.files = append(version.files, pf.path)
+		}
+		versions = append(versions, version)
+	}
+
+	// 番号格式命名的版本作为主版本排在最前
+	sort.SliceStable(versions, func(i, j int) bool {
+		return mainVideoPattern.MatchString(filepath.Base(versions[i].files[0])) &&
+			!mainVideoPattern.MatchString(filepath.Base(versions[j].files[0]))
+	})
+	return versions
+}
+
+// validPartGroup 判断一组文件是否构成有效的分段序列
+func validPartGroup(members []partFile) bool {
+	if len(members) == 1 {
+		return members[0].part == 0 || !members[0].letter
+	}
+
+	seen := make(map[int]bool, len(members))
+	hasFirst := false
+	for _, pf := range members {
+		if pf.part == 0 || seen[pf.part] {
+			return false
+		}
+		seen[pf.part] = true
+		if pf.part == 1 {
+			hasFirst = true
+		}
+	}
+	return hasFirst
+}
+
+// versionLabel 生成版本标识，优先使用分辨率；同一目录存在多个版本时使用番号之后的文件名部分
+func versionLabel(base, code string, multiVersion bool) string {
+	if m := resolutionPattern.FindStringSubmatch(base); m != nil {
+		switch strings.ToLower(m[1]) {
+		case "2160p", "4k", "uhd":
+			return "4K"
+		case "fhd":
+			return "1080p"
+		default:
+			return strings.ToLower(m[1])
+		}
+	}
+	if !multiVersion {
+		return ""
+	}
+
+	label := base
+	if code != "" {
+		if idx := strings.Index(strings.ToUpper(label), strings.ToUpper(code)); idx >= 0 {
+			label = label[:idx] + label[idx+len(code):]
+		}
+	}
+	return strings.Trim(label, "-_. []()")
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/probe"
+	"regexp"
+	"time"
+)
+
+// 文件名中的无码标记：ABC-123-U、ABC-123-UC、[无码流出]、uncensored、leak
+var uncensoredTagPattern = regexp.MustCompile(`(?i)(?:\d[-_ ]?uc?(?:$|[-_ .\[(])|uncensored|leak|无码|無碼|流出|破解)`)
+
+// probeMovie 探测影片的时长、分辨率与编码，多分段影片的时长与码率按所有分段计算
+func (s *ScannerService) probeMovie(movie *model.LocalMovie) {
+	movie.Probed = true
+
+	files := []string{movie.Path}
+	if len(movie.Parts) > 0 {
+		files = files[:0]
+		for _, part := range movie.Parts {
+			files = append(files, part.Path)
+		}
+	}
+
+	var first *probe.Info
+	var total time.Duration
+	if probe.Supported(files[0]) {
+		for _, file := range files {
+			info, err := probe.File(file)
+			if err != nil {
+				if s.logService != nil {
+					s.logService.LogWarn("scanner", "media-probe", fmt.Sprintf("读取媒体信息失败 [%s]: %v", file, err))
+				}
+				continue
+			}
+			if first == nil {
+				first = info
+			}
+			total += info.Duration
+		}
+	}
+
+	if first == nil {
+		// 不支持探测的格式退回到文件名中的分辨率标识
+		movie.Duration, movie.Width, movie.Height, movie.Bitrate = 0, 0, 0, 0
+		movie.VideoCodec, movie.AudioCodec = "", ""
+		movie.Quality = versionQuality(movie.Version)
+		return
+	}
+
+	movie.Duration = int(math.Round(total.Seconds()))
+	movie.Width = first.Width
+	movie.Height = first.Height
+	movie.VideoCodec = first.VideoCodec
+	movie.AudioCodec = first.AudioCodec
+	movie.Bitrate = 0
+	if total > 0 {
+		movie.Bitrate = int64(float64(movie.Size*8) / total.Seconds())
+	}
+	movie.Quality = first.Quality()
+	if movie.Quality == "" {
+		movie.Quality = versionQuality(movie.Version)
+	}
+}
+
+// versionQuality 版本标识为分辨率时作为画质
+func versionQuality(version string) string {
+	switch version {
+	case "4K", "1080p", "720p", "480p":
+		return version
+	}
+	return ""
+}
+
+// hasUncensoredTag 判断影片名称是否带有无码标记
+func hasUncensoredTag(names ...string) bool {
+	for _, name := range names {
+		if name != "" && uncensoredTagPattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// qualityHeight 画质对应的分辨率高度，未知画质为 0
+func qualityHeight(quality string) int {
+	switch quality {
+	case "4K":
+		return 2160
+	case "1080p":
+		return 1080
+	case "720p":
+		return 720
+	case "480p":
+		return 480
+	}
+	return 0
+}
+
+// fillMovieMediaInfo 用探测结果补全影片元数据中缺失的时长与画质
+func (s *ScannerService) fillMovieMediaInfo(movie *model.LocalMovie) {
+	if s.movieRepo == nil || movie.Code == "" || (movie.Duration == 0 && movie.Quality == "") {
+		return
+	}
+	minutes := int(math.Round(float64(movie.Duration) / 60))
+	if err := s.movieRepo.FillMediaInfo(movie.Code, minutes, movie.Quality); err != nil && s.logService != nil {
+		s.logService.LogWarn("scanner", "media-probe", fmt.Sprintf("更新影片元数据失败 [%s]: %v", movie.Code, err))
+	}
+}
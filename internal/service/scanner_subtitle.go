@@ -0,0 +1,331 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"nsfw-go/internal/model"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 支持的外挂字幕格式
+var subtitleExts = map[string]bool{
+	".srt": true,
+	".ass": true,
+	".ssa": true,
+	".vtt": true,
+}
+
+// 存放字幕的子目录名称
+var subtitleDirNames = map[string]bool{
+	"subs":      true,
+	"sub":       true,
+	"subtitles": true,
+	"subtitle":  true,
+	"字幕":        true,
+}
+
+// 文件名中的中文字幕标记：ABC-123-C、ABC-123C、ABC-123-UC、ABC-123ch、[中文字幕]
+var chineseTagPattern = regexp.MustCompile(`(?i)(?:\d[-_ ]?(?:c|uc|ch)(?:$|[-_ .\[(])|中文字幕|中字)`)
+
+// 文件名中的语言标记
+var subtitleLanguageTags = map[string]string{
+	"zh": "zh", "chs": "zh", "cht": "zh", "chi": "zh", "zho": "zh", "chn": "zh", "sc": "zh", "tc": "zh",
+	"gb": "zh", "big5": "zh", "chinese": "zh", "zh-cn": "zh", "zh-tw": "zh", "zh-hans": "zh", "zh-hant": "zh",
+	"中文": "zh", "简体": "zh", "繁体": "zh", "简中": "zh", "繁中": "zh", "中字": "zh", "简": "zh", "繁": "zh", "中": "zh",
+	"ja": "ja", "jp": "ja", "jpn": "ja", "japanese": "ja", "日文": "ja", "日语": "ja", "日": "ja",
+	"en": "en", "eng": "en", "english": "en", "英文": "en", "英语": "en", "英": "en",
+	"ko": "ko", "kor": "ko", "korean": "ko", "韩文": "ko", "韩语": "ko",
+}
+
+// 字幕内容识别时读取的最大字节数
+const subtitleSniffSize = 64 * 1024
+
+// hasChineseTag 判断影片名称是否带有中文字幕标记
+func hasChineseTag(names ...string) bool {
+	for _, name := range names {
+		if name != "" && chineseTagPattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// findSubtitles 查找影片的外挂字幕，shared 为 true 时只匹配以视频名开头的字幕文件
+func findSubtitles(movieDir, baseName string, shared bool) []model.LocalMovieSubtitle {
+	entries, err := os.ReadDir(movieDir)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			// 影片目录下的字幕子目录
+			if !shared && subtitleDirNames[strings.ToLower(entry.Name())] {
+				subDir := filepath.Join(movieDir, entry.Name())
+				if subEntries, err := os.ReadDir(subDir); err == nil {
+					for _, sub := range subEntries {
+						if !sub.IsDir() && isSubtitleFile(sub.Name()) {
+							paths = append(paths, filepath.Join(subDir, sub.Name()))
+						}
+					}
+				}
+			}
+			continue
+		}
+		if !isSubtitleFile(entry.Name()) {
+			continue
+		}
+		if shared && !subtitleMatchesVideo(entry.Name(), baseName) {
+			continue
+		}
+		paths = append(paths, filepath.Join(movieDir, entry.Name()))
+	}
+	sort.Strings(paths)
+
+	subtitles := make([]model.LocalMovieSubtitle, 0, len(paths))
+	for _, path := range paths {
+		language, source := detectSubtitleLanguage(path, baseName)
+		subtitles = append(subtitles, model.LocalMovieSubtitle{
+			Path:     path,
+			Format:   strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."),
+			Language: language,
+			Source:   source,
+		})
+	}
+	return subtitles
+}
+
+// isSubtitleFile 判断是否为支持的字幕文件
+func isSubtitleFile(name string) bool {
+	return !strings.HasPrefix(name, ".") && subtitleExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// subtitleMatchesVideo 判断字幕文件名是否属于指定视频（ABC-123.srt、ABC-123.chs.srt、ABC-123-cd1.srt）
+func subtitleMatchesVideo(name, baseName string) bool {
+	stem := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+	base := strings.ToLower(baseName)
+	if !strings.HasPrefix(stem, base) {
+		return false
+	}
+	if len(stem) == len(base) {
+		return true
+	}
+	return strings.ContainsRune(".-_ [(", rune(stem[len(base)]))
+}
+
+// detectSubtitleLanguage 识别字幕语言，优先使用文件名中的语言标记，其次根据内容的文字类型判断
+func detectSubtitleLanguage(path, baseName string) (string, string) {
+	if language := languageFromFileName(filepath.Base(path), baseName); language != "" {
+		return language, "filename"
+	}
+	if language := languageFromContent(path); language != "" {
+		return language, "content"
+	}
+	return "", ""
+}
+
+// languageFromFileName 从字幕文件名中视频名之后的部分解析语言标记
+func languageFromFileName(name, baseName string) string {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if len(stem) >= len(baseName) && strings.EqualFold(stem[:len(baseName)], baseName) {
+		stem = stem[len(baseName):]
+	}
+
+	tokens := strings.FieldsFunc(strings.ToLower(stem), func(r rune) bool {
+		return r == '.' || r == '_' || r == ' ' || r == '[' || r == ']' || r == '(' || r == ')' || r == '&' || r == '+'
+	})
+	// 双语字幕（如 chs&eng）只要包含中文即视为中文字幕
+	found := ""
+	for _, token := range tokens {
+		language := subtitleLanguageTags[token]
+		if language == "" {
+			// zh-CN 这类带地区的标记在整体未命中时再按横线拆分
+			for _, part := range strings.Split(token, "-") {
+				if language = subtitleLanguageTags[part]; language != "" {
+					break
+				}
+			}
+		}
+		if language == "zh" {
+			return language
+		}
+		if found == "" {
+			found = language
+		}
+	}
+	return found
+}
+
+// languageFromContent 读取字幕开头部分，根据中日韩文字与拉丁字母的比例判断语言
+func languageFromContent(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, subtitleSniffSize))
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+
+	text, ok := decodeSubtitleText(data)
+	if !ok {
+		return guessLegacyEncoding(data)
+	}
+	return classifySubtitleText(text, strings.ToLower(filepath.Ext(path)))
+}
+
+// decodeSubtitleText 将字幕内容解码为 UTF-8 文本，非 UTF-8/UTF-16 编码时返回 false
+func decodeSubtitleText(data []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		bigEndian := data[0] == 0xFE
+		data = data[2:]
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			if bigEndian {
+				units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+			} else {
+				units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+			}
+		}
+		return string(utf16.Decode(units)), true
+	}
+
+	// 截断位置可能落在多字节字符中间
+	for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	if !utf8.Valid(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+// guessLegacyEncoding 判断非 UTF-8 字幕的语言：Shift-JIS 的假名集中在 0x82/0x83 首字节，否则按 GBK/Big5 中文处理
+func guessLegacyEncoding(data []byte) string {
+	var multiByte, kana int
+	for i := 0; i+1 < len(data); i++ {
+		if data[i] < 0x81 {
+			continue
+		}
+		multiByte++
+		if data[i] == 0x82 && data[i+1] >= 0x9F && data[i+1] <= 0xF1 ||
+			data[i] == 0x83 && data[i+1] >= 0x40 && data[i+1] <= 0x96 {
+			kana++
+		}
+		i++ // 跳过双字节字符的第二个字节
+	}
+	if multiByte < 10 {
+		return ""
+	}
+	if kana*3 >= multiByte {
+		return "ja"
+	}
+	return "zh"
+}
+
+// classifySubtitleText 统计字幕正文中各类文字的数量并判断语言
+func classifySubtitleText(text, ext string) string {
+	var han, kana, hangul, latin int
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), subtitleSniffSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// ASS/SSA 只统计对白行的文本部分，跳过样式定义
+		if ext == ".ass" || ext == ".ssa" {
+			if !strings.HasPrefix(line, "Dialogue:") {
+				continue
+			}
+			if fields := strings.SplitN(line, ",", 10); len(fields) == 10 {
+				line = fields[9]
+			}
+		}
+		for _, r := range line {
+			switch {
+			case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+				kana++
+			case unicode.Is(unicode.Hangul, r):
+				hangul++
+			case unicode.Is(unicode.Han, r):
+				han++
+			case r < unicode.MaxASCII && unicode.IsLetter(r):
+				latin++
+			}
+		}
+	}
+
+	cjk := han + kana + hangul
+	// 一个汉字大致对应一个英文单词，按字母数的四分之一比较
+	if cjk >= 10 && cjk*4 >= latin {
+		switch {
+		case hangul*2 >= cjk:
+			return "ko"
+		case kana*20 >= cjk:
+			return "ja"
+		default:
+			return "zh"
+		}
+	}
+	if latin >= 50 {
+		return "en"
+	}
+	return ""
+}
+
+// subtitleLanguages 汇总字幕语言，按出现顺序去重
+func subtitleLanguages(subtitles []model.LocalMovieSubtitle, hardSubtitle bool) []string {
+	var languages []string
+	seen := make(map[string]bool)
+	add := func(language string) {
+		if language != "" && !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+	if hardSubtitle {
+		add("zh")
+	}
+	for _, subtitle := range subtitles {
+		add(subtitle.Language)
+	}
+	return languages
+}
+
+// hasLanguage 判断语言列表中是否包含指定语言
+func hasLanguage(languages []string, language string) bool {
+	for _, l := range languages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// subtitlesChanged 判断外挂字幕是否有变化
+func subtitlesChanged(old, scanned []model.LocalMovieSubtitle) bool {
+	if len(old) != len(scanned) {
+		return true
+	}
+	sort.Slice(old, func(i, j int) bool { return old[i].Path < old[j].Path })
+	for i := range old {
+		if old[i].Path != scanned[i].Path ||
+			old[i].Language != scanned[i].Language ||
+			old[i].Source != scanned[i].Source {
+			return true
+		}
+	}
+	return false
+}
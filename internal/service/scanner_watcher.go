@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"nsfw-go/internal/model"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	WatchDebounce     = 10 * time.Second // 目录静默10秒后才索引，避免收录复制中的文件
+	watchPollInterval = 2 * time.Second  // 检查待处理目录的间隔
+)
+
+// SetWatchEnabled 设置是否启用实时目录监听
+func (s *ScannerService) SetWatchEnabled(enabled bool) {
+	s.watchEnabled = enabled
+}
+
+// startWatcher 启动媒体库实时监听，新增、移动、删除的影片目录会在数秒内同步到数据库
+func (s *ScannerService) startWatcher() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogWarn("scanner", "media-watch", fmt.Sprintf("创建目录监听失败，仅使用定时扫描: %v", err))
+		}
+		return
+	}
+
+	count := 0
+	for _, root := range s.roots {
+		count += s.addWatchRecursive(watcher, root.root)
+	}
+	if s.logService != nil {
+		s.logService.LogInfo("scanner", "media-watch", fmt.Sprintf("实时目录监听已启动，监听 %d 个目录", count))
+	}
+
+	// 待处理目录 -> 最后一次事件时间
+	pending := make(map[string]time.Time)
+	dirQueue := make(chan string, 256)
+
+	// 处理协程，保证事件循环不被数据库操作阻塞
+	go func() {
+		for {
+			select {
+			case dir := <-dirQueue:
+				s.rescanSubtree(dir)
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+		defer watcher.Close()
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				s.handleWatchEvent(watcher, event, pending)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				if errors.Is(err, fsnotify.ErrEventOverflow) {
+					// 事件溢出时无法确定哪些目录有变化，退回到一次完整扫描
+					if s.logService != nil {
+						s.logService.LogWarn("scanner", "media-watch", "监听事件溢出，触发完整扫描")
+					}
+					s.startBackgroundScan("", model.ScanTriggerWatch)
+					continue
+				}
+				if s.logService != nil {
+					s.logService.LogWarn("scanner", "media-watch", fmt.Sprintf("目录监听错误: %v", err))
+				}
+			case <-ticker.C:
+				now := time.Now()
+				for dir, last := range pending {
+					if now.Sub(last) < WatchDebounce {
+						continue
+					}
+					select {
+					case dirQueue <- dir:
+						delete(pending, dir)
+					default:
+						// 队列已满，下次再试
+					}
+				}
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// handleWatchEvent 将文件系统事件归并到所属的影片（目录或文件）
+func (s *ScannerService) handleWatchEvent(watcher *fsnotify.Watcher, event fsnotify.Event, pending map[string]time.Time) {
+	root := s.rootForPath(event.Name)
+	if root == nil {
+		return
+	}
+	target := root.watchTarget(event.Name)
+	if target == "" {
+		return
+	}
+
+	// 新建目录需要加入监听（fsnotify不支持递归监听）
+	if event.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			s.addWatchRecursive(watcher, event.Name)
+		}
+	}
+
+	pending[target] = time.Now()
+}
+
+// addWatchRecursive 递归监听目录，返回成功添加的目录数
+func (s *ScannerService) addWatchRecursive(watcher *fsnotify.Watcher, root string) int {
+	count := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if root := s.rootForPath(path); root != nil && root.isExcluded(root.relParts(path)) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			// 通常是inotify监听数量达到上限，剩余目录依赖定时扫描
+			if s.logService != nil {
+				s.logService.LogWarn("scanner", "media-watch", fmt.Sprintf("添加目录监听失败 [%s]: %v", path, err))
+			}
+			return filepath.SkipAll
+		}
+		count++
+		return nil
+	})
+	return count
+}
+
+// rescanSubtree 只重新扫描指定范围（影片目录、上级目录或单个文件），并同步到数据库
+func (s *ScannerService) rescanSubtree(target string) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	root := s.rootForPath(target)
+	if root == nil {
+		return
+	}
+
+	// 路径已不存在时movies为空，其下的记录会全部被移除
+	var movies []*model.LocalMovie
+	if _, err := os.Stat(target); err == nil {
+		movies = root.scan(target, s.parseMovieInfo, nil)
+	}
+
+	existing, err := s.localMovieRepo.ListUnderPathWithDeleted(target)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogError("scanner", "media-watch", fmt.Sprintf("加载目录记录失败 [%s]: %v", target, err))
+		}
+		return
+	}
+
+	result, err := s.syncMovies(movies, existing, nil)
+	if err != nil {
+		if s.logService != nil {
+			s.logService.LogError("scanner", "media-watch", fmt.Sprintf("同步目录失败 [%s]: %v", target, err))
+		}
+		return
+	}
+
+	s.notifyLibraryChanged(result)
+
+	if s.logService != nil && result.Added+result.Removed+result.Changed > 0 {
+		rel, _ := filepath.Rel(root.root, target)
+		s.logService.LogInfo("scanner", "media-watch", fmt.Sprintf("目录变化已同步 [%s/%s]：新增 %d 部，移除 %d 部，变更 %d 部",
+			root.name, rel, result.Added, result.Removed, result.Changed))
+	}
+}
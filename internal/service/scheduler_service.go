@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nsfw-go/internal/cron"
+	"nsfw-go/internal/model"
+	"nsfw-go/internal/repo"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrJobNotFound 定时任务不存在
+	ErrJobNotFound = errors.New("定时任务不存在")
+	// ErrJobRunning 定时任务正在执行
+	ErrJobRunning = errors.New("定时任务正在执行")
+)
+
+// schedulerTick 调度器检查到期任务的间隔，按墙上时间比较，系统休眠或时间跳变后也不会漏掉执行
+const schedulerTick = 10 * time.Second
+
+// ScheduledJobFunc 定时任务执行函数，返回的文本作为执行结果保存
+type ScheduledJobFunc func(ctx context.Context) (string, error)
+
+// ScheduledJobSpec 定时任务定义
+type ScheduledJobSpec struct {
+	Name        string
+	Description string
+	Cron        string // 默认调度规则，可被配置 scheduler.jobs 覆盖
+	Timezone    string // 为空时使用调度器默认时区
+	CatchUp     bool   // 服务停止期间错过执行时，启动后补跑一次
+	Run         ScheduledJobFunc
+}
+
+// ScheduledJobStatus 定时任务状态
+type ScheduledJobStatus struct {
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	Cron           string     `json:"cron"`
+	Timezone       string     `json:"timezone"`
+	CatchUp        bool       `json:"catch_up"`
+	Paused         bool       `json:"paused"`
+	Running        bool       `json:"running"`
+	NextRunAt      *time.Time `json:"next_run_at"` // 暂停时为空
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+	LastTrigger    string     `json:"last_trigger"`
+	LastStatus     string     `json:"last_status"`
+	LastResult     string     `json:"last_result,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	RunCount       int        `json:"run_count"`
+	FailCount      int        `json:"fail_count"`
+}
+
+// scheduledJob 已注册的定时任务
+type scheduledJob struct {
+	spec     ScheduledJobSpec
+	schedule *cron.Schedule
+	state    *model.ScheduledJob
+	next     time.Time
+	running  bool
+}
+
+// SchedulerService 定时任务调度服务，统一管理排行榜爬取、本地检查、媒体库扫描等后台任务
+type SchedulerService struct {
+	jobRepo    repo.ScheduledJobRepository
+	logService *LogService
+	location   *time.Location
+	overrides  map[string]model.ScheduledJobConfig
+
+	mu      sync.Mutex
+	jobs    []*scheduledJob
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewSchedulerService 创建定时任务调度服务
+func NewSchedulerService(jobRepo repo.ScheduledJobRepository, logService *LogService) *SchedulerService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SchedulerService{
+		jobRepo:    jobRepo,
+		logService: logService,
+		location:   time.Local,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// SetTimezone 设置默认时区，为空时使用服务器时区
+func (s *SchedulerService) SetTimezone(name string) error {
+	loc, err := loadLocation(name)
+	if err != nil {
+		return err
+	}
+	s.location = loc
+	return nil
+}
+
+// SetJobConfigs 设置按任务名称覆盖的调度规则，需在注册任务之前调用
+func (s *SchedulerService) SetJobConfigs(configs map[string]model.ScheduledJobConfig) {
+	s.overrides = configs
+}
+
+// Register 注册定时任务，配置中的调度规则、时区与补跑设置优先于任务默认值
+func (s *SchedulerService) Register(spec ScheduledJobSpec) error {
+	if override, ok := s.overrides[spec.Name]; ok {
+		if override.Cron != "" {
+			spec.Cron = override.Cron
+		}
+		if override.Timezone != "" {
+			spec.Timezone = override.Timezone
+		}
+		if override.CatchUp != nil {
+			spec.CatchUp = *override.CatchUp
+		}
+	}
+
+	loc := s.location
+	if spec.Timezone != "" {
+		var err error
+		if loc, err = loadLocation(spec.Timezone); err != nil {
+			return fmt.Errorf("定时任务 %s: %w", spec.Name, err)
+		}
+	}
+	schedule, err := cron.Parse(spec.Cron, loc)
+	if err != nil {
+		return fmt.Errorf("定时任务 %s: %w", spec.Name, err)
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("定时任务 %s: 表达式 %q 不会触发", spec.Name, spec.Cron)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findJob(spec.Name) != nil {
+		return fmt.Errorf("定时任务 %s 已注册", spec.Name)
+	}
+	job := &scheduledJob{spec: spec, schedule: schedule}
+	s.jobs = append(s.jobs, job)
+	if s.started {
+		s.initJob(job, time.Now())
+	}
+	return nil
+}
+
+// Start 加载任务状态并启动调度，服务停止期间错过执行且开启补跑的任务立即执行一次
+func (s *SchedulerService) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	now := time.Now()
+	for _, job := range s.jobs {
+		s.initJob(job, now)
+	}
+	s.mu.Unlock()
+
+	s.logInfo(fmt.Sprintf("定时任务调度已启动，共 %d 个任务，默认时区 %s", len(s.jobs), s.location))
+
+	go func() {
+		ticker := time.NewTicker(schedulerTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.runDue(time.Now())
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止调度，正在执行的任务会收到取消信号
+func (s *SchedulerService) Stop() {
+	s.cancel()
+}
+
+// List 获取所有定时任务的状态
+func (s *SchedulerService) List() []*ScheduledJobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]*ScheduledJobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		statuses = append(statuses, job.status())
+	}
+	return statuses
+}
+
+// Get 获取定时任务的状态
+func (s *SchedulerService) Get(name string) (*ScheduledJobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.findJob(name)
+	if job == nil {
+		return nil, ErrJobNotFound
+	}
+	return job.status(), nil
+}
+
+// Pause 暂停定时任务，暂停状态会保存，服务重启后保持暂停
+func (s *SchedulerService) Pause(name string) (*ScheduledJobStatus, error) {
+	return s.setPaused(name, true)
+}
+
+// Resume 恢复定时任务，从当前时间开始计算下一次执行时间
+func (s *SchedulerService) Resume(name string) (*ScheduledJobStatus, error) {
+	return s.setPaused(name, false)
+}
+
+// Trigger 立即执行定时任务，不影响下一次按计划执行的时间；暂停的任务也可以手动执行
+func (s *SchedulerService) Trigger(name string) (*ScheduledJobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.findJob(name)
+	if job == nil {
+		return nil, ErrJobNotFound
+	}
+	if job.running {
+		return nil, ErrJobRunning
+	}
+	s.startJob(job, model.JobTriggerManual)
+	return job.status(), nil
+}
+
+// setPaused 更新任务的暂停状态
+func (s *SchedulerService) setPaused(name string, paused bool) (*ScheduledJobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.findJob(name)
+	if job == nil {
+		return nil, ErrJobNotFound
+	}
+	if job.state == nil {
+		job.state = &model.ScheduledJob{Name: name}
+	}
+	job.state.Paused = paused
+	if !paused {
+		job.next = job.schedule.Next(time.Now())
+	}
+	job.state.NextRunAt = job.nextRunAt()
+	s.saveState(job)
+
+	if paused {
+		s.logInfo(fmt.Sprintf("定时任务 %s 已暂停", name))
+	} else {
+		s.logInfo(fmt.Sprintf("定时任务 %s 已恢复，下次执行: %s", name, job.next.Format(time.RFC3339)))
+	}
+	return job.status(), nil
+}
+
+// initJob 加载任务状态并计算下一次执行时间，需持有锁
+func (s *SchedulerService) initJob(job *scheduledJob, now time.Time) {
+	state, err := s.jobRepo.GetOrCreate(job.spec.Name)
+	if err != nil {
+		s.logError(fmt.Sprintf("加载定时任务 %s 的状态失败: %v", job.spec.Name, err))
+		state = &model.ScheduledJob{Name: job.spec.Name}
+	}
+	job.state = state
+
+	// 服务在任务执行过程中退出
+	if state.LastStatus == model.JobStatusRunning {
+		state.LastStatus = model.JobStatusFailed
+		state.LastError = "服务重启，执行中断"
+	}
+
+	// 错过了上次保存的执行时间，或从未执行过
+	missed := (state.NextRunAt != nil && state.NextRunAt.Before(now)) || (state.NextRunAt == nil && state.LastRunAt == nil)
+	job.next = job.schedule.Next(now)
+	state.NextRunAt = job.nextRunAt()
+	s.saveState(job)
+
+	if missed && job.spec.CatchUp && !state.Paused {
+		s.logInfo(fmt.Sprintf("定时任务 %s 错过了计划执行时间，立即补跑", job.spec.Name))
+		s.startJob(job, model.JobTriggerCatchUp)
+	}
+}
+
+// runDue 执行到期的任务，多次错过的执行只补跑一次
+func (s *SchedulerService) runDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.state == nil || job.state.Paused || job.next.IsZero() || now.Before(job.next) {
+			continue
+		}
+		if late := now.Sub(job.next); late > time.Minute {
+			s.logWarn(fmt.Sprintf("定时任务 %s 延迟 %s 执行", job.spec.Name, late.Round(time.Second)))
+		}
+		job.next = job.schedule.Next(now)
+		if job.running {
+			s.logWarn(fmt.Sprintf("定时任务 %s 上一次执行尚未结束，跳过本次执行", job.spec.Name))
+			job.state.NextRunAt = job.nextRunAt()
+			s.saveState(job)
+			continue
+		}
+		s.startJob(job, model.JobTriggerSchedule)
+	}
+}
+
+// startJob 在后台执行任务，需持有锁
+func (s *SchedulerService) startJob(job *scheduledJob, trigger string) {
+	startedAt := time.Now()
+	job.running = true
+	job.state.LastRunAt = &startedAt
+	job.state.LastTrigger = trigger
+	job.state.LastStatus = model.JobStatusRunning
+	job.state.NextRunAt = job.nextRunAt()
+	s.saveState(job)
+
+	go func() {
+		result, err := s.runSafely(job)
+		finishedAt := time.Now()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		job.running = false
+		job.state.LastFinishedAt = &finishedAt
+		job.state.LastDurationMs = finishedAt.Sub(startedAt).Milliseconds()
+		job.state.LastResult = result
+		job.state.RunCount++
+		if err != nil {
+			job.state.LastStatus = model.JobStatusFailed
+			job.state.LastError = err.Error()
+			job.state.FailCount++
+			s.logError(fmt.Sprintf("定时任务 %s 执行失败: %v", job.spec.Name, err))
+		} else {
+			job.state.LastStatus = model.JobStatusSuccess
+			job.state.LastError = ""
+			s.logInfo(fmt.Sprintf("定时任务 %s 执行完成，耗时 %s", job.spec.Name, finishedAt.Sub(startedAt).Round(time.Millisecond)))
+		}
+		s.saveState(job)
+	}()
+}
+
+// runSafely 执行任务函数，panic 视为执行失败
+func (s *SchedulerService) runSafely(job *scheduledJob) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务异常退出: %v", r)
+		}
+	}()
+	return job.spec.Run(s.ctx)
+}
+
+// saveState 保存任务状态，需持有锁
+func (s *SchedulerService) saveState(job *scheduledJob) {
+	if err := s.jobRepo.Save(job.state); err != nil {
+		s.logError(fmt.Sprintf("保存定时任务 %s 的状态失败: %v", job.spec.Name, err))
+	}
+}
+
+// findJob 根据名称查找任务，需持有锁
+func (s *SchedulerService) findJob(name string) *scheduledJob {
+	for _, job := range s.jobs {
+		if job.spec.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// nextRunAt 下一次计划执行时间，暂停或未启动时为空
+func (j *scheduledJob) nextRunAt() *time.Time {
+	if j.next.IsZero() || (j.state != nil && j.state.Paused) {
+		return nil
+	}
+	next := j.next
+	return &next
+}
+
+// status 任务状态快照，需持有锁
+func (j *scheduledJob) status() *ScheduledJobStatus {
+	status := &ScheduledJobStatus{
+		Name:        j.spec.Name,
+		Description: j.spec.Description,
+		Cron:        j.schedule.String(),
+		Timezone:    j.schedule.Location().String(),
+		CatchUp:     j.spec.CatchUp,
+		Running:     j.running,
+		NextRunAt:   j.nextRunAt(),
+	}
+	if state := j.state; state != nil {
+		status.Paused = state.Paused
+		status.LastRunAt = state.LastRunAt
+		status.LastFinishedAt = state.LastFinishedAt
+		status.LastTrigger = state.LastTrigger
+		status.LastStatus = state.LastStatus
+		status.LastResult = state.LastResult
+		status.LastError = state.LastError
+		status.LastDurationMs = state.LastDurationMs
+		status.RunCount = state.RunCount
+		status.FailCount = state.FailCount
+	}
+	return status
+}
+
+// loadLocation 加载时区，为空时使用服务器时区
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q: %v", name, err)
+	}
+	return loc, nil
+}
+
+func (s *SchedulerService) logInfo(message string) {
+	if s.logService != nil {
+		s.logService.LogInfo("system", "scheduler", message)
+	}
+}
+
+func (s *SchedulerService) logWarn(message string) {
+	if s.logService != nil {
+		s.logService.LogWarn("system", "scheduler", message)
+	}
+}
+
+func (s *SchedulerService) logError(message string) {
+	if s.logService != nil {
+		s.logService.LogError("system", "scheduler", message)
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"nsfw-go/internal/moviecode"
+)
+
+// TorrentTag 种子偏好标签，标题包含任一关键词或番号带有任一变体后缀时加分
+type TorrentTag struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+	Variants []string `json:"variants"` // 番号变体后缀，如 C、U、UC、4K
+	Weight   int      `json:"weight"`   // 负数表示减分
+}
+
+// TorrentSelectionRules 种子选择规则：先按硬性条件排除，再按偏好计算得分
+type TorrentSelectionRules struct {
+	RequireCodeMatch bool           `json:"require_code_match"` // 标题中必须包含同一番号
+	PreferredTags    []TorrentTag   `json:"preferred_tags"`
+	ExcludeKeywords  []string       `json:"exclude_keywords"` // 标题包含任一关键词时排除（不区分大小写）
+	MinSize          int64          `json:"min_size"`         // 字节，0 表示不限制
+	MaxSize          int64          `json:"max_size"`         // 字节，0 表示不限制
+	MinSeeders       int            `json:"min_seeders"`
+	MaxAgeDays       int            `json:"max_age_days"`    // 发布天数上限，0 表示不限制
+	TrackerWeights   map[string]int `json:"tracker_weights"` // 按索引器名称（小写）加减分，SetSelectionRules 会统一为小写
+	SeedersWeight    int            `json:"seeders_weight"`  // 做种数得分上限，100 人做种时满分
+	SizeWeight       int            `json:"size_weight"`     // 文件大小得分上限，达到大小上限时满分
+	AgeWeight        int            `json:"age_weight"`      // 发布时间得分上限，当天发布满分，一年后为 0
+}
+
+// DefaultTorrentTags 内置偏好标签：中文字幕、4K、无码流出
+func DefaultTorrentTags() []TorrentTag {
+	return []TorrentTag{
+		{Name: "chinese-subtitle", Keywords: []string{"中文字幕", "中字", "chinese sub"}, Variants: []string{"C", "UC"}, Weight: 30},
+		{Name: "4k", Keywords: []string{"4k", "2160p", "uhd"}, Variants: []string{"4K"}, Weight: 20},
+		{Name: "uncensored-leak", Keywords: []string{"uncensored", "leak", "无码", "無碼", "流出", "破解"}, Variants: []string{"U", "UC"}, Weight: 25},
+	}
+}
+
+// DefaultTorrentSelectionRules 默认选择规则
+func DefaultTorrentSelectionRules() TorrentSelectionRules {
+	return TorrentSelectionRules{
+		RequireCodeMatch: true,
+		PreferredTags:    DefaultTorrentTags(),
+		MinSize:          200 << 20,
+		MaxSize:          12 << 30,
+		MinSeeders:       1,
+		SeedersWeight:    30,
+		SizeWeight:       10,
+	}
+}
+
+// normalizeTrackerWeights 将索引器名称统一为小写；只有大小写不同的名称按字典序靠后的为准，保证评分稳定
+func normalizeTrackerWeights(weights map[string]int) map[string]int {
+	if weights == nil {
+		return nil
+	}
+	trackers := make([]string, 0, len(weights))
+	for tracker := range weights {
+		trackers = append(trackers, tracker)
+	}
+	sort.Strings(trackers)
+	normalized := make(map[string]int, len(weights))
+	for _, tracker := range trackers {
+		normalized[strings.ToLower(tracker)] = weights[tracker]
+	}
+	return normalized
+}
+
+// TorrentScoreReason 单条规则的评分结果
+type TorrentScoreReason struct {
+	Rule     string `json:"rule"`
+	Points   int    `json:"points"`
+	Rejected bool   `json:"rejected,omitempty"` // 该规则排除了种子
+	Detail   string `json:"detail"`
+}
+
+// TorrentScore 种子评分结果
+type TorrentScore struct {
+	Torrent  JackettResult        `json:"torrent"`
+	Score    int                  `json:"score"`
+	Rejected bool                 `json:"rejected"`
+	Reasons  []TorrentScoreReason `json:"reasons"`
+}
+
+// TorrentSelection 番号的种子评分明细
+type TorrentSelection struct {
+	Code       string                `json:"code"`
+	Rules      TorrentSelectionRules `json:"rules"`
+	Best       *TorrentScore         `json:"best"` // 没有未被排除的种子时为空
+	Candidates []TorrentScore        `json:"candidates"`
+}
+
+// Score 为番号的候选种子评分，按未排除优先、得分、做种数、大小排序
+func (r TorrentSelectionRules) Score(code string, results []JackettResult) []TorrentScore {
+	now := time.Now()
+	scores := make([]TorrentScore, 0, len(results))
+	for _, result := range results {
+		scores = append(scores, r.score(code, result, now))
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if a.Rejected != b.Rejected {
+			return !a.Rejected
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Torrent.Seeders != b.Torrent.Seeders {
+			return a.Torrent.Seeders > b.Torrent.Seeders
+		}
+		return a.Torrent.Size > b.Torrent.Size
+	})
+	return scores
+}
+
+// score 计算单个种子的得分，记录每条规则的结果
+func (r TorrentSelectionRules) score(code string, result JackettResult, now time.Time) TorrentScore {
+	s := TorrentScore{Torrent: result}
+	add := func(rule string, points int, detail string) {
+		s.Score += points
+		s.Reasons = append(s.Reasons, TorrentScoreReason{Rule: rule, Points: points, Detail: detail})
+	}
+	reject := func(rule, detail string) {
+		s.Rejected = true
+		s.Reasons = append(s.Reasons, TorrentScoreReason{Rule: rule, Rejected: true, Detail: detail})
+	}
+	title := strings.ToLower(result.Title)
+
+	// 番号
+	parsed, hasCode := moviecode.Parse(result.Title)
+	switch {
+	case hasCode && parsed.Key() == moviecode.Key(code):
+		add("code", 0, fmt.Sprintf("标题中的番号 %s 与 %s 一致", parsed.String(), code))
+	case r.RequireCodeMatch && hasCode:
+		reject("code", fmt.Sprintf("标题中的番号 %s 与 %s 不一致", parsed.String(), code))
+	case r.RequireCodeMatch:
+		reject("code", fmt.Sprintf("标题中没有番号 %s", code))
+	}
+
+	// 排除关键词
+	for _, keyword := range r.ExcludeKeywords {
+		if keyword != "" && strings.Contains(title, strings.ToLower(keyword)) {
+			reject("exclude", fmt.Sprintf("标题包含排除关键词 %q", keyword))
+		}
+	}
+
+	// 大小
+	switch {
+	case result.Size <= 0 && (r.MinSize > 0 || r.MaxSize > 0):
+		add("size", 0, "索引器未提供文件大小")
+	case r.MinSize > 0 && result.Size < r.MinSize:
+		reject("size", fmt.Sprintf("%s 小于下限 %s", formatFileSize(result.Size), formatFileSize(r.MinSize)))
+	case r.MaxSize > 0 && result.Size > r.MaxSize:
+		reject("size", fmt.Sprintf("%s 超过上限 %s", formatFileSize(result.Size), formatFileSize(r.MaxSize)))
+	case r.SizeWeight != 0 && r.MaxSize > 0:
+		points := int(math.Round(float64(r.SizeWeight) * float64(result.Size) / float64(r.MaxSize)))
+		add("size", points, fmt.Sprintf("%s，上限 %s", formatFileSize(result.Size), formatFileSize(r.MaxSize)))
+	}
+
+	// 做种数
+	if result.Seeders < r.MinSeeders {
+		reject("seeders", fmt.Sprintf("做种数 %d 少于 %d", result.Seeders, r.MinSeeders))
+	} else if r.SeedersWeight != 0 {
+		ratio := math.Min(1, math.Log10(1+float64(result.Seeders))/2)
+		add("seeders", int(math.Round(float64(r.SeedersWeight)*ratio)), fmt.Sprintf("做种数 %d", result.Seeders))
+	}
+
+	// 偏好标签
+	for _, tag := range r.PreferredTags {
+		if matched, ok := tag.match(title, parsed, hasCode); ok {
+			add("tag:"+tag.Name, tag.Weight, fmt.Sprintf("匹配 %s", matched))
+		}
+	}
+
+	// 索引器
+	if weight, ok := r.TrackerWeights[strings.ToLower(result.Tracker)]; ok {
+		add("tracker", weight, fmt.Sprintf("索引器 %s", result.Tracker))
+	}
+
+	// 发布时间
+	if published, ok := parsePublishDate(result.PublishDate); ok {
+		days := int(now.Sub(published).Hours() / 24)
+		switch {
+		case r.MaxAgeDays > 0 && days > r.MaxAgeDays:
+			reject("age", fmt.Sprintf("发布于 %d 天前，超过 %d 天", days, r.MaxAgeDays))
+		case r.AgeWeight != 0:
+			ratio := math.Max(0, 1-float64(days)/365)
+			add("age", int(math.Round(float64(r.AgeWeight)*ratio)), fmt.Sprintf("发布于 %d 天前", days))
+		}
+	}
+
+	return s
+}
+
+// match 判断标签是否匹配，返回匹配到的关键词或变体后缀
+func (t TorrentTag) match(title string, code moviecode.Code, hasCode bool) (string, bool) {
+	if hasCode {
+		for _, variant := range t.Variants {
+			if code.HasVariant(strings.ToUpper(variant)) {
+				return "-" + strings.ToUpper(variant), true
+			}
+		}
+	}
+	for _, keyword := range t.Keywords {
+		if keyword != "" && strings.Contains(title, strings.ToLower(keyword)) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
+
+// Jackett 返回的发布时间格式
+var publishDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", time.RFC1123Z, time.RFC1123}
+
+// parsePublishDate 解析索引器返回的发布时间
+func parsePublishDate(value string) (time.Time, bool) {
+	for _, layout := range publishDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTorrentSelectionRulesReject(t *testing.T) {
+	rules := DefaultTorrentSelectionRules()
+	rules.ExcludeKeywords = []string{"Trailer"}
+	rules.MaxAgeDays = 30
+	recent := time.Now().Add(-24 * time.Hour).Format(time.RFC3339)
+	old := time.Now().AddDate(0, -2, 0).Format(time.RFC3339)
+
+	tests := []struct {
+		name   string
+		result JackettResult
+		want   string // 排除的规则，为空表示不排除
+	}{
+		{"正常", JackettResult{Title: "SSIS-123", Size: 4 << 30, Seeders: 5, PublishDate: recent}, ""},
+		{"番号等价写法", JackettResult{Title: "ssis00123 1080p", Size: 4 << 30, Seeders: 5}, ""},
+		{"番号不一致", JackettResult{Title: "SSIS-124", Size: 4 << 30, Seeders: 5}, "code"},
+		{"没有番号", JackettResult{Title: "some movie", Size: 4 << 30, Seeders: 5}, "code"},
+		{"排除关键词", JackettResult{Title: "SSIS-123 trailer", Size: 4 << 30, Seeders: 5}, "exclude"},
+		{"过小", JackettResult{Title: "SSIS-123", Size: 100 << 20, Seeders: 5}, "size"},
+		{"过大", JackettResult{Title: "SSIS-123", Size: 20 << 30, Seeders: 5}, "size"},
+		{"未提供大小", JackettResult{Title: "SSIS-123", Seeders: 5}, ""},
+		{"做种数不足", JackettResult{Title: "SSIS-123", Size: 4 << 30}, "seeders"},
+		{"发布过久", JackettResult{Title: "SSIS-123", Size: 4 << 30, Seeders: 5, PublishDate: old}, "age"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := rules.Score("SSIS-123", []JackettResult{tt.result})[0]
+			if score.Rejected != (tt.want != "") {
+				t.Fatalf("Rejected = %v，期望排除规则 %q：%+v", score.Rejected, tt.want, score.Reasons)
+			}
+			if tt.want == "" {
+				return
+			}
+			for _, reason := range score.Reasons {
+				if reason.Rejected && reason.Rule == tt.want {
+					return
+				}
+			}
+			t.Errorf("未按 %s 排除：%+v", tt.want, score.Reasons)
+		})
+	}
+}
+
+func TestTorrentSelectionRulesPoints(t *testing.T) {
+	rules := TorrentSelectionRules{
+		PreferredTags:  DefaultTorrentTags(),
+		TrackerWeights: normalizeTrackerWeights(map[string]int{"TrackerA": 15, "trackerb": -10}),
+		MaxSize:        10 << 30,
+		SeedersWeight:  30,
+		SizeWeight:     10,
+	}
+	tests := []struct {
+		name   string
+		result JackettResult
+		want   int
+	}{
+		{"无加分", JackettResult{Title: "SSIS-123"}, 0},
+		{"做种数满分", JackettResult{Title: "SSIS-123", Seeders: 99}, 30},
+		{"做种数一半", JackettResult{Title: "SSIS-123", Seeders: 9}, 15},
+		{"大小", JackettResult{Title: "SSIS-123", Size: 5 << 30}, 5},
+		{"中文字幕后缀", JackettResult{Title: "SSIS-123-C"}, 30},
+		{"中文字幕关键词", JackettResult{Title: "SSIS-123 中文字幕"}, 30},
+		{"无码中字", JackettResult{Title: "SSIS-123-UC"}, 55},
+		{"4K", JackettResult{Title: "SSIS-123-4K"}, 20},
+		{"无码流出", JackettResult{Title: "SSIS-123 uncensored leak"}, 25},
+		{"索引器不区分大小写", JackettResult{Title: "SSIS-123", Tracker: "TRACKERA"}, 15},
+		{"索引器减分", JackettResult{Title: "SSIS-123", Tracker: "TrackerB"}, -10},
+		{"未配置的索引器", JackettResult{Title: "SSIS-123", Tracker: "TrackerC"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := rules.Score("SSIS-123", []JackettResult{tt.result})[0]
+			if score.Rejected || score.Score != tt.want {
+				t.Errorf("Score = %d（排除 %v），期望 %d：%+v", score.Score, score.Rejected, tt.want, score.Reasons)
+			}
+		})
+	}
+}
+
+func TestTorrentSelectionRulesOrder(t *testing.T) {
+	rules := TorrentSelectionRules{RequireCodeMatch: true, PreferredTags: DefaultTorrentTags(), MinSeeders: 1}
+	results := []JackettResult{
+		{Title: "SSIS-124-C", Seeders: 50},
+		{Title: "SSIS-123", Seeders: 10, Size: 1},
+		{Title: "SSIS-123", Seeders: 10, Size: 2},
+		{Title: "SSIS-123-C", Seeders: 1},
+		{Title: "SSIS-123", Seeders: 20},
+	}
+	var got []JackettResult
+	for _, score := range rules.Score("SSIS-123", results) {
+		got = append(got, score.Torrent)
+	}
+	want := []JackettResult{results[3], results[4], results[2], results[1], results[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("排序 = %+v，期望 %+v", got, want)
+	}
+}
+
+func TestNormalizeTrackerWeights(t *testing.T) {
+	tests := []struct {
+		weights map[string]int
+		want    map[string]int
+	}{
+		{nil, nil},
+		{map[string]int{"TrackerA": 1, "trackerb": 2}, map[string]int{"trackera": 1, "trackerb": 2}},
+		// 只有大小写不同时按字典序靠后的为准
+		{map[string]int{"trackera": 1, "TrackerA": 2, "TRACKERA": 3}, map[string]int{"trackera": 1}},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			if got := normalizeTrackerWeights(tt.weights); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("normalizeTrackerWeights(%v) = %v，期望 %v", tt.weights, got, tt.want)
+			}
+		}
+	}
+}
@@ -1,29 +1,28 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"nsfw-go/internal/downloader"
+	"nsfw-go/internal/indexer"
 )
 
 // TorrentService 种子下载服务
 type TorrentService struct {
-	jackettHost     string
-	jackettAPIKey   string
-	qbittorrentHost string
-	qbittorrentUser string
-	qbittorrentPass string
+	indexers        *indexer.Searcher
+	downloadClient  downloader.DownloadClient
 	maxResults      int
 	minSeeders      int
 	sortBySize      bool
 	timeout         time.Duration
 	localMovieRepo  LocalMovieRepository
 	telegramService *TelegramService
+	selectionRules  TorrentSelectionRules
 }
 
 // LocalMovieRepository 本地影片仓库接口（定义在这里避免循环依赖）
@@ -38,20 +37,27 @@ type LocalMovie struct {
 	Title string `json:"title"`
 }
 
+// 添加到下载器的种子使用的分类与标签，种子列表按分类过滤
+const (
+	torrentCategory = "PornDB"
+	torrentTag      = "PornDB"
+)
+
+// ErrTorrentExists 种子已在下载器中
+var ErrTorrentExists = downloader.ErrTorrentExists
+
 // NewTorrentService 创建种子下载服务
-func NewTorrentService(jackettHost, jackettAPIKey, qbittorrentHost, qbittorrentUser, qbittorrentPass string, localMovieRepo LocalMovieRepository) *TorrentService {
+func NewTorrentService(indexers *indexer.Searcher, downloadClient downloader.DownloadClient, localMovieRepo LocalMovieRepository) *TorrentService {
 	return &TorrentService{
-		jackettHost:     jackettHost,
-		jackettAPIKey:   jackettAPIKey,
-		qbittorrentHost: qbittorrentHost,
-		qbittorrentUser: qbittorrentUser,
-		qbittorrentPass: qbittorrentPass,
+		indexers:        indexers,
+		downloadClient:  downloadClient,
 		maxResults:      20,
 		minSeeders:      1,
 		sortBySize:      true,
 		timeout:         30 * time.Second,
 		localMovieRepo:  localMovieRepo,
 		telegramService: nil, // 将在路由设置中注入
+		selectionRules:  DefaultTorrentSelectionRules(),
 	}
 }
 
@@ -60,7 +66,20 @@ func (s *TorrentService) SetTelegramService(telegramService *TelegramService) {
 	s.telegramService = telegramService
 }
 
-// JackettResult Jackett搜索结果
+// SetSelectionRules 设置按番号选择种子时使用的规则，索引器名称统一为小写
+func (s *TorrentService) SetSelectionRules(rules TorrentSelectionRules) {
+	rules.TrackerWeights = normalizeTrackerWeights(rules.TrackerWeights)
+	s.selectionRules = rules
+}
+
+// IndexerStatus 索引器状态
+type IndexerStatus struct {
+	Name  string        `json:"name"`
+	Caps  *indexer.Caps `json:"caps,omitempty"`
+	Error string        `json:"error,omitempty"` // 获取能力失败的原因
+}
+
+// JackettResult 索引器搜索结果
 type JackettResult struct {
 	Title         string `json:"title"`
 	Link          string `json:"link"`
@@ -72,116 +91,98 @@ type JackettResult struct {
 	MagnetURI     string `json:"magnetUri"`
 	InfoHash      string `json:"infoHash"`
 	Tracker       string `json:"tracker"`
+	Indexer       string `json:"indexer"`
 	Category      string `json:"category"`
 }
 
-// JackettResponse Jackett API响应结构
-type JackettResponse struct {
-	Results []struct {
-		Title       string `json:"Title"`
-		TrackerID   string `json:"TrackerId"`
-		Tracker     string `json:"Tracker"`
-		CategoryID  int    `json:"CategoryId"`
-		Category    []int  `json:"Category"` // 修复: Category 是数组而不是字符串
-		Size        int64  `json:"Size"`
-		Files       int    `json:"Files"`
-		Grabs       int    `json:"Grabs"`
-		Description string `json:"Description"`
-		Link        string `json:"Link"`
-		Comments    string `json:"Comments"`
-		PublishDate string `json:"PublishDate"`
-		Seeders     int    `json:"Seeders"`
-		Peers       int    `json:"Peers"`
-		InfoHash    string `json:"InfoHash"`
-		MagnetURI   string `json:"MagnetUri"`
-		MinSeedTime int    `json:"MinSeedTime"`
-	} `json:"Results"`
-}
-
 // SearchTorrents 搜索种子（按文件大小排序）
 func (s *TorrentService) SearchTorrents(keyword string) ([]JackettResult, error) {
-	// 构建Jackett API URL，使用成人内容的分类ID
-	categories := []string{
-		"6000", "6010", "6060", "6080",
-		"100431", "100437", "100410", "100424", 
-		"100432", "100426", "100429", "100430",
-		"100436", "100433", "100425",
+	jackettResults, err := s.searchIndexers(keyword)
+	if err != nil {
+		return nil, err
 	}
 
-	// 构建分类参数
-	categoryParams := ""
-	for _, cat := range categories {
-		categoryParams += "&Category%5B%5D=" + cat
+	// 过滤掉做种数不足的
+	var results []JackettResult
+	for _, result := range jackettResults {
+		if result.Seeders >= s.minSeeders {
+			results = append(results, result)
+		}
 	}
 
-	apiURL := fmt.Sprintf("%s/api/v2.0/indexers/all/results?apikey=%s&Query=%s%s",
-		s.jackettHost, s.jackettAPIKey, url.QueryEscape(keyword), categoryParams)
-
-	// 创建HTTP客户端
-	client := &http.Client{
-		Timeout: s.timeout,
+	// 按文件大小排序（从大到小）
+	if s.sortBySize {
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].Size > results[j].Size
+		})
 	}
 
-	// 发送请求到Jackett
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return nil, fmt.Errorf("调用Jackett API失败: %v", err)
+	// 限制结果数量
+	if len(results) > s.maxResults {
+		results = results[:s.maxResults]
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Jackett API返回错误状态码: %d", resp.StatusCode)
-	}
+	return results, nil
+}
 
-	// 解析响应
-	var jackettResp JackettResponse
-	if err := json.NewDecoder(resp.Body).Decode(&jackettResp); err != nil {
-		return nil, fmt.Errorf("解析Jackett响应失败: %v", err)
+// searchIndexers 并行查询全部索引器，返回合并去重后的全部结果
+func (s *TorrentService) searchIndexers(keyword string) ([]JackettResult, error) {
+	indexerResults, failures, err := s.indexers.Search(context.Background(), keyword)
+	if err != nil {
+		return nil, err
+	}
+	for _, failure := range failures {
+		fmt.Printf("⚠️  索引器搜索失败: %v\n", failure)
 	}
 
-	// 转换为标准格式并过滤
-	var results []JackettResult
-	for _, item := range jackettResp.Results {
-		// 过滤掉做种数不足的
-		if item.Seeders < s.minSeeders {
-			continue
-		}
-
-		// 格式化文件大小
-		sizeFormatted := formatFileSize(item.Size)
-
+	// 转换为标准格式
+	results := make([]JackettResult, 0, len(indexerResults))
+	for _, item := range indexerResults {
 		result := JackettResult{
 			Title:         item.Title,
 			Link:          item.Link,
 			Size:          item.Size,
-			SizeFormatted: sizeFormatted,
+			SizeFormatted: formatFileSize(item.Size),
 			Seeders:       item.Seeders,
-			Leechers:      item.Peers,
-			PublishDate:   item.PublishDate,
+			Leechers:      item.Leechers,
 			MagnetURI:     item.MagnetURI,
 			InfoHash:      item.InfoHash,
 			Tracker:       item.Tracker,
-			Category:      fmt.Sprintf("%v", item.Category), // 将数组转换为字符串
+			Indexer:       item.Indexer,
+			Category:      fmt.Sprintf("%v", item.Categories), // 将数组转换为字符串
+		}
+		if !item.PublishDate.IsZero() {
+			result.PublishDate = item.PublishDate.Format(time.RFC3339)
 		}
 		results = append(results, result)
 	}
 
-	// 按文件大小排序（从大到小）
-	if s.sortBySize {
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].Size > results[j].Size
-		})
-	}
+	return results, nil
+}
 
-	// 限制结果数量
-	if len(results) > s.maxResults {
-		results = results[:s.maxResults]
+// GetIndexers 获取已启用的索引器及其能力（t=caps）
+func (s *TorrentService) GetIndexers(ctx context.Context) []IndexerStatus {
+	indexers := s.indexers.Indexers()
+	statuses := make([]IndexerStatus, len(indexers))
+	var wg sync.WaitGroup
+	for i, idx := range indexers {
+		wg.Add(1)
+		go func(i int, idx indexer.Indexer) {
+			defer wg.Done()
+			statuses[i] = IndexerStatus{Name: idx.Name()}
+			caps, err := idx.Caps(ctx)
+			if err != nil {
+				statuses[i].Error = err.Error()
+				return
+			}
+			statuses[i].Caps = caps
+		}(i, idx)
 	}
-
-	return results, nil
+	wg.Wait()
+	return statuses
 }
 
-// SearchTorrentsForCode 为特定番号搜索种子
+// SearchTorrentsForCode 为特定番号搜索种子，只返回符合选择规则的种子（按得分从高到低排序）
 func (s *TorrentService) SearchTorrentsForCode(code string) ([]JackettResult, error) {
 	// 检查本地是否已存在该番号
 	_, err := s.localMovieRepo.SearchByCode(code)
@@ -196,11 +197,50 @@ func (s *TorrentService) SearchTorrentsForCode(code string) ([]JackettResult, er
 		return nil, fmt.Errorf("检查本地电影失败: %v", err)
 	}
 
-	// 搜索种子
-	return s.SearchTorrents(code)
+	return s.selectTorrents(code)
+}
+
+// SearchTorrentsForUpgrade 为已在本地的番号搜索种子，用于寻找更高画质的版本，不检查本地是否已存在
+func (s *TorrentService) SearchTorrentsForUpgrade(code string) ([]JackettResult, error) {
+	return s.selectTorrents(code)
+}
+
+// selectTorrents 搜索种子并按选择规则评分，只返回未被排除的种子（按得分从高到低排序）
+func (s *TorrentService) selectTorrents(code string) ([]JackettResult, error) {
+	results, err := s.searchIndexers(code)
+	if err != nil {
+		return nil, err
+	}
+
+	var accepted []JackettResult
+	for _, score := range s.selectionRules.Score(code, results) {
+		if score.Rejected || len(accepted) >= s.maxResults {
+			break
+		}
+		accepted = append(accepted, score.Torrent)
+	}
+	return accepted, nil
+}
+
+// ExplainTorrentsForCode 搜索番号的种子并返回每个候选种子的评分明细，不检查本地是否已存在
+func (s *TorrentService) ExplainTorrentsForCode(code string) (*TorrentSelection, error) {
+	results, err := s.searchIndexers(code)
+	if err != nil {
+		return nil, err
+	}
+
+	selection := &TorrentSelection{
+		Code:       code,
+		Rules:      s.selectionRules,
+		Candidates: s.selectionRules.Score(code, results),
+	}
+	if len(selection.Candidates) > 0 && !selection.Candidates[0].Rejected {
+		selection.Best = &selection.Candidates[0]
+	}
+	return selection, nil
 }
 
-// GetBestTorrentForCode 为番号获取最佳种子（最大文件）
+// GetBestTorrentForCode 为番号获取最佳种子（选择规则得分最高）
 func (s *TorrentService) GetBestTorrentForCode(code string) (*JackettResult, error) {
 	results, err := s.SearchTorrentsForCode(code)
 	if err != nil {
@@ -208,10 +248,10 @@ func (s *TorrentService) GetBestTorrentForCode(code string) (*JackettResult, err
 	}
 
 	if len(results) == 0 {
-		return nil, fmt.Errorf("未找到番号 %s 的种子资源", code)
+		return nil, fmt.Errorf("未找到番号 %s 符合选择规则的种子资源", code)
 	}
 
-	// 返回第一个结果（已按文件大小从大到小排序）
+	// 返回第一个结果（已按得分从高到低排序）
 	bestTorrent := &results[0]
 	
 	fmt.Printf("🎯 已为番号 %s 选择最佳种子:\n", code)
@@ -223,7 +263,7 @@ func (s *TorrentService) GetBestTorrentForCode(code string) (*JackettResult, err
 	return bestTorrent, nil
 }
 
-// DownloadBestTorrentForCode 自动为番号下载最佳种子（最大文件）
+// DownloadBestTorrentForCode 自动为番号下载最佳种子（选择规则得分最高）
 func (s *TorrentService) DownloadBestTorrentForCode(code string) error {
 	// 获取最佳种子
 	bestTorrent, err := s.GetBestTorrentForCode(code)
@@ -267,12 +307,12 @@ func (s *TorrentService) DownloadTorrentWithNotification(magnetURI, downloadURI,
 	}
 
 	// 添加种子到下载器
-	err := s.DownloadTorrent(actualURI)
+	err := s.DownloadTorrent(actualURI, "")
 	if err != nil {
 		// 如果HTTP链接失败且有磁力链接，尝试使用磁力链接
 		if uriType == "HTTP链接" && magnetURI != "" {
 			fmt.Printf("HTTP下载失败，尝试使用磁力链接: %s\n", err.Error())
-			err = s.DownloadTorrent(magnetURI)
+			err = s.DownloadTorrent(magnetURI, "")
 			if err == nil {
 				actualURI = magnetURI
 				uriType = "磁力链接(备用)"
@@ -320,54 +360,28 @@ func (s *TorrentService) DownloadTorrentWithNotification(magnetURI, downloadURI,
 	return nil
 }
 
-// DownloadTorrent 添加种子到qBittorrent (支持磁力链接和HTTP下载链接)
-func (s *TorrentService) DownloadTorrent(downloadURI string) error {
+// DownloadTorrent 添加种子到下载器 (支持磁力链接和HTTP下载链接)
+// infoHash 为索引器提供的种子哈希，用于识别已在下载器中的 HTTP 种子链接，未知时为空
+func (s *TorrentService) DownloadTorrent(downloadURI, infoHash string) error {
 	if downloadURI == "" {
 		return fmt.Errorf("下载链接不能为空")
 	}
 
-	client := &http.Client{
-		Timeout: s.timeout,
-	}
-
-	// 登录qBittorrent
-	loginURL := fmt.Sprintf("%s/api/v2/auth/login", s.qbittorrentHost)
-	loginData := url.Values{
-		"username": {s.qbittorrentUser},
-		"password": {s.qbittorrentPass},
-	}
-
-	resp, err := client.PostForm(loginURL, loginData)
-	if err != nil {
-		return fmt.Errorf("登录qBittorrent失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("登录qBittorrent失败，状态码: %d", resp.StatusCode)
-	}
-
-	// 获取Cookie
-	cookies := resp.Cookies()
-	if len(cookies) == 0 {
-		return fmt.Errorf("未获取到qBittorrent登录Cookie")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
 
 	// 检查种子是否已存在
-	existingTorrents, err := s.getTorrentListWithCookies(client, cookies)
+	existingTorrents, err := s.downloadClient.List(ctx)
 	if err != nil {
 		fmt.Printf("⚠️  无法检查现有种子列表: %v\n", err)
 	} else {
-		// 检查重复种子
+		hash := downloader.MagnetInfoHash(downloadURI)
+		if hash == "" {
+			hash = strings.ToLower(infoHash)
+		}
 		for _, torrent := range existingTorrents {
-			if magnetURI, ok := torrent["magnet_uri"].(string); ok {
-				if magnetURI == downloadURI {
-					name := "未知"
-					if n, ok := torrent["name"].(string); ok {
-						name = n
-					}
-					return fmt.Errorf("种子 '%s' 已存在于下载列表中，无法重复添加", name)
-				}
+			if torrent.MagnetURI == downloadURI || (hash != "" && torrent.Hash == hash) {
+				return fmt.Errorf("种子 '%s' %w", torrent.Name, ErrTorrentExists)
 			}
 		}
 	}
@@ -379,154 +393,57 @@ func (s *TorrentService) DownloadTorrent(downloadURI string) error {
 		downloadPath = strings.Trim(config.String(), "\"")
 	}
 
-	// 添加种子 - 支持磁力链接和HTTP下载链接
-	addURL := fmt.Sprintf("%s/api/v2/torrents/add", s.qbittorrentHost)
-	addData := url.Values{
-		"urls":        {downloadURI}, // qBittorrent 的 urls 参数可以同时处理磁力链接和HTTP链接
-		"savepath":    {downloadPath}, // 从配置获取下载目录
-		"tags":        {"PornDB"}, // 添加PornDB标签
-		"category":    {"PornDB"}, // 设置分类
-		"paused":      {"false"}, // 立即开始下载
-		"root_folder": {"false"}, // 不创建根文件夹
-		"rename":      {""}, // 不重命名
-		"upLimit":     {""}, // 无上传限制
-		"dlLimit":     {""}, // 无下载限制
-	}
-	
 	// 记录请求详情用于调试
-	fmt.Printf("🔧 qBittorrent API 请求参数:\n")
-	fmt.Printf("   URL: %s\n", addURL)
+	fmt.Printf("🔧 添加种子到 %s:\n", s.downloadClient.Name())
 	fmt.Printf("   下载路径: %s\n", downloadPath)
 	fmt.Printf("   下载URI: %s\n", downloadURI)
-	fmt.Printf("   分类: PornDB\n")
-	fmt.Printf("   标签: PornDB\n")
-
-	req, err := http.NewRequest("POST", addURL, strings.NewReader(addData.Encode()))
-	if err != nil {
-		return fmt.Errorf("创建添加种子请求失败: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	for _, cookie := range cookies {
-		req.AddCookie(cookie)
-	}
+	fmt.Printf("   分类: %s\n", torrentCategory)
 
-	resp, err = client.Do(req)
+	err = s.downloadClient.Add(ctx, downloadURI, downloader.AddOptions{
+		SavePath: downloadPath,         // 从配置获取下载目录
+		Category: torrentCategory,      // 设置分类
+		Tags:     []string{torrentTag}, // 添加PornDB标签
+	})
 	if err != nil {
-		return fmt.Errorf("添加种子到qBittorrent失败: %v", err)
+		fmt.Printf("❌ 添加种子失败: %v\n", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Printf("❌ qBittorrent API 错误响应:\n")
-		fmt.Printf("   状态码: %d\n", resp.StatusCode)
-		fmt.Printf("   响应内容: %s\n", string(body))
-		return fmt.Errorf("添加种子失败，状态码: %d，响应: %s", resp.StatusCode, string(body))
-	}
-
-	// 成功响应
-	body, _ := io.ReadAll(resp.Body)
-	responseText := strings.TrimSpace(string(body))
-	fmt.Printf("✅ qBittorrent API 成功响应: %s\n", responseText)
-	
-	// 检查是否是重复种子
-	if responseText == "Fails." {
-		return fmt.Errorf("检测到尝试添加重复 Torrent 文件，qBittorrent 已拒绝添加")
-	}
-	
-	fmt.Printf("✅ 种子已添加到 qBittorrent，应保存至: %s\n", downloadPath)
+	fmt.Printf("✅ 种子已添加到 %s，应保存至: %s\n", s.downloadClient.Name(), downloadPath)
 
 	return nil
 }
 
-// GetTorrentList 获取qBittorrent中的种子列表（只返回PornDB标签的种子）
-func (s *TorrentService) GetTorrentList() ([]map[string]interface{}, error) {
-	client := &http.Client{
-		Timeout: s.timeout,
-	}
+// GetTorrentList 获取下载器中的种子列表（只返回PornDB分类的种子，不支持分类的下载器返回全部种子）
+func (s *TorrentService) GetTorrentList() ([]downloader.Torrent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
 
-	// 登录qBittorrent
-	loginURL := fmt.Sprintf("%s/api/v2/auth/login", s.qbittorrentHost)
-	loginData := url.Values{
-		"username": {s.qbittorrentUser},
-		"password": {s.qbittorrentPass},
-	}
-
-	resp, err := client.PostForm(loginURL, loginData)
+	torrents, err := s.downloadClient.List(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("登录qBittorrent失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("登录qBittorrent失败，状态码: %d", resp.StatusCode)
-	}
-
-	// 获取Cookie
-	cookies := resp.Cookies()
-	if len(cookies) == 0 {
-		return nil, fmt.Errorf("未获取到qBittorrent登录Cookie")
-	}
-
-	// 获取种子列表，只显示标签为 PornDB 的种子
-	listURL := fmt.Sprintf("%s/api/v2/torrents/info?tag=PornDB", s.qbittorrentHost)
-	req, err := http.NewRequest("GET", listURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建获取种子列表请求失败: %v", err)
-	}
-
-	for _, cookie := range cookies {
-		req.AddCookie(cookie)
-	}
-
-	resp, err = client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("获取种子列表失败: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("获取种子列表失败，状态码: %d", resp.StatusCode)
+	if s.downloadClient.Name() == downloader.TypeAria2 {
+		return torrents, nil
 	}
 
-	var torrents []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
-		return nil, fmt.Errorf("解析种子列表失败: %v", err)
+	filtered := make([]downloader.Torrent, 0, len(torrents))
+	for _, torrent := range torrents {
+		if strings.EqualFold(torrent.Category, torrentCategory) {
+			filtered = append(filtered, torrent)
+		}
 	}
-
-	return torrents, nil
+	return filtered, nil
 }
 
-// getTorrentListWithCookies 使用现有cookies获取种子列表（内部辅助方法）
-func (s *TorrentService) getTorrentListWithCookies(client *http.Client, cookies []*http.Cookie) ([]map[string]interface{}, error) {
-	// 获取种子列表
-	listURL := fmt.Sprintf("%s/api/v2/torrents/info", s.qbittorrentHost)
-	req, err := http.NewRequest("GET", listURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建获取种子列表请求失败: %v", err)
-	}
-
-	for _, cookie := range cookies {
-		req.AddCookie(cookie)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("获取种子列表失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("获取种子列表失败，状态码: %d", resp.StatusCode)
-	}
-
-	var torrents []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
-		return nil, fmt.Errorf("解析种子列表失败: %v", err)
-	}
+// ListTorrents 获取下载器中的全部种子
+func (s *TorrentService) ListTorrents(ctx context.Context) ([]downloader.Torrent, error) {
+	return s.downloadClient.List(ctx)
+}
 
-	return torrents, nil
+// DownloadClient 返回当前使用的下载器
+func (s *TorrentService) DownloadClient() downloader.DownloadClient {
+	return s.downloadClient
 }
 
 // formatFileSize 格式化文件大小
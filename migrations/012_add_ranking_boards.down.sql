@@ -0,0 +1,4 @@
+-- 删除女优排行榜与排行榜榜单标识
+DROP TABLE IF EXISTS actress_rankings;
+DROP INDEX IF EXISTS idx_rankings_board;
+ALTER TABLE rankings DROP COLUMN IF EXISTS board;
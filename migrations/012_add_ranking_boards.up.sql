@@ -0,0 +1,21 @@
+-- 排行榜记录增加榜单标识，旧记录都来自有码榜，标识与排行榜类型相同
+ALTER TABLE rankings ADD COLUMN IF NOT EXISTS board VARCHAR(20);
+UPDATE rankings SET board = rank_type WHERE board IS NULL OR board = '';
+CREATE INDEX IF NOT EXISTS idx_rankings_board ON rankings(board);
+
+-- 创建actress_rankings表用于存储女优排行榜
+CREATE TABLE IF NOT EXISTS actress_rankings (
+    id SERIAL PRIMARY KEY,
+    board VARCHAR(20) NOT NULL,
+    javdb_id VARCHAR(50),
+    name VARCHAR(200) NOT NULL,
+    avatar_url VARCHAR(1000),
+    position INTEGER NOT NULL,
+    actress_id INTEGER,
+    crawled_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_actress_rankings_board ON actress_rankings(board);
+CREATE INDEX IF NOT EXISTS idx_actress_rankings_javdb_id ON actress_rankings(javdb_id);
+CREATE INDEX IF NOT EXISTS idx_actress_rankings_actress_id ON actress_rankings(actress_id);
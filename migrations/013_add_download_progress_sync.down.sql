@@ -0,0 +1,6 @@
+-- 删除下载进度同步字段
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS last_synced_at;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS content_path;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS client_state;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS eta;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS download_speed;
@@ -0,0 +1,7 @@
+-- 下载任务增加从qBittorrent同步的状态字段
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS download_speed BIGINT DEFAULT 0;
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS eta BIGINT DEFAULT 0;
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS client_state VARCHAR(30);
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS content_path VARCHAR(1000);
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS last_synced_at TIMESTAMP;
+
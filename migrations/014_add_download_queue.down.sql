@@ -0,0 +1,3 @@
+-- 删除下载队列优先级
+DROP INDEX IF EXISTS idx_ranking_download_tasks_priority;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS priority;
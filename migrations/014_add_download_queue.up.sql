@@ -0,0 +1,4 @@
+-- 下载任务增加队列优先级，手动下载优先于订阅下载
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS priority INTEGER DEFAULT 0;
+UPDATE ranking_download_tasks SET priority = 10 WHERE source = 'manual';
+CREATE INDEX IF NOT EXISTS idx_ranking_download_tasks_priority ON ranking_download_tasks(priority);
@@ -0,0 +1,8 @@
+-- 删除种子黑名单与下载重试字段
+DROP TABLE IF EXISTS torrent_blocklist;
+DROP INDEX IF EXISTS idx_ranking_download_tasks_next_retry_at;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS attempts;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS candidates;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS next_retry_at;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS retry_count;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS progress_changed_at;
@@ -0,0 +1,22 @@
+-- 下载任务增加重试状态、候选种子与尝试记录
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS progress_changed_at TIMESTAMP;
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS retry_count INTEGER DEFAULT 0;
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMP;
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS candidates TEXT;
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS attempts TEXT;
+CREATE INDEX IF NOT EXISTS idx_ranking_download_tasks_next_retry_at ON ranking_download_tasks(next_retry_at);
+
+-- 种子黑名单
+CREATE TABLE IF NOT EXISTS torrent_blocklist (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    info_hash VARCHAR(100) NOT NULL,
+    code VARCHAR(50),
+    title VARCHAR(500),
+    reason VARCHAR(1000)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_torrent_blocklist_info_hash ON torrent_blocklist(info_hash);
+CREATE INDEX IF NOT EXISTS idx_torrent_blocklist_code ON torrent_blocklist(code);
+CREATE INDEX IF NOT EXISTS idx_torrent_blocklist_deleted_at ON torrent_blocklist(deleted_at);
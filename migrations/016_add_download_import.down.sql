@@ -0,0 +1,5 @@
+-- 删除下载导入状态字段
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS imported_at;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS import_error;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS imported_path;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS import_status;
@@ -0,0 +1,5 @@
+-- 下载任务增加导入媒体库的状态
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS import_status VARCHAR(20);
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS imported_path VARCHAR(1000);
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS import_error VARCHAR(1000);
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS imported_at TIMESTAMP;
@@ -0,0 +1,4 @@
+-- 删除画质升级字段
+DROP INDEX IF EXISTS idx_ranking_download_tasks_upgrade_of;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS upgrade_reason;
+ALTER TABLE ranking_download_tasks DROP COLUMN IF EXISTS upgrade_of;
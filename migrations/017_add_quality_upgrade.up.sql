@@ -0,0 +1,4 @@
+-- 下载任务增加画质升级信息
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS upgrade_of BIGINT;
+ALTER TABLE ranking_download_tasks ADD COLUMN IF NOT EXISTS upgrade_reason VARCHAR(200);
+CREATE INDEX IF NOT EXISTS idx_ranking_download_tasks_upgrade_of ON ranking_download_tasks(upgrade_of);
@@ -0,0 +1,3 @@
+-- 恢复番号唯一索引（同一番号存在多条未删除任务时需要先清理）
+DROP INDEX IF EXISTS idx_ranking_download_tasks_code;
+CREATE UNIQUE INDEX idx_ranking_download_tasks_code ON ranking_download_tasks(code) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- 画质升级为同一番号创建新任务并保留历史任务，番号索引不再唯一
+DROP INDEX IF EXISTS idx_ranking_download_tasks_code;
+CREATE INDEX IF NOT EXISTS idx_ranking_download_tasks_code ON ranking_download_tasks(code);
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	"nsfw-go/internal/database"
 	"nsfw-go/internal/model"
 	
 	"gorm.io/driver/postgres"
@@ -26,6 +27,11 @@ func Migrate(dsn string) error {
 		log.Printf("创建UUID扩展失败（可能已存在）: %v", err)
 	}
 
+	// 删除已改为普通索引的旧唯一索引，AutoMigrate 不会修改同名索引
+	if err := database.DropRetiredIndexes(db); err != nil {
+		return err
+	}
+
 	// 自动迁移所有模型
 	models := model.GetAllModels()
 	
@@ -362,7 +362,7 @@
                         </div>
                     </div>
                     <div class="flex gap-2">
-                        ${torrent.state === 'pausedDL' ? `
+                        ${torrent.state === 'paused' ? `
                             <button class="resume-btn px-2 py-1 bg-green-500 text-white rounded hover:bg-green-600" data-hash="${torrent.hash}">
                                 <i class="fas fa-play"></i>
                             </button>
@@ -393,9 +393,9 @@
     function getStatusInfo(state) {
         const states = {
             'downloading': { text: '下载中', class: 'text-blue-400' },
-            'pausedDL': { text: '已暂停', class: 'text-yellow-400' },
-            'queuedDL': { text: '排队中', class: 'text-gray-400' },
-            'stalledDL': { text: '等待中', class: 'text-orange-400' },
+            'paused': { text: '已暂停', class: 'text-yellow-400' },
+            'queued': { text: '排队中', class: 'text-gray-400' },
+            'checking': { text: '校验中', class: 'text-orange-400' },
             'completed': { text: '已完成', class: 'text-green-400' },
             'seeding': { text: '做种中', class: 'text-green-400' },
             'error': { text: '错误', class: 'text-red-400' }